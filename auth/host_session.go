@@ -223,7 +223,7 @@ func SessionHandler(conn *websocket.Conn) {
 		if err = conn.WriteMessage(messageType, reponseData); err != nil {
 			logger.Log(0, "error during message writing:", err.Error())
 		}
-		go CheckNetRegAndHostUpdate(netsToAdd[:], &result.Host, uuid.Nil, []models.TagID{})
+		go CheckNetRegAndHostUpdate(netsToAdd[:], &result.Host, uuid.Nil, []models.TagID{}, nil)
 	case <-timeout: // the read from req.answerCh has timed out
 		logger.Log(0, "timeout signal recv,exiting oauth socket conn")
 		break
@@ -236,8 +236,9 @@ func SessionHandler(conn *websocket.Conn) {
 	}
 }
 
-// CheckNetRegAndHostUpdate - run through networks and send a host update
-func CheckNetRegAndHostUpdate(networks []string, h *models.Host, relayNodeId uuid.UUID, tags []models.TagID) {
+// CheckNetRegAndHostUpdate - run through networks and send a host update,
+// applying the node settings of the enrollment key's provisioning profile to the new nodes if any
+func CheckNetRegAndHostUpdate(networks []string, h *models.Host, relayNodeId uuid.UUID, tags []models.TagID, profile *models.HostProvisioningProfile) {
 	// publish host update through MQ
 	for i := range networks {
 		network := networks[i]
@@ -279,6 +280,7 @@ func CheckNetRegAndHostUpdate(networks []string, h *models.Host, relayNodeId uui
 				continue
 			}
 			logger.Log(1, "added new node", newNode.ID.String(), "to host", h.Name)
+			logic.ApplyNodeProvisioningProfile(newNode, profile)
 			hostactions.AddAction(models.HostUpdate{
 				Action: models.JoinHostToNetwork,
 				Host:   *h,
@@ -0,0 +1,33 @@
+package dns
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/gravitl/netmaker/cli/functions"
+	"github.com/spf13/cobra"
+)
+
+var dnsExportCmd = &cobra.Command{
+	Use:   "export [NETWORK NAME]",
+	Args:  cobra.ExactArgs(1),
+	Short: "Export the DNS entries of a network as a zone file",
+	Long:  `Export the node, ext client and custom DNS entries of a network as an RFC 1035 zone file`,
+	Run: func(cmd *cobra.Command, args []string) {
+		zone := functions.ExportDNSZone(args[0], origin)
+		if zoneFile == "" {
+			fmt.Print(zone)
+			return
+		}
+		if err := os.WriteFile(zoneFile, []byte(zone), 0644); err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+func init() {
+	dnsExportCmd.Flags().StringVar(&origin, "origin", "", "Origin of the zone, defaults to the server's default domain")
+	dnsExportCmd.Flags().StringVar(&zoneFile, "file", "", "Path to write the zone file to instead of stdout")
+	rootCmd.AddCommand(dnsExportCmd)
+}
@@ -6,4 +6,7 @@ var (
 	address6    string
 	networkName string
 	dnsType     string
+	origin      string
+	zoneFile    string
+	dryRun      bool
 )
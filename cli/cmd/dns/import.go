@@ -0,0 +1,59 @@
+package dns
+
+import (
+	"log"
+	"os"
+
+	"github.com/gravitl/netmaker/cli/cmd/commons"
+	"github.com/gravitl/netmaker/cli/functions"
+	"github.com/gravitl/netmaker/models"
+	"github.com/guumaster/tablewriter"
+	"github.com/spf13/cobra"
+)
+
+var dnsImportCmd = &cobra.Command{
+	Use:   "import [NETWORK NAME]",
+	Args:  cobra.ExactArgs(1),
+	Short: "Import a zone file into the custom DNS entries of a network",
+	Long:  `Import an RFC 1035 zone file into the custom DNS entries of a network, names that already exist are reported as conflicts`,
+	Run: func(cmd *cobra.Command, args []string) {
+		content, err := os.ReadFile(zoneFile)
+		if err != nil {
+			log.Fatal("Error when opening file: ", err)
+		}
+		result := functions.ImportDNSZone(args[0], &models.DNSZoneImportRequest{
+			Zone:   string(content),
+			Origin: origin,
+			DryRun: dryRun,
+		})
+		switch commons.OutputFormat {
+		case commons.JsonOutput:
+			functions.PrettyPrint(result)
+		default:
+			status := "created"
+			if result.DryRun {
+				status = "would create"
+			}
+			table := tablewriter.NewWriter(os.Stdout)
+			table.SetHeader([]string{"Name", "Type", "Status"})
+			for _, entry := range result.Created {
+				table.Append([]string{entry.Name, string(entry.RecordType()), status})
+			}
+			for _, conflict := range result.Conflicts {
+				table.Append([]string{conflict.Entry.Name, string(conflict.Entry.RecordType()), "conflict: " + conflict.Reason})
+			}
+			for _, skipped := range result.Skipped {
+				table.Append([]string{skipped.Record, "", "skipped: " + skipped.Reason})
+			}
+			table.Render()
+		}
+	},
+}
+
+func init() {
+	dnsImportCmd.Flags().StringVar(&zoneFile, "file", "", "Path to the zone file")
+	dnsImportCmd.MarkFlagRequired("file")
+	dnsImportCmd.Flags().StringVar(&origin, "origin", "", "Origin of relative names, defaults to the server's default domain")
+	dnsImportCmd.Flags().BoolVar(&dryRun, "dry_run", false, "Only report what would be imported")
+	rootCmd.AddCommand(dnsImportCmd)
+}
@@ -14,6 +14,9 @@ var (
 	networks      string
 	unlimited     bool
 	tags          string
+	sourceCIDRs   string
+	hostPattern   string
+	allowedOS     string
 )
 
 var enrollmentKeyCreateCmd = &cobra.Command{
@@ -33,6 +36,13 @@ var enrollmentKeyCreateCmd = &cobra.Command{
 		if tags != "" {
 			enrollKey.Tags = strings.Split(tags, ",")
 		}
+		if sourceCIDRs != "" {
+			enrollKey.Constraints.SourceCIDRs = strings.Split(sourceCIDRs, ",")
+		}
+		if allowedOS != "" {
+			enrollKey.Constraints.AllowedOS = strings.Split(allowedOS, ",")
+		}
+		enrollKey.Constraints.HostNamePattern = hostPattern
 		functions.PrettyPrint(functions.CreateEnrollmentKey(enrollKey))
 	},
 }
@@ -43,5 +53,8 @@ func init() {
 	enrollmentKeyCreateCmd.Flags().StringVar(&networks, "networks", "", "Comma-separated list of networks which the enrollment key can access")
 	enrollmentKeyCreateCmd.Flags().BoolVar(&unlimited, "unlimited", false, "Should the key have unlimited uses ?")
 	enrollmentKeyCreateCmd.Flags().StringVar(&tags, "tags", "", "Comma-separated list of any additional tags")
+	enrollmentKeyCreateCmd.Flags().StringVar(&sourceCIDRs, "source_cidrs", "", "Comma-separated list of CIDRs a registering host's public IP must belong to")
+	enrollmentKeyCreateCmd.Flags().StringVar(&hostPattern, "hostname_pattern", "", "Regular expression a registering host's name must match")
+	enrollmentKeyCreateCmd.Flags().StringVar(&allowedOS, "allowed_os", "", "Comma-separated list of operating systems allowed to register")
 	rootCmd.AddCommand(enrollmentKeyCreateCmd)
 }
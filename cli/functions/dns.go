@@ -3,6 +3,7 @@ package functions
 import (
 	"fmt"
 	"net/http"
+	"net/url"
 
 	"github.com/gravitl/netmaker/models"
 )
@@ -41,3 +42,17 @@ func PushDNS() *string {
 func DeleteDNS(networkName, domainName string) *string {
 	return request[string](http.MethodDelete, fmt.Sprintf("/api/dns/%s/%s", networkName, domainName), nil)
 }
+
+// ExportDNSZone - fetch the DNS entries of a network as a zone file
+func ExportDNSZone(networkName, origin string) string {
+	route := fmt.Sprintf("/api/dns/adm/%s/zone", networkName)
+	if origin != "" {
+		route += "?origin=" + url.QueryEscape(origin)
+	}
+	return get(route)
+}
+
+// ImportDNSZone - import a zone file into the custom DNS entries of a network
+func ImportDNSZone(networkName string, payload *models.DNSZoneImportRequest) *models.DNSZoneImport {
+	return request[models.DNSZoneImport](http.MethodPost, fmt.Sprintf("/api/dns/adm/%s/zone", networkName), payload)
+}
@@ -106,6 +106,16 @@ type ServerConfig struct {
 	StunServers                string        `yaml:"stun_servers"`
 	DefaultDomain              string        `yaml:"default_domain"`
 	PublicIp                   string        `yaml:"public_ip"`
+	AttestationCertsDir        string        `yaml:"attestation_certs_dir"`
+	EphemeralHostTimeout       int           `yaml:"ephemeral_host_timeout"`
+	PresharedKeyRotation       int           `yaml:"preshared_key_rotation"`
+	GeoIPDBPath                string        `yaml:"geoip_db_path"`
+	GeoIPBlockedCountries      string        `yaml:"geoip_blocked_countries"`
+	TrustedProxies             string        `yaml:"trusted_proxies"`
+	EmbeddedDNS                bool          `yaml:"embedded_dns"`
+	EmbeddedDNSAddr            string        `yaml:"embedded_dns_addr"`
+	DNSUpstreams               string        `yaml:"dns_upstreams"`
+	DNSForwardRules            string        `yaml:"dns_forward_rules"`
 }
 
 // SQLConfig - Generic SQL Config
@@ -31,6 +31,7 @@ var HttpHandlers = []interface{}{
 	userHandlers,
 	networkHandlers,
 	dnsHandlers,
+	dnsFilterHandlers,
 	fileHandlers,
 	serverHandlers,
 	extClientHandlers,
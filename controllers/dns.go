@@ -9,11 +9,14 @@ import (
 
 	"github.com/gorilla/mux"
 	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/db"
 	"github.com/gravitl/netmaker/logger"
 	"github.com/gravitl/netmaker/logic"
 	"github.com/gravitl/netmaker/models"
 	"github.com/gravitl/netmaker/mq"
+	"github.com/gravitl/netmaker/schema"
 	"github.com/gravitl/netmaker/servercfg"
+	"github.com/miekg/dns"
 )
 
 func dnsHandlers(r *mux.Router) {
@@ -24,6 +27,20 @@ func dnsHandlers(r *mux.Router) {
 		Methods(http.MethodGet)
 	r.HandleFunc("/api/dns/adm/{network}/custom", logic.SecurityCheck(true, http.HandlerFunc(getCustomDNS))).
 		Methods(http.MethodGet)
+	r.HandleFunc("/api/dns/adm/{network}/reverse", logic.SecurityCheck(true, http.HandlerFunc(getReverseDNS))).
+		Methods(http.MethodGet)
+	r.HandleFunc("/api/dns/adm/{network}/zone", logic.SecurityCheck(true, http.HandlerFunc(exportDNSZone))).
+		Methods(http.MethodGet)
+	r.HandleFunc("/api/dns/adm/{network}/zone", logic.SecurityCheck(true, http.HandlerFunc(importDNSZone))).
+		Methods(http.MethodPost)
+	r.HandleFunc("/api/dns/adm/{network}/dynamic", logic.SecurityCheck(true, http.HandlerFunc(getDNSSyncConfig))).
+		Methods(http.MethodGet)
+	r.HandleFunc("/api/dns/adm/{network}/dynamic", logic.SecurityCheck(true, http.HandlerFunc(updateDNSSyncConfig))).
+		Methods(http.MethodPut)
+	r.HandleFunc("/api/dns/adm/{network}/dynamic", logic.SecurityCheck(true, http.HandlerFunc(deleteDNSSyncConfig))).
+		Methods(http.MethodDelete)
+	r.HandleFunc("/api/dns/adm/{network}/dynamic/sync", logic.SecurityCheck(true, http.HandlerFunc(syncDNSSyncConfig))).
+		Methods(http.MethodPost)
 	r.HandleFunc("/api/dns/adm/{network}", logic.SecurityCheck(true, http.HandlerFunc(getDNS))).
 		Methods(http.MethodGet)
 	r.HandleFunc("/api/dns/adm/{network}/sync", logic.SecurityCheck(true, http.HandlerFunc(syncDNS))).
@@ -112,6 +129,90 @@ func getCustomDNS(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(dns)
 }
 
+// @Summary     Gets the PTR records generated for a network's address ranges
+// @Router      /api/dns/adm/{network}/reverse [get]
+// @Tags        DNS
+// @Accept      json
+// @Param       network path string true "Network identifier"
+// @Success     200 {array} models.DNSEntry
+// @Failure     500 {object} models.ErrorResponse
+func getReverseDNS(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	network := mux.Vars(r)["network"]
+	ptrs, err := logic.GetReverseDNS(network)
+	if err != nil {
+		logger.Log(0, r.Header.Get("user"),
+			fmt.Sprintf("failed to get reverse DNS entries for network [%s]: %v", network, err))
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(ptrs)
+}
+
+// @Summary     Export the DNS entries of a network as an RFC 1035 zone file
+// @Router      /api/dns/adm/{network}/zone [get]
+// @Tags        DNS
+// @Produce     plain
+// @Param       network path string true "Network identifier"
+// @Param       origin query string false "Origin of the zone, defaults to the default domain"
+// @Success     200 {string} string "zone file"
+// @Failure     400 {object} models.ErrorResponse
+func exportDNSZone(w http.ResponseWriter, r *http.Request) {
+	network := mux.Vars(r)["network"]
+	zone, err := logic.ExportDNSZone(network, r.URL.Query().Get("origin"))
+	if err != nil {
+		logger.Log(0, r.Header.Get("user"),
+			fmt.Sprintf("failed to export DNS zone of network [%s]: %v", network, err))
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s.zone", network))
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(zone))
+}
+
+// @Summary     Import an RFC 1035 zone file into the custom DNS entries of a network
+// @Router      /api/dns/adm/{network}/zone [post]
+// @Tags        DNS
+// @Accept      json
+// @Param       network path string true "Network identifier"
+// @Param       body body models.DNSZoneImportRequest true "Zone file"
+// @Success     200 {object} models.DNSZoneImport
+// @Failure     400 {object} models.ErrorResponse
+// @Failure     500 {object} models.ErrorResponse
+func importDNSZone(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	network := mux.Vars(r)["network"]
+	var req models.DNSZoneImportRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+		return
+	}
+	result, err := logic.ImportDNSZone(network, req)
+	if err != nil {
+		logger.Log(0, r.Header.Get("user"),
+			fmt.Sprintf("failed to import DNS zone into network [%s]: %v", network, err))
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+		return
+	}
+	if !req.DryRun && len(result.Created) > 0 {
+		if servercfg.IsDNSMode() {
+			if err := logic.SetDNS(); err != nil {
+				logger.Log(0, r.Header.Get("user"),
+					fmt.Sprintf("Failed to set DNS entries on file: %v", err))
+			}
+		}
+		if logic.GetManageDNS() {
+			mq.SendDNSSyncByNetwork(network)
+		}
+		logger.Log(1, r.Header.Get("user"), "imported", fmt.Sprint(len(result.Created)), "DNS entries into network", network)
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(result)
+}
+
 // @Summary     Get all DNS entries associated with the network
 // @Router      /api/dns/adm/{network} [get]
 // @Tags        DNS
@@ -202,6 +303,7 @@ func createDNS(w http.ResponseWriter, r *http.Request) {
 // @Accept      json
 // @Param       network path string true "Network identifier"
 // @Param       domain path string true "Domain Name"
+// @Param       type query string false "Record type to delete, all records of the name when empty"
 // @Success     200 {array} models.DNSEntry
 // @Failure     500 {object} models.ErrorResponse
 func deleteDNS(w http.ResponseWriter, r *http.Request) {
@@ -212,7 +314,8 @@ func deleteDNS(w http.ResponseWriter, r *http.Request) {
 	var params = mux.Vars(r)
 	netID := params["network"]
 	entrytext := params["domain"] + "." + params["network"]
-	err := logic.DeleteDNS(params["domain"], params["network"])
+	recordType := models.DNSRecordType(strings.ToUpper(r.URL.Query().Get("type")))
+	err := logic.DeleteDNSRecord(params["domain"], params["network"], recordType)
 
 	if err != nil {
 		logger.Log(0, "failed to delete dns entry: ", entrytext)
@@ -317,3 +420,119 @@ func syncDNS(w http.ResponseWriter, r *http.Request) {
 	logger.Log(1, r.Header.Get("user"), "DNS Sync complelted successfully")
 	json.NewEncoder(w).Encode("DNS Sync completed successfully")
 }
+
+// @Summary     Get the external DNS server a network's records are pushed to
+// @Router      /api/dns/adm/{network}/dynamic [get]
+// @Tags        DNS
+// @Param       network path string true "Network identifier"
+// @Success     200 {object} schema.DNSSyncConfig
+// @Failure     404 {object} models.ErrorResponse
+func getDNSSyncConfig(w http.ResponseWriter, r *http.Request) {
+	cfg := &schema.DNSSyncConfig{Network: mux.Vars(r)["network"]}
+	if err := cfg.Get(db.WithContext(r.Context())); err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "notfound"))
+		return
+	}
+	logic.ReturnSuccessResponseWithJson(w, r, maskDNSSyncConfig(*cfg), "fetched dynamic dns config")
+}
+
+// @Summary     Push a network's records to an external DNS server with RFC 2136 dynamic updates
+// @Router      /api/dns/adm/{network}/dynamic [put]
+// @Tags        DNS
+// @Accept      json
+// @Param       network path string true "Network identifier"
+// @Param       body body schema.DNSSyncConfig true "External DNS server, zone and TSIG key"
+// @Success     200 {object} schema.DNSSyncConfig
+// @Failure     400 {object} models.ErrorResponse
+func updateDNSSyncConfig(w http.ResponseWriter, r *http.Request) {
+	network := mux.Vars(r)["network"]
+	if _, err := logic.GetNetwork(network); err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+		return
+	}
+	var req schema.DNSSyncConfig
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+		return
+	}
+	ctx := db.WithContext(r.Context())
+	cfg := &schema.DNSSyncConfig{Network: network}
+	exists := cfg.Get(ctx) == nil
+	if req.TSIGSecret == logic.Mask() {
+		req.TSIGSecret = cfg.TSIGSecret
+	}
+	owned := cfg.OwnedRecords
+	if exists && (req.Server != cfg.Server || !strings.EqualFold(dns.Fqdn(req.Zone), cfg.Zone)) {
+		// records on the old server or in the old zone are cleaned up first
+		if err := logic.RemoveNetworkDNSSync(network); err != nil {
+			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+			return
+		}
+		owned = nil
+	}
+	cfg = &schema.DNSSyncConfig{
+		Network:       network,
+		Server:        req.Server,
+		Zone:          req.Zone,
+		TSIGKeyName:   req.TSIGKeyName,
+		TSIGAlgorithm: req.TSIGAlgorithm,
+		TSIGSecret:    req.TSIGSecret,
+		OwnedRecords:  owned,
+	}
+	if err := logic.ValidateDNSSyncConfig(cfg); err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+		return
+	}
+	if err := cfg.Upsert(ctx); err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return
+	}
+	// a failed first push is reported in last_error and retried by the periodic sync
+	_ = logic.SyncNetworkDNS(cfg, true)
+	logger.Log(1, r.Header.Get("user"), "set dynamic dns server", cfg.Server, "for network", network)
+	logic.ReturnSuccessResponseWithJson(w, r, maskDNSSyncConfig(*cfg), "updated dynamic dns config")
+}
+
+// @Summary     Stop pushing a network's records, the records pushed before are removed from the server
+// @Router      /api/dns/adm/{network}/dynamic [delete]
+// @Tags        DNS
+// @Param       network path string true "Network identifier"
+// @Success     200 {object} models.SuccessResponse
+// @Failure     404 {object} models.ErrorResponse
+func deleteDNSSyncConfig(w http.ResponseWriter, r *http.Request) {
+	network := mux.Vars(r)["network"]
+	if err := logic.RemoveNetworkDNSSync(network); err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "notfound"))
+		return
+	}
+	logger.Log(1, r.Header.Get("user"), "removed dynamic dns server of network", network)
+	logic.ReturnSuccessResponse(w, r, "removed dynamic dns config")
+}
+
+// @Summary     Reconcile a network's records with its external DNS server now
+// @Router      /api/dns/adm/{network}/dynamic/sync [post]
+// @Tags        DNS
+// @Param       network path string true "Network identifier"
+// @Success     200 {object} schema.DNSSyncConfig
+// @Failure     404 {object} models.ErrorResponse
+// @Failure     500 {object} models.ErrorResponse
+func syncDNSSyncConfig(w http.ResponseWriter, r *http.Request) {
+	cfg := &schema.DNSSyncConfig{Network: mux.Vars(r)["network"]}
+	if err := cfg.Get(db.WithContext(r.Context())); err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "notfound"))
+		return
+	}
+	if err := logic.SyncNetworkDNS(cfg, true); err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return
+	}
+	logic.ReturnSuccessResponseWithJson(w, r, maskDNSSyncConfig(*cfg), "synced dynamic dns records")
+}
+
+// maskDNSSyncConfig - hides the TSIG secret of a config returned by the api
+func maskDNSSyncConfig(cfg schema.DNSSyncConfig) schema.DNSSyncConfig {
+	if cfg.TSIGSecret != "" {
+		cfg.TSIGSecret = logic.Mask()
+	}
+	return cfg
+}
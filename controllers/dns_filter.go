@@ -0,0 +1,200 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/gravitl/netmaker/db"
+	"github.com/gravitl/netmaker/logger"
+	"github.com/gravitl/netmaker/logic"
+	"github.com/gravitl/netmaker/models"
+	"github.com/gravitl/netmaker/schema"
+)
+
+func dnsFilterHandlers(r *mux.Router) {
+	r.HandleFunc("/api/v1/dns/filters", logic.SecurityCheck(true, http.HandlerFunc(listDNSFilterPolicies))).Methods(http.MethodGet)
+	r.HandleFunc("/api/v1/dns/filters", logic.SecurityCheck(true, http.HandlerFunc(createDNSFilterPolicy))).Methods(http.MethodPost)
+	r.HandleFunc("/api/v1/dns/filters", logic.SecurityCheck(true, http.HandlerFunc(updateDNSFilterPolicy))).Methods(http.MethodPut)
+	r.HandleFunc("/api/v1/dns/filters", logic.SecurityCheck(true, http.HandlerFunc(deleteDNSFilterPolicy))).Methods(http.MethodDelete)
+	r.HandleFunc("/api/v1/dns/filters/stats", logic.SecurityCheck(true, http.HandlerFunc(getDNSFilterStats))).Methods(http.MethodGet)
+}
+
+// @Summary     List DNS filter policies
+// @Router      /api/v1/dns/filters [get]
+// @Tags        DNS
+// @Success     200 {array} schema.DNSFilterPolicy
+// @Failure     500 {object} models.ErrorResponse
+func listDNSFilterPolicies(w http.ResponseWriter, r *http.Request) {
+	policies, err := (&schema.DNSFilterPolicy{}).ListAll(db.WithContext(r.Context()))
+	if err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return
+	}
+	logic.ReturnSuccessResponseWithJson(w, r, policies, "fetched dns filter policies")
+}
+
+// @Summary     Create a DNS filter policy, enforced by the embedded DNS server
+// @Router      /api/v1/dns/filters [post]
+// @Tags        DNS
+// @Accept      json
+// @Param       body body schema.DNSFilterPolicy true "Blocklists, allowlist and the networks and user groups it applies to"
+// @Success     200 {object} schema.DNSFilterPolicy
+// @Failure     400 {object} models.ErrorResponse
+// @Failure     500 {object} models.ErrorResponse
+func createDNSFilterPolicy(w http.ResponseWriter, r *http.Request) {
+	var policy schema.DNSFilterPolicy
+	if err := json.NewDecoder(r.Body).Decode(&policy); err != nil {
+		logger.Log(0, "error decoding request body: ", err.Error())
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+		return
+	}
+	policy.ID = uuid.New().String()
+	policy.CreatedBy = r.Header.Get("user")
+	policy.CreatedAt = time.Now().UTC()
+	policy.UpdatedAt = policy.CreatedAt
+	if err := logic.ValidateDNSFilterPolicy(&policy); err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+		return
+	}
+	if err := policy.Create(db.WithContext(r.Context())); err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return
+	}
+	logic.LogEvent(&models.Event{
+		Action: models.Create,
+		Source: models.Subject{
+			ID:   r.Header.Get("user"),
+			Name: r.Header.Get("user"),
+			Type: models.UserSub,
+		},
+		TriggeredBy: r.Header.Get("user"),
+		Target: models.Subject{
+			ID:   policy.ID,
+			Name: policy.Name,
+			Type: models.DNSFilterSub,
+		},
+		Origin: models.Dashboard,
+	})
+	go logic.LoadDNSFilters()
+	logic.ReturnSuccessResponseWithJson(w, r, policy, "created dns filter policy")
+}
+
+// @Summary     Update a DNS filter policy
+// @Router      /api/v1/dns/filters [put]
+// @Tags        DNS
+// @Accept      json
+// @Param       body body schema.DNSFilterPolicy true "The policy with its id"
+// @Success     200 {object} schema.DNSFilterPolicy
+// @Failure     400 {object} models.ErrorResponse
+// @Failure     500 {object} models.ErrorResponse
+func updateDNSFilterPolicy(w http.ResponseWriter, r *http.Request) {
+	var req schema.DNSFilterPolicy
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		logger.Log(0, "error decoding request body: ", err.Error())
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+		return
+	}
+	policy := schema.DNSFilterPolicy{ID: req.ID}
+	if err := policy.Get(db.WithContext(r.Context())); err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+		return
+	}
+	old := policy
+	policy.Name = req.Name
+	policy.Blocklists = req.Blocklists
+	policy.Allowlist = req.Allowlist
+	policy.Networks = req.Networks
+	policy.UserGroups = req.UserGroups
+	policy.Enabled = req.Enabled
+	policy.UpdatedAt = time.Now().UTC()
+	if err := logic.ValidateDNSFilterPolicy(&policy); err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+		return
+	}
+	if err := policy.Update(db.WithContext(r.Context())); err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return
+	}
+	logic.LogEvent(&models.Event{
+		Action: models.Update,
+		Source: models.Subject{
+			ID:   r.Header.Get("user"),
+			Name: r.Header.Get("user"),
+			Type: models.UserSub,
+		},
+		TriggeredBy: r.Header.Get("user"),
+		Target: models.Subject{
+			ID:   policy.ID,
+			Name: policy.Name,
+			Type: models.DNSFilterSub,
+		},
+		Diff: models.Diff{
+			Old: old,
+			New: policy,
+		},
+		Origin: models.Dashboard,
+	})
+	go logic.LoadDNSFilters()
+	logic.ReturnSuccessResponseWithJson(w, r, policy, "updated dns filter policy")
+}
+
+// @Summary     Delete a DNS filter policy
+// @Router      /api/v1/dns/filters [delete]
+// @Tags        DNS
+// @Param       id query string true "Policy id"
+// @Success     200 {object} models.SuccessResponse
+// @Failure     400 {object} models.ErrorResponse
+// @Failure     500 {object} models.ErrorResponse
+func deleteDNSFilterPolicy(w http.ResponseWriter, r *http.Request) {
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(errors.New("id is required"), "badrequest"))
+		return
+	}
+	policy := schema.DNSFilterPolicy{ID: id}
+	if err := policy.Get(db.WithContext(r.Context())); err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+		return
+	}
+	if err := policy.Delete(db.WithContext(r.Context())); err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return
+	}
+	logic.LogEvent(&models.Event{
+		Action: models.Delete,
+		Source: models.Subject{
+			ID:   r.Header.Get("user"),
+			Name: r.Header.Get("user"),
+			Type: models.UserSub,
+		},
+		TriggeredBy: r.Header.Get("user"),
+		Target: models.Subject{
+			ID:   policy.ID,
+			Name: policy.Name,
+			Type: models.DNSFilterSub,
+		},
+		Origin: models.Dashboard,
+	})
+	go logic.LoadDNSFilters()
+	logic.ReturnSuccessResponse(w, r, "deleted dns filter policy "+policy.Name)
+}
+
+// @Summary     Get the DNS queries and blocks of each client the filter policies were checked for
+// @Router      /api/v1/dns/filters/stats [get]
+// @Tags        DNS
+// @Param       network query string false "Only clients of this network"
+// @Success     200 {array} models.DNSFilterStats
+func getDNSFilterStats(w http.ResponseWriter, r *http.Request) {
+	network := r.URL.Query().Get("network")
+	stats := []models.DNSFilterStats{}
+	for _, s := range logic.GetDNSFilterStats() {
+		if network == "" || s.Network == network {
+			stats = append(stats, s)
+		}
+	}
+	logic.ReturnSuccessResponseWithJson(w, r, stats, "fetched dns filter stats")
+}
@@ -420,6 +420,39 @@ func TestValidateDNSCreate(t *testing.T) {
 		assert.NotNil(t, err)
 		assert.Contains(t, err.Error(), "invalid input")
 	})
+	t.Run("RecordTypes", func(t *testing.T) {
+		t.Setenv("EMBEDDED_DNS", "true")
+		valid := []models.DNSEntry{
+			{Name: "alias", Network: "skynet", Type: models.DNSRecordCNAME, Target: "myhost"},
+			{Name: "_http._tcp", Network: "skynet", Type: models.DNSRecordSRV, Target: "myhost", Port: 80},
+			{Name: "txt", Network: "skynet", Type: models.DNSRecordTXT, Text: []string{"hello"}},
+			{Name: "mx", Network: "skynet", Type: models.DNSRecordMX, Target: "myhost", Priority: 10},
+			{Name: "*.apps", Network: "skynet", Address: "10.10.10.6"},
+		}
+		for _, entry := range valid {
+			assert.Nil(t, logic.ValidateDNSCreate(entry), entry.Name)
+		}
+		invalid := []models.DNSEntry{
+			{Name: "noaddress", Network: "skynet"},
+			{Name: "alias", Network: "skynet", Type: models.DNSRecordCNAME},
+			{Name: "alias", Network: "skynet", Type: models.DNSRecordCNAME, Target: "alias"},
+			{Name: "alias", Network: "skynet", Type: models.DNSRecordCNAME, Target: "myhost", Address: "10.10.10.7"},
+			{Name: "_http._tcp", Network: "skynet", Type: models.DNSRecordSRV, Target: "myhost"},
+			{Name: "txt", Network: "skynet", Type: models.DNSRecordTXT},
+			{Name: "mx", Network: "skynet", Type: models.DNSRecordMX, Target: "*.apps"},
+			{Name: "apps.*", Network: "skynet", Address: "10.10.10.6"},
+			{Name: "ns", Network: "skynet", Type: "NS", Target: "myhost"},
+		}
+		for _, entry := range invalid {
+			assert.NotNil(t, logic.ValidateDNSCreate(entry), entry.Name)
+		}
+		// hosts files only hold addresses and aliases
+		t.Setenv("EMBEDDED_DNS", "false")
+		assert.Nil(t, logic.ValidateDNSCreate(valid[0]))
+		for _, entry := range valid[1:] {
+			assert.NotNil(t, logic.ValidateDNSCreate(entry), entry.Name)
+		}
+	})
 
 }
 
@@ -2,7 +2,9 @@ package controller
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net"
 	"net/http"
 	"time"
 
@@ -28,8 +30,14 @@ func enrollmentKeyHandlers(r *mux.Router) {
 		Methods(http.MethodDelete)
 	r.HandleFunc("/api/v1/host/register/{token}", http.HandlerFunc(handleHostRegister)).
 		Methods(http.MethodPost)
+	r.HandleFunc("/api/v1/host/attest/register", http.HandlerFunc(handleAttestedHostRegister)).
+		Methods(http.MethodPost)
 	r.HandleFunc("/api/v1/enrollment-keys/{keyID}", logic.SecurityCheck(true, http.HandlerFunc(updateEnrollmentKey))).
 		Methods(http.MethodPut)
+	r.HandleFunc("/api/v1/enrollment-keys/{keyID}/usage", logic.SecurityCheck(true, http.HandlerFunc(getEnrollmentKeyUsage))).
+		Methods(http.MethodGet)
+	r.HandleFunc("/api/v1/enrollment-keys/{keyID}/revoke", logic.SecurityCheck(true, http.HandlerFunc(revokeEnrollmentKeyHosts))).
+		Methods(http.MethodPost)
 }
 
 // @Summary     Lists all EnrollmentKeys for admins
@@ -103,6 +111,7 @@ func deleteEnrollmentKey(w http.ResponseWriter, r *http.Request) {
 }
 
 // @Summary     Creates an EnrollmentKey for hosts to register with server and join networks
+// @Description constraints.source_cidrs are matched against the public ip of the registering host, behind a reverse proxy set TRUSTED_PROXIES to the proxy so its forwarding headers are used
 // @Router      /api/v1/enrollment-keys [post]
 // @Tags        EnrollmentKeys
 // @Security    oauth
@@ -181,6 +190,9 @@ func createEnrollmentKey(w http.ResponseWriter, r *http.Request) {
 		relayId,
 		false,
 		enrollmentKeyBody.AutoEgress,
+		enrollmentKeyBody.Constraints,
+		enrollmentKeyBody.Profile,
+		enrollmentKeyBody.Attestation,
 	)
 	if err != nil {
 		logger.Log(0, r.Header.Get("user"), "failed to create enrollment key:", err.Error())
@@ -213,7 +225,8 @@ func createEnrollmentKey(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(newEnrollmentKey)
 }
 
-// @Summary     Updates an EnrollmentKey. Updates are only limited to the relay to use
+// @Summary     Updates an EnrollmentKey. Updates are only limited to the relay, groups, constraints, profile and attestation
+// @Description constraints.source_cidrs are matched against the public ip of the registering host, behind a reverse proxy set TRUSTED_PROXIES to the proxy so its forwarding headers are used
 // @Router      /api/v1/enrollment-keys/{keyid} [put]
 // @Tags        EnrollmentKeys
 // @Security    oauth
@@ -245,7 +258,8 @@ func updateEnrollmentKey(w http.ResponseWriter, r *http.Request) {
 	}
 	currKey, _ := logic.GetEnrollmentKey(keyId)
 
-	newEnrollmentKey, err := logic.UpdateEnrollmentKey(keyId, relayId, enrollmentKeyBody.Groups)
+	newEnrollmentKey, err := logic.UpdateEnrollmentKey(keyId, relayId, enrollmentKeyBody.Groups, enrollmentKeyBody.Constraints,
+		enrollmentKeyBody.Profile, enrollmentKeyBody.Attestation)
 	if err != nil {
 		slog.Error("failed to update enrollment key", "error", err)
 		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
@@ -281,6 +295,89 @@ func updateEnrollmentKey(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(newEnrollmentKey)
 }
 
+// @Summary     Lists the hosts that registered using an EnrollmentKey
+// @Router      /api/v1/enrollment-keys/{keyid}/usage [get]
+// @Tags        EnrollmentKeys
+// @Security    oauth
+// @Param       keyid path string true "Enrollment Key ID"
+// @Success     200 {array} schema.EnrollmentKeyUsage
+// @Failure     500 {object} models.ErrorResponse
+func getEnrollmentKeyUsage(w http.ResponseWriter, r *http.Request) {
+	keyID := mux.Vars(r)["keyID"]
+	usages, err := logic.GetEnrollmentKeyUsage(keyID)
+	if err != nil {
+		slog.Error("failed to fetch enrollment key usage", "id", keyID, "error", err)
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(usages)
+}
+
+// @Summary     Removes every host that registered using an EnrollmentKey from the networks it joined with the key
+// @Router      /api/v1/enrollment-keys/{keyid}/revoke [post]
+// @Tags        EnrollmentKeys
+// @Security    oauth
+// @Param       keyid path string true "Enrollment Key ID"
+// @Success     200 {array} models.RevokedHostNetwork
+// @Failure     500 {object} models.ErrorResponse
+func revokeEnrollmentKeyHosts(w http.ResponseWriter, r *http.Request) {
+	keyID := mux.Vars(r)["keyID"]
+	usages, err := logic.GetEnrollmentKeyUsage(keyID)
+	if err != nil {
+		slog.Error("failed to fetch enrollment key usage", "id", keyID, "error", err)
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return
+	}
+	revoked := []models.RevokedHostNetwork{}
+	processed := make(map[string]struct{})
+	for _, usage := range usages {
+		host, err := logic.GetHost(usage.HostID)
+		if err != nil {
+			continue
+		}
+		for _, network := range usage.Networks {
+			if _, ok := processed[usage.HostID+network]; ok {
+				continue
+			}
+			processed[usage.HostID+network] = struct{}{}
+			if node, err := logic.RemoveHostFromNetwork(host, network, false); err != nil {
+				if node != nil {
+					slog.Error("failed to delete node", "nodeid", node.ID.String(), "error", err)
+				}
+				continue
+			}
+			logic.LogEvent(&models.Event{
+				Action: models.RemoveHostFromNet,
+				Source: models.Subject{
+					ID:   r.Header.Get("user"),
+					Name: r.Header.Get("user"),
+					Type: models.UserSub,
+				},
+				TriggeredBy: r.Header.Get("user"),
+				Target: models.Subject{
+					ID:   host.ID.String(),
+					Name: host.Name,
+					Type: models.DeviceSub,
+				},
+				NetworkID: models.NetworkID(network),
+				Origin:    models.Dashboard,
+			})
+			revoked = append(revoked, models.RevokedHostNetwork{
+				HostID:   host.ID.String(),
+				HostName: host.Name,
+				Network:  network,
+			})
+		}
+	}
+	if len(revoked) > 0 && servercfg.IsDNSMode() {
+		go logic.SetDNS()
+	}
+	slog.Info("revoked hosts enrolled with key", "id", keyID, "count", len(revoked), "user", r.Header.Get("user"))
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(revoked)
+}
+
 // @Summary     Handles a Netclient registration with server and add nodes accordingly
 // @Router      /api/v1/host/register/{token} [post]
 // @Tags        EnrollmentKeys
@@ -309,6 +406,54 @@ func handleHostRegister(w http.ResponseWriter, r *http.Request) {
 		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
 		return
 	}
+	registerHost(w, r, enrollmentKey, &newHost, nil)
+}
+
+// @Summary     Handles a Netclient registration backed by a signed cloud instance identity document
+// @Router      /api/v1/host/attest/register [post]
+// @Tags        EnrollmentKeys
+// @Param       body body models.AttestedRegisterRequest true "Host and instance identity document"
+// @Success     200 {object} models.RegisterResponse
+// @Failure     400 {object} models.ErrorResponse
+// @Failure     401 {object} models.ErrorResponse
+// @Failure     500 {object} models.ErrorResponse
+func handleAttestedHostRegister(w http.ResponseWriter, r *http.Request) {
+	var req models.AttestedRegisterRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+		return
+	}
+	identity, err := logic.VerifyInstanceIdentity(req.Provider, req.Document)
+	if err != nil {
+		slog.Error("failed to verify instance identity", "provider", req.Provider, "host", req.Host.Name, "error", err)
+		if errors.Is(err, logic.AttestationErrors.NotConfigured) {
+			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+			return
+		}
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "unauthorized"))
+		return
+	}
+	enrollmentKey, err := logic.GetAttestedEnrollmentKey(identity)
+	if err != nil {
+		slog.Error("attested registration rejected", "provider", identity.Provider,
+			"account", identity.Account, "region", identity.Region, "instance", identity.InstanceID, "error", err)
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "unauthorized"))
+		return
+	}
+	if err = logic.CheckInstanceIdentityReuse(identity, req.Host.ID.String()); err != nil {
+		slog.Error("attested registration rejected", "instance", identity.InstanceID, "host", req.Host.ID.String(), "error", err)
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "unauthorized"))
+		return
+	}
+	defer logic.ReleaseInstanceIdentity(identity)
+	logger.Log(0, "received attested registration from", string(identity.Provider), "instance", identity.InstanceID)
+	registerHost(w, r, enrollmentKey, &req.Host, &identity)
+}
+
+// registerHost - registers a host with the networks of an enrollment key
+func registerHost(w http.ResponseWriter, r *http.Request, enrollmentKey *models.EnrollmentKey, requestedHost *models.Host, identity *models.CloudIdentity) {
+	newHost := *requestedHost
+	var err error
 	// check if host already exists
 	hostExists := false
 	if hostExists = logic.HostExists(&newHost); hostExists && len(enrollmentKey.Networks) == 0 {
@@ -344,7 +489,13 @@ func handleHostRegister(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	// use the token
-	if ok := logic.TryToUseEnrollmentKey(enrollmentKey); !ok {
+	sourceIP, _ := logic.ParseRequestIP(r)
+	if logic.IsGeoBlockedIP(net.ParseIP(sourceIP)) || logic.IsGeoBlockedIP(newHost.EndpointIP) {
+		logger.Log(0, "host", newHost.ID.String(), newHost.Name, "attempted to register from a blocked country")
+		logic.ReturnErrorResponse(w, r, logic.FormatError(errGeoBlocked, "forbidden"))
+		return
+	}
+	if ok := logic.TryToUseEnrollmentKey(enrollmentKey, &newHost, sourceIP); !ok {
 		logger.Log(0, "host", newHost.ID.String(), newHost.Name, "failed registration")
 		logic.ReturnErrorResponse(
 			w,
@@ -355,6 +506,8 @@ func handleHostRegister(w http.ResponseWriter, r *http.Request) {
 	}
 	if !hostExists {
 		newHost.PersistentKeepalive = models.DefaultPersistentKeepAlive
+		newHost.IsEphemeral = enrollmentKey.Profile != nil && enrollmentKey.Profile.Ephemeral
+		logic.ApplyHostProvisioningProfile(&newHost, enrollmentKey.Profile)
 		// register host
 		_ = logic.CheckHostPorts(&newHost)
 		// create EMQX credentials and ACLs for host
@@ -395,6 +548,7 @@ func handleHostRegister(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 		logic.UpdateHostFromClient(&newHost, currHost)
+		logic.ApplyHostProvisioningProfile(currHost, enrollmentKey.Profile)
 		err = logic.UpsertHost(currHost)
 		if err != nil {
 			slog.Error("failed to update host", "id", currHost.ID, "error", err)
@@ -407,6 +561,14 @@ func handleHostRegister(w http.ResponseWriter, r *http.Request) {
 		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
 		return
 	}
+	if err = logic.RecordEnrollmentKeyUsage(enrollmentKey, host, sourceIP, identity); err != nil {
+		slog.Error("failed to record enrollment key usage", "key", enrollmentKey.Value, "host", host.ID.String(), "error", err)
+		if identity != nil {
+			// an unrecorded identity document could be replayed
+			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+			return
+		}
+	}
 	// ready the response
 	server := logic.GetServerInfo()
 	server.TrafficKey = key
@@ -437,5 +599,5 @@ func handleHostRegister(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(&response)
 	// notify host of changes, peer and node updates
-	go auth.CheckNetRegAndHostUpdate(enrollmentKey.Networks, host, enrollmentKey.Relay, enrollmentKey.Groups)
+	go auth.CheckNetRegAndHostUpdate(enrollmentKey.Networks, host, enrollmentKey.Relay, enrollmentKey.Groups, enrollmentKey.Profile)
 }
@@ -1,30 +1,25 @@
 package controller
 
 import (
-	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"net"
 	"net/http"
 	"reflect"
-	"strconv"
 	"strings"
+	"time"
 
 	"github.com/go-playground/validator/v10"
 	"github.com/gorilla/mux"
 	"github.com/gravitl/netmaker/database"
-	"github.com/gravitl/netmaker/db"
 	"github.com/gravitl/netmaker/logger"
 	"github.com/gravitl/netmaker/logic"
-	"github.com/gravitl/netmaker/schema"
 	"github.com/gravitl/netmaker/servercfg"
 
 	"github.com/gravitl/netmaker/models"
 
 	"github.com/gravitl/netmaker/mq"
-	"github.com/skip2/go-qrcode"
-	"golang.org/x/exp/slices"
 	"golang.org/x/exp/slog"
 	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
 )
@@ -46,6 +41,13 @@ func extClientHandlers(r *mux.Router) {
 	r.HandleFunc("/api/extclients/{network}/{nodeid}", logic.SecurityCheck(false, checkFreeTierLimits(limitChoiceMachines, http.HandlerFunc(createExtClient)))).
 		Methods(http.MethodPost)
 	r.HandleFunc("/api/v1/client_conf/{network}", logic.SecurityCheck(false, http.HandlerFunc(getExtClientHAConf))).Methods(http.MethodGet)
+	r.HandleFunc("/api/v1/extclients/{network}/{clientid}/links", logic.SecurityCheck(true, http.HandlerFunc(createExtClientConfigLink))).
+		Methods(http.MethodPost)
+	r.HandleFunc("/api/v1/extclients/{network}/{clientid}/links", logic.SecurityCheck(true, http.HandlerFunc(listExtClientConfigLinks))).
+		Methods(http.MethodGet)
+	r.HandleFunc("/api/v1/extclients/links/{link_id}", logic.SecurityCheck(true, http.HandlerFunc(revokeExtClientConfigLink))).
+		Methods(http.MethodDelete)
+	r.HandleFunc("/api/v1/extclient_config/{token}", downloadExtClientConfigLink).Methods(http.MethodGet)
 }
 
 func checkIngressExists(nodeID string) bool {
@@ -60,6 +62,9 @@ func checkIngressExists(nodeID string) bool {
 // @Router      /api/extclients/{network} [get]
 // @Tags        Remote Access Client
 // @Security    oauth2
+// @Param       country query string false "ISO country code of the public endpoint"
+// @Param       city query string false "City of the public endpoint"
+// @Param       asn query int false "Autonomous system of the public endpoint"
 // @Success     200 {object} models.ExtClient
 // @Failure     500 {object} models.ErrorResponse
 func getNetworkExtClients(w http.ResponseWriter, r *http.Request) {
@@ -76,6 +81,9 @@ func getNetworkExtClients(w http.ResponseWriter, r *http.Request) {
 		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
 		return
 	}
+	if filter := logic.ParseGeoFilter(r.URL.Query()); filter.IsSet() {
+		extclients = logic.FilterExtClientsByLocation(extclients, filter)
+	}
 
 	//Returns all the extclients in JSON format
 	w.WriteHeader(http.StatusOK)
@@ -86,6 +94,9 @@ func getNetworkExtClients(w http.ResponseWriter, r *http.Request) {
 // @Router      /api/extclients [get]
 // @Tags        Remote Access Client
 // @Security    oauth2
+// @Param       country query string false "ISO country code of the public endpoint"
+// @Param       city query string false "City of the public endpoint"
+// @Param       asn query int false "Autonomous system of the public endpoint"
 // @Success     200 {object} models.ExtClient
 // @Failure     500 {object} models.ErrorResponse
 // Not quite sure if this is necessary. Probably necessary based on front end but may
@@ -100,6 +111,9 @@ func getAllExtClients(w http.ResponseWriter, r *http.Request) {
 		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
 		return
 	}
+	if filter := logic.ParseGeoFilter(r.URL.Query()); filter.IsSet() {
+		clients = logic.FilterExtClientsByLocation(clients, filter)
+	}
 	//Return all the extclients in JSON format
 	logic.SortExtClient(clients[:])
 	w.WriteHeader(http.StatusOK)
@@ -162,252 +176,45 @@ func getExtClientConf(w http.ResponseWriter, r *http.Request) {
 		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
 		return
 	}
-
-	gwnode, err := logic.GetNodeByID(client.IngressGatewayID)
-	if err != nil {
-		logger.Log(
-			0,
-			r.Header.Get("user"),
-			fmt.Sprintf(
-				"failed to get ingress gateway node [%s] info: %v",
-				client.IngressGatewayID,
-				err,
-			),
-		)
-		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+	if !logic.IsExtClientConfFormat(params["type"]) {
+		// the client itself doesn't need its gateway
+		logger.Log(2, r.Header.Get("user"), "retrieved ext client config")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(client)
 		return
 	}
-	eli, _ := (&schema.Egress{Network: gwnode.Network}).ListByNetwork(db.WithContext(context.TODO()))
-	acls, _ := logic.ListAclsByNetwork(models.NetworkID(client.Network))
-	logic.GetNodeEgressInfo(&gwnode, eli, acls)
-	host, err := logic.GetHost(gwnode.HostID.String())
+
+	conf, err := logic.GetExtClientConf(&client, strings.TrimSpace(r.URL.Query().Get("preferredip")))
 	if err != nil {
-		logger.Log(
-			0,
-			r.Header.Get("user"),
-			fmt.Sprintf(
-				"failed to get host for ingress gateway node [%s] info: %v",
-				client.IngressGatewayID,
-				err,
-			),
-		)
+		logger.Log(0, r.Header.Get("user"), "failed to get ext client config:", err.Error())
+		if errors.Is(err, logic.ErrInvalidPreferredIP) {
+			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+			return
+		}
 		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
 		return
 	}
-
-	network, err := logic.GetParentNetwork(client.Network)
+	data, contentType, fileName, err := logic.RenderExtClientConf(&conf, params["type"])
 	if err != nil {
-		logger.Log(
-			1,
-			r.Header.Get("user"),
-			"Could not retrieve Ingress Gateway Network",
-			client.Network,
-		)
+		logger.Log(1, r.Header.Get("user"), "failed to render ext client config", params["type"], err.Error())
 		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
 		return
 	}
-
-	preferredIp := strings.TrimSpace(r.URL.Query().Get("preferredip"))
-	if preferredIp != "" {
-		allowedPreferredIps := []string{}
-		for i := range gwnode.AdditionalRagIps {
-			allowedPreferredIps = append(allowedPreferredIps, gwnode.AdditionalRagIps[i].String())
-		}
-		allowedPreferredIps = append(allowedPreferredIps, host.EndpointIP.String())
-		allowedPreferredIps = append(allowedPreferredIps, host.EndpointIPv6.String())
-		if !slices.Contains(allowedPreferredIps, preferredIp) {
-			slog.Warn(
-				"preferred endpoint ip is not associated with the RAG. proceeding with preferred ip",
-				"preferred ip",
-				preferredIp,
-			)
-			logic.ReturnErrorResponse(
-				w,
-				r,
-				logic.FormatError(
-					errors.New("preferred endpoint ip is not associated with the RAG"),
-					"badrequest",
-				),
-			)
-			return
-		}
-		if net.ParseIP(preferredIp).To4() == nil {
-			preferredIp = fmt.Sprintf("[%s]", preferredIp)
-		}
-	}
-
-	addrString := client.Address
-	if addrString != "" {
-		addrString += "/32"
-	}
-	if client.Address6 != "" {
-		if addrString != "" {
-			addrString += ","
-		}
-		addrString += client.Address6 + "/128"
-	}
-
-	keepalive := ""
-	if network.DefaultKeepalive != 0 {
-		keepalive = "PersistentKeepalive = " + strconv.Itoa(int(network.DefaultKeepalive))
-	}
-	if gwnode.IngressPersistentKeepalive != 0 {
-		keepalive = "PersistentKeepalive = " + strconv.Itoa(int(gwnode.IngressPersistentKeepalive))
-	}
-
-	gwendpoint := ""
-	if preferredIp == "" {
-		if host.EndpointIP.To4() == nil {
-			gwendpoint = fmt.Sprintf("[%s]:%d", host.EndpointIPv6.String(), host.ListenPort)
-		} else {
-			gwendpoint = fmt.Sprintf("%s:%d", host.EndpointIP.String(), host.ListenPort)
-		}
-	} else {
-		gwendpoint = fmt.Sprintf("%s:%d", preferredIp, host.ListenPort)
-	}
-
-	var newAllowedIPs string
-	if logic.IsInternetGw(gwnode) {
-		egressrange := "0.0.0.0/0"
-		if gwnode.Address6.IP != nil && client.Address6 != "" {
-			egressrange += "," + "::/0"
-		}
-		newAllowedIPs = egressrange
-	} else {
-		newAllowedIPs = network.AddressRange
-		if newAllowedIPs != "" && network.AddressRange6 != "" {
-			newAllowedIPs += ","
-		}
-		if network.AddressRange6 != "" {
-			newAllowedIPs += network.AddressRange6
-		}
-		if egressGatewayRanges, err := logic.GetEgressRangesOnNetwork(&client); err == nil {
-			for _, egressGatewayRange := range egressGatewayRanges {
-				newAllowedIPs += "," + egressGatewayRange
-			}
-		}
-	}
-
-	defaultDNS := ""
-	if client.DNS != "" {
-		defaultDNS = "DNS = " + client.DNS
-	} else if gwnode.IngressDNS != "" {
-		defaultDNS = "DNS = " + gwnode.IngressDNS
-	}
-	if client.DNS == "" {
-		if len(network.NameServers) > 0 {
-			if defaultDNS == "" {
-				defaultDNS = "DNS = " + strings.Join(network.NameServers, ",")
-			} else {
-				defaultDNS += "," + strings.Join(network.NameServers, ",")
-			}
-
-		}
-	}
-	// if servercfg.GetManageDNS() {
-	// 	if gwnode.Address6.IP != nil {
-	// 		if defaultDNS == "" {
-	// 			defaultDNS = "DNS = " + gwnode.Address6.IP.String()
-	// 		} else {
-	// 			defaultDNS = defaultDNS + ", " + gwnode.Address6.IP.String()
-	// 		}
-	// 	}
-	// 	if gwnode.Address.IP != nil {
-	// 		if defaultDNS == "" {
-	// 			defaultDNS = "DNS = " + gwnode.Address.IP.String()
-	// 		} else {
-	// 			defaultDNS = defaultDNS + ", " + gwnode.Address.IP.String()
-	// 		}
-	// 	}
-	// }
-
-	defaultMTU := 1420
-	if host.MTU != 0 {
-		defaultMTU = host.MTU
-	}
-	if gwnode.IngressMTU != 0 {
-		defaultMTU = int(gwnode.IngressMTU)
-	}
-
-	postUp := strings.Builder{}
-	if client.PostUp != "" && params["type"] != "qr" {
-		for _, loc := range strings.Split(client.PostUp, "\n") {
-			postUp.WriteString(fmt.Sprintf("PostUp = %s\n", loc))
-		}
-	}
-
-	postDown := strings.Builder{}
-	if client.PostDown != "" && params["type"] != "qr" {
-		for _, loc := range strings.Split(client.PostDown, "\n") {
-			postDown.WriteString(fmt.Sprintf("PostDown = %s\n", loc))
-		}
-	}
-
-	config := fmt.Sprintf(`[Interface]
-Address = %s
-PrivateKey = %s
-MTU = %d
-%s
-%s
-%s
-
-[Peer]
-PublicKey = %s
-AllowedIPs = %s
-Endpoint = %s
-%s
-
-`, addrString,
-		client.PrivateKey,
-		defaultMTU,
-		defaultDNS,
-		postUp.String(),
-		postDown.String(),
-		host.PublicKey,
-		newAllowedIPs,
-		gwendpoint,
-		keepalive,
-	)
-
-	if params["type"] == "qr" {
-		bytes, err := qrcode.Encode(config, qrcode.Medium, 220)
-		if err != nil {
-			logger.Log(1, r.Header.Get("user"), "failed to encode qr code: ", err.Error())
-			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
-			return
-		}
-		w.Header().Set("Content-Type", "image/png")
-		w.WriteHeader(http.StatusOK)
-		_, err = w.Write(bytes)
-		if err != nil {
-			logger.Log(1, r.Header.Get("user"), "response writer error (qr) ", err.Error())
-			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
-			return
-		}
-		return
-	}
-
-	if params["type"] == "file" {
-		name := client.ClientID + ".conf"
-		w.Header().Set("Content-Type", "application/config")
-		w.Header().Set("Content-Disposition", "attachment; filename=\""+name+"\"")
-		w.WriteHeader(http.StatusOK)
-		_, err := fmt.Fprint(w, config)
-		if err != nil {
-			logger.Log(1, r.Header.Get("user"), "response writer error (file) ", err.Error())
-			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
-		}
-		return
+	w.Header().Set("Content-Type", contentType)
+	if fileName != "" {
+		w.Header().Set("Content-Disposition", "attachment; filename=\""+fileName+"\"")
 	}
-	logger.Log(2, r.Header.Get("user"), "retrieved ext client config")
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(client)
+	if _, err = w.Write(data); err != nil {
+		logger.Log(1, r.Header.Get("user"), "response writer error ("+params["type"]+") ", err.Error())
+	}
 }
 
 // @Summary     Get an individual remote access client
 // @Router      /api/extclients/{network}/{clientid}/{type} [get]
 // @Tags        Remote Access Client
 // @Security    oauth2
+// @Param       type query string false "config format, file by default"
 // @Success     200 {object} models.ExtClient
 // @Failure     500 {object} models.ErrorResponse
 // @Failure     403 {object} models.ErrorResponse
@@ -415,8 +222,7 @@ func getExtClientHAConf(w http.ResponseWriter, r *http.Request) {
 
 	var params = mux.Vars(r)
 	networkid := params["network"]
-	network, err := logic.GetParentNetwork(networkid)
-	if err != nil {
+	if _, err := logic.GetParentNetwork(networkid); err != nil {
 		logger.Log(
 			1,
 			r.Header.Get("user"),
@@ -471,15 +277,21 @@ func getExtClientHAConf(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var userName string
+	var caller *models.User
 	if r.Header.Get("ismaster") == "yes" {
 		userName = logic.MasterUser
 	} else {
-		caller, err := logic.GetUser(r.Header.Get("user"))
+		caller, err = logic.GetUser(r.Header.Get("user"))
 		if err != nil {
 			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
 			return
 		}
 		userName = caller.UserName
+		if err := logic.CheckUserExtClientLimits(caller, networkid, targetGwID); err != nil {
+			slog.Error("failed to create extclient", "user", userName, "error", err)
+			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "forbidden"))
+			return
+		}
 	}
 	// create client
 	var extclient models.ExtClient
@@ -508,6 +320,13 @@ func getExtClientHAConf(w http.ResponseWriter, r *http.Request) {
 	listenPort := logic.GetPeerListenPort(host)
 	extclient.IngressGatewayEndpoint = fmt.Sprintf("%s:%d", host.EndpointIP.String(), listenPort)
 	extclient.Enabled = true
+	if caller != nil && !canSetExtClientQuota(caller) {
+		// configs issued to users follow the session policy of the gateway
+		if err = logic.ApplyRacSessionPolicy(caller, &gwnode, &extclient, time.Now()); err != nil {
+			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "unauthorized"))
+			return
+		}
+	}
 
 	if err = logic.CreateExtClient(&extclient); err != nil {
 		slog.Error(
@@ -527,106 +346,27 @@ func getExtClientHAConf(w http.ResponseWriter, r *http.Request) {
 		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
 		return
 	}
-	addrString := client.Address
-	if addrString != "" {
-		addrString += "/32"
-	}
-	if client.Address6 != "" {
-		if addrString != "" {
-			addrString += ","
-		}
-		addrString += client.Address6 + "/128"
-	}
-
-	keepalive := ""
-	if network.DefaultKeepalive != 0 {
-		keepalive = "PersistentKeepalive = " + strconv.Itoa(int(network.DefaultKeepalive))
-	}
-	if gwnode.IngressPersistentKeepalive != 0 {
-		keepalive = "PersistentKeepalive = " + strconv.Itoa(int(gwnode.IngressPersistentKeepalive))
-	}
-	var newAllowedIPs string
-	if logic.IsInternetGw(gwnode) || gwnode.InternetGwID != "" {
-		egressrange := "0.0.0.0/0"
-		if gwnode.Address6.IP != nil && client.Address6 != "" {
-			egressrange += "," + "::/0"
-		}
-		newAllowedIPs = egressrange
-	} else {
-		newAllowedIPs = network.AddressRange
-		if newAllowedIPs != "" && network.AddressRange6 != "" {
-			newAllowedIPs += ","
-		}
-		if network.AddressRange6 != "" {
-			newAllowedIPs += network.AddressRange6
-		}
-		if egressGatewayRanges, err := logic.GetEgressRangesOnNetwork(&client); err == nil {
-			for _, egressGatewayRange := range egressGatewayRanges {
-				newAllowedIPs += "," + egressGatewayRange
-			}
-		}
-	}
-	gwendpoint := ""
-	if host.EndpointIP.To4() == nil {
-		gwendpoint = fmt.Sprintf("[%s]:%d", host.EndpointIPv6.String(), host.ListenPort)
-	} else {
-		gwendpoint = fmt.Sprintf("%s:%d", host.EndpointIP.String(), host.ListenPort)
-	}
-	defaultDNS := ""
-	if client.DNS != "" {
-		defaultDNS = "DNS = " + client.DNS
-	} else if gwnode.IngressDNS != "" {
-		defaultDNS = "DNS = " + gwnode.IngressDNS
-	}
-
-	defaultMTU := 1420
-	if host.MTU != 0 {
-		defaultMTU = host.MTU
+	conf, err := logic.GetExtClientHAConf(&client)
+	if err != nil {
+		logger.Log(0, r.Header.Get("user"), "failed to get ext client config:", err.Error())
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return
 	}
-	if gwnode.IngressMTU != 0 {
-		defaultMTU = int(gwnode.IngressMTU)
+	format := r.URL.Query().Get("type")
+	if format == "" {
+		format = "file"
 	}
-
-	postUp := strings.Builder{}
-	if client.PostUp != "" && params["type"] != "qr" {
-		for _, loc := range strings.Split(client.PostUp, "\n") {
-			postUp.WriteString(fmt.Sprintf("PostUp = %s\n", loc))
-		}
-	}
-
-	postDown := strings.Builder{}
-	if client.PostDown != "" && params["type"] != "qr" {
-		for _, loc := range strings.Split(client.PostDown, "\n") {
-			postDown.WriteString(fmt.Sprintf("PostDown = %s\n", loc))
+	data, contentType, fileName, err := logic.RenderExtClientConf(&conf, format)
+	if err != nil {
+		logger.Log(1, r.Header.Get("user"), "failed to render ext client config", format, err.Error())
+		if errors.Is(err, logic.ErrUnknownConfFormat) {
+			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+			return
 		}
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return
 	}
 
-	config := fmt.Sprintf(`[Interface]
-Address = %s
-PrivateKey = %s
-MTU = %d
-%s
-%s
-%s
-
-[Peer]
-PublicKey = %s
-AllowedIPs = %s
-Endpoint = %s
-%s
-
-`, addrString,
-		client.PrivateKey,
-		defaultMTU,
-		defaultDNS,
-		postUp.String(),
-		postDown.String(),
-		host.PublicKey,
-		newAllowedIPs,
-		gwendpoint,
-		keepalive,
-	)
-
 	go func() {
 		if err := logic.SetClientDefaultACLs(&extclient); err != nil {
 			slog.Error(
@@ -649,14 +389,13 @@ Endpoint = %s
 		}
 	}()
 
-	name := client.ClientID + ".conf"
-	w.Header().Set("Content-Type", "application/config")
-	w.Header().Set("Content-Disposition", "attachment; filename=\""+name+"\"")
+	w.Header().Set("Content-Type", contentType)
+	if fileName != "" {
+		w.Header().Set("Content-Disposition", "attachment; filename=\""+fileName+"\"")
+	}
 	w.WriteHeader(http.StatusOK)
-	_, err = fmt.Fprint(w, config)
-	if err != nil {
-		logger.Log(1, r.Header.Get("user"), "response writer error (file) ", err.Error())
-		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+	if _, err = w.Write(data); err != nil {
+		logger.Log(1, r.Header.Get("user"), "response writer error ("+format+") ", err.Error())
 	}
 }
 
@@ -709,10 +448,11 @@ func createExtClient(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	var userName string
+	var caller *models.User
 	if r.Header.Get("ismaster") == "yes" {
 		userName = logic.MasterUser
 	} else {
-		caller, err := logic.GetUser(r.Header.Get("user"))
+		caller, err = logic.GetUser(r.Header.Get("user"))
 		if err != nil {
 			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
 			return
@@ -735,6 +475,19 @@ func createExtClient(w http.ResponseWriter, r *http.Request) {
 				return
 			}
 		}
+		if err := logic.CheckUserExtClientLimits(caller, node.Network, nodeid); err != nil {
+			slog.Error("failed to create extclient", "user", userName, "error", err)
+			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "forbidden"))
+			return
+		}
+		if (customExtClient.Quota != nil || customExtClient.RateLimit != nil) && !canSetExtClientQuota(caller) {
+			logic.ReturnErrorResponse(w, r, logic.FormatError(errExtClientQuotaForbidden, "forbidden"))
+			return
+		}
+		if (customExtClient.Expiry != nil || customExtClient.KeyRotation != nil) && !canSetExtClientQuota(caller) {
+			logic.ReturnErrorResponse(w, r, logic.FormatError(errExtClientLifetimeForbidden, "forbidden"))
+			return
+		}
 	}
 
 	extclient := logic.UpdateExtClient(&models.ExtClient{}, &customExtClient)
@@ -780,6 +533,25 @@ func createExtClient(w http.ResponseWriter, r *http.Request) {
 	}
 	extclient.PublicEndpoint = customExtClient.PublicEndpoint
 	extclient.Country = customExtClient.Country
+	logic.UpdateExtClientLocation(&extclient, false)
+	if logic.IsGeoBlocked(extclient.Location) {
+		slog.Error("failed to create extclient", "user", userName, "country", extclient.Location.Country, "error", errGeoBlocked)
+		logic.ReturnErrorResponse(w, r, logic.FormatError(errGeoBlocked, "forbidden"))
+		return
+	}
+	if customExtClient.Expiry != nil {
+		extclient.Expiry = *customExtClient.Expiry
+	}
+	if customExtClient.KeyRotation != nil {
+		extclient.KeyRotation = *customExtClient.KeyRotation
+	}
+	if caller != nil && !canSetExtClientQuota(caller) {
+		// configs issued to users follow the session policy of the gateway
+		if err = logic.ApplyRacSessionPolicy(caller, &node, &extclient, time.Now()); err != nil {
+			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "unauthorized"))
+			return
+		}
+	}
 
 	if err = logic.CreateExtClient(&extclient); err != nil {
 		slog.Error(
@@ -900,6 +672,35 @@ func updateExtClient(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	quotaChanged := (update.Quota != nil && *update.Quota != oldExtClient.Quota) ||
+		(update.RateLimit != nil && *update.RateLimit != oldExtClient.RateLimit)
+	lifetimeChanged := isExtClientExpiryChanged(oldExtClient.Expiry, update.Expiry) ||
+		(update.KeyRotation != nil && *update.KeyRotation != oldExtClient.KeyRotation)
+	// clients suspended for their quota stay disabled until the period ends unless an admin lifts it
+	liftsSuspension := update.Enabled && !oldExtClient.Enabled && time.Now().Before(oldExtClient.QuotaSuspendedUntil)
+	if (quotaChanged || lifetimeChanged || liftsSuspension) && r.Header.Get("ismaster") != "yes" {
+		caller, err := logic.GetUser(r.Header.Get("user"))
+		if err != nil {
+			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+			return
+		}
+		if liftsSuspension && !canSetExtClientQuota(caller) {
+			logic.ReturnErrorResponse(w, r, logic.FormatError(errExtClientQuotaSuspended, "forbidden"))
+			return
+		}
+		if quotaChanged && !canSetExtClientQuota(caller) {
+			logic.ReturnErrorResponse(w, r, logic.FormatError(errExtClientQuotaForbidden, "forbidden"))
+			return
+		}
+		if lifetimeChanged && !canSetExtClientQuota(caller) {
+			logic.ReturnErrorResponse(w, r, logic.FormatError(errExtClientLifetimeForbidden, "forbidden"))
+			return
+		}
+	}
+	if quotaChanged {
+		sendPeerUpdate = true
+	}
+
 	var gateway models.EgressGatewayRequest
 	gateway.NetID = params["network"]
 	gateway.Ranges = update.ExtraAllowedIPs
@@ -1109,7 +910,31 @@ func validateCustomExtClient(customExtClient *models.CustomExtClient, checkID bo
 			}
 		}
 	}
-	return nil
+	if err := customExtClient.Expiry.Validate(); err != nil {
+		return err
+	}
+	if err := customExtClient.Quota.Validate(); err != nil {
+		return err
+	}
+	if err := customExtClient.RateLimit.Validate(); err != nil {
+		return err
+	}
+	return logic.ValidateExtClientKeyRotation(customExtClient.KeyRotation)
+}
+
+// canSetExtClientQuota - only admins may set the quotas, rate limits, expiry and key rotation of ext clients
+func canSetExtClientQuota(caller *models.User) bool {
+	return caller == nil || caller.PlatformRoleID == models.SuperAdminRole || caller.PlatformRoleID == models.AdminRole
+}
+
+// isExtClientExpiryChanged - checks if the update changes the lifetime limits of the client
+func isExtClientExpiryChanged(current models.ExtClientExpiry, update *models.ExtClientExpiry) bool {
+	if update == nil {
+		return false
+	}
+	return !update.ExpiresAt.Equal(current.ExpiresAt) || update.TTL != current.TTL ||
+		update.InactivityTimeout != current.InactivityTimeout || update.Action != current.Action ||
+		update.NotifyBefore != current.NotifyBefore
 }
 
 // isValid	Checks if the clientid is valid
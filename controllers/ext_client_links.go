@@ -0,0 +1,191 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/gravitl/netmaker/db"
+	"github.com/gravitl/netmaker/logic"
+	"github.com/gravitl/netmaker/models"
+	"github.com/gravitl/netmaker/schema"
+	"golang.org/x/exp/slog"
+)
+
+// @Summary     Mint a signed download link for a remote access client config
+// @Router      /api/v1/extclients/{network}/{clientid}/links [post]
+// @Tags        Remote Access Client
+// @Security    oauth2
+// @Param       network path string true "Network ID"
+// @Param       clientid path string true "Client ID"
+// @Param       body body models.ExtClientConfigLinkReq true "Link settings"
+// @Success     200 {object} models.ExtClientConfigLinkResp
+// @Failure     400 {object} models.ErrorResponse
+// @Failure     500 {object} models.ErrorResponse
+func createExtClientConfigLink(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	var req models.ExtClientConfigLinkReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+		return
+	}
+	client, err := logic.GetExtClient(params["clientid"], params["network"])
+	if err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+		return
+	}
+	link, token, err := logic.CreateExtClientConfigLink(&client, req, r.Header.Get("user"))
+	if err != nil {
+		if errors.Is(err, logic.ErrInvalidExtClientConfigLink) {
+			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+			return
+		}
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return
+	}
+	resp := models.ExtClientConfigLinkResp{
+		ID:        link.ID,
+		URL:       logic.ExtClientConfigLinkURL(token),
+		ExpiresAt: link.ExpiresAt,
+	}
+	if req.Recipient != "" {
+		if err := logic.SendExtClientConfigLink(link, resp.URL); err != nil {
+			slog.Error("failed to email config link", "client", client.ClientID, "recipient", req.Recipient, "error", err)
+		} else {
+			resp.Emailed = true
+		}
+	}
+	logic.LogEvent(&models.Event{
+		Action: models.Create,
+		Source: models.Subject{
+			ID:   r.Header.Get("user"),
+			Name: r.Header.Get("user"),
+			Type: models.UserSub,
+		},
+		TriggeredBy: r.Header.Get("user"),
+		Target: models.Subject{
+			ID:   link.ID,
+			Name: client.ClientID,
+			Type: models.ConfigLinkSub,
+		},
+		NetworkID: models.NetworkID(client.Network),
+		Origin:    models.Dashboard,
+	})
+	logic.ReturnSuccessResponseWithJson(w, r, resp, "created config link for "+client.ClientID)
+}
+
+// @Summary     List the download links of a remote access client config
+// @Router      /api/v1/extclients/{network}/{clientid}/links [get]
+// @Tags        Remote Access Client
+// @Security    oauth2
+// @Param       network path string true "Network ID"
+// @Param       clientid path string true "Client ID"
+// @Success     200 {array} schema.ExtClientConfigLink
+// @Failure     500 {object} models.ErrorResponse
+func listExtClientConfigLinks(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	links, err := (&schema.ExtClientConfigLink{
+		ClientID: params["clientid"],
+		Network:  params["network"],
+	}).ListByClient(db.WithContext(context.TODO()))
+	if err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return
+	}
+	logic.ReturnSuccessResponseWithJson(w, r, links, "fetched config links")
+}
+
+// @Summary     Revoke a config download link
+// @Router      /api/v1/extclients/links/{link_id} [delete]
+// @Tags        Remote Access Client
+// @Security    oauth2
+// @Param       link_id path string true "Link ID"
+// @Success     200 {object} models.SuccessResponse
+// @Failure     400 {object} models.ErrorResponse
+func revokeExtClientConfigLink(w http.ResponseWriter, r *http.Request) {
+	link, err := logic.RevokeExtClientConfigLink(mux.Vars(r)["link_id"])
+	if err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+		return
+	}
+	logic.LogEvent(&models.Event{
+		Action: models.Delete,
+		Source: models.Subject{
+			ID:   r.Header.Get("user"),
+			Name: r.Header.Get("user"),
+			Type: models.UserSub,
+		},
+		TriggeredBy: r.Header.Get("user"),
+		Target: models.Subject{
+			ID:   link.ID,
+			Name: link.ClientID,
+			Type: models.ConfigLinkSub,
+		},
+		NetworkID: models.NetworkID(link.Network),
+		Origin:    models.Dashboard,
+	})
+	logic.ReturnSuccessResponse(w, r, "revoked config link "+link.ID)
+}
+
+// @Summary     Download a remote access client config through a signed link
+// @Router      /api/v1/extclient_config/{token} [get]
+// @Tags        Remote Access Client
+// @Param       token path string true "Signed link token"
+// @Success     200 {string} string "config"
+// @Failure     404 {object} models.ErrorResponse
+func downloadExtClientConfigLink(w http.ResponseWriter, r *http.Request) {
+	token := mux.Vars(r)["token"]
+	link, err := logic.GetExtClientConfigLink(token)
+	if err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "notfound"))
+		return
+	}
+	client, err := logic.GetExtClient(link.ClientID, link.Network)
+	if err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "notfound"))
+		return
+	}
+	conf, err := logic.GetExtClientConf(&client, "")
+	if err != nil {
+		slog.Error("failed to get ext client config for link", "link", link.ID, "client", link.ClientID, "error", err)
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return
+	}
+	data, contentType, fileName, err := logic.RenderExtClientConf(&conf, link.Format)
+	if err != nil {
+		slog.Error("failed to render ext client config for link", "link", link.ID, "format", link.Format, "error", err)
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return
+	}
+	// the download only counts once the config is ready to be sent
+	if link, err = logic.ConsumeExtClientConfigLink(token); err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "notfound"))
+		return
+	}
+	logic.LogEvent(&models.Event{
+		Action: models.Download,
+		Source: models.Subject{
+			ID:   link.ID,
+			Name: r.RemoteAddr,
+			Type: models.ConfigLinkSub,
+		},
+		TriggeredBy: link.Recipient,
+		Target: models.Subject{
+			ID:   link.ClientID,
+			Name: link.ClientID,
+			Type: models.DeviceSub,
+		},
+		NetworkID: models.NetworkID(link.Network),
+		Origin:    models.Api,
+	})
+	w.Header().Set("Content-Type", contentType)
+	if fileName != "" {
+		w.Header().Set("Content-Disposition", "attachment; filename=\""+fileName+"\"")
+	}
+	w.WriteHeader(http.StatusOK)
+	if _, err = w.Write(data); err != nil {
+		slog.Error("failed to write ext client config for link", "link", link.ID, "error", err)
+	}
+}
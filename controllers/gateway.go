@@ -2,10 +2,10 @@ package controller
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 
-	"github.com/google/uuid"
 	"github.com/gorilla/mux"
 	"github.com/gravitl/netmaker/logger"
 	"github.com/gravitl/netmaker/logic"
@@ -50,47 +50,25 @@ func createGateway(w http.ResponseWriter, r *http.Request) {
 		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
 		return
 	}
-	node, err = logic.CreateIngressGateway(netid, nodeid, req.IngressRequest)
+	relayNode, err := logic.CreateGateway(netid, nodeid, req)
 	if err != nil {
 		logger.Log(0, r.Header.Get("user"),
 			fmt.Sprintf("failed to create gateway on node [%s] on network [%s]: %v",
 				nodeid, netid, err))
+		if errors.Is(err, models.ErrInvalidRacSessionPolicy) {
+			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+			return
+		}
 		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
 		return
 	}
-	req.RelayRequest.NetID = netid
-	req.RelayRequest.NodeID = nodeid
-	_, relayNode, err := logic.CreateRelay(req.RelayRequest)
-	if err != nil {
-		logger.Log(
-			0,
-			r.Header.Get("user"),
-			fmt.Sprintf(
-				"failed to create relay on node [%s] on network [%s]: %v",
-				req.RelayRequest.NodeID,
-				req.RelayRequest.NetID,
-				err,
-			),
-		)
-		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
-		return
-	}
-	for _, relayedNodeID := range relayNode.RelayedNodes {
-		relayedNode, err := logic.GetNodeByID(relayedNodeID)
-		if err == nil {
-			if relayedNode.FailedOverBy != uuid.Nil {
-				go logic.ResetFailedOverPeer(&relayedNode)
-			}
-
-		}
-	}
 	logger.Log(
 		1,
 		r.Header.Get("user"),
 		"created gw node",
-		req.RelayRequest.NodeID,
+		nodeid,
 		"on network",
-		req.RelayRequest.NetID,
+		netid,
 	)
 	logic.GetNodeStatus(&relayNode, false)
 	apiNode := relayNode.ConvertToAPINode()
@@ -109,12 +87,10 @@ func createGateway(w http.ResponseWriter, r *http.Request) {
 		},
 		Origin: models.Dashboard,
 	})
-	host.IsStaticPort = true
-	logic.UpsertHost(host)
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(apiNode)
 	go func() {
-		if err := mq.NodeUpdate(&node); err != nil {
+		if err := mq.NodeUpdate(&relayNode); err != nil {
 			slog.Error("error publishing node update to node", "node", node.ID, "error", err)
 		}
 		mq.PublishPeerUpdate(false)
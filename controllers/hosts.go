@@ -150,6 +150,9 @@ func upgradeHost(w http.ResponseWriter, r *http.Request) {
 // @Router      /api/hosts [get]
 // @Tags        Hosts
 // @Security    oauth
+// @Param       country query string false "ISO country code of the public endpoint"
+// @Param       city query string false "City of the public endpoint"
+// @Param       asn query int false "Autonomous system of the public endpoint"
 // @Success     200 {array} models.ApiHost
 // @Failure     500 {object} models.ErrorResponse
 func getHosts(w http.ResponseWriter, r *http.Request) {
@@ -162,6 +165,9 @@ func getHosts(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if filter := logic.ParseGeoFilter(r.URL.Query()); filter.IsSet() {
+		currentHosts = logic.FilterHostsByLocation(currentHosts, filter)
+	}
 	apiHosts := logic.GetAllHostsAPI(currentHosts[:])
 	logger.Log(2, r.Header.Get("user"), "fetched all hosts")
 	logic.SortApiHosts(apiHosts[:])
@@ -622,7 +628,8 @@ func deleteHostFromNetwork(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	node, err := logic.UpdateHostNetwork(currHost, network, false)
+	logger.Log(1, "removing host", currHost.Name, "from network", network)
+	node, err := logic.RemoveHostFromNetwork(currHost, network, forceDelete)
 	if err != nil {
 		if node == nil && forceDelete {
 			// force cleanup the node
@@ -656,6 +663,14 @@ func deleteHostFromNetwork(w http.ResponseWriter, r *http.Request) {
 			logic.ReturnSuccessResponse(w, r, "force deleted daemon node successfully")
 			return
 		}
+		if node != nil {
+			logic.ReturnErrorResponse(
+				w,
+				r,
+				logic.FormatError(fmt.Errorf("failed to delete node"), "internal"),
+			)
+			return
+		}
 		logger.Log(
 			0,
 			r.Header.Get("user"),
@@ -667,25 +682,9 @@ func deleteHostFromNetwork(w http.ResponseWriter, r *http.Request) {
 		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
 		return
 	}
-	var gwClients []models.ExtClient
-	if node.IsIngressGateway {
-		gwClients = logic.GetGwExtclients(node.ID.String(), node.Network)
+	if servercfg.IsDNSMode() {
+		go logic.SetDNS()
 	}
-	logger.Log(1, "deleting node", node.ID.String(), "from host", currHost.Name)
-	if err := logic.DeleteNode(node, forceDelete); err != nil {
-		logic.ReturnErrorResponse(
-			w,
-			r,
-			logic.FormatError(fmt.Errorf("failed to delete node"), "internal"),
-		)
-		return
-	}
-	go func() {
-		mq.PublishMqUpdatesForDeletedNode(*node, true, gwClients)
-		if servercfg.IsDNSMode() {
-			logic.SetDNS()
-		}
-	}()
 	logic.LogEvent(&models.Event{
 		Action: models.RemoveHostFromNet,
 		Source: models.Subject{
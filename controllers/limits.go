@@ -46,7 +46,7 @@ func checkFreeTierLimits(limitChoice int, next http.Handler) http.HandlerFunc {
 				clients, cErr := logic.GetAllExtClients()
 				if (hErr != nil && !database.IsEmptyRecord(hErr)) ||
 					(cErr != nil && !database.IsEmptyRecord(cErr)) ||
-					len(hosts)+len(clients) >= logic.MachinesLimit {
+					logic.CountPersistentHosts(hosts)+len(clients) >= logic.MachinesLimit {
 					errorResponse.Message += "machines"
 					logic.ReturnErrorResponse(w, r, errorResponse)
 					return
@@ -496,6 +496,7 @@ func deleteNetwork(w http.ResponseWriter, r *http.Request) {
 	go logic.DeleteNetworkRoles(network)
 	go logic.DeleteAllNetworkTags(models.NetworkID(network))
 	go logic.DeleteNetworkPolicies(models.NetworkID(network))
+	go logic.RemoveNetworkDNSSync(network)
 	//delete network from allocated ip map
 	go logic.RemoveNetworkFromAllocatedIpMap(network)
 	go func() {
@@ -604,6 +605,11 @@ func createNetwork(w http.ResponseWriter, r *http.Request) {
 	logic.CreateDefaultAclNetworkPolicies(models.NetworkID(network.NetID))
 	logic.CreateDefaultTags(models.NetworkID(network.NetID))
 	logic.AddNetworkToAllocatedIpMap(network.NetID)
+	if servercfg.IsDNSMode() && len(network.DNSForwardRules) > 0 {
+		if err := logic.SetDNS(); err != nil {
+			slog.Error("failed to write dns forwarding rules", "network", network.NetID, "error", err)
+		}
+	}
 
 	go func() {
 		defaultHosts := logic.GetDefaultHosts()
@@ -677,7 +683,7 @@ func createNetwork(w http.ResponseWriter, r *http.Request) {
 // @Tags        Networks
 // @Security    oauth
 // @Param       networkname path string true "Network name"
-// @Param       body body models.Network true "Network details"
+// @Param       body body models.NetworkUpdate true "Network details"
 // @Produce     json
 // @Success     200 {object} models.Network
 // @Failure     400 {object} models.ErrorResponse
@@ -685,7 +691,7 @@ func updateNetwork(w http.ResponseWriter, r *http.Request) {
 
 	w.Header().Set("Content-Type", "application/json")
 
-	var payload models.Network
+	var payload models.NetworkUpdate
 
 	// we decode our body request params
 	err := json.NewDecoder(r.Body).Decode(&payload)
@@ -704,14 +710,35 @@ func updateNetwork(w http.ResponseWriter, r *http.Request) {
 	netNew := netOld
 	netNew.NameServers = payload.NameServers
 	netNew.DefaultACL = payload.DefaultACL
+	if payload.PresharedKeys != nil {
+		netNew.PresharedKeys = *payload.PresharedKeys
+		if netNew.PresharedKeys == "" {
+			netNew.PresharedKeys = "no"
+		}
+	}
+	if payload.ExtClientKeyRotation != nil {
+		if err = logic.ValidateExtClientKeyRotation(payload.ExtClientKeyRotation); err != nil {
+			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+			return
+		}
+		netNew.ExtClientKeyRotation = *payload.ExtClientKeyRotation
+	}
+	if payload.DNSForwardRules != nil {
+		netNew.DNSForwardRules = *payload.DNSForwardRules
+	}
 	_, _, _, err = logic.UpdateNetwork(&netOld, &netNew)
 	if err != nil {
 		slog.Info("failed to update network", "user", r.Header.Get("user"), "err", err)
 		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
 		return
 	}
+	if servercfg.IsDNSMode() {
+		if err := logic.SetDNS(); err != nil {
+			slog.Error("failed to write dns forwarding rules", "network", netNew.NetID, "error", err)
+		}
+	}
 	go mq.PublishPeerUpdate(false)
 	slog.Info("updated network", "network", payload.NetID, "user", r.Header.Get("user"))
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(payload)
+	json.NewEncoder(w).Encode(netNew)
 }
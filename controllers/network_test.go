@@ -4,7 +4,10 @@ import (
 	"context"
 	"github.com/gravitl/netmaker/db"
 	"github.com/gravitl/netmaker/schema"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"strings"
 	"testing"
 
 	"github.com/google/uuid"
@@ -59,6 +62,37 @@ func TestCreateNetwork(t *testing.T) {
 	_, err := logic.CreateNetwork(network)
 	assert.Nil(t, err)
 }
+func TestUpdateNetworkKeepsUnsetFields(t *testing.T) {
+	deleteAllNetworks()
+	network := models.Network{NetID: "skynet2", AddressRange: "10.12.0.0/24"}
+	network, err := logic.CreateNetwork(network)
+	assert.Nil(t, err)
+	network.PresharedKeys = "yes"
+	network.ExtClientKeyRotation = models.KeyRotationPolicy{Interval: 3600, Overlap: 60}
+	network.DNSForwardRules = []models.DNSForwardRule{{Domain: "corp.example", NameServers: []string{"10.12.0.53"}}}
+	assert.Nil(t, logic.SaveNetwork(&network))
+
+	update := func(body string) models.Network {
+		w := httptest.NewRecorder()
+		updateNetwork(w, httptest.NewRequest(http.MethodPut, "/api/networks/skynet2", strings.NewReader(body)))
+		assert.Equal(t, http.StatusOK, w.Code)
+		updated, err := logic.GetNetwork("skynet2")
+		assert.Nil(t, err)
+		return updated
+	}
+	// an older client only sends the settings it knows about
+	updated := update(`{"netid":"skynet2","defaultacl":"yes","dns_nameservers":["1.1.1.1"]}`)
+	assert.Equal(t, []string{"1.1.1.1"}, updated.NameServers)
+	assert.Equal(t, "yes", updated.PresharedKeys)
+	assert.Equal(t, network.ExtClientKeyRotation, updated.ExtClientKeyRotation)
+	assert.Equal(t, network.DNSForwardRules, updated.DNSForwardRules)
+
+	updated = update(`{"netid":"skynet2","defaultacl":"yes","presharedkeys":"no","dns_forward_rules":[]}`)
+	assert.Equal(t, "no", updated.PresharedKeys)
+	assert.Empty(t, updated.DNSForwardRules)
+	assert.Equal(t, network.ExtClientKeyRotation, updated.ExtClientKeyRotation)
+}
+
 func TestGetNetwork(t *testing.T) {
 	createNet()
 
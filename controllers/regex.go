@@ -6,11 +6,15 @@ import (
 )
 
 var (
-	errInvalidExtClientPubKey  = errors.New("incorrect client public key")
-	errInvalidExtClientID      = errors.New("node name must be alphanumderic and/or dashes and less that 15 chars")
-	errInvalidExtClientExtraIP = errors.New("client extra ip must be a valid cidr")
-	errInvalidExtClientDNS     = errors.New("client dns must be a valid ip address")
-	errDuplicateExtClientName  = errors.New("duplicate client name")
+	errInvalidExtClientPubKey     = errors.New("incorrect client public key")
+	errInvalidExtClientID         = errors.New("node name must be alphanumderic and/or dashes and less that 15 chars")
+	errInvalidExtClientExtraIP    = errors.New("client extra ip must be a valid cidr")
+	errInvalidExtClientDNS        = errors.New("client dns must be a valid ip address")
+	errDuplicateExtClientName     = errors.New("duplicate client name")
+	errExtClientQuotaForbidden    = errors.New("only admins can set client quotas and rate limits")
+	errExtClientLifetimeForbidden = errors.New("only admins can set client expiry and key rotation")
+	errExtClientQuotaSuspended    = errors.New("client is suspended until its quota period ends")
+	errGeoBlocked                 = errors.New("access from this country is not allowed")
 )
 
 // allow only dashes and alphaneumeric for ext client and node names
@@ -383,7 +383,7 @@ func authenticateUser(response http.ResponseWriter, request *http.Request) {
 				return
 			}
 			for _, client := range clients {
-				if client.OwnerID == username && !client.Enabled {
+				if client.OwnerID == username && !client.Enabled && !time.Now().Before(client.QuotaSuspendedUntil) {
 					slog.Info(
 						fmt.Sprintf(
 							"enabling ext client %s for user %s due to RAC autodisabling feature",
@@ -778,7 +778,7 @@ func createUser(w http.ResponseWriter, r *http.Request) {
 // @Router      /api/users/{username} [put]
 // @Tags        Users
 // @Param       username path string true "Username of the user to update"
-// @Param       body body models.User true "User details"
+// @Param       body body models.UserUpdate true "User details"
 // @Success     200 {object} models.User
 // @Failure     400 {object} models.ErrorResponse
 // @Failure     403 {object} models.ErrorResponse
@@ -807,14 +807,23 @@ func updateUser(w http.ResponseWriter, r *http.Request) {
 		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
 		return
 	}
-	var userchange models.User
+	var payload models.UserUpdate
 	// we decode our body request params
-	err = json.NewDecoder(r.Body).Decode(&userchange)
+	err = json.NewDecoder(r.Body).Decode(&payload)
 	if err != nil {
 		slog.Error("failed to decode body", "error ", err.Error())
 		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
 		return
 	}
+	userchange := payload.User
+	userchange.ExtClientQuota = user.ExtClientQuota
+	if payload.ExtClientQuota != nil {
+		userchange.ExtClientQuota = *payload.ExtClientQuota
+	}
+	userchange.ExtClientRateLimit = user.ExtClientRateLimit
+	if payload.ExtClientRateLimit != nil {
+		userchange.ExtClientRateLimit = *payload.ExtClientRateLimit
+	}
 	if user.UserName != userchange.UserName {
 		logic.ReturnErrorResponse(
 			w,
@@ -870,6 +879,12 @@ func updateUser(w http.ResponseWriter, r *http.Request) {
 			return
 
 		}
+		if user.ExtClientQuota != userchange.ExtClientQuota || user.ExtClientRateLimit != userchange.ExtClientRateLimit {
+			err = errors.New("user cannot update their own quota")
+			slog.Error("failed to update user", "caller", caller.UserName, "attempted to update user", username, "error", err)
+			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "forbidden"))
+			return
+		}
 		if servercfg.IsPro {
 			// user cannot update his own roles and groups
 			if len(user.NetworkRoles) != len(userchange.NetworkRoles) || !reflect.DeepEqual(user.NetworkRoles, userchange.NetworkRoles) {
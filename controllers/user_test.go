@@ -1,5 +1,18 @@
 package controller
 
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/logic"
+	"github.com/gravitl/netmaker/models"
+	"github.com/stretchr/testify/assert"
+)
+
 // TODO: Need Update Tests for New User Mgmt
 // func deleteAllUsers(t *testing.T) {
 // 	t.Helper()
@@ -359,3 +372,41 @@ package controller
 // 		assert.NotNil(t, jwt)
 // 	})
 // }
+
+func TestUpdateUserKeepsExtClientLimits(t *testing.T) {
+	logic.InitialiseRoles()
+	user := models.User{
+		UserName:           "quotauser",
+		Password:           "password",
+		PlatformRoleID:     models.AdminRole,
+		ExtClientQuota:     models.ExtClientQuota{Bytes: 1 << 30, Period: models.QuotaPeriodDay},
+		ExtClientRateLimit: models.ExtClientRateLimit{Upload: 1000, Download: 2000},
+	}
+	caller := models.User{UserName: "quotaadmin", Password: "password", PlatformRoleID: models.SuperAdminRole}
+	assert.Nil(t, logic.CreateUser(&caller))
+	assert.Nil(t, logic.CreateUser(&user))
+	defer func() {
+		_ = database.DeleteRecord(database.USERS_TABLE_NAME, caller.UserName)
+		_ = database.DeleteRecord(database.USERS_TABLE_NAME, user.UserName)
+	}()
+
+	update := func(body string) *models.User {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPut, "/api/users/quotauser", strings.NewReader(body))
+		r.Header.Set("user", caller.UserName)
+		updateUser(w, mux.SetURLVars(r, map[string]string{"username": "quotauser"}))
+		assert.Equal(t, http.StatusOK, w.Code)
+		updated, err := logic.GetUser("quotauser")
+		assert.Nil(t, err)
+		return updated
+	}
+	// a profile update leaves the limits out
+	updated := update(`{"username":"quotauser","display_name":"Quota User","platform_role_id":"admin"}`)
+	assert.Equal(t, "Quota User", updated.DisplayName)
+	assert.Equal(t, user.ExtClientQuota, updated.ExtClientQuota)
+	assert.Equal(t, user.ExtClientRateLimit, updated.ExtClientRateLimit)
+
+	updated = update(`{"username":"quotauser","platform_role_id":"admin","extclient_quota":{}}`)
+	assert.Equal(t, models.ExtClientQuota{}, updated.ExtClientQuota)
+	assert.Equal(t, user.ExtClientRateLimit, updated.ExtClientRateLimit)
+}
@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/gravitl/netmaker/logger"
+	"github.com/gravitl/netmaker/metrics"
 	"github.com/gravitl/netmaker/servercfg"
 )
 
@@ -174,6 +175,7 @@ func CreateTable(tableName string) error {
 func Insert(key string, value string, tableName string) error {
 	dbMutex.Lock()
 	defer dbMutex.Unlock()
+	defer metrics.ObserveDB("insert", tableName, time.Now())
 	if key != "" && value != "" {
 		return getCurrentDB()[INSERT].(func(string, string, string) error)(key, value, tableName)
 	} else {
@@ -185,6 +187,7 @@ func Insert(key string, value string, tableName string) error {
 func DeleteRecord(tableName string, key string) error {
 	dbMutex.Lock()
 	defer dbMutex.Unlock()
+	defer metrics.ObserveDB("delete", tableName, time.Now())
 	return getCurrentDB()[DELETE].(func(string, string) error)(tableName, key)
 }
 
@@ -219,6 +222,7 @@ func FetchRecord(tableName string, key string) (string, error) {
 func FetchRecords(tableName string) (map[string]string, error) {
 	dbMutex.RLock()
 	defer dbMutex.RUnlock()
+	defer metrics.ObserveDB("fetch", tableName, time.Now())
 	return getCurrentDB()[FETCH_ALL].(func(string) (map[string]string, error))(tableName)
 }
 
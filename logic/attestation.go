@@ -0,0 +1,369 @@
+package logic
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/x509"
+	b64 "encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/db"
+	"github.com/gravitl/netmaker/models"
+	"github.com/gravitl/netmaker/schema"
+	"github.com/gravitl/netmaker/servercfg"
+	"go.mozilla.org/pkcs7"
+	"golang.org/x/exp/slices"
+)
+
+const (
+	gcpIdentityIssuer  = "https://accounts.google.com"
+	azureMetadataHost  = "metadata.azure.com"
+	azureTimeStampForm = "01/02/06 15:04:05 -0700"
+)
+
+// AttestationErrors - errors returned while verifying cloud instance identity documents
+var AttestationErrors = struct {
+	NotConfigured   error
+	InvalidDocument error
+	NoKeyMatched    error
+	InstanceReused  error
+	DocumentReused  error
+}{
+	NotConfigured:   errors.New("instance identity attestation is not configured"),
+	InvalidDocument: errors.New("instance identity document could not be verified"),
+	NoKeyMatched:    errors.New("no enrollment key matches the instance identity"),
+	InstanceReused:  errors.New("instance identity already used by another host"),
+	DocumentReused:  errors.New("instance identity document was already used"),
+}
+
+var (
+	attestationDigestMutex = &sync.Mutex{}
+	// claimedAttestations - identity documents with a registration in progress
+	claimedAttestations = make(map[string]struct{})
+)
+
+type awsIdentityDocument struct {
+	AccountID  string `json:"accountId"`
+	Region     string `json:"region"`
+	InstanceID string `json:"instanceId"`
+}
+
+type gcpIdentityClaims struct {
+	jwt.RegisteredClaims
+	Google struct {
+		ComputeEngine struct {
+			ProjectID  string `json:"project_id"`
+			Zone       string `json:"zone"`
+			InstanceID string `json:"instance_id"`
+		} `json:"compute_engine"`
+	} `json:"google"`
+}
+
+type azureAttestedData struct {
+	SubscriptionID string `json:"subscriptionId"`
+	VMID           string `json:"vmId"`
+	TimeStamp      struct {
+		CreatedOn string `json:"createdOn"`
+		ExpiresOn string `json:"expiresOn"`
+	} `json:"timeStamp"`
+}
+
+// VerifyInstanceIdentity - verifies a signed cloud instance identity document against the
+// certificates configured for the provider and returns the identity it attests to
+func VerifyInstanceIdentity(provider models.CloudProvider, document string) (models.CloudIdentity, error) {
+	certs, err := getAttestationCerts(provider)
+	if err != nil {
+		return models.CloudIdentity{}, err
+	}
+	switch provider {
+	case models.AWSCloud:
+		return verifyAWSIdentity(document, certs)
+	case models.GCPCloud:
+		return verifyGCPIdentity(document, certs)
+	case models.AzureCloud:
+		return verifyAzureIdentity(document, certs, time.Now())
+	}
+	return models.CloudIdentity{}, fmt.Errorf("%w: unknown provider %s", AttestationErrors.InvalidDocument, provider)
+}
+
+// GetAttestedEnrollmentKey - finds a valid enrollment key whose attestation rules match the identity
+func GetAttestedEnrollmentKey(identity models.CloudIdentity) (*models.EnrollmentKey, error) {
+	keys, err := GetAllEnrollmentKeys()
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].Value < keys[j].Value })
+	for i := range keys {
+		if attestationMatches(keys[i].Attestation, identity) && keys[i].IsValid() {
+			return &keys[i], nil
+		}
+	}
+	return nil, AttestationErrors.NoKeyMatched
+}
+
+// CheckInstanceIdentityReuse - ensures an instance identity is only ever used to register a single host
+// and claims the identity document until ReleaseInstanceIdentity, so a replay can't race the registration.
+// a document becomes used once its registration is recorded in the enrollment key usage, after that only
+// the host it registered can present it again while that host exists, as aws documents never change for an instance
+func CheckInstanceIdentityReuse(identity models.CloudIdentity, hostID string) error {
+	ctx := db.WithContext(context.TODO())
+	instanceID := instanceUsageID(identity)
+	usages, err := (&schema.EnrollmentKeyUsage{
+		InstanceID: instanceID,
+	}).ListByInstance(ctx)
+	if err != nil {
+		return err
+	}
+	for _, usage := range usages {
+		if usage.HostID != hostID && usageHostExists(usage) {
+			return AttestationErrors.InstanceReused
+		}
+	}
+	if identity.Digest == "" {
+		return AttestationErrors.InvalidDocument
+	}
+	attestationDigestMutex.Lock()
+	defer attestationDigestMutex.Unlock()
+	if _, ok := claimedAttestations[identity.Digest]; ok {
+		return AttestationErrors.DocumentReused
+	}
+	usages, err = (&schema.EnrollmentKeyUsage{AttestationDigest: identity.Digest}).ListByAttestationDigest(ctx)
+	if err != nil {
+		return err
+	}
+	for _, usage := range usages {
+		if (usage.HostID != hostID || usage.InstanceID != instanceID) && usageHostExists(usage) {
+			return AttestationErrors.DocumentReused
+		}
+	}
+	claimedAttestations[identity.Digest] = struct{}{}
+	return nil
+}
+
+// usageHostExists - checks whether the host of an enrollment key usage is still registered,
+// the usage of a deleted host doesn't hold the instance so a reinstalled netclient can register again
+func usageHostExists(usage schema.EnrollmentKeyUsage) bool {
+	_, err := GetHost(usage.HostID)
+	return err == nil || !database.IsEmptyRecord(err)
+}
+
+// ReleaseInstanceIdentity - drops the claim on an identity document once its registration is done,
+// a successful registration has recorded the document in the enrollment key usage by then
+func ReleaseInstanceIdentity(identity models.CloudIdentity) {
+	attestationDigestMutex.Lock()
+	defer attestationDigestMutex.Unlock()
+	delete(claimedAttestations, identity.Digest)
+}
+
+// attestationDigest - hashes the signature of an identity document
+func attestationDigest(signature []byte) string {
+	sum := sha256.Sum256(signature)
+	return hex.EncodeToString(sum[:])
+}
+
+func instanceUsageID(identity models.CloudIdentity) string {
+	return string(identity.Provider) + ":" + identity.Account + ":" + identity.InstanceID
+}
+
+func attestationMatches(a *models.EnrollmentKeyAttestation, identity models.CloudIdentity) bool {
+	if a == nil || a.Provider != identity.Provider {
+		return false
+	}
+	if !slices.Contains(a.Accounts, identity.Account) {
+		return false
+	}
+	if len(a.Regions) > 0 {
+		matched := false
+		for _, region := range a.Regions {
+			// gcp reports zones, so a region also matches all of its zones
+			if identity.Region == region || strings.HasPrefix(identity.Region, region+"-") {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	// gcp identity tokens can be minted for any audience, only accept the configured one
+	if a.Provider == models.GCPCloud {
+		return a.Audience != "" && a.Audience == identity.Audience
+	}
+	return true
+}
+
+func verifyAWSIdentity(document string, certs []*x509.Certificate) (models.CloudIdentity, error) {
+	p7, err := parsePKCS7(document)
+	if err != nil {
+		return models.CloudIdentity{}, err
+	}
+	// the aws signature does not embed the signing certificate,
+	// only ever trust the configured regional certificates
+	p7.Certificates = certs
+	if err = p7.Verify(); err != nil {
+		return models.CloudIdentity{}, fmt.Errorf("%w: %s", AttestationErrors.InvalidDocument, err.Error())
+	}
+	var doc awsIdentityDocument
+	if err = json.Unmarshal(p7.Content, &doc); err != nil || doc.AccountID == "" || doc.InstanceID == "" {
+		return models.CloudIdentity{}, fmt.Errorf("%w: malformed aws identity document", AttestationErrors.InvalidDocument)
+	}
+	return models.CloudIdentity{
+		Provider:   models.AWSCloud,
+		Account:    doc.AccountID,
+		Region:     doc.Region,
+		InstanceID: doc.InstanceID,
+		Digest:     pkcs7Digest(p7),
+	}, nil
+}
+
+func verifyGCPIdentity(document string, certs []*x509.Certificate) (models.CloudIdentity, error) {
+	var lastErr error
+	for _, cert := range certs {
+		claims := gcpIdentityClaims{}
+		token, err := jwt.ParseWithClaims(strings.TrimSpace(document), &claims, func(token *jwt.Token) (interface{}, error) {
+			if _, ok := token.Method.(*jwt.SigningMethodRSA); !ok {
+				return nil, fmt.Errorf("unexpected signing method %v", token.Header["alg"])
+			}
+			return cert.PublicKey, nil
+		})
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		compute := claims.Google.ComputeEngine
+		if !claims.VerifyIssuer(gcpIdentityIssuer, true) || claims.ExpiresAt == nil ||
+			compute.ProjectID == "" || compute.InstanceID == "" {
+			return models.CloudIdentity{}, fmt.Errorf("%w: malformed gcp identity token", AttestationErrors.InvalidDocument)
+		}
+		identity := models.CloudIdentity{
+			Provider:   models.GCPCloud,
+			Account:    compute.ProjectID,
+			Region:     compute.Zone,
+			InstanceID: compute.InstanceID,
+			Digest:     attestationDigest([]byte(token.Signature)),
+		}
+		if len(claims.Audience) > 0 {
+			identity.Audience = claims.Audience[0]
+		}
+		return identity, nil
+	}
+	return models.CloudIdentity{}, fmt.Errorf("%w: %v", AttestationErrors.InvalidDocument, lastErr)
+}
+
+func verifyAzureIdentity(document string, certs []*x509.Certificate, now time.Time) (models.CloudIdentity, error) {
+	p7, err := parsePKCS7(document)
+	if err != nil {
+		return models.CloudIdentity{}, err
+	}
+	signer := p7.GetOnlySigner()
+	if signer == nil || !isAzureMetadataCert(signer) {
+		return models.CloudIdentity{}, fmt.Errorf("%w: unexpected azure signer", AttestationErrors.InvalidDocument)
+	}
+	truststore := x509.NewCertPool()
+	for _, cert := range certs {
+		truststore.AddCert(cert)
+	}
+	if err = p7.VerifyWithChainAtTime(truststore, now); err != nil {
+		return models.CloudIdentity{}, fmt.Errorf("%w: %s", AttestationErrors.InvalidDocument, err.Error())
+	}
+	var doc azureAttestedData
+	if err = json.Unmarshal(p7.Content, &doc); err != nil || doc.SubscriptionID == "" || doc.VMID == "" {
+		return models.CloudIdentity{}, fmt.Errorf("%w: malformed azure attested data", AttestationErrors.InvalidDocument)
+	}
+	expiresOn, err := time.Parse(azureTimeStampForm, doc.TimeStamp.ExpiresOn)
+	if err != nil || now.After(expiresOn) {
+		return models.CloudIdentity{}, fmt.Errorf("%w: azure attested data expired", AttestationErrors.InvalidDocument)
+	}
+	return models.CloudIdentity{
+		Provider:   models.AzureCloud,
+		Account:    doc.SubscriptionID,
+		InstanceID: doc.VMID,
+		Digest:     pkcs7Digest(p7),
+	}, nil
+}
+
+// pkcs7Digest - the digest of the signatures of a verified pkcs7 document
+func pkcs7Digest(p7 *pkcs7.PKCS7) string {
+	signatures := []byte{}
+	for _, signer := range p7.Signers {
+		signatures = append(signatures, signer.EncryptedDigest...)
+	}
+	return attestationDigest(signatures)
+}
+
+func isAzureMetadataCert(cert *x509.Certificate) bool {
+	names := append([]string{cert.Subject.CommonName}, cert.DNSNames...)
+	for _, name := range names {
+		if name == azureMetadataHost || strings.HasSuffix(name, "."+azureMetadataHost) {
+			return true
+		}
+	}
+	return false
+}
+
+// parsePKCS7 - parses a base64 or PEM encoded pkcs7 signature
+func parsePKCS7(document string) (*pkcs7.PKCS7, error) {
+	var der []byte
+	if block, _ := pem.Decode([]byte(document)); block != nil {
+		der = block.Bytes
+	} else {
+		var err error
+		der, err = b64.StdEncoding.DecodeString(strings.Join(strings.Fields(document), ""))
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", AttestationErrors.InvalidDocument, err.Error())
+		}
+	}
+	p7, err := pkcs7.Parse(der)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", AttestationErrors.InvalidDocument, err.Error())
+	}
+	return p7, nil
+}
+
+// getAttestationCerts - loads the PEM certificates configured for a provider
+func getAttestationCerts(provider models.CloudProvider) ([]*x509.Certificate, error) {
+	dir := servercfg.GetAttestationCertsDir()
+	if dir == "" {
+		return nil, AttestationErrors.NotConfigured
+	}
+	files, err := os.ReadDir(filepath.Join(dir, string(provider)))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", AttestationErrors.NotConfigured, err.Error())
+	}
+	certs := []*x509.Certificate{}
+	for _, f := range files {
+		if f.IsDir() {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, string(provider), f.Name()))
+		if err != nil {
+			return nil, err
+		}
+		for block, rest := pem.Decode(data); block != nil; block, rest = pem.Decode(rest) {
+			if block.Type != "CERTIFICATE" {
+				continue
+			}
+			cert, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse certificate %s: %w", f.Name(), err)
+			}
+			certs = append(certs, cert)
+		}
+	}
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("%w: no certificates for %s", AttestationErrors.NotConfigured, provider)
+	}
+	return certs, nil
+}
@@ -0,0 +1,190 @@
+package logic
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	b64 "encoding/base64"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
+	"github.com/gravitl/netmaker/models"
+	"github.com/stretchr/testify/assert"
+	"go.mozilla.org/pkcs7"
+)
+
+func newTestCert(t *testing.T, name string, parent *x509.Certificate, parentKey *rsa.PrivateKey) (*x509.Certificate, *rsa.PrivateKey) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.Nil(t, err)
+	serial, _ := rand.Int(rand.Reader, big.NewInt(1<<62))
+	tmpl := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: name},
+		DNSNames:              []string{name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour * 24),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  parent == nil,
+	}
+	if parent == nil {
+		parent, parentKey = tmpl, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, &key.PublicKey, parentKey)
+	assert.Nil(t, err)
+	cert, err := x509.ParseCertificate(der)
+	assert.Nil(t, err)
+	return cert, key
+}
+
+func writeTestCerts(t *testing.T, dir string, provider models.CloudProvider, certs ...*x509.Certificate) {
+	assert.Nil(t, os.MkdirAll(filepath.Join(dir, string(provider)), 0700))
+	data := []byte{}
+	for _, cert := range certs {
+		data = append(data, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})...)
+	}
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, string(provider), "certs.pem"), data, 0600))
+}
+
+func signTestPKCS7(t *testing.T, content string, cert *x509.Certificate, key *rsa.PrivateKey, chain ...*x509.Certificate) string {
+	sd, err := pkcs7.NewSignedData([]byte(content))
+	assert.Nil(t, err)
+	assert.Nil(t, sd.AddSignerChain(cert, key, chain, pkcs7.SignerInfoConfig{}))
+	der, err := sd.Finish()
+	assert.Nil(t, err)
+	return b64.StdEncoding.EncodeToString(der)
+}
+
+func TestVerifyInstanceIdentity(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("ATTESTATION_CERTS_DIR", dir)
+
+	t.Run("Not_Configured", func(t *testing.T) {
+		_, err := VerifyInstanceIdentity(models.AWSCloud, "")
+		assert.ErrorIs(t, err, AttestationErrors.NotConfigured)
+	})
+
+	t.Run("AWS", func(t *testing.T) {
+		awsCert, awsKey := newTestCert(t, "aws", nil, nil)
+		otherCert, otherKey := newTestCert(t, "aws", nil, nil)
+		writeTestCerts(t, dir, models.AWSCloud, awsCert)
+		doc := `{"accountId":"123456789012","region":"eu-west-1","instanceId":"i-0abc"}`
+		identity, err := VerifyInstanceIdentity(models.AWSCloud, signTestPKCS7(t, doc, awsCert, awsKey))
+		assert.Nil(t, err)
+		assert.NotEmpty(t, identity.Digest)
+		identity.Digest = ""
+		assert.Equal(t, models.CloudIdentity{
+			Provider:   models.AWSCloud,
+			Account:    "123456789012",
+			Region:     "eu-west-1",
+			InstanceID: "i-0abc",
+		}, identity)
+		_, err = VerifyInstanceIdentity(models.AWSCloud, signTestPKCS7(t, doc, otherCert, otherKey))
+		assert.ErrorIs(t, err, AttestationErrors.InvalidDocument)
+	})
+
+	t.Run("GCP", func(t *testing.T) {
+		gcpCert, gcpKey := newTestCert(t, "gcp", nil, nil)
+		_, otherKey := newTestCert(t, "gcp", nil, nil)
+		writeTestCerts(t, dir, models.GCPCloud, gcpCert)
+		claims := gcpIdentityClaims{
+			RegisteredClaims: jwt.RegisteredClaims{
+				Issuer:    gcpIdentityIssuer,
+				Audience:  jwt.ClaimStrings{"https://netmaker.example.com"},
+				ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+			},
+		}
+		claims.Google.ComputeEngine.ProjectID = "my-project"
+		claims.Google.ComputeEngine.Zone = "us-central1-a"
+		claims.Google.ComputeEngine.InstanceID = "42"
+		token, err := jwt.NewWithClaims(jwt.SigningMethodRS256, claims).SignedString(gcpKey)
+		assert.Nil(t, err)
+		identity, err := VerifyInstanceIdentity(models.GCPCloud, token)
+		assert.Nil(t, err)
+		assert.Equal(t, "my-project", identity.Account)
+		assert.Equal(t, "us-central1-a", identity.Region)
+		assert.Equal(t, "https://netmaker.example.com", identity.Audience)
+		forged, _ := jwt.NewWithClaims(jwt.SigningMethodRS256, claims).SignedString(otherKey)
+		_, err = VerifyInstanceIdentity(models.GCPCloud, forged)
+		assert.ErrorIs(t, err, AttestationErrors.InvalidDocument)
+		claims.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Hour))
+		expired, _ := jwt.NewWithClaims(jwt.SigningMethodRS256, claims).SignedString(gcpKey)
+		_, err = VerifyInstanceIdentity(models.GCPCloud, expired)
+		assert.ErrorIs(t, err, AttestationErrors.InvalidDocument)
+	})
+
+	t.Run("Azure", func(t *testing.T) {
+		rootCert, rootKey := newTestCert(t, "azure root", nil, nil)
+		signerCert, signerKey := newTestCert(t, "eastus.metadata.azure.com", rootCert, rootKey)
+		otherCert, otherKey := newTestCert(t, "example.com", rootCert, rootKey)
+		writeTestCerts(t, dir, models.AzureCloud, rootCert)
+		expires := time.Now().Add(time.Hour).UTC().Format(azureTimeStampForm)
+		doc := `{"vmId":"vm-1","subscriptionId":"sub-1","timeStamp":{"expiresOn":"` + expires + `"}}`
+		identity, err := VerifyInstanceIdentity(models.AzureCloud, signTestPKCS7(t, doc, signerCert, signerKey))
+		assert.Nil(t, err)
+		assert.Equal(t, "sub-1", identity.Account)
+		assert.Equal(t, "vm-1", identity.InstanceID)
+		_, err = VerifyInstanceIdentity(models.AzureCloud, signTestPKCS7(t, doc, otherCert, otherKey))
+		assert.ErrorIs(t, err, AttestationErrors.InvalidDocument)
+	})
+}
+
+func TestAttestationMatches(t *testing.T) {
+	identity := models.CloudIdentity{Provider: models.GCPCloud, Account: "my-project", Region: "us-central1-a"}
+	assert.False(t, attestationMatches(nil, identity))
+	assert.False(t, attestationMatches(&models.EnrollmentKeyAttestation{
+		Provider: models.GCPCloud, Accounts: []string{"my-project"}, Regions: []string{"us-central1"},
+	}, identity))
+	assert.False(t, attestationMatches(&models.EnrollmentKeyAttestation{
+		Provider: models.AWSCloud, Accounts: []string{"my-project"},
+	}, identity))
+	assert.False(t, attestationMatches(&models.EnrollmentKeyAttestation{
+		Provider: models.GCPCloud, Accounts: []string{"other-project"},
+	}, identity))
+	assert.False(t, attestationMatches(&models.EnrollmentKeyAttestation{
+		Provider: models.GCPCloud, Accounts: []string{"my-project"}, Regions: []string{"europe-west1"},
+	}, identity))
+	assert.False(t, attestationMatches(&models.EnrollmentKeyAttestation{
+		Provider: models.GCPCloud, Accounts: []string{"my-project"}, Audience: "https://netmaker.example.com",
+	}, identity))
+}
+
+func TestCheckInstanceIdentityReuse(t *testing.T) {
+	identity := models.CloudIdentity{Provider: models.AWSCloud, Account: "123456789012", InstanceID: uuid.NewString(), Digest: uuid.NewString()}
+	key := &models.EnrollmentKey{Value: "reuse-key"}
+	host := &models.Host{ID: uuid.New(), Name: "reuse-host"}
+	assert.Nil(t, UpsertHost(host))
+	assert.Nil(t, CheckInstanceIdentityReuse(identity, host.ID.String()))
+	// a replay is rejected while the first registration is in progress
+	assert.ErrorIs(t, CheckInstanceIdentityReuse(identity, host.ID.String()), AttestationErrors.DocumentReused)
+	// a failed registration leaves the document unused
+	ReleaseInstanceIdentity(identity)
+	assert.Nil(t, CheckInstanceIdentityReuse(identity, host.ID.String()))
+	assert.Nil(t, RecordEnrollmentKeyUsage(key, host, "203.0.113.7", &identity))
+	ReleaseInstanceIdentity(identity)
+	// once recorded, only the registered host of the instance can present the document again
+	assert.Nil(t, CheckInstanceIdentityReuse(identity, host.ID.String()))
+	ReleaseInstanceIdentity(identity)
+	assert.ErrorIs(t, CheckInstanceIdentityReuse(identity, uuid.NewString()), AttestationErrors.InstanceReused)
+	other := identity
+	other.InstanceID = uuid.NewString()
+	assert.ErrorIs(t, CheckInstanceIdentityReuse(other, host.ID.String()), AttestationErrors.DocumentReused)
+	identity.Digest = uuid.NewString()
+	assert.ErrorIs(t, CheckInstanceIdentityReuse(identity, uuid.NewString()), AttestationErrors.InstanceReused)
+	assert.Nil(t, CheckInstanceIdentityReuse(identity, host.ID.String()))
+	ReleaseInstanceIdentity(identity)
+	// once the host is deleted the instance may register again, e.g. after a netclient reinstall
+	assert.Nil(t, RemoveHost(host, false))
+	assert.Nil(t, CheckInstanceIdentityReuse(identity, uuid.NewString()))
+	ReleaseInstanceIdentity(identity)
+	assert.ErrorIs(t, (&models.EnrollmentKeyAttestation{
+		Provider: models.GCPCloud, Accounts: []string{"my-project"},
+	}).Validate(), models.ErrInvalidKeyAttestation)
+}
@@ -358,6 +358,14 @@ func UpdateUser(userchange, user *models.User) (*models.User, error) {
 		}
 	}
 
+	if err := userchange.ExtClientQuota.Validate(); err != nil {
+		return userchange, err
+	}
+	if err := userchange.ExtClientRateLimit.Validate(); err != nil {
+		return userchange, err
+	}
+	user.ExtClientQuota = userchange.ExtClientQuota
+	user.ExtClientRateLimit = userchange.ExtClientRateLimit
 	user.UserGroups = userchange.UserGroups
 	user.NetworkRoles = userchange.NetworkRoles
 	AddGlobalNetRolesToAdmins(user)
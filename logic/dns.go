@@ -1,22 +1,37 @@
 package logic
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"os"
 	"regexp"
 	"sort"
+	"strconv"
+	"strings"
 
 	validator "github.com/go-playground/validator/v10"
 	"github.com/gravitl/netmaker/database"
 	"github.com/gravitl/netmaker/logger"
 	"github.com/gravitl/netmaker/models"
+	"github.com/gravitl/netmaker/servercfg"
 	"github.com/txn2/txeh"
 )
 
-// SetDNS - sets the dns on file
+var errDNSRecordNeedsEmbeddedDNS = errors.New("SRV, MX and TXT records and wildcards are only served by the embedded DNS server, set EMBEDDED_DNS to use them")
+
+var errInvalidDNSName = errors.New("invalid input. Only uppercase letters (A-Z), lowercase letters (a-z), numbers (0-9), minus sign (-), underscores (_) and dots (.) are allowed, a leading *. matches any name below it")
+
+// SetDNS - sets the dns on file, nothing is written when the embedded DNS server answers the entries,
+// the changes are also pushed to the external DNS servers of networks with dynamic DNS
 func SetDNS() error {
+	InvalidateDNSSnapshot()
+	TriggerDNSSync()
+	if servercfg.IsEmbeddedDNS() {
+		return nil
+	}
 	hostfile, err := txeh.NewHosts(&txeh.HostsConfig{})
 	if err != nil {
 		return err
@@ -29,17 +44,18 @@ func SetDNS() error {
 
 	for _, net := range networks {
 		corefilestring = corefilestring + net.NetID + " "
+		// tag names resolve to several nodes, which a hosts file can't hold, so only the embedded server answers them
 		dns, err := GetDNS(net.NetID)
 		if err != nil && !database.IsEmptyRecord(err) {
 			return err
 		}
-		for _, entry := range dns {
-			hostfile.AddHost(entry.Address, entry.Name)
+		for _, entry := range GetHostsDNSEntries(dns) {
+			addHostsEntry(hostfile, entry)
 		}
 	}
 	dns := GetExtclientDNS()
 	for _, entry := range dns {
-		hostfile.AddHost(entry.Address, entry.Name)
+		addHostsEntry(hostfile, entry)
 	}
 	if corefilestring == "" {
 		corefilestring = "example.com"
@@ -55,7 +71,8 @@ func SetDNS() error {
 		err = SetCorefile(corefilestring)
 	}
 	*/
-	return err
+	// keeps the forwarding rules of new, changed and deleted networks in the Corefile
+	return SetCorefile(".")
 }
 
 // GetDNS - gets the DNS of a current network
@@ -74,6 +91,16 @@ func GetDNS(network string) ([]models.DNSEntry, error) {
 	return dns, nil
 }
 
+// addHostsEntry - writes the addresses of an entry to the hosts file, one line per address family
+func addHostsEntry(hostfile *txeh.Hosts, entry models.DNSEntry) {
+	if entry.Address != "" {
+		hostfile.AddHost(entry.Address, entry.Name)
+	}
+	if entry.Address6 != "" {
+		hostfile.AddHost(entry.Address6, entry.Name)
+	}
+}
+
 // GetExtclientDNS - gets all extclients dns entries
 func GetExtclientDNS() []models.DNSEntry {
 	extclients, err := GetAllExtClients()
@@ -156,7 +183,7 @@ func GetCustomDNS(network string) ([]models.DNSEntry, error) {
 	return dns, err
 }
 
-// SetCorefile - sets the core file of the system
+// SetCorefile - sets the core file of the system, forwarding rules get their own server blocks
 func SetCorefile(domains string) error {
 	dir, err := os.Getwd()
 	if err != nil {
@@ -174,10 +201,13 @@ func SetCorefile(domains string) error {
     hosts /root/dnsconfig/netmaker.hosts {
 	fallthrough	
     }
-    forward . 8.8.8.8 8.8.4.4
+    forward . ` + strings.Join(servercfg.GetDNSUpstreams(), " ") + `
     log
 }
 `
+	for _, rule := range getAllDNSForwardRules() {
+		corefile += fmt.Sprintf("%s {\n    forward . %s\n    log\n}\n", rule.Domain, strings.Join(rule.NameServers, " "))
+	}
 	err = os.WriteFile(dir+"/config/dnsconfig/Corefile", []byte(corefile), 0644)
 	if err != nil {
 		return err
@@ -222,6 +252,58 @@ func GetDNSEntryNum(domain string, network string) (int, error) {
 	return num, nil
 }
 
+// isDNSNameTaken - checks if the name of an entry holds a record it can not sit next to,
+// a name holds one record of each type and a CNAME can not share its name with other records,
+// the current record of an update is left out
+func isDNSNameTaken(entry models.DNSEntry, current *models.DNSEntry) bool {
+	entries, err := GetDNS(entry.Network)
+	if err != nil && !database.IsEmptyRecord(err) {
+		return true
+	}
+	for _, existing := range entries {
+		if !strings.EqualFold(existing.Name, entry.Name) {
+			continue
+		}
+		if current != nil && isSameDNSRecord(existing, *current) {
+			continue
+		}
+		if existing.RecordType() == models.DNSRecordCNAME || entry.RecordType() == models.DNSRecordCNAME {
+			return true
+		}
+		if existing.RecordType() == entry.RecordType() &&
+			(entry.RecordType() == models.DNSRecordA || dnsEntryValue(existing) == dnsEntryValue(entry)) {
+			return true
+		}
+	}
+	return false
+}
+
+// isSameDNSRecord - checks if two entries are the same record, a name can hold a single address record
+// but several SRV, MX and TXT records that differ in their data
+func isSameDNSRecord(a, b models.DNSEntry) bool {
+	return strings.EqualFold(a.Name, b.Name) && a.Network == b.Network &&
+		a.RecordType() == b.RecordType() && dnsEntryValue(a) == dnsEntryValue(b)
+}
+
+// dnsEntryValue - the data telling records of the same name and type apart,
+// empty for address records and aliases as a name holds only one of them
+func dnsEntryValue(entry models.DNSEntry) string {
+	target := strings.ToLower(strings.TrimSuffix(entry.Target, "."))
+	switch entry.RecordType() {
+	case models.DNSRecordSRV:
+		return fmt.Sprintf("%d %d %d %s", entry.Priority, entry.Weight, entry.Port, target)
+	case models.DNSRecordMX:
+		return fmt.Sprintf("%d %s", entry.Priority, target)
+	case models.DNSRecordTXT:
+		quoted := make([]string, 0, len(entry.Text))
+		for _, text := range entry.Text {
+			quoted = append(quoted, strconv.Quote(text))
+		}
+		return strings.Join(quoted, " ")
+	}
+	return ""
+}
+
 // SortDNSEntrys - Sorts slice of DNSEnteys by their Address alphabetically with numbers first
 func SortDNSEntrys(unsortedDNSEntrys []models.DNSEntry) {
 	sort.Slice(unsortedDNSEntrys, func(i, j int) bool {
@@ -231,14 +313,88 @@ func SortDNSEntrys(unsortedDNSEntrys []models.DNSEntry) {
 
 // IsNetworkNameValid - checks if a netid of a network uses valid characters
 func IsDNSEntryValid(d string) bool {
-	re := regexp.MustCompile(`^[A-Za-z0-9-.]+$`)
+	re := regexp.MustCompile(`^(\*\.)?[A-Za-z0-9-._]+$`)
 	return re.MatchString(d)
 }
 
+// validateDNSRecord - checks the fields a record of the entry's type needs
+func validateDNSRecord(entry models.DNSEntry) error {
+	recordType := entry.RecordType()
+	if recordType != models.DNSRecordA && (entry.Address != "" || entry.Address6 != "") {
+		return fmt.Errorf("a %s record can not have an address", recordType)
+	}
+	if entry.Target != "" && (strings.Contains(entry.Target, "*") || !IsDNSEntryValid(entry.Target)) {
+		return errors.New("invalid target " + entry.Target)
+	}
+	// hosts files only hold addresses and the aliases of them
+	if !servercfg.IsEmbeddedDNS() && (entry.IsWildcard() ||
+		(recordType != models.DNSRecordA && recordType != models.DNSRecordCNAME)) {
+		return errDNSRecordNeedsEmbeddedDNS
+	}
+	switch recordType {
+	case models.DNSRecordA:
+		if entry.Address == "" && entry.Address6 == "" {
+			return errors.New("an A record needs an address or address6")
+		}
+	case models.DNSRecordCNAME:
+		if entry.Target == "" {
+			return errors.New("a CNAME record needs a target")
+		}
+		if strings.EqualFold(strings.TrimSuffix(entry.Target, "."), entry.Name) {
+			return errors.New("a CNAME record can not point at itself")
+		}
+	case models.DNSRecordSRV:
+		if entry.Target == "" || entry.Port == 0 {
+			return errors.New("a SRV record needs a target and a port")
+		}
+	case models.DNSRecordMX:
+		if entry.Target == "" {
+			return errors.New("a MX record needs a target")
+		}
+	case models.DNSRecordTXT:
+		if len(entry.Text) == 0 {
+			return errors.New("a TXT record needs text")
+		}
+	}
+	return nil
+}
+
+// GetHostsDNSEntries - gets the entries a hosts file can hold, address records and aliases of them,
+// other types and wildcards are only answered by the embedded DNS server
+func GetHostsDNSEntries(entries []models.DNSEntry) []models.DNSEntry {
+	byName := make(map[string]models.DNSEntry, len(entries))
+	for _, entry := range entries {
+		if !entry.IsWildcard() {
+			byName[strings.ToLower(entry.Name)] = entry
+		}
+	}
+	hostsEntries := []models.DNSEntry{}
+	for _, entry := range entries {
+		if entry.IsWildcard() {
+			continue
+		}
+		target := entry
+		// follow aliases to the address record, giving up on loops
+		for hops := 0; target.RecordType() == models.DNSRecordCNAME && hops < 8; hops++ {
+			target = byName[strings.ToLower(strings.TrimSuffix(target.Target, "."))]
+		}
+		if target.RecordType() != models.DNSRecordA || (target.Address == "" && target.Address6 == "") {
+			continue
+		}
+		hostsEntries = append(hostsEntries, models.DNSEntry{
+			Name:     entry.Name,
+			Network:  entry.Network,
+			Address:  target.Address,
+			Address6: target.Address6,
+		})
+	}
+	return hostsEntries
+}
+
 // ValidateDNSCreate - checks if an entry is valid
 func ValidateDNSCreate(entry models.DNSEntry) error {
 	if !IsDNSEntryValid(entry.Name) {
-		return errors.New("invalid input. Only uppercase letters (A-Z), lowercase letters (a-z), numbers (0-9), minus sign (-) and dots (.) are allowed")
+		return errInvalidDNSName
 	}
 	v := validator.New()
 
@@ -248,8 +404,7 @@ func ValidateDNSCreate(entry models.DNSEntry) error {
 	})
 
 	_ = v.RegisterValidation("name_unique", func(fl validator.FieldLevel) bool {
-		num, err := GetDNSEntryNum(entry.Name, entry.Network)
-		return err == nil && num == 0
+		return !isDNSNameTaken(entry, nil)
 	})
 
 	_ = v.RegisterValidation("network_exists", func(fl validator.FieldLevel) bool {
@@ -262,8 +417,9 @@ func ValidateDNSCreate(entry models.DNSEntry) error {
 		for _, e := range err.(validator.ValidationErrors) {
 			logger.Log(1, e.Error())
 		}
+		return err
 	}
-	return err
+	return validateDNSRecord(entry)
 }
 
 // ValidateDNSUpdate - validates a DNS update
@@ -277,12 +433,11 @@ func ValidateDNSUpdate(change models.DNSEntry, entry models.DNSEntry) error {
 	})
 
 	_ = v.RegisterValidation("name_unique", func(fl validator.FieldLevel) bool {
-		//if name & net not changing name we are good
-		if change.Name == entry.Name && change.Network == entry.Network {
+		//if the record isn't changing we are good
+		if isSameDNSRecord(change, entry) {
 			return true
 		}
-		num, err := GetDNSEntryNum(change.Name, change.Network)
-		return err == nil && num == 0
+		return !isDNSNameTaken(change, &entry)
 	})
 	_ = v.RegisterValidation("network_exists", func(fl validator.FieldLevel) bool {
 		_, err := GetParentNetwork(change.Network)
@@ -295,24 +450,63 @@ func ValidateDNSUpdate(change models.DNSEntry, entry models.DNSEntry) error {
 		for _, e := range err.(validator.ValidationErrors) {
 			logger.Log(1, e.Error())
 		}
+		return err
 	}
-	return err
+	if !IsDNSEntryValid(change.Name) {
+		return errInvalidDNSName
+	}
+	return validateDNSRecord(change)
 }
 
-// DeleteDNS - deletes a DNS entry
+// DeleteDNS - deletes the DNS entries of a name, of every record type
 func DeleteDNS(domain string, network string) error {
-	key, err := GetRecordKey(domain, network)
-	if err != nil {
+	return DeleteDNSRecord(domain, network, "")
+}
+
+// DeleteDNSRecord - deletes the DNS entries of a name and record type, an empty type deletes all of them
+func DeleteDNSRecord(domain string, network string, recordType models.DNSRecordType) error {
+	if _, err := GetRecordKey(domain, network); err != nil {
 		return err
 	}
-	err = database.DeleteRecord(database.DNS_TABLE_NAME, key)
-	return err
+	collection, err := database.FetchRecords(database.DNS_TABLE_NAME)
+	if err != nil && !database.IsEmptyRecord(err) {
+		return err
+	}
+	defer InvalidateDNSSnapshot()
+	for key, value := range collection {
+		var entry models.DNSEntry
+		if err := json.Unmarshal([]byte(value), &entry); err != nil {
+			continue
+		}
+		if entry.Network != network || entry.Name != domain || (recordType != "" && entry.RecordType() != recordType) {
+			continue
+		}
+		if err := database.DeleteRecord(database.DNS_TABLE_NAME, key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dnsEntryKey - the database key of an entry, address records keep the name and network key
+// and other records add their type and data, so a name can hold several SRV, MX and TXT records
+func dnsEntryKey(entry models.DNSEntry) (string, error) {
+	if entry.RecordType() == models.DNSRecordA {
+		return GetRecordKey(entry.Name, entry.Network)
+	}
+	id := entry.Name + "#" + string(entry.RecordType())
+	if value := dnsEntryValue(entry); value != "" {
+		// the data may hold quotes and spaces, only a digest of it goes into the key
+		sum := sha256.Sum256([]byte(value))
+		id += "#" + hex.EncodeToString(sum[:8])
+	}
+	return GetRecordKey(id, entry.Network)
 }
 
 // CreateDNS - creates a DNS entry
 func CreateDNS(entry models.DNSEntry) (models.DNSEntry, error) {
 
-	k, err := GetRecordKey(entry.Name, entry.Network)
+	k, err := dnsEntryKey(entry)
 	if err != nil {
 		return models.DNSEntry{}, err
 	}
@@ -323,5 +517,6 @@ func CreateDNS(entry models.DNSEntry) (models.DNSEntry, error) {
 	}
 
 	err = database.Insert(k, string(data), database.DNS_TABLE_NAME)
+	InvalidateDNSSnapshot()
 	return entry, err
 }
@@ -0,0 +1,372 @@
+package logic
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gravitl/netmaker/db"
+	"github.com/gravitl/netmaker/models"
+	"github.com/gravitl/netmaker/schema"
+	"golang.org/x/exp/slog"
+)
+
+// DNSFilterRefreshInterval - how often the blocklists of the filter policies are loaded again
+const DNSFilterRefreshInterval = 6 * time.Hour
+
+// dnsFilterClientTTL - how long the client behind an address is remembered
+const dnsFilterClientTTL = time.Minute
+
+// maxDNSFilterClients - the most addresses remembered, expired ones are dropped first
+const maxDNSFilterClients = 4096
+
+// maxDNSBlocklistSize - the most read from a single blocklist
+const maxDNSBlocklistSize = 64 << 20
+
+// ErrInvalidDNSFilterPolicy - a filter policy without a name, blocklists or anyone it applies to
+var ErrInvalidDNSFilterPolicy = errors.New("invalid dns filter policy")
+
+type dnsFilter struct {
+	policy  schema.DNSFilterPolicy
+	blocked map[string]struct{}
+	allowed map[string]struct{}
+}
+
+type dnsFilterClient struct {
+	id      string
+	network string
+	address string
+	groups  map[models.UserGroupID]struct{}
+	expires time.Time
+}
+
+var (
+	dnsFilters       []dnsFilter
+	dnsBlocklists    = make(map[string]map[string]struct{})
+	dnsFilterClients = make(map[string]dnsFilterClient)
+	dnsFilterStats   = make(map[string]*models.DNSFilterStats)
+	dnsFilterMutex   = &sync.RWMutex{}
+	dnsFilterLoadMu  = &sync.Mutex{}
+)
+
+// ManageDNSFilters - loads the filter policies and refreshes their blocklists periodically
+func ManageDNSFilters(ctx context.Context) {
+	LoadDNSFilters()
+	ticker := time.NewTicker(DNSFilterRefreshInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			LoadDNSFilters()
+		}
+	}
+}
+
+// LoadDNSFilters - loads the enabled filter policies and their blocklists,
+// a blocklist that fails to load keeps the domains it had last time
+func LoadDNSFilters() {
+	dnsFilterLoadMu.Lock()
+	defer dnsFilterLoadMu.Unlock()
+	policies, err := (&schema.DNSFilterPolicy{}).ListAll(db.WithContext(context.TODO()))
+	if err != nil {
+		slog.Error("failed to list dns filter policies", "error", err)
+		return
+	}
+	blocklists := make(map[string]map[string]struct{})
+	var filters []dnsFilter
+	for _, policy := range policies {
+		if !policy.Enabled {
+			continue
+		}
+		filter := dnsFilter{
+			policy:  policy,
+			blocked: make(map[string]struct{}),
+			allowed: make(map[string]struct{}),
+		}
+		for _, source := range policy.Blocklists {
+			domains, ok := blocklists[source]
+			if !ok {
+				domains, err = loadDNSBlocklist(source)
+				if err != nil {
+					slog.Error("failed to load dns blocklist", "policy", policy.Name, "source", source, "error", err)
+					dnsFilterMutex.RLock()
+					domains = dnsBlocklists[source]
+					dnsFilterMutex.RUnlock()
+				}
+				blocklists[source] = domains
+			}
+			for domain := range domains {
+				filter.blocked[domain] = struct{}{}
+			}
+		}
+		for _, domain := range policy.Allowlist {
+			filter.allowed[normalizeDNSFilterDomain(domain)] = struct{}{}
+		}
+		filters = append(filters, filter)
+	}
+	dnsFilterMutex.Lock()
+	dnsFilters = filters
+	dnsBlocklists = blocklists
+	dnsFilterMutex.Unlock()
+}
+
+// ValidateDNSFilterPolicy - checks a filter policy and normalises its allowlist
+func ValidateDNSFilterPolicy(policy *schema.DNSFilterPolicy) error {
+	if strings.TrimSpace(policy.Name) == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidDNSFilterPolicy)
+	}
+	if len(policy.Blocklists) == 0 {
+		return fmt.Errorf("%w: at least one blocklist is required", ErrInvalidDNSFilterPolicy)
+	}
+	for _, source := range policy.Blocklists {
+		if isDNSBlocklistURL(source) {
+			if _, err := url.ParseRequestURI(source); err != nil {
+				return fmt.Errorf("%w: invalid blocklist url %s", ErrInvalidDNSFilterPolicy, source)
+			}
+			continue
+		}
+		if info, err := os.Stat(source); err != nil || info.IsDir() {
+			return fmt.Errorf("%w: blocklist file %s not found", ErrInvalidDNSFilterPolicy, source)
+		}
+	}
+	for i, domain := range policy.Allowlist {
+		domain = normalizeDNSFilterDomain(domain)
+		if domain == "" || strings.HasPrefix(domain, "*") || !IsDNSEntryValid(domain) {
+			return fmt.Errorf("%w: invalid allowlist domain %s", ErrInvalidDNSFilterPolicy, policy.Allowlist[i])
+		}
+		policy.Allowlist[i] = domain
+	}
+	if len(policy.Networks) == 0 && len(policy.UserGroups) == 0 {
+		return fmt.Errorf("%w: assign the policy to a network or user group", ErrInvalidDNSFilterPolicy)
+	}
+	for _, network := range policy.Networks {
+		if _, err := GetNetwork(network); err != nil {
+			return fmt.Errorf("%w: network %s not found", ErrInvalidDNSFilterPolicy, network)
+		}
+	}
+	for _, group := range policy.UserGroups {
+		if err := IsGroupValid(models.UserGroupID(group)); err != nil {
+			return fmt.Errorf("%w: user group %s not found", ErrInvalidDNSFilterPolicy, group)
+		}
+	}
+	return nil
+}
+
+// GetDNSFilterStats - gets the queries and blocks of every client the filter policies were checked for
+func GetDNSFilterStats() []models.DNSFilterStats {
+	dnsFilterMutex.RLock()
+	stats := make([]models.DNSFilterStats, 0, len(dnsFilterStats))
+	for _, s := range dnsFilterStats {
+		stats = append(stats, *s)
+	}
+	dnsFilterMutex.RUnlock()
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Network != stats[j].Network {
+			return stats[i].Network < stats[j].Network
+		}
+		return stats[i].ClientID < stats[j].ClientID
+	})
+	return stats
+}
+
+// filterDNSQuery - counts a query of a client and tells whether it is blocked, a name is blocked
+// when a policy of the client lists it and no policy of the client allows it, names in the netmaker zones never are
+func filterDNSQuery(name string, ip net.IP, inZone bool) bool {
+	dnsFilterMutex.RLock()
+	filtering := len(dnsFilters) > 0
+	dnsFilterMutex.RUnlock()
+	if !filtering || ip == nil {
+		return false
+	}
+	client, ok := getDNSFilterClient(ip)
+	if !ok {
+		return false
+	}
+	name = normalizeDNSFilterDomain(name)
+	dnsFilterMutex.Lock()
+	defer dnsFilterMutex.Unlock()
+	blocked := false
+	if !inZone {
+		for _, filter := range dnsFilters {
+			if !dnsFilterApplies(filter.policy, client) {
+				continue
+			}
+			if matchDNSFilterDomain(filter.allowed, name) {
+				blocked = false
+				break
+			}
+			if matchDNSFilterDomain(filter.blocked, name) {
+				blocked = true
+			}
+		}
+	}
+	stats, ok := dnsFilterStats[client.address]
+	if !ok || stats.ClientID != client.id {
+		stats = &models.DNSFilterStats{ClientID: client.id, Network: client.network, Address: client.address}
+		dnsFilterStats[client.address] = stats
+	}
+	stats.Queries++
+	stats.LastQueryAt = time.Now()
+	if blocked {
+		stats.Blocked++
+		stats.LastBlocked = name
+		stats.LastBlockedAt = stats.LastQueryAt
+	}
+	return blocked
+}
+
+func dnsFilterApplies(policy schema.DNSFilterPolicy, client dnsFilterClient) bool {
+	if slices.Contains(policy.Networks, client.network) {
+		return true
+	}
+	for _, group := range policy.UserGroups {
+		if _, ok := client.groups[models.UserGroupID(group)]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// getDNSFilterClient - finds the ext client or node an address belongs to, with the user groups of the ext client's owner,
+// only addresses of a network's range are looked up and only in that network
+func getDNSFilterClient(ip net.IP) (dnsFilterClient, bool) {
+	network := getDNSSnapshot().clientNetwork(ip)
+	if network == nil {
+		return dnsFilterClient{}, false
+	}
+	address := ip.String()
+	dnsFilterMutex.RLock()
+	client, ok := dnsFilterClients[address]
+	dnsFilterMutex.RUnlock()
+	now := time.Now()
+	if ok && now.Before(client.expires) {
+		return client, client.id != ""
+	}
+	client = dnsFilterClient{address: address, expires: now.Add(dnsFilterClientTTL)}
+	if extclients, err := GetNetworkExtClients(network.NetID); err == nil {
+		for _, extclient := range extclients {
+			if extclient.Address != address && extclient.Address6 != address {
+				continue
+			}
+			client.id, client.network = extclient.ClientID, extclient.Network
+			if extclient.OwnerID != "" {
+				if user, err := GetUser(extclient.OwnerID); err == nil {
+					client.groups = user.UserGroups
+				}
+			}
+			break
+		}
+	}
+	if client.id == "" {
+		if nodes, err := GetNetworkNodes(network.NetID); err == nil {
+			for _, node := range nodes {
+				if (node.Address.IP != nil && node.Address.IP.Equal(ip)) || (node.Address6.IP != nil && node.Address6.IP.Equal(ip)) {
+					client.id, client.network = node.ID.String(), node.Network
+					break
+				}
+			}
+		}
+	}
+	dnsFilterMutex.Lock()
+	if len(dnsFilterClients) >= maxDNSFilterClients {
+		pruneDNSFilterClients(now)
+	}
+	dnsFilterClients[address] = client
+	dnsFilterMutex.Unlock()
+	return client, client.id != ""
+}
+
+// pruneDNSFilterClients - drops the expired addresses, or all of them when none expired yet,
+// the caller holds dnsFilterMutex
+func pruneDNSFilterClients(now time.Time) {
+	for address, client := range dnsFilterClients {
+		if !now.Before(client.expires) {
+			delete(dnsFilterClients, address)
+		}
+	}
+	if len(dnsFilterClients) >= maxDNSFilterClients {
+		dnsFilterClients = make(map[string]dnsFilterClient)
+	}
+}
+
+func isDNSBlocklistURL(source string) bool {
+	return strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://")
+}
+
+// loadDNSBlocklist - reads the domains of a blocklist file or url
+func loadDNSBlocklist(source string) (map[string]struct{}, error) {
+	var list io.ReadCloser
+	if isDNSBlocklistURL(source) {
+		client := &http.Client{Timeout: 30 * time.Second}
+		resp, err := client.Get(source)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return nil, fmt.Errorf("unexpected status %s", resp.Status)
+		}
+		list = resp.Body
+	} else {
+		f, err := os.Open(source)
+		if err != nil {
+			return nil, err
+		}
+		list = f
+	}
+	defer list.Close()
+	return parseDNSBlocklist(io.LimitReader(list, maxDNSBlocklistSize))
+}
+
+// parseDNSBlocklist - reads hosts-format lines (0.0.0.0 domain) or one domain per line, comments start with #
+func parseDNSBlocklist(list io.Reader) (map[string]struct{}, error) {
+	domains := make(map[string]struct{})
+	scanner := bufio.NewScanner(list)
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if net.ParseIP(fields[0]) != nil {
+			fields = fields[1:]
+		}
+		for _, field := range fields {
+			domain := normalizeDNSFilterDomain(field)
+			// hosts files map these to loopback, they are not meant to be blocked
+			if domain == "" || !strings.Contains(domain, ".") || domain == "localhost.localdomain" || !IsDNSEntryValid(domain) {
+				continue
+			}
+			domains[domain] = struct{}{}
+		}
+	}
+	return domains, scanner.Err()
+}
+
+// matchDNSFilterDomain - tells whether a domain or one of the domains above it is in the set
+func matchDNSFilterDomain(domains map[string]struct{}, name string) bool {
+	for name != "" {
+		if _, ok := domains[name]; ok {
+			return true
+		}
+		_, name, _ = strings.Cut(name, ".")
+	}
+	return false
+}
+
+func normalizeDNSFilterDomain(domain string) string {
+	return strings.ToLower(strings.Trim(strings.TrimSpace(domain), "."))
+}
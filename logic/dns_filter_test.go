@@ -0,0 +1,140 @@
+package logic
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/db"
+	"github.com/gravitl/netmaker/models"
+	"github.com/gravitl/netmaker/schema"
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseDNSBlocklist(t *testing.T) {
+	domains, err := parseDNSBlocklist(strings.NewReader(`# malware hosts
+127.0.0.1 localhost
+::1 localhost ip6-localhost
+0.0.0.0 Malware.Example.com  # trailing comment
+0.0.0.0 ads.example.net tracker.example.net
+phishing.example.org.
+not a domain!
+`))
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]struct{}{
+		"malware.example.com":  {},
+		"ads.example.net":      {},
+		"tracker.example.net":  {},
+		"phishing.example.org": {},
+	}, domains)
+	assert.True(t, matchDNSFilterDomain(domains, "cdn.ads.example.net"))
+	assert.False(t, matchDNSFilterDomain(domains, "example.net"))
+}
+
+func TestFilterDNSQuery(t *testing.T) {
+	database.InitializeDatabase()
+	defer database.CloseDB()
+	ctx := db.WithContext(context.TODO())
+	network := models.Network{NetID: "filternet", AddressRange: "10.110.0.0/24"}
+	assert.Nil(t, SaveNetwork(&network))
+	defer func() {
+		_ = database.DeleteRecord(database.NETWORKS_TABLE_NAME, network.NetID)
+	}()
+	remote := models.ExtClient{ClientID: "laptop", Network: "filternet", Address: "10.110.0.20"}
+	assert.Nil(t, SaveExtClient(&remote))
+	defer DeleteExtClient(remote.Network, remote.ClientID)
+
+	blocklist := filepath.Join(t.TempDir(), "hosts")
+	assert.Nil(t, os.WriteFile(blocklist, []byte("0.0.0.0 malware.example.com\n0.0.0.0 ads.example.net\n"), 0600))
+	policy := schema.DNSFilterPolicy{
+		ID:         "filter-test",
+		Name:       "malware",
+		Blocklists: []string{blocklist},
+		Allowlist:  []string{"Good.Ads.Example.Net."},
+		Networks:   []string{"filternet"},
+		Enabled:    true,
+	}
+	assert.Nil(t, ValidateDNSFilterPolicy(&policy))
+	assert.Equal(t, "good.ads.example.net", policy.Allowlist[0])
+	assert.Nil(t, policy.Create(ctx))
+	defer func() {
+		_ = policy.Delete(ctx)
+		LoadDNSFilters()
+	}()
+	LoadDNSFilters()
+
+	client := net.ParseIP("10.110.0.20")
+	t.Run("Blocked", func(t *testing.T) {
+		resp := ResolveDNS(new(dns.Msg).SetQuestion("www.malware.example.com.", dns.TypeA), client)
+		assert.Equal(t, dns.RcodeNameError, resp.Rcode)
+		assert.True(t, filterDNSQuery("ads.example.net.", client, false))
+		// the allowlist overrides the blocklist
+		assert.False(t, filterDNSQuery("good.ads.example.net.", client, false))
+		assert.False(t, filterDNSQuery("example.com.", client, false))
+		// names of the netmaker zones are never blocked
+		assert.False(t, filterDNSQuery("malware.example.com.", client, true))
+	})
+	t.Run("OtherClients", func(t *testing.T) {
+		assert.False(t, filterDNSQuery("malware.example.com.", net.ParseIP("10.111.0.5"), false))
+		assert.False(t, filterDNSQuery("malware.example.com.", nil, false))
+		// addresses outside the networks are not looked up or remembered
+		dnsFilterMutex.RLock()
+		_, ok := dnsFilterClients["10.111.0.5"]
+		dnsFilterMutex.RUnlock()
+		assert.False(t, ok)
+	})
+	t.Run("Pruning", func(t *testing.T) {
+		dnsFilterMutex.Lock()
+		dnsFilterClients = make(map[string]dnsFilterClient)
+		for i := 0; i < maxDNSFilterClients; i++ {
+			address := fmt.Sprintf("10.110.%d.%d", i/256, i%256)
+			dnsFilterClients[address] = dnsFilterClient{address: address, expires: time.Now().Add(-time.Second)}
+		}
+		dnsFilterMutex.Unlock()
+		_, ok := getDNSFilterClient(net.ParseIP("10.110.0.21"))
+		assert.False(t, ok)
+		dnsFilterMutex.RLock()
+		assert.Len(t, dnsFilterClients, 1)
+		dnsFilterMutex.RUnlock()
+	})
+	t.Run("Stats", func(t *testing.T) {
+		var stats models.DNSFilterStats
+		for _, s := range GetDNSFilterStats() {
+			if s.ClientID == "laptop" {
+				stats = s
+			}
+		}
+		assert.Equal(t, "filternet", stats.Network)
+		assert.Equal(t, uint64(5), stats.Queries)
+		assert.Equal(t, uint64(2), stats.Blocked)
+		assert.Equal(t, "ads.example.net", stats.LastBlocked)
+	})
+	t.Run("Disabled", func(t *testing.T) {
+		policy.Enabled = false
+		assert.Nil(t, policy.Update(ctx))
+		LoadDNSFilters()
+		assert.False(t, filterDNSQuery("malware.example.com.", client, false))
+	})
+	t.Run("Invalid", func(t *testing.T) {
+		invalid := []schema.DNSFilterPolicy{
+			{Blocklists: []string{blocklist}, Networks: []string{"filternet"}},
+			{Name: "none", Networks: []string{"filternet"}},
+			{Name: "missing", Blocklists: []string{"/does/not/exist"}, Networks: []string{"filternet"}},
+			{Name: "unassigned", Blocklists: []string{blocklist}},
+			{Name: "network", Blocklists: []string{blocklist}, Networks: []string{"missingnet"}},
+			{Name: "allow", Blocklists: []string{blocklist}, Allowlist: []string{"*.example.com"}, Networks: []string{"filternet"}},
+		}
+		for _, p := range invalid {
+			assert.ErrorIs(t, ValidateDNSFilterPolicy(&p), ErrInvalidDNSFilterPolicy, p.Name)
+		}
+		url := schema.DNSFilterPolicy{Name: "url", Blocklists: []string{"https://example.com/hosts"}, Networks: []string{"filternet"}}
+		assert.Nil(t, ValidateDNSFilterPolicy(&url))
+	})
+}
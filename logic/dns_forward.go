@@ -0,0 +1,180 @@
+package logic
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/models"
+	"github.com/gravitl/netmaker/servercfg"
+	"github.com/miekg/dns"
+	"golang.org/x/exp/slog"
+)
+
+// GetGlobalDNSForwardRules - parses the server wide conditional forwarding rules, invalid rules are skipped
+func GetGlobalDNSForwardRules() []models.DNSForwardRule {
+	rules, _ := parseGlobalDNSForwardRules()
+	return rules
+}
+
+// CheckGlobalDNSForwardRules - warns about the server wide forwarding rules that are skipped, run once at startup
+func CheckGlobalDNSForwardRules() {
+	_, invalid := parseGlobalDNSForwardRules()
+	for _, raw := range invalid {
+		slog.Warn("skipping invalid DNS forwarding rule", "rule", raw)
+	}
+}
+
+// parseGlobalDNSForwardRules - parses the server wide forwarding rules, returning the invalid ones apart
+func parseGlobalDNSForwardRules() (rules []models.DNSForwardRule, invalid []string) {
+	for _, raw := range strings.Split(servercfg.GetDNSForwardRules(), ",") {
+		domain, servers, ok := strings.Cut(strings.TrimSpace(raw), "=")
+		if !ok {
+			continue
+		}
+		rule := models.DNSForwardRule{Domain: strings.TrimSpace(domain)}
+		for _, ns := range strings.Split(servers, ";") {
+			if ns = strings.TrimSpace(ns); ns != "" {
+				rule.NameServers = append(rule.NameServers, ns)
+			}
+		}
+		if err := rule.Validate(); err != nil {
+			invalid = append(invalid, raw)
+			continue
+		}
+		rules = append(rules, rule)
+	}
+	return rules, invalid
+}
+
+// ValidateDNSForwardRules - checks the forwarding rules of a network, a domain may only be forwarded once
+// and not to other nameservers than another network forwards it to, the Corefile forwards for every network
+func ValidateDNSForwardRules(netID string, rules []models.DNSForwardRule) error {
+	domains := make(map[string]struct{}, len(rules))
+	for i := range rules {
+		if err := rules[i].Validate(); err != nil {
+			return fmt.Errorf("%w: %s", err, rules[i].Domain)
+		}
+		domain := strings.ToLower(dns.Fqdn(rules[i].Domain))
+		if _, ok := domains[domain]; ok {
+			return errors.New("duplicate dns forwarding rule for " + rules[i].Domain)
+		}
+		domains[domain] = struct{}{}
+	}
+	if len(rules) == 0 {
+		return nil
+	}
+	networks, err := GetNetworks()
+	if err != nil && !database.IsEmptyRecord(err) {
+		return err
+	}
+	for _, network := range networks {
+		if network.NetID == netID {
+			continue
+		}
+		for _, other := range network.DNSForwardRules {
+			for _, rule := range rules {
+				if strings.EqualFold(dns.Fqdn(rule.Domain), dns.Fqdn(other.Domain)) && !slices.Equal(rule.NameServers, other.NameServers) {
+					return fmt.Errorf("%w: %s is forwarded to other nameservers by network %s", models.ErrInvalidDNSForwardRule, rule.Domain, network.NetID)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// GetDNSForwardRules - gets the forwarding rules of a network followed by the server wide ones,
+// a network rule wins over a server wide rule for the same domain
+func GetDNSForwardRules(network *models.Network) []models.DNSForwardRule {
+	var rules []models.DNSForwardRule
+	seen := make(map[string]struct{})
+	add := func(rule models.DNSForwardRule) {
+		domain := strings.ToLower(dns.Fqdn(rule.Domain))
+		if _, ok := seen[domain]; ok {
+			return
+		}
+		seen[domain] = struct{}{}
+		rules = append(rules, rule)
+	}
+	if network != nil {
+		for _, rule := range network.DNSForwardRules {
+			add(rule)
+		}
+	}
+	for _, rule := range GetGlobalDNSForwardRules() {
+		add(rule)
+	}
+	return rules
+}
+
+// getAllDNSForwardRules - gets the rules of every network and the server wide rules, one per domain,
+// a network rule wins over a server wide rule like in GetDNSForwardRules and
+// networks forwarding the same domain agree on its nameservers
+func getAllDNSForwardRules() []models.DNSForwardRule {
+	var rules []models.DNSForwardRule
+	seen := make(map[string]struct{})
+	add := func(rule models.DNSForwardRule) {
+		domain := strings.ToLower(dns.Fqdn(rule.Domain))
+		if _, ok := seen[domain]; ok {
+			return
+		}
+		seen[domain] = struct{}{}
+		rules = append(rules, rule)
+	}
+	networks, _ := GetNetworks()
+	for _, network := range networks {
+		for _, rule := range network.DNSForwardRules {
+			add(rule)
+		}
+	}
+	for _, rule := range GetGlobalDNSForwardRules() {
+		add(rule)
+	}
+	return rules
+}
+
+// findDNSForwardRule - gets the rule with the longest domain covering a name
+func findDNSForwardRule(rules []models.DNSForwardRule, name string) (models.DNSForwardRule, bool) {
+	var found models.DNSForwardRule
+	var length int
+	for _, rule := range rules {
+		domain := strings.ToLower(dns.Fqdn(rule.Domain))
+		if dns.IsSubDomain(domain, name) && len(domain) > length {
+			found, length = rule, len(domain)
+		}
+	}
+	return found, length > 0
+}
+
+// getDNSUpstreams - gets the resolvers a name is forwarded to: a matching forwarding rule,
+// else the nameservers of the client's network, else the server wide upstreams
+func getDNSUpstreams(name string, client net.IP) []string {
+	network := getDNSSnapshot().clientNetwork(client)
+	var upstreams []string
+	if rule, ok := findDNSForwardRule(GetDNSForwardRules(network), name); ok {
+		upstreams = rule.NameServers
+	} else if network != nil && len(network.NameServers) > 0 {
+		upstreams = network.NameServers
+	} else {
+		upstreams = servercfg.GetDNSUpstreams()
+	}
+	self := servercfg.GetCoreDNSAddr()
+	var servers []string
+	for _, upstream := range upstreams {
+		host := upstream
+		if h, _, err := net.SplitHostPort(upstream); err == nil {
+			host = h
+		} else {
+			upstream = net.JoinHostPort(upstream, "53")
+		}
+		// networks often push the netmaker server itself to their hosts, forwarding there would loop
+		if host == self {
+			continue
+		}
+		servers = append(servers, upstream)
+	}
+	return servers
+}
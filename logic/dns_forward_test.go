@@ -0,0 +1,82 @@
+package logic
+
+import (
+	"net"
+	"testing"
+
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDNSForwardRules(t *testing.T) {
+	database.InitializeDatabase()
+	defer database.CloseDB()
+	t.Setenv("DNS_UPSTREAMS", "9.9.9.9, 149.112.112.112")
+	t.Setenv("DNS_FORWARD_RULES", "corp.local=10.0.0.53;10.0.0.54:5353, bad=,lab.internal=not-an-ip")
+	t.Setenv("COREDNS_ADDR", "10.105.0.1")
+	network := models.Network{
+		NetID:        "fwdnet",
+		AddressRange: "10.105.0.0/24",
+		NameServers:  []string{"10.105.0.1", "10.105.0.2"},
+		DNSForwardRules: []models.DNSForwardRule{
+			{Domain: "eu.corp.local", NameServers: []string{"10.1.0.53"}},
+		},
+	}
+	assert.Nil(t, SaveNetwork(&network))
+	defer func() {
+		_ = database.DeleteRecord(database.NETWORKS_TABLE_NAME, network.NetID)
+	}()
+
+	t.Run("Validate", func(t *testing.T) {
+		assert.Nil(t, ValidateDNSForwardRules(network.NetID, network.DNSForwardRules))
+		assert.ErrorIs(t, ValidateDNSForwardRules("othernet", []models.DNSForwardRule{{Domain: "corp.local"}}), models.ErrInvalidDNSForwardRule)
+		assert.ErrorIs(t, ValidateDNSForwardRules("othernet", []models.DNSForwardRule{{Domain: "corp local", NameServers: []string{"10.0.0.53"}}}), models.ErrInvalidDNSForwardRule)
+		assert.NotNil(t, ValidateDNSForwardRules("othernet", []models.DNSForwardRule{
+			{Domain: "corp.local", NameServers: []string{"10.0.0.53"}},
+			{Domain: "CORP.local.", NameServers: []string{"[fd00::53]:53"}},
+		}))
+		// other networks may forward a domain only to the same nameservers
+		assert.Nil(t, ValidateDNSForwardRules("othernet", network.DNSForwardRules))
+		assert.ErrorIs(t, ValidateDNSForwardRules("othernet", []models.DNSForwardRule{
+			{Domain: "EU.corp.local", NameServers: []string{"10.2.0.53"}},
+		}), models.ErrInvalidDNSForwardRule)
+	})
+	t.Run("GlobalRules", func(t *testing.T) {
+		assert.Equal(t, []models.DNSForwardRule{
+			{Domain: "corp.local", NameServers: []string{"10.0.0.53", "10.0.0.54:5353"}},
+		}, GetGlobalDNSForwardRules())
+		assert.Len(t, GetDNSForwardRules(&network), 2)
+	})
+	t.Run("NetworkRuleWins", func(t *testing.T) {
+		override := models.Network{
+			NetID:        "fwdnet2",
+			AddressRange: "10.106.0.0/24",
+			DNSForwardRules: []models.DNSForwardRule{
+				{Domain: "CORP.local", NameServers: []string{"10.3.0.53"}},
+			},
+		}
+		assert.Nil(t, SaveNetwork(&override))
+		defer func() {
+			_ = database.DeleteRecord(database.NETWORKS_TABLE_NAME, override.NetID)
+		}()
+		want := models.DNSForwardRule{Domain: "CORP.local", NameServers: []string{"10.3.0.53"}}
+		rule, ok := findDNSForwardRule(GetDNSForwardRules(&override), "dc1.corp.local.")
+		assert.True(t, ok)
+		assert.Equal(t, want, rule)
+		rule, ok = findDNSForwardRule(getAllDNSForwardRules(), "dc1.corp.local.")
+		assert.True(t, ok)
+		assert.Equal(t, want, rule)
+	})
+	t.Run("Upstreams", func(t *testing.T) {
+		client := net.ParseIP("10.105.0.20")
+		// the most specific rule wins, a network rule over a global one
+		assert.Equal(t, []string{"10.1.0.53:53"}, getDNSUpstreams("dc1.eu.corp.local.", client))
+		assert.Equal(t, []string{"10.0.0.53:53", "10.0.0.54:5353"}, getDNSUpstreams("dc1.corp.local.", client))
+		// the network's nameservers without the netmaker server itself
+		assert.Equal(t, []string{"10.105.0.2:53"}, getDNSUpstreams("example.com.", client))
+		assert.Equal(t, []string{"9.9.9.9:53", "149.112.112.112:53"}, getDNSUpstreams("example.com.", net.ParseIP("192.0.2.1")))
+		// network rules only apply to the network's clients
+		assert.Equal(t, []string{"10.0.0.53:53", "10.0.0.54:5353"}, getDNSUpstreams("dc1.eu.corp.local.", nil))
+	})
+}
@@ -0,0 +1,95 @@
+package logic
+
+import (
+	"math/big"
+	"net"
+	"strings"
+
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/models"
+	"github.com/miekg/dns"
+)
+
+// GetReverseDNSZones - gets the in-addr.arpa and ip6.arpa zones covering the address ranges of a network,
+// a range off an octet or nibble boundary is split into the zones of the next boundary
+func GetReverseDNSZones(network *models.Network) []string {
+	var zones []string
+	for _, cidr := range []string{network.AddressRange, network.AddressRange6} {
+		_, ipnet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			continue
+		}
+		zones = append(zones, reverseDNSZones(ipnet)...)
+	}
+	return zones
+}
+
+func reverseDNSZones(ipnet *net.IPNet) []string {
+	ones, bits := ipnet.Mask.Size()
+	step := 8
+	ip := ipnet.IP.To4()
+	if ip == nil {
+		step = 4
+		ip = ipnet.IP.To16()
+	}
+	boundary := (ones + step - 1) / step * step
+	if boundary == 0 {
+		boundary = step
+	}
+	// labels of the full reverse name that lie below the zone
+	drop := (bits - boundary) / step
+	start := new(big.Int).SetBytes(ip)
+	increment := new(big.Int).Lsh(big.NewInt(1), uint(bits-boundary))
+	var zones []string
+	for i := 0; i < 1<<(boundary-ones); i++ {
+		subnet := make(net.IP, len(ip))
+		start.FillBytes(subnet)
+		reverse, err := dns.ReverseAddr(subnet.String())
+		if err == nil {
+			zones = append(zones, strings.Join(strings.Split(reverse, ".")[drop:], "."))
+		}
+		start.Add(start, increment)
+	}
+	return zones
+}
+
+// GetReverseDNS - gets the PTR records of the nodes, ext clients and custom address entries of a network
+func GetReverseDNS(network string) ([]models.DNSEntry, error) {
+	entries, err := GetDNS(network)
+	if err != nil && !database.IsEmptyRecord(err) {
+		return nil, err
+	}
+	for _, entry := range GetExtclientDNS() {
+		if entry.Network == network {
+			entries = append(entries, entry)
+		}
+	}
+	return reverseDNSEntries(network, entries), nil
+}
+
+// reverseDNSEntries - turns the address entries of a network into PTR records
+func reverseDNSEntries(network string, entries []models.DNSEntry) []models.DNSEntry {
+	ptrs := []models.DNSEntry{}
+	for _, entry := range entries {
+		if entry.RecordType() != models.DNSRecordA || entry.IsWildcard() {
+			continue
+		}
+		for _, address := range []string{entry.Address, entry.Address6} {
+			if net.ParseIP(address) == nil {
+				continue
+			}
+			reverse, err := dns.ReverseAddr(address)
+			if err != nil {
+				continue
+			}
+			ptrs = append(ptrs, models.DNSEntry{
+				Name:    reverse,
+				Network: network,
+				Type:    models.DNSRecordPTR,
+				Target:  dns.Fqdn(entry.Name),
+				TTL:     entry.TTL,
+			})
+		}
+	}
+	return ptrs
+}
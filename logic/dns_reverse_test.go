@@ -0,0 +1,69 @@
+package logic
+
+import (
+	"net"
+	"testing"
+
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/models"
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReverseDNSZones(t *testing.T) {
+	zones := func(cidr string) []string {
+		_, ipnet, _ := net.ParseCIDR(cidr)
+		return reverseDNSZones(ipnet)
+	}
+	assert.Equal(t, []string{"0.106.10.in-addr.arpa."}, zones("10.106.0.0/24"))
+	assert.Equal(t, []string{"10.in-addr.arpa."}, zones("10.0.0.0/8"))
+	assert.Equal(t, []string{"16.172.in-addr.arpa.", "17.172.in-addr.arpa.", "18.172.in-addr.arpa.", "19.172.in-addr.arpa."}, zones("172.16.0.0/14"))
+	assert.Len(t, zones("10.106.0.0/20"), 16)
+	assert.Equal(t, []string{"0.0.0.0.0.0.0.0.0.0.0.0.0.0.d.f.ip6.arpa."}, zones("fd00::/64"))
+	assert.Equal(t, []string{
+		"8.b.d.0.1.0.0.2.ip6.arpa.", "9.b.d.0.1.0.0.2.ip6.arpa.",
+		"a.b.d.0.1.0.0.2.ip6.arpa.", "b.b.d.0.1.0.0.2.ip6.arpa.",
+	}, zones("2001:db8::/30"))
+}
+
+func TestResolveReverseDNS(t *testing.T) {
+	database.InitializeDatabase()
+	defer database.CloseDB()
+	network := models.Network{NetID: "ptrnet", AddressRange: "10.106.0.0/24", AddressRange6: "fd00:106::/64"}
+	assert.Nil(t, SaveNetwork(&network))
+	defer func() {
+		_ = database.DeleteRecord(database.NETWORKS_TABLE_NAME, network.NetID)
+	}()
+	_, err := CreateDNS(models.DNSEntry{Name: "web.ptrnet", Network: "ptrnet", Address: "10.106.0.10", Address6: "fd00:106::10"})
+	assert.Nil(t, err)
+	defer DeleteDNS("web.ptrnet", "ptrnet")
+	_, err = CreateDNS(models.DNSEntry{Name: "alias.ptrnet", Network: "ptrnet", Type: models.DNSRecordCNAME, Target: "web.ptrnet"})
+	assert.Nil(t, err)
+	defer DeleteDNS("alias.ptrnet", "ptrnet")
+	client := models.ExtClient{ClientID: "phone", Network: "ptrnet", Address: "10.106.0.20"}
+	assert.Nil(t, SaveExtClient(&client))
+	defer DeleteExtClient(client.Network, client.ClientID)
+
+	ptrs, err := GetReverseDNS("ptrnet")
+	assert.Nil(t, err)
+	assert.Len(t, ptrs, 3)
+
+	query := func(name string) *dns.Msg {
+		return ResolveDNS(new(dns.Msg).SetQuestion(name, dns.TypePTR), nil)
+	}
+	resp := query("10.0.106.10.in-addr.arpa.")
+	assert.True(t, resp.Authoritative)
+	assert.Len(t, resp.Answer, 1)
+	assert.Equal(t, "web.ptrnet.", resp.Answer[0].(*dns.PTR).Ptr)
+	reverse, _ := dns.ReverseAddr("fd00:106::10")
+	assert.Equal(t, "web.ptrnet.", query(reverse).Answer[0].(*dns.PTR).Ptr)
+	assert.Equal(t, "phone.ptrnet.", query("20.0.106.10.in-addr.arpa.").Answer[0].(*dns.PTR).Ptr)
+	// unused addresses of the range are not forwarded upstream
+	assert.Equal(t, dns.RcodeNameError, query("99.0.106.10.in-addr.arpa.").Rcode)
+
+	// addresses changing are picked up on the next query
+	client.Address = "10.106.0.21"
+	assert.Nil(t, SaveExtClient(&client))
+	assert.Equal(t, dns.RcodeNameError, query("20.0.106.10.in-addr.arpa.").Rcode)
+	assert.Len(t, query("21.0.106.10.in-addr.arpa.").Answer, 1)
+}
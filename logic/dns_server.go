@@ -0,0 +1,232 @@
+package logic
+
+import (
+	"context"
+	"math/rand"
+	"net"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gravitl/netmaker/models"
+	"github.com/gravitl/netmaker/servercfg"
+	"github.com/miekg/dns"
+	"golang.org/x/exp/slog"
+)
+
+// DNSDefaultTTL - ttl of the records answered by the embedded DNS server,
+// kept short so clients pick up changes quickly
+const DNSDefaultTTL = 60
+
+// StartDNSServer - answers DNS on udp and tcp until the context is cancelled
+func StartDNSServer(ctx context.Context, wg *sync.WaitGroup) {
+	defer wg.Done()
+	addr := servercfg.GetEmbeddedDNSAddr()
+	handler := dns.HandlerFunc(ServeDNS)
+	servers := []*dns.Server{
+		{Addr: addr, Net: "udp", Handler: handler},
+		{Addr: addr, Net: "tcp", Handler: handler},
+	}
+	for _, server := range servers {
+		go func(server *dns.Server) {
+			slog.Info("starting embedded DNS server", "addr", addr, "net", server.Net)
+			if err := server.ListenAndServe(); err != nil {
+				slog.Error("embedded DNS server stopped", "net", server.Net, "error", err)
+			}
+		}(server)
+	}
+	<-ctx.Done()
+	for _, server := range servers {
+		_ = server.Shutdown()
+	}
+}
+
+// ServeDNS - handles a query to the embedded DNS server
+func ServeDNS(w dns.ResponseWriter, r *dns.Msg) {
+	var client net.IP
+	var udp bool
+	switch addr := w.RemoteAddr().(type) {
+	case *net.UDPAddr:
+		client, udp = addr.IP, true
+	case *net.TCPAddr:
+		client = addr.IP
+	}
+	m := ResolveDNS(r, client)
+	if udp {
+		// replies over udp must fit the client's buffer, a truncated reply makes it retry over tcp
+		size := dns.MinMsgSize
+		if opt := r.IsEdns0(); opt != nil {
+			size = max(int(opt.UDPSize()), dns.MinMsgSize)
+		}
+		m.Truncate(size)
+	}
+	if err := w.WriteMsg(m); err != nil {
+		slog.Debug("failed to answer DNS query", "error", err)
+	}
+}
+
+// ResolveDNS - answers a query from the node, ext client and custom entries, names outside the netmaker zones
+// are forwarded upstream as the client's network asks unless a filter policy blocks them,
+// queries for them from outside the networks are refused
+func ResolveDNS(r *dns.Msg, client net.IP) *dns.Msg {
+	m := new(dns.Msg)
+	if r.Opcode != dns.OpcodeQuery {
+		return m.SetRcode(r, dns.RcodeNotImplemented)
+	}
+	if len(r.Question) != 1 {
+		return m.SetRcode(r, dns.RcodeFormatError)
+	}
+	q := r.Question[0]
+	name := strings.ToLower(dns.Fqdn(q.Name))
+	snapshot := getDNSSnapshot()
+	zone, ok := snapshot.findZone(name)
+	if filterDNSQuery(name, client, ok) {
+		return m.SetRcode(r, dns.RcodeNameError)
+	}
+	if !ok {
+		// only the networks' own clients may use the server as a resolver
+		if client == nil || (!client.IsLoopback() && snapshot.clientNetwork(client) == nil) {
+			return m.SetRcode(r, dns.RcodeRefused)
+		}
+		return forwardDNS(r, getDNSUpstreams(name, client))
+	}
+	m.SetReply(r)
+	m.Authoritative = true
+	byName := snapshot.byName
+	if strings.HasSuffix(zone, ".arpa.") {
+		byName = snapshot.reverse
+	}
+	// the snapshot is shared by every query, so the entries are shuffled on a copy
+	entries := slices.Clone(lookupDNSEntries(byName, name, zone))
+	// names held by several entries, like tags, are answered round-robin
+	rand.Shuffle(len(entries), func(i, j int) {
+		entries[i], entries[j] = entries[j], entries[i]
+	})
+	m.Answer = answerDNS(byName, entries, q, 0)
+	if name == zone && q.Qtype == dns.TypeSOA {
+		m.Answer = append(m.Answer, dnsSOA(zone))
+	}
+	if len(m.Answer) == 0 {
+		if len(entries) == 0 && name != zone {
+			m.Rcode = dns.RcodeNameError
+		}
+		m.Ns = []dns.RR{dnsSOA(zone)}
+	}
+	return m
+}
+
+// lookupDNSEntries - gets the entries of a name, falling back to the closest wildcard above it
+func lookupDNSEntries(byName map[string][]models.DNSEntry, name, zone string) []models.DNSEntry {
+	if entries, ok := byName[name]; ok {
+		return entries
+	}
+	for parent := name; parent != zone; {
+		labels := strings.SplitN(parent, ".", 2)
+		if len(labels) < 2 || labels[1] == "" {
+			break
+		}
+		parent = labels[1]
+		if entries, ok := byName["*."+parent]; ok {
+			return entries
+		}
+		// an existing name stops wildcards above it from matching
+		if _, ok := byName[parent]; ok {
+			break
+		}
+	}
+	return nil
+}
+
+// answerDNS - turns the entries of a name into the records a question asks for,
+// aliases pointing inside the netmaker zones are followed
+func answerDNS(byName map[string][]models.DNSEntry, entries []models.DNSEntry, q dns.Question, depth int) []dns.RR {
+	var answer []dns.RR
+	for _, entry := range entries {
+		for _, rr := range dnsEntryRecords(entry, q.Name) {
+			rrtype := rr.Header().Rrtype
+			if rrtype == dns.TypeCNAME && q.Qtype != dns.TypeCNAME {
+				answer = append(answer, rr)
+				target := rr.(*dns.CNAME).Target
+				if depth < 8 {
+					answer = append(answer, answerDNS(byName, byName[target], dns.Question{Name: target, Qtype: q.Qtype, Qclass: q.Qclass}, depth+1)...)
+				}
+				continue
+			}
+			if q.Qtype == rrtype || q.Qtype == dns.TypeANY {
+				answer = append(answer, rr)
+			}
+		}
+	}
+	return answer
+}
+
+// dnsEntryRecords - gets the resource records of an entry under the given name
+func dnsEntryRecords(entry models.DNSEntry, name string) []dns.RR {
+	ttl := entry.TTL
+	if ttl == 0 {
+		ttl = DNSDefaultTTL
+	}
+	hdr := func(rrtype uint16) dns.RR_Header {
+		return dns.RR_Header{Name: name, Rrtype: rrtype, Class: dns.ClassINET, Ttl: ttl}
+	}
+	var records []dns.RR
+	switch entry.RecordType() {
+	case models.DNSRecordA:
+		if ip := net.ParseIP(entry.Address); ip != nil {
+			records = append(records, &dns.A{Hdr: hdr(dns.TypeA), A: ip.To4()})
+		}
+		if ip := net.ParseIP(entry.Address6); ip != nil {
+			records = append(records, &dns.AAAA{Hdr: hdr(dns.TypeAAAA), AAAA: ip})
+		}
+	case models.DNSRecordCNAME:
+		records = append(records, &dns.CNAME{Hdr: hdr(dns.TypeCNAME), Target: strings.ToLower(dns.Fqdn(entry.Target))})
+	case models.DNSRecordSRV:
+		records = append(records, &dns.SRV{
+			Hdr:      hdr(dns.TypeSRV),
+			Priority: entry.Priority,
+			Weight:   entry.Weight,
+			Port:     entry.Port,
+			Target:   dns.Fqdn(entry.Target),
+		})
+	case models.DNSRecordMX:
+		records = append(records, &dns.MX{Hdr: hdr(dns.TypeMX), Preference: entry.Priority, Mx: dns.Fqdn(entry.Target)})
+	case models.DNSRecordTXT:
+		records = append(records, &dns.TXT{Hdr: hdr(dns.TypeTXT), Txt: entry.Text})
+	case models.DNSRecordPTR:
+		records = append(records, &dns.PTR{Hdr: hdr(dns.TypePTR), Ptr: entry.Target})
+	}
+	return records
+}
+
+// forwardDNS - passes a query on to the first upstream resolver that answers
+func forwardDNS(r *dns.Msg, upstreams []string) *dns.Msg {
+	client := &dns.Client{Timeout: 2 * time.Second}
+	for _, upstream := range upstreams {
+		resp, _, err := client.Exchange(r, upstream)
+		if err != nil {
+			slog.Debug("failed to forward DNS query", "upstream", upstream, "error", err)
+			continue
+		}
+		return resp
+	}
+	return new(dns.Msg).SetRcode(r, dns.RcodeServerFailure)
+}
+
+func dnsHeader(name string, rrtype uint16) dns.RR_Header {
+	return dns.RR_Header{Name: name, Rrtype: rrtype, Class: dns.ClassINET, Ttl: DNSDefaultTTL}
+}
+
+// dnsSOA - the start of authority of a netmaker zone, sent with negative answers
+func dnsSOA(zone string) *dns.SOA {
+	return &dns.SOA{
+		Hdr:     dnsHeader(zone, dns.TypeSOA),
+		Ns:      "ns." + zone,
+		Mbox:    "hostmaster." + zone,
+		Serial:  uint32(time.Now().Unix()),
+		Refresh: 3600,
+		Retry:   600,
+		Expire:  86400,
+		Minttl:  DNSDefaultTTL,
+	}
+}
@@ -0,0 +1,211 @@
+package logic
+
+import (
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/models"
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResolveDNS(t *testing.T) {
+	t.Setenv("EMBEDDED_DNS", "true")
+	database.InitializeDatabase()
+	defer database.CloseDB()
+	network := models.Network{NetID: "dnsnet", AddressRange: "10.104.0.0/24"}
+	assert.Nil(t, SaveNetwork(&network))
+	defer func() {
+		_ = database.DeleteRecord(database.NETWORKS_TABLE_NAME, network.NetID)
+	}()
+	_, err := CreateDNS(models.DNSEntry{Name: "db.dnsnet", Network: "dnsnet", Address: "10.104.0.10", Address6: "fd00::10"})
+	assert.Nil(t, err)
+	defer DeleteDNS("db.dnsnet", "dnsnet")
+	client := models.ExtClient{ClientID: "laptop", Network: "dnsnet", Address: "10.104.0.20"}
+	assert.Nil(t, SaveExtClient(&client))
+	defer DeleteExtClient(client.Network, client.ClientID)
+
+	query := func(name string, qtype uint16) *dns.Msg {
+		return ResolveDNS(new(dns.Msg).SetQuestion(name, qtype), nil)
+	}
+
+	t.Run("CustomEntry", func(t *testing.T) {
+		resp := query("DB.dnsnet.", dns.TypeA)
+		assert.Equal(t, dns.RcodeSuccess, resp.Rcode)
+		assert.True(t, resp.Authoritative)
+		assert.Len(t, resp.Answer, 1)
+		assert.Equal(t, "10.104.0.10", resp.Answer[0].(*dns.A).A.String())
+		resp = query("db.dnsnet.", dns.TypeAAAA)
+		assert.Len(t, resp.Answer, 1)
+		assert.Equal(t, "fd00::10", resp.Answer[0].(*dns.AAAA).AAAA.String())
+	})
+	t.Run("ExtClient", func(t *testing.T) {
+		resp := query("laptop.dnsnet.", dns.TypeA)
+		assert.Len(t, resp.Answer, 1)
+		// no ipv6 address gives an empty answer rather than an error
+		resp = query("laptop.dnsnet.", dns.TypeAAAA)
+		assert.Equal(t, dns.RcodeSuccess, resp.Rcode)
+		assert.Empty(t, resp.Answer)
+		assert.Len(t, resp.Ns, 1)
+	})
+	t.Run("BareName", func(t *testing.T) {
+		_, err := CreateDNS(models.DNSEntry{Name: "nas", Network: "dnsnet", Address: "10.104.0.30"})
+		assert.Nil(t, err)
+		defer DeleteDNS("nas", "dnsnet")
+		InvalidateDNSSnapshot()
+		resp := query("nas.", dns.TypeA)
+		assert.Equal(t, dns.RcodeSuccess, resp.Rcode)
+		assert.True(t, resp.Authoritative)
+		assert.Len(t, resp.Answer, 1)
+		assert.Equal(t, "10.104.0.30", resp.Answer[0].(*dns.A).A.String())
+	})
+	t.Run("NXDomain", func(t *testing.T) {
+		resp := query("missing.dnsnet.", dns.TypeA)
+		assert.Equal(t, dns.RcodeNameError, resp.Rcode)
+		assert.IsType(t, &dns.SOA{}, resp.Ns[0])
+	})
+	t.Run("TypedRecords", func(t *testing.T) {
+		records := []models.DNSEntry{
+			{Name: "www.dnsnet", Network: "dnsnet", Type: models.DNSRecordCNAME, Target: "db.dnsnet", TTL: 300},
+			{Name: "_pg._tcp.dnsnet", Network: "dnsnet", Type: models.DNSRecordSRV, Target: "db.dnsnet", Priority: 10, Weight: 5, Port: 5432},
+			{Name: "mail.dnsnet", Network: "dnsnet", Type: models.DNSRecordMX, Target: "db.dnsnet", Priority: 10},
+			{Name: "info.dnsnet", Network: "dnsnet", Type: models.DNSRecordTXT, Text: []string{"v=spf1 -all"}},
+			{Name: "*.apps.dnsnet", Network: "dnsnet", Address: "10.104.0.40"},
+		}
+		for _, record := range records {
+			_, err := CreateDNS(record)
+			assert.Nil(t, err)
+			defer DeleteDNS(record.Name, record.Network)
+		}
+		resp := query("www.dnsnet.", dns.TypeA)
+		assert.Len(t, resp.Answer, 2)
+		assert.Equal(t, "db.dnsnet.", resp.Answer[0].(*dns.CNAME).Target)
+		assert.Equal(t, uint32(300), resp.Answer[0].Header().Ttl)
+		assert.Equal(t, "10.104.0.10", resp.Answer[1].(*dns.A).A.String())
+
+		resp = query("_pg._tcp.dnsnet.", dns.TypeSRV)
+		assert.Len(t, resp.Answer, 1)
+		srv := resp.Answer[0].(*dns.SRV)
+		assert.Equal(t, uint16(5432), srv.Port)
+		assert.Equal(t, "db.dnsnet.", srv.Target)
+		assert.Equal(t, "db.dnsnet.", query("mail.dnsnet.", dns.TypeMX).Answer[0].(*dns.MX).Mx)
+		assert.Equal(t, []string{"v=spf1 -all"}, query("info.dnsnet.", dns.TypeTXT).Answer[0].(*dns.TXT).Txt)
+
+		resp = query("web.apps.dnsnet.", dns.TypeA)
+		assert.Len(t, resp.Answer, 1)
+		assert.Equal(t, "web.apps.dnsnet.", resp.Answer[0].Header().Name)
+		assert.Equal(t, dns.RcodeNameError, query("web.other.dnsnet.", dns.TypeA).Rcode)
+
+		// hosts files only get address records and the aliases of them
+		entries, err := GetDNS("dnsnet")
+		assert.Nil(t, err)
+		hostsEntries := map[string]string{}
+		for _, entry := range GetHostsDNSEntries(entries) {
+			hostsEntries[entry.Name] = entry.Address
+		}
+		assert.Equal(t, map[string]string{"db.dnsnet": "10.104.0.10", "www.dnsnet": "10.104.0.10"}, hostsEntries)
+	})
+	t.Run("SharedNames", func(t *testing.T) {
+		// a name holds one address record and any number of other distinct records, a CNAME can not share it
+		txt := models.DNSEntry{Name: "db.dnsnet", Network: "dnsnet", Type: models.DNSRecordTXT, Text: []string{"primary"}}
+		assert.Nil(t, ValidateDNSCreate(txt))
+		assert.NotNil(t, ValidateDNSCreate(models.DNSEntry{Name: "db.dnsnet", Network: "dnsnet", Address: "10.104.0.11"}))
+		assert.NotNil(t, ValidateDNSCreate(models.DNSEntry{Name: "db.dnsnet", Network: "dnsnet", Type: models.DNSRecordCNAME, Target: "laptop.dnsnet"}))
+		_, err := CreateDNS(txt)
+		assert.Nil(t, err)
+		assert.NotNil(t, ValidateDNSCreate(txt))
+		assert.Equal(t, []string{"primary"}, query("db.dnsnet.", dns.TypeTXT).Answer[0].(*dns.TXT).Txt)
+		assert.Len(t, query("db.dnsnet.", dns.TypeA).Answer, 1)
+		spf := models.DNSEntry{Name: "db.dnsnet", Network: "dnsnet", Type: models.DNSRecordTXT, Text: []string{"v=spf1 -all"}}
+		assert.Nil(t, ValidateDNSCreate(spf))
+		_, err = CreateDNS(spf)
+		assert.Nil(t, err)
+		assert.Len(t, query("db.dnsnet.", dns.TypeTXT).Answer, 2)
+		assert.Nil(t, DeleteDNSRecord("db.dnsnet", "dnsnet", models.DNSRecordTXT))
+		assert.Empty(t, query("db.dnsnet.", dns.TypeTXT).Answer)
+		assert.Len(t, query("db.dnsnet.", dns.TypeA).Answer, 1)
+	})
+	t.Run("SeveralRecords", func(t *testing.T) {
+		records := []models.DNSEntry{
+			{Name: "_sip._udp.dnsnet", Network: "dnsnet", Type: models.DNSRecordSRV, Target: "db.dnsnet", Priority: 10, Weight: 60, Port: 5060},
+			{Name: "_sip._udp.dnsnet", Network: "dnsnet", Type: models.DNSRecordSRV, Target: "laptop.dnsnet", Priority: 10, Weight: 40, Port: 5060},
+			{Name: "_sip._udp.dnsnet", Network: "dnsnet", Type: models.DNSRecordSRV, Target: "db.dnsnet", Priority: 20, Weight: 0, Port: 5060},
+			{Name: "mx.dnsnet", Network: "dnsnet", Type: models.DNSRecordMX, Target: "db.dnsnet", Priority: 10},
+			{Name: "mx.dnsnet", Network: "dnsnet", Type: models.DNSRecordMX, Target: "laptop.dnsnet", Priority: 20},
+		}
+		for _, record := range records {
+			assert.Nil(t, ValidateDNSCreate(record))
+			_, err := CreateDNS(record)
+			assert.Nil(t, err)
+			defer DeleteDNS(record.Name, record.Network)
+		}
+		// only exact duplicates are refused
+		assert.NotNil(t, ValidateDNSCreate(records[0]))
+		assert.Len(t, query("_sip._udp.dnsnet.", dns.TypeSRV).Answer, 3)
+		assert.Len(t, query("mx.dnsnet.", dns.TypeMX).Answer, 2)
+	})
+	t.Run("Changes", func(t *testing.T) {
+		_, err := CreateDNS(models.DNSEntry{Name: "cache.dnsnet", Network: "dnsnet", Address: "10.104.0.30"})
+		assert.Nil(t, err)
+		assert.Len(t, query("cache.dnsnet.", dns.TypeA).Answer, 1)
+		assert.Nil(t, DeleteDNS("cache.dnsnet", "dnsnet"))
+		assert.Equal(t, dns.RcodeNameError, query("cache.dnsnet.", dns.TypeA).Rcode)
+		// records written around the logic are answered once the snapshot is invalidated
+		key, err := GetRecordKey("raw.dnsnet", "dnsnet")
+		assert.Nil(t, err)
+		assert.Nil(t, database.Insert(key, `{"name":"raw.dnsnet","network":"dnsnet","address":"10.104.0.31"}`, database.DNS_TABLE_NAME))
+		defer DeleteDNS("raw.dnsnet", "dnsnet")
+		assert.Equal(t, dns.RcodeNameError, query("raw.dnsnet.", dns.TypeA).Rcode)
+		InvalidateDNSSnapshot()
+		assert.Len(t, query("raw.dnsnet.", dns.TypeA).Answer, 1)
+	})
+	t.Run("Truncate", func(t *testing.T) {
+		long := models.DNSEntry{Name: "long.dnsnet", Network: "dnsnet", Type: models.DNSRecordTXT,
+			Text: []string{strings.Repeat("a", 250), strings.Repeat("b", 250), strings.Repeat("c", 250)}}
+		_, err := CreateDNS(long)
+		assert.Nil(t, err)
+		defer DeleteDNS(long.Name, long.Network)
+		serve := func(r *dns.Msg, tcp bool) *dns.Msg {
+			w := &testDNSWriter{tcp: tcp}
+			ServeDNS(w, r)
+			return w.msg
+		}
+		resp := serve(new(dns.Msg).SetQuestion("long.dnsnet.", dns.TypeTXT), false)
+		assert.True(t, resp.Truncated)
+		assert.Empty(t, resp.Answer)
+		resp = serve(new(dns.Msg).SetQuestion("long.dnsnet.", dns.TypeTXT).SetEdns0(4096, false), false)
+		assert.False(t, resp.Truncated)
+		assert.Len(t, resp.Answer, 1)
+		resp = serve(new(dns.Msg).SetQuestion("long.dnsnet.", dns.TypeTXT), true)
+		assert.False(t, resp.Truncated)
+		assert.Len(t, resp.Answer, 1)
+	})
+	t.Run("Refused", func(t *testing.T) {
+		// names outside the zones are only forwarded for the networks' clients
+		resp := query("example.com.", dns.TypeA)
+		assert.Equal(t, dns.RcodeRefused, resp.Rcode)
+		resp = ResolveDNS(new(dns.Msg).SetQuestion("example.com.", dns.TypeA), net.ParseIP("192.0.2.1"))
+		assert.Equal(t, dns.RcodeRefused, resp.Rcode)
+	})
+}
+
+// testDNSWriter - records the reply written to a query from 127.0.0.1
+type testDNSWriter struct {
+	dns.ResponseWriter
+	tcp bool
+	msg *dns.Msg
+}
+
+func (w *testDNSWriter) RemoteAddr() net.Addr {
+	if w.tcp {
+		return &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 53000}
+	}
+	return &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 53000}
+}
+
+func (w *testDNSWriter) WriteMsg(m *dns.Msg) error {
+	w.msg = m
+	return nil
+}
@@ -0,0 +1,143 @@
+package logic
+
+import (
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/models"
+	"github.com/miekg/dns"
+	"golang.org/x/exp/slog"
+)
+
+// dnsSnapshotTTL - how long a snapshot is reused, changes made without invalidating it,
+// like nodes of a tag going offline, are picked up after this
+const dnsSnapshotTTL = 30 * time.Second
+
+// dnsSnapshot - the zones, network ranges and records the embedded DNS server answers from,
+// indexed by fully qualified name
+type dnsSnapshot struct {
+	zones    []string
+	networks []dnsSnapshotNetwork
+	byName   map[string][]models.DNSEntry
+	reverse  map[string][]models.DNSEntry
+	expires  time.Time
+}
+
+type dnsSnapshotNetwork struct {
+	network models.Network
+	ranges  []*net.IPNet
+}
+
+var (
+	dnsSnapshotCache *dnsSnapshot
+	dnsSnapshotMutex = &sync.Mutex{}
+)
+
+// InvalidateDNSSnapshot - makes the embedded DNS server rebuild its records on the next query
+func InvalidateDNSSnapshot() {
+	dnsSnapshotMutex.Lock()
+	dnsSnapshotCache = nil
+	dnsSnapshotMutex.Unlock()
+}
+
+// getDNSSnapshot - gets the current snapshot, building it when it was invalidated or expired
+func getDNSSnapshot() *dnsSnapshot {
+	dnsSnapshotMutex.Lock()
+	defer dnsSnapshotMutex.Unlock()
+	if dnsSnapshotCache == nil || time.Now().After(dnsSnapshotCache.expires) {
+		dnsSnapshotCache = buildDNSSnapshot()
+	}
+	return dnsSnapshotCache
+}
+
+func buildDNSSnapshot() *dnsSnapshot {
+	snapshot := &dnsSnapshot{
+		byName:  make(map[string][]models.DNSEntry),
+		reverse: make(map[string][]models.DNSEntry),
+		expires: time.Now().Add(dnsSnapshotTTL),
+	}
+	if domain := GetDefaultDomain(); domain != "" {
+		snapshot.zones = append(snapshot.zones, strings.ToLower(dns.Fqdn(domain)))
+	}
+	networks, err := GetNetworks()
+	if err != nil && !database.IsEmptyRecord(err) {
+		slog.Error("failed to get networks for DNS", "error", err)
+	}
+	extclientDNS := make(map[string][]models.DNSEntry)
+	for _, entry := range GetExtclientDNS() {
+		extclientDNS[entry.Network] = append(extclientDNS[entry.Network], entry)
+	}
+	for i := range networks {
+		network := dnsSnapshotNetwork{network: networks[i]}
+		for _, cidr := range []string{networks[i].AddressRange, networks[i].AddressRange6} {
+			if _, ipnet, err := net.ParseCIDR(cidr); err == nil {
+				network.ranges = append(network.ranges, ipnet)
+			}
+		}
+		snapshot.networks = append(snapshot.networks, network)
+		snapshot.zones = append(snapshot.zones, strings.ToLower(dns.Fqdn(networks[i].NetID)))
+		for _, zone := range GetReverseDNSZones(&networks[i]) {
+			snapshot.zones = append(snapshot.zones, strings.ToLower(dns.Fqdn(zone)))
+		}
+		entries, err := GetDNS(networks[i].NetID)
+		if err != nil && !database.IsEmptyRecord(err) {
+			slog.Error("failed to get DNS entries", "network", networks[i].NetID, "error", err)
+		}
+		entries = append(entries, extclientDNS[networks[i].NetID]...)
+		// tag names are shared by their nodes, addresses only point back at the nodes' own names
+		for _, ptr := range reverseDNSEntries(networks[i].NetID, entries) {
+			snapshot.reverse[ptr.Name] = append(snapshot.reverse[ptr.Name], ptr)
+		}
+		taken := make(map[string]struct{}, len(entries))
+		for _, entry := range entries {
+			name := strings.ToLower(dns.Fqdn(entry.Name))
+			taken[name] = struct{}{}
+			snapshot.byName[name] = append(snapshot.byName[name], entry)
+		}
+		for _, entry := range GetTagDNS(networks[i].NetID) {
+			name := strings.ToLower(dns.Fqdn(entry.Name))
+			if _, ok := taken[name]; !ok {
+				snapshot.byName[name] = append(snapshot.byName[name], entry)
+			}
+		}
+	}
+	// custom entries named outside the network and default domain zones, like bare names
+	// when no default domain is set, are answered as zones of their own
+	for name := range snapshot.byName {
+		zone := strings.TrimPrefix(name, "*.")
+		if _, ok := snapshot.findZone(zone); !ok && zone != "." {
+			snapshot.zones = append(snapshot.zones, zone)
+		}
+	}
+	return snapshot
+}
+
+// findZone - gets the netmaker zone a name belongs to, networks, their reverse zones,
+// the default domain and names of custom entries outside them are zones
+func (s *dnsSnapshot) findZone(name string) (string, bool) {
+	var found string
+	for _, zone := range s.zones {
+		if dns.IsSubDomain(zone, name) && len(zone) > len(found) {
+			found = zone
+		}
+	}
+	return found, found != ""
+}
+
+// clientNetwork - gets the network whose address range holds the ip a query came from
+func (s *dnsSnapshot) clientNetwork(ip net.IP) *models.Network {
+	if ip == nil {
+		return nil
+	}
+	for i := range s.networks {
+		for _, ipnet := range s.networks[i].ranges {
+			if ipnet.Contains(ip) {
+				return &s.networks[i].network
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,264 @@
+package logic
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/db"
+	"github.com/gravitl/netmaker/schema"
+	"github.com/miekg/dns"
+	"golang.org/x/exp/slog"
+	"gorm.io/datatypes"
+)
+
+// DNSSyncInterval - how often the records of every network are fully reconciled with its external DNS server
+const DNSSyncInterval = 10 * time.Minute
+
+// ErrInvalidDNSSyncConfig - a dynamic DNS config without a valid server, zone or TSIG key
+var ErrInvalidDNSSyncConfig = errors.New("invalid dynamic dns config")
+
+var (
+	dnsSyncCh    = make(chan struct{}, 1)
+	dnsSyncMutex = &sync.Mutex{}
+)
+
+// TriggerDNSSync - asks for changed records to be pushed to the external DNS servers,
+// triggers arriving while a sync runs are merged into one
+func TriggerDNSSync() {
+	select {
+	case dnsSyncCh <- struct{}{}:
+	default:
+	}
+}
+
+// ManageDNSSync - pushes the records of networks to their external DNS servers on changes
+// and reconciles them periodically
+func ManageDNSSync(ctx context.Context) {
+	ticker := time.NewTicker(DNSSyncInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-dnsSyncCh:
+			syncAllNetworkDNS(false)
+		case <-ticker.C:
+			syncAllNetworkDNS(true)
+		}
+	}
+}
+
+func syncAllNetworkDNS(full bool) {
+	configs, err := (&schema.DNSSyncConfig{}).ListAll(db.WithContext(context.TODO()))
+	if err != nil {
+		slog.Error("failed to list dynamic dns configs", "error", err)
+		return
+	}
+	for i := range configs {
+		if err := SyncNetworkDNS(&configs[i], full); err != nil {
+			slog.Error("failed to sync network dns", "network", configs[i].Network, "server", configs[i].Server, "error", err)
+		}
+	}
+}
+
+// ValidateDNSSyncConfig - checks a dynamic DNS config and normalises its server, zone and key
+func ValidateDNSSyncConfig(cfg *schema.DNSSyncConfig) error {
+	if _, _, err := net.SplitHostPort(cfg.Server); err != nil {
+		cfg.Server = net.JoinHostPort(cfg.Server, "53")
+	}
+	if host, _, _ := net.SplitHostPort(cfg.Server); host == "" {
+		return fmt.Errorf("%w: server is required", ErrInvalidDNSSyncConfig)
+	}
+	if _, ok := dns.IsDomainName(cfg.Zone); !ok || !IsDNSEntryValid(cfg.Zone) ||
+		strings.HasPrefix(cfg.Zone, "*") || strings.Trim(cfg.Zone, ".") == "" {
+		return fmt.Errorf("%w: invalid zone %s", ErrInvalidDNSSyncConfig, cfg.Zone)
+	}
+	cfg.Zone = strings.ToLower(dns.Fqdn(cfg.Zone))
+	if cfg.TSIGKeyName == "" {
+		cfg.TSIGAlgorithm, cfg.TSIGSecret = "", ""
+		return nil
+	}
+	cfg.TSIGKeyName = strings.ToLower(dns.Fqdn(cfg.TSIGKeyName))
+	if cfg.TSIGAlgorithm == "" {
+		cfg.TSIGAlgorithm = dns.HmacSHA256
+	}
+	cfg.TSIGAlgorithm = strings.ToLower(dns.Fqdn(cfg.TSIGAlgorithm))
+	if !slices.Contains([]string{dns.HmacSHA1, dns.HmacSHA224, dns.HmacSHA256, dns.HmacSHA384, dns.HmacSHA512}, cfg.TSIGAlgorithm) {
+		return fmt.Errorf("%w: unsupported tsig algorithm %s", ErrInvalidDNSSyncConfig, cfg.TSIGAlgorithm)
+	}
+	if secret, err := base64.StdEncoding.DecodeString(cfg.TSIGSecret); err != nil || len(secret) == 0 {
+		return fmt.Errorf("%w: the tsig secret must be base64", ErrInvalidDNSSyncConfig)
+	}
+	return nil
+}
+
+// SyncNetworkDNS - pushes the records of a network inside the configured zone to the external server,
+// only records that changed since the last push are sent unless full is set, stale owned records are removed
+func SyncNetworkDNS(cfg *schema.DNSSyncConfig, full bool) error {
+	dnsSyncMutex.Lock()
+	defer dnsSyncMutex.Unlock()
+	desired, skipped, err := getDNSSyncRecords(cfg)
+	if err != nil {
+		return err
+	}
+	if len(skipped) > 0 {
+		slog.Warn("skipping dns names outside the dynamic dns zone", "network", cfg.Network, "zone", cfg.Zone, "names", skipped)
+	}
+	cfg.SkippedNames = skipped
+	err = pushDNSUpdate(cfg, desired, full)
+	if err != nil {
+		cfg.LastError = err.Error()
+	} else {
+		cfg.OwnedRecords = desired
+		cfg.LastSync = time.Now()
+		cfg.LastError = ""
+	}
+	if uerr := cfg.Upsert(db.WithContext(context.TODO())); uerr != nil {
+		slog.Error("failed to save dynamic dns state", "network", cfg.Network, "error", uerr)
+	}
+	return err
+}
+
+// RemoveNetworkDNSSync - removes the records owned on the external server and deletes the config
+func RemoveNetworkDNSSync(network string) error {
+	ctx := db.WithContext(context.TODO())
+	cfg := &schema.DNSSyncConfig{Network: network}
+	if err := cfg.Get(ctx); err != nil {
+		return err
+	}
+	dnsSyncMutex.Lock()
+	err := pushDNSUpdate(cfg, nil, true)
+	dnsSyncMutex.Unlock()
+	if err != nil {
+		slog.Warn("failed to remove records from external dns server", "network", network, "server", cfg.Server, "error", err)
+	}
+	return cfg.Delete(ctx)
+}
+
+// getDNSSyncRecords - gets the records of a network's node, ext client and custom entries inside the zone,
+// along with the names that can't be placed in it
+func getDNSSyncRecords(cfg *schema.DNSSyncConfig) (datatypes.JSONSlice[string], datatypes.JSONSlice[string], error) {
+	entries, err := GetDNS(cfg.Network)
+	if err != nil && !database.IsEmptyRecord(err) {
+		return nil, nil, err
+	}
+	for _, entry := range GetExtclientDNS() {
+		if entry.Network == cfg.Network {
+			entries = append(entries, entry)
+		}
+	}
+	records := datatypes.JSONSlice[string]{}
+	skipped := datatypes.JSONSlice[string]{}
+	for _, entry := range entries {
+		name, ok := getDNSSyncName(cfg, strings.ToLower(dns.Fqdn(entry.Name)))
+		if !ok {
+			skipped = append(skipped, strings.ToLower(dns.Fqdn(entry.Name)))
+			continue
+		}
+		for _, rr := range dnsEntryRecords(entry, name) {
+			records = append(records, rr.String())
+		}
+	}
+	sort.Strings(records)
+	sort.Strings(skipped)
+	return slices.Compact(records), slices.Compact(skipped), nil
+}
+
+// getDNSSyncName - gets the name an entry is pushed as, names below the network like <client>.<network>
+// are moved under the zone when the zone is not the network's own
+func getDNSSyncName(cfg *schema.DNSSyncConfig, name string) (string, bool) {
+	if dns.IsSubDomain(cfg.Zone, name) {
+		return name, true
+	}
+	network := strings.ToLower(dns.Fqdn(cfg.Network))
+	suffixes := []string{network}
+	if domain := GetDefaultDomain(); domain != "" {
+		suffixes = append([]string{network + strings.ToLower(dns.Fqdn(domain))}, suffixes...)
+	}
+	for _, suffix := range suffixes {
+		if name != suffix && dns.IsSubDomain(suffix, name) {
+			return strings.TrimSuffix(name, suffix) + cfg.Zone, true
+		}
+	}
+	return "", false
+}
+
+// groupDNSRRsets - parses records into their RRsets keyed by name and type
+func groupDNSRRsets(records []string) map[string][]dns.RR {
+	rrsets := make(map[string][]dns.RR)
+	for _, record := range records {
+		rr, err := dns.NewRR(record)
+		if err != nil || rr == nil {
+			continue
+		}
+		key := rr.Header().Name + " " + dns.TypeToString[rr.Header().Rrtype]
+		rrsets[key] = append(rrsets[key], rr)
+	}
+	return rrsets
+}
+
+// pushDNSUpdate - sends one RFC 2136 update replacing the desired RRsets and deleting owned RRsets no longer desired,
+// RRsets the server has that netmaker never pushed are left alone
+func pushDNSUpdate(cfg *schema.DNSSyncConfig, desired []string, full bool) error {
+	owned := groupDNSRRsets(cfg.OwnedRecords)
+	wanted := groupDNSRRsets(desired)
+	keys := make([]string, 0, len(owned)+len(wanted))
+	for key := range owned {
+		keys = append(keys, key)
+	}
+	for key := range wanted {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	m := new(dns.Msg)
+	m.SetUpdate(cfg.Zone)
+	for _, key := range slices.Compact(keys) {
+		rrset, ok := wanted[key]
+		if !ok {
+			m.RemoveRRset(owned[key][:1])
+			continue
+		}
+		if !full && sameDNSRRset(owned[key], rrset) {
+			continue
+		}
+		m.RemoveRRset(rrset[:1])
+		m.Insert(rrset)
+	}
+	if len(m.Ns) == 0 {
+		return nil
+	}
+	client := &dns.Client{Net: "tcp", Timeout: 10 * time.Second}
+	if cfg.TSIGKeyName != "" {
+		client.TsigSecret = map[string]string{cfg.TSIGKeyName: cfg.TSIGSecret}
+		m.SetTsig(cfg.TSIGKeyName, cfg.TSIGAlgorithm, 300, time.Now().Unix())
+	}
+	resp, _, err := client.Exchange(m, cfg.Server)
+	if err != nil {
+		return err
+	}
+	if resp.Rcode != dns.RcodeSuccess {
+		return fmt.Errorf("update refused by %s: %s", cfg.Server, dns.RcodeToString[resp.Rcode])
+	}
+	return nil
+}
+
+func sameDNSRRset(a, b []dns.RR) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].String() != b[i].String() {
+			return false
+		}
+	}
+	return true
+}
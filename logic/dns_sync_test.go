@@ -0,0 +1,187 @@
+package logic
+
+import (
+	"context"
+	"net"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/db"
+	"github.com/gravitl/netmaker/models"
+	"github.com/gravitl/netmaker/schema"
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+)
+
+// testDNSUpdateServer - an authoritative server applying RFC 2136 updates to an in-memory zone
+type testDNSUpdateServer struct {
+	mu      sync.Mutex
+	records map[string]string
+}
+
+func (s *testDNSUpdateServer) ServeDNS(w dns.ResponseWriter, r *dns.Msg) {
+	resp := new(dns.Msg).SetReply(r)
+	if r.IsTsig() == nil || w.TsigStatus() != nil {
+		resp.Rcode = dns.RcodeRefused
+		_ = w.WriteMsg(resp)
+		return
+	}
+	s.mu.Lock()
+	for _, rr := range r.Ns {
+		hdr := rr.Header()
+		switch hdr.Class {
+		case dns.ClassANY:
+			for key, record := range s.records {
+				existing, _ := dns.NewRR(record)
+				if existing.Header().Name == hdr.Name && existing.Header().Rrtype == hdr.Rrtype {
+					delete(s.records, key)
+				}
+			}
+		case dns.ClassINET:
+			s.records[rr.String()] = rr.String()
+		}
+	}
+	s.mu.Unlock()
+	resp.SetTsig(r.IsTsig().Hdr.Name, r.IsTsig().Algorithm, 300, int64(r.IsTsig().TimeSigned))
+	_ = w.WriteMsg(resp)
+}
+
+func (s *testDNSUpdateServer) list() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	records := make([]string, 0, len(s.records))
+	for record := range s.records {
+		records = append(records, record)
+	}
+	sort.Strings(records)
+	return records
+}
+
+func TestSyncNetworkDNS(t *testing.T) {
+	database.InitializeDatabase()
+	defer database.CloseDB()
+
+	const secret = "c2VjcmV0LWtleS1mb3ItdGVzdHM="
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	zone := &testDNSUpdateServer{records: map[string]string{}}
+	server := &dns.Server{
+		Listener:   listener,
+		Handler:    zone,
+		TsigSecret: map[string]string{"netmaker.": secret},
+		MsgAcceptFunc: func(dh dns.Header) dns.MsgAcceptAction {
+			if int(dh.Bits>>11)&0xF == dns.OpcodeUpdate {
+				return dns.MsgAccept
+			}
+			return dns.DefaultMsgAcceptFunc(dh)
+		},
+	}
+	go server.ActivateAndServe()
+	defer server.Shutdown()
+
+	network := models.Network{NetID: "syncnet", AddressRange: "10.108.0.0/24"}
+	assert.Nil(t, SaveNetwork(&network))
+	defer func() {
+		_ = database.DeleteRecord(database.NETWORKS_TABLE_NAME, network.NetID)
+	}()
+	_, err = CreateDNS(models.DNSEntry{Name: "web.syncnet", Network: "syncnet", Address: "10.108.0.10"})
+	assert.Nil(t, err)
+	defer DeleteDNS("web.syncnet", "syncnet")
+	_, err = CreateDNS(models.DNSEntry{Name: "outside.example", Network: "syncnet", Address: "10.108.0.11"})
+	assert.Nil(t, err)
+	defer DeleteDNS("outside.example", "syncnet")
+	client := models.ExtClient{ClientID: "laptop", Network: "syncnet", Address: "10.108.0.20"}
+	assert.Nil(t, SaveExtClient(&client))
+	defer DeleteExtClient(client.Network, client.ClientID)
+	// a record on the server netmaker never pushed
+	zone.records["manual.syncnet.\t300\tIN\tA\t10.108.0.99"] = "manual.syncnet.\t300\tIN\tA\t10.108.0.99"
+
+	cfg := &schema.DNSSyncConfig{
+		Network:     "syncnet",
+		Server:      listener.Addr().String(),
+		Zone:        "SyncNet",
+		TSIGKeyName: "netmaker",
+		TSIGSecret:  secret,
+	}
+	assert.Nil(t, ValidateDNSSyncConfig(cfg))
+	assert.Equal(t, "syncnet.", cfg.Zone)
+	assert.Equal(t, dns.HmacSHA256, cfg.TSIGAlgorithm)
+	defer func() {
+		_ = (&schema.DNSSyncConfig{Network: "syncnet"}).Delete(db.WithContext(context.TODO()))
+	}()
+
+	t.Run("InitialPush", func(t *testing.T) {
+		assert.Nil(t, SyncNetworkDNS(cfg, true))
+		assert.Empty(t, cfg.LastError)
+		assert.Equal(t, []string{
+			"laptop.syncnet.\t60\tIN\tA\t10.108.0.20",
+			"manual.syncnet.\t300\tIN\tA\t10.108.0.99",
+			"web.syncnet.\t60\tIN\tA\t10.108.0.10",
+		}, zone.list())
+		assert.Len(t, cfg.OwnedRecords, 2)
+		assert.Equal(t, []string{"outside.example."}, []string(cfg.SkippedNames))
+		saved := &schema.DNSSyncConfig{Network: "syncnet"}
+		assert.Nil(t, saved.Get(db.WithContext(context.TODO())))
+		assert.Equal(t, cfg.OwnedRecords, saved.OwnedRecords)
+	})
+	t.Run("Changes", func(t *testing.T) {
+		assert.Nil(t, DeleteDNS("web.syncnet", "syncnet"))
+		_, err := CreateDNS(models.DNSEntry{Name: "web.syncnet", Network: "syncnet", Address: "10.108.0.12"})
+		assert.Nil(t, err)
+		_, err = CreateDNS(models.DNSEntry{Name: "api.syncnet", Network: "syncnet", Address: "10.108.0.13"})
+		assert.Nil(t, err)
+		defer DeleteDNS("api.syncnet", "syncnet")
+		assert.Nil(t, SyncNetworkDNS(cfg, false))
+		assert.Equal(t, []string{
+			"api.syncnet.\t60\tIN\tA\t10.108.0.13",
+			"laptop.syncnet.\t60\tIN\tA\t10.108.0.20",
+			"manual.syncnet.\t300\tIN\tA\t10.108.0.99",
+			"web.syncnet.\t60\tIN\tA\t10.108.0.12",
+		}, zone.list())
+	})
+	t.Run("StaleRemoved", func(t *testing.T) {
+		// api.syncnet was deleted above
+		assert.Nil(t, SyncNetworkDNS(cfg, true))
+		assert.Equal(t, []string{
+			"laptop.syncnet.\t60\tIN\tA\t10.108.0.20",
+			"manual.syncnet.\t300\tIN\tA\t10.108.0.99",
+			"web.syncnet.\t60\tIN\tA\t10.108.0.12",
+		}, zone.list())
+	})
+	t.Run("NetworkNamesUnderZone", func(t *testing.T) {
+		// names of the network are pushed under a zone that is not the network's own
+		sub := *cfg
+		sub.Zone = "syncnet.corp.example."
+		records, skipped, err := getDNSSyncRecords(&sub)
+		assert.Nil(t, err)
+		assert.Equal(t, []string{
+			"laptop.syncnet.corp.example.\t60\tIN\tA\t10.108.0.20",
+			"web.syncnet.corp.example.\t60\tIN\tA\t10.108.0.12",
+		}, []string(records))
+		assert.Equal(t, []string{"outside.example."}, []string(skipped))
+	})
+	t.Run("BadKey", func(t *testing.T) {
+		bad := *cfg
+		bad.TSIGSecret = "d3Jvbmc="
+		bad.OwnedRecords = nil
+		assert.NotNil(t, SyncNetworkDNS(&bad, true))
+		assert.NotEmpty(t, bad.LastError)
+		assert.Nil(t, cfg.Upsert(db.WithContext(context.TODO())))
+	})
+	t.Run("Remove", func(t *testing.T) {
+		assert.Nil(t, RemoveNetworkDNSSync("syncnet"))
+		assert.Equal(t, []string{"manual.syncnet.\t300\tIN\tA\t10.108.0.99"}, zone.list())
+		assert.NotNil(t, (&schema.DNSSyncConfig{Network: "syncnet"}).Get(db.WithContext(context.TODO())))
+	})
+	t.Run("Invalid", func(t *testing.T) {
+		assert.ErrorIs(t, ValidateDNSSyncConfig(&schema.DNSSyncConfig{Zone: "syncnet"}), ErrInvalidDNSSyncConfig)
+		assert.ErrorIs(t, ValidateDNSSyncConfig(&schema.DNSSyncConfig{Server: "10.0.0.1", Zone: "bad zone!"}), ErrInvalidDNSSyncConfig)
+		assert.ErrorIs(t, ValidateDNSSyncConfig(&schema.DNSSyncConfig{Server: "10.0.0.1", Zone: "syncnet", TSIGKeyName: "k", TSIGSecret: "not base64"}), ErrInvalidDNSSyncConfig)
+		assert.ErrorIs(t, ValidateDNSSyncConfig(&schema.DNSSyncConfig{Server: "10.0.0.1", Zone: "syncnet", TSIGKeyName: "k", TSIGAlgorithm: "md5", TSIGSecret: secret}), ErrInvalidDNSSyncConfig)
+		valid := &schema.DNSSyncConfig{Server: "10.0.0.1", Zone: "syncnet"}
+		assert.Nil(t, ValidateDNSSyncConfig(valid))
+		assert.Equal(t, "10.0.0.1:53", valid.Server)
+	})
+}
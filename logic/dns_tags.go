@@ -0,0 +1,172 @@
+package logic
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gravitl/netmaker/models"
+	"golang.org/x/exp/slog"
+)
+
+// TagDNSRefreshInterval - how often the members of tag DNS names are checked for nodes going on or offline
+const TagDNSRefreshInterval = time.Minute
+
+// ErrInvalidTagDNSService - a tag service without a valid name, protocol or port
+var ErrInvalidTagDNSService = errors.New("invalid tag dns service")
+
+var tagDNSServiceRegex = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,13}[a-z0-9])?$`)
+
+// GetNetworkTagsWithNodes - gets the tags of a network and the nodes carrying each of them, tags are a pro feature
+var GetNetworkTagsWithNodes = func(netID models.NetworkID) ([]models.Tag, map[models.TagID][]models.Node) {
+	return nil, nil
+}
+
+// ValidateTagDNSServices - checks the services published as SRV records of a tag
+func ValidateTagDNSServices(services []models.TagDNSService) error {
+	seen := make(map[string]struct{}, len(services))
+	for _, service := range services {
+		if !tagDNSServiceRegex.MatchString(service.Service) {
+			return fmt.Errorf("%w: invalid service name %s", ErrInvalidTagDNSService, service.Service)
+		}
+		if service.Protocol != "tcp" && service.Protocol != "udp" {
+			return fmt.Errorf("%w: protocol of %s must be tcp or udp", ErrInvalidTagDNSService, service.Service)
+		}
+		if service.Port == 0 {
+			return fmt.Errorf("%w: port of %s is required", ErrInvalidTagDNSService, service.Service)
+		}
+		key := service.Service + "/" + service.Protocol
+		if _, ok := seen[key]; ok {
+			return fmt.Errorf("%w: %s is set twice", ErrInvalidTagDNSService, key)
+		}
+		seen[key] = struct{}{}
+	}
+	return nil
+}
+
+// GetTagDNSName - gets the DNS name of a tag, <tag>.<network> followed by the default domain when set
+func GetTagDNSName(tagName, network string) string {
+	label := strings.ToLower(strings.Join(strings.Fields(tagName), "-"))
+	if defaultDomain := GetDefaultDomain(); defaultDomain != "" {
+		return fmt.Sprintf("%s.%s.%s", label, network, defaultDomain)
+	}
+	return fmt.Sprintf("%s.%s", label, network)
+}
+
+// GetTagDNS - gets the entries of a network's tags, the name of a tag resolves round-robin to every online node carrying it
+// and each service of the tag gets an SRV record per online node, only the embedded DNS server answers them
+func GetTagDNS(network string) []models.DNSEntry {
+	tags, tagNodes := GetNetworkTagsWithNodes(models.NetworkID(network))
+	if len(tags) == 0 {
+		return nil
+	}
+	defaultPolicy, _ := GetDefaultPolicy(models.NetworkID(network), models.DevicePolicy)
+	defaultDomain := GetDefaultDomain()
+	var entries []models.DNSEntry
+	for _, tag := range tags {
+		name := GetTagDNSName(tag.TagName, network)
+		members := getTagDNSMembers(tagNodes[tag.ID], defaultPolicy.Enabled, defaultDomain)
+		for _, member := range members {
+			entries = append(entries, models.DNSEntry{
+				Name:     name,
+				Network:  network,
+				Address:  member.Address,
+				Address6: member.Address6,
+			})
+		}
+		for _, service := range tag.DNSServices {
+			for _, member := range members {
+				entries = append(entries, models.DNSEntry{
+					Name:    fmt.Sprintf("_%s._%s.%s", service.Service, service.Protocol, name),
+					Network: network,
+					Type:    models.DNSRecordSRV,
+					Target:  member.Name,
+					Port:    service.Port,
+				})
+			}
+		}
+	}
+	return entries
+}
+
+// getTagDNSMembers - gets the node entries of the tagged nodes that are online, sorted by name
+func getTagDNSMembers(nodes []models.Node, defaultPolicyEnabled bool, defaultDomain string) []models.DNSEntry {
+	var members []models.DNSEntry
+	for _, node := range nodes {
+		GetNodeStatus(&node, defaultPolicyEnabled)
+		// nodes with a warning still check in, only some of their peers are unreachable
+		if node.Status != models.OnlineSt && node.Status != models.WarningSt {
+			continue
+		}
+		member := models.DNSEntry{}
+		if node.IsStatic {
+			member.Name = fmt.Sprintf("%s.%s", node.StaticNode.ClientID, node.StaticNode.Network)
+			member.Address, member.Address6 = node.StaticNode.Address, node.StaticNode.Address6
+		} else {
+			host, err := GetHost(node.HostID.String())
+			if err != nil {
+				continue
+			}
+			member.Name = fmt.Sprintf("%s.%s", host.Name, node.Network)
+			if defaultDomain != "" {
+				member.Name += "." + defaultDomain
+			}
+			if node.Address.IP != nil {
+				member.Address = node.Address.IP.String()
+			}
+			if node.Address6.IP != nil {
+				member.Address6 = node.Address6.IP.String()
+			}
+		}
+		if member.Address == "" && member.Address6 == "" {
+			continue
+		}
+		members = append(members, member)
+	}
+	sort.Slice(members, func(i, j int) bool {
+		return members[i].Name < members[j].Name
+	})
+	return members
+}
+
+// ManageTagDNS - rewrites the DNS entries when the online members of tag names change,
+// node status follows check-ins so there is no event for a node going offline
+func ManageTagDNS(ctx context.Context) {
+	ticker := time.NewTicker(TagDNSRefreshInterval)
+	defer ticker.Stop()
+	last := getAllTagDNSState()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			current := getAllTagDNSState()
+			if current == last {
+				continue
+			}
+			last = current
+			if err := SetDNS(); err != nil {
+				slog.Error("failed to update tag dns entries", "error", err)
+			}
+		}
+	}
+}
+
+// getAllTagDNSState - gets the tag entries of every network as one comparable string
+func getAllTagDNSState() string {
+	networks, err := GetNetworks()
+	if err != nil {
+		return ""
+	}
+	var state strings.Builder
+	for _, network := range networks {
+		for _, entry := range GetTagDNS(network.NetID) {
+			fmt.Fprintf(&state, "%s %s %s %s %d\n", entry.Name, entry.Address, entry.Address6, entry.Target, entry.Port)
+		}
+	}
+	return state.String()
+}
@@ -0,0 +1,133 @@
+package logic
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/models"
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTagDNS(t *testing.T) {
+	database.InitializeDatabase()
+	defer database.CloseDB()
+	network := models.Network{NetID: "tagnet", AddressRange: "10.109.0.0/24"}
+	assert.Nil(t, SaveNetwork(&network))
+	defer func() {
+		_ = database.DeleteRecord(database.NETWORKS_TABLE_NAME, network.NetID)
+	}()
+
+	staticNode := func(id, address string, enabled bool) models.Node {
+		return models.Node{IsStatic: true, StaticNode: models.ExtClient{
+			ClientID: id,
+			Network:  "tagnet",
+			Address:  address,
+			Enabled:  enabled,
+		}}
+	}
+	tagNodes := map[models.TagID][]models.Node{
+		"tagnet.db": {
+			staticNode("db2", "10.109.0.12", true),
+			staticNode("db1", "10.109.0.11", true),
+			staticNode("db3", "10.109.0.13", false),
+			{CommonNode: models.CommonNode{ID: uuid.New(), Network: "tagnet", Connected: false}, LastCheckIn: time.Now()},
+		},
+		"tagnet.web": {staticNode("web1", "10.109.0.21", true)},
+	}
+	tags := []models.Tag{
+		{ID: "tagnet.db", TagName: "DB", Network: "tagnet", DNSServices: []models.TagDNSService{{Service: "postgres", Protocol: "tcp", Port: 5432}}},
+		{ID: "tagnet.web", TagName: "web servers", Network: "tagnet"},
+	}
+	defaultTagsWithNodes := GetNetworkTagsWithNodes
+	GetNetworkTagsWithNodes = func(netID models.NetworkID) ([]models.Tag, map[models.TagID][]models.Node) {
+		if netID != "tagnet" {
+			return nil, nil
+		}
+		return tags, tagNodes
+	}
+	defer func() {
+		GetNetworkTagsWithNodes = defaultTagsWithNodes
+	}()
+
+	t.Run("Entries", func(t *testing.T) {
+		entries := GetTagDNS("tagnet")
+		assert.Len(t, entries, 5)
+		assert.Equal(t, models.DNSEntry{Name: "db.tagnet", Network: "tagnet", Address: "10.109.0.11"}, entries[0])
+		assert.Equal(t, "10.109.0.12", entries[1].Address)
+		assert.Equal(t, models.DNSEntry{
+			Name:    "_postgres._tcp.db.tagnet",
+			Network: "tagnet",
+			Type:    models.DNSRecordSRV,
+			Target:  "db1.tagnet",
+			Port:    5432,
+		}, entries[2])
+		assert.Equal(t, "web-servers.tagnet", entries[4].Name)
+	})
+	t.Run("Resolve", func(t *testing.T) {
+		resp := ResolveDNS(new(dns.Msg).SetQuestion("db.tagnet.", dns.TypeA), nil)
+		assert.Len(t, resp.Answer, 2)
+		resp = ResolveDNS(new(dns.Msg).SetQuestion("_postgres._tcp.db.tagnet.", dns.TypeSRV), nil)
+		assert.Len(t, resp.Answer, 2)
+		// nodes going offline drop out of the name
+		tagNodes["tagnet.db"][0].StaticNode.Enabled = false
+		InvalidateDNSSnapshot()
+		defer func() {
+			tagNodes["tagnet.db"][0].StaticNode.Enabled = true
+			InvalidateDNSSnapshot()
+		}()
+		resp = ResolveDNS(new(dns.Msg).SetQuestion("db.tagnet.", dns.TypeA), nil)
+		assert.Len(t, resp.Answer, 1)
+		assert.Equal(t, "10.109.0.11", resp.Answer[0].(*dns.A).A.String())
+	})
+	t.Run("ParallelQueries", func(t *testing.T) {
+		// run with -race, answers are shuffled per query and leave the shared snapshot alone
+		InvalidateDNSSnapshot()
+		var wg sync.WaitGroup
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := 0; j < 50; j++ {
+					resp := ResolveDNS(new(dns.Msg).SetQuestion("db.tagnet.", dns.TypeA), nil)
+					assert.Len(t, resp.Answer, 2)
+				}
+			}()
+		}
+		wg.Wait()
+		entries := getDNSSnapshot().byName["db.tagnet."]
+		assert.Len(t, entries, 2)
+		assert.Equal(t, "10.109.0.11", entries[0].Address)
+		assert.Equal(t, "10.109.0.12", entries[1].Address)
+	})
+	t.Run("NoReverseRecords", func(t *testing.T) {
+		// tag names stay out of the network's own entries, PTR records, zone exports and dynamic dns
+		entries, err := GetDNS("tagnet")
+		assert.Nil(t, err)
+		assert.Empty(t, entries)
+		ptrs, err := GetReverseDNS("tagnet")
+		assert.Nil(t, err)
+		assert.Empty(t, ptrs)
+		resp := ResolveDNS(new(dns.Msg).SetQuestion("11.0.109.10.in-addr.arpa.", dns.TypePTR), nil)
+		assert.Equal(t, dns.RcodeNameError, resp.Rcode)
+	})
+	t.Run("CustomEntryWins", func(t *testing.T) {
+		_, err := CreateDNS(models.DNSEntry{Name: "web-servers.tagnet", Network: "tagnet", Address: "10.109.0.99"})
+		assert.Nil(t, err)
+		defer DeleteDNS("web-servers.tagnet", "tagnet")
+		InvalidateDNSSnapshot()
+		resp := ResolveDNS(new(dns.Msg).SetQuestion("web-servers.tagnet.", dns.TypeA), nil)
+		assert.Len(t, resp.Answer, 1)
+		assert.Equal(t, "10.109.0.99", resp.Answer[0].(*dns.A).A.String())
+	})
+	t.Run("ValidateServices", func(t *testing.T) {
+		assert.Nil(t, ValidateTagDNSServices([]models.TagDNSService{{Service: "http", Protocol: "tcp", Port: 80}, {Service: "http", Protocol: "udp", Port: 80}}))
+		assert.ErrorIs(t, ValidateTagDNSServices([]models.TagDNSService{{Service: "_http", Protocol: "tcp", Port: 80}}), ErrInvalidTagDNSService)
+		assert.ErrorIs(t, ValidateTagDNSServices([]models.TagDNSService{{Service: "http", Protocol: "sctp", Port: 80}}), ErrInvalidTagDNSService)
+		assert.ErrorIs(t, ValidateTagDNSServices([]models.TagDNSService{{Service: "http", Protocol: "tcp"}}), ErrInvalidTagDNSService)
+		assert.ErrorIs(t, ValidateTagDNSServices([]models.TagDNSService{{Service: "http", Protocol: "tcp", Port: 80}, {Service: "http", Protocol: "tcp", Port: 8080}}), ErrInvalidTagDNSService)
+	})
+}
@@ -0,0 +1,237 @@
+package logic
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/models"
+	"github.com/miekg/dns"
+)
+
+// GetDNSZoneOrigin - gets the origin a network's zone file is written with,
+// the default domain or else the network name
+func GetDNSZoneOrigin(network, origin string) string {
+	if origin == "" {
+		origin = GetDefaultDomain()
+	}
+	if origin == "" {
+		origin = network
+	}
+	return strings.ToLower(dns.Fqdn(origin))
+}
+
+// ExportDNSZone - writes the node, ext client and custom entries of a network as an RFC 1035 zone file,
+// custom entries outside the origin follow as absolute names so the export imports back,
+// node and ext client names outside it are listed as comments since netmaker recreates them
+func ExportDNSZone(network, origin string) (string, error) {
+	if _, err := GetNetwork(network); err != nil {
+		return "", err
+	}
+	origin = GetDNSZoneOrigin(network, origin)
+	generated, err := GetNodeDNS(network)
+	if err != nil && !database.IsEmptyRecord(err) {
+		return "", err
+	}
+	for _, entry := range GetExtclientDNS() {
+		if entry.Network == network {
+			generated = append(generated, entry)
+		}
+	}
+	custom, err := GetCustomDNS(network)
+	if err != nil && !database.IsEmptyRecord(err) {
+		return "", err
+	}
+	isGenerated := make(map[string]bool, len(generated))
+	for _, entry := range generated {
+		isGenerated[strings.ToLower(entry.Name)] = true
+	}
+	entries := append(generated, custom...)
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Name < entries[j].Name
+	})
+	var zone strings.Builder
+	fmt.Fprintf(&zone, "; netmaker network %s\n$ORIGIN %s\n$TTL %d\n", network, origin, DNSDefaultTTL)
+	zone.WriteString(dnsSOA(origin).String() + "\n")
+	var outside []models.DNSEntry
+	var skipped []string
+	for _, entry := range entries {
+		name := strings.ToLower(dns.Fqdn(entry.Name))
+		if !dns.IsSubDomain(origin, name) {
+			if isGenerated[strings.ToLower(entry.Name)] {
+				skipped = append(skipped, name)
+			} else {
+				outside = append(outside, entry)
+			}
+			continue
+		}
+		for _, rr := range dnsEntryRecords(entry, name) {
+			zone.WriteString(rr.String() + "\n")
+		}
+	}
+	if len(outside) > 0 {
+		zone.WriteString("; custom entries outside the origin\n")
+	}
+	for _, entry := range outside {
+		for _, rr := range dnsEntryRecords(entry, strings.ToLower(dns.Fqdn(entry.Name))) {
+			zone.WriteString(rr.String() + "\n")
+		}
+	}
+	for _, name := range skipped {
+		fmt.Fprintf(&zone, "; skipped %s, outside the origin\n", name)
+	}
+	return zone.String(), nil
+}
+
+// ImportDNSZone - parses an RFC 1035 zone file into custom entries of a network,
+// names taken by existing entries are reported as conflicts and nothing is created on a dry run
+func ImportDNSZone(network string, req models.DNSZoneImportRequest) (models.DNSZoneImport, error) {
+	result := models.DNSZoneImport{
+		DryRun:    req.DryRun,
+		Created:   []models.DNSEntry{},
+		Conflicts: []models.DNSZoneConflict{},
+		Skipped:   []models.DNSZoneConflict{},
+	}
+	if _, err := GetNetwork(network); err != nil {
+		return result, err
+	}
+	entries, skipped, err := parseDNSZone(network, GetDNSZoneOrigin(network, req.Origin), strings.NewReader(req.Zone))
+	if err != nil {
+		return result, err
+	}
+	result.Skipped = append(result.Skipped, skipped...)
+	// an export carries the ext client names when they are under the origin
+	extClientNames := make(map[string]bool)
+	for _, entry := range GetExtclientDNS() {
+		if entry.Network == network {
+			extClientNames[strings.ToLower(entry.Name)] = true
+		}
+	}
+	for _, parsed := range entries {
+		conflict := models.DNSZoneConflict{Record: parsed.record, Entry: parsed.entry}
+		if extClientNames[parsed.entry.Name] || isDNSNameTaken(parsed.entry, nil) {
+			conflict.Reason = "name already has this record or a CNAME"
+			result.Conflicts = append(result.Conflicts, conflict)
+			continue
+		}
+		if err := ValidateDNSCreate(parsed.entry); err != nil {
+			conflict.Reason = err.Error()
+			result.Skipped = append(result.Skipped, conflict)
+			continue
+		}
+		if !req.DryRun {
+			if _, err := CreateDNS(parsed.entry); err != nil {
+				return result, err
+			}
+		}
+		result.Created = append(result.Created, parsed.entry)
+	}
+	return result, nil
+}
+
+type parsedDNSEntry struct {
+	record string
+	entry  models.DNSEntry
+}
+
+// parseDNSZone - turns the records of a zone file into entries, a name holds a single address entry
+// so the A and AAAA records of a name are merged, repeated records and records next to a CNAME are skipped
+func parseDNSZone(network, origin string, zone io.Reader) ([]parsedDNSEntry, []models.DNSZoneConflict, error) {
+	var entries []parsedDNSEntry
+	var skipped []models.DNSZoneConflict
+	byNameType := make(map[string]int)
+	types := make(map[string][]models.DNSRecordType)
+	parser := dns.NewZoneParser(zone, origin, "")
+	for rr, ok := parser.Next(); ok; rr, ok = parser.Next() {
+		hdr := rr.Header()
+		entry := models.DNSEntry{
+			Name:    strings.ToLower(strings.TrimSuffix(hdr.Name, ".")),
+			Network: network,
+			TTL:     hdr.Ttl,
+		}
+		switch record := rr.(type) {
+		case *dns.A:
+			entry.Address = record.A.String()
+		case *dns.AAAA:
+			entry.Address6 = record.AAAA.String()
+		case *dns.CNAME:
+			entry.Type = models.DNSRecordCNAME
+			entry.Target = strings.TrimSuffix(record.Target, ".")
+		case *dns.SRV:
+			entry.Type = models.DNSRecordSRV
+			entry.Target = strings.TrimSuffix(record.Target, ".")
+			entry.Priority, entry.Weight, entry.Port = record.Priority, record.Weight, record.Port
+		case *dns.MX:
+			entry.Type = models.DNSRecordMX
+			entry.Target = strings.TrimSuffix(record.Mx, ".")
+			entry.Priority = record.Preference
+		case *dns.TXT:
+			entry.Type = models.DNSRecordTXT
+			entry.Text = record.Txt
+		case *dns.SOA, *dns.NS:
+			// zone metadata, the netmaker zones have their own
+			continue
+		default:
+			skipped = append(skipped, models.DNSZoneConflict{
+				Record: rr.String(),
+				Reason: "unsupported record type " + dns.TypeToString[hdr.Rrtype],
+			})
+			continue
+		}
+		if entry.TTL == DNSDefaultTTL {
+			entry.TTL = 0
+		}
+		key := entry.Name + " " + string(entry.RecordType()) + " " + dnsEntryValue(entry)
+		i, exists := byNameType[key]
+		if !exists {
+			if len(types[entry.Name]) > 0 && (entry.RecordType() == models.DNSRecordCNAME ||
+				slices.Contains(types[entry.Name], models.DNSRecordCNAME)) {
+				skipped = append(skipped, models.DNSZoneConflict{
+					Record: rr.String(),
+					Entry:  entry,
+					Reason: "a CNAME can not share its name with other records",
+				})
+				continue
+			}
+			byNameType[key] = len(entries)
+			types[entry.Name] = append(types[entry.Name], entry.RecordType())
+			entries = append(entries, parsedDNSEntry{record: rr.String(), entry: entry})
+			continue
+		}
+		if !mergeDNSAddress(&entries[i].entry, entry) {
+			skipped = append(skipped, models.DNSZoneConflict{
+				Record: rr.String(),
+				Entry:  entry,
+				Reason: "duplicate record in zone file",
+			})
+			continue
+		}
+		entries[i].record += "\n" + rr.String()
+	}
+	if err := parser.Err(); err != nil {
+		return nil, nil, errors.New("invalid zone file: " + err.Error())
+	}
+	return entries, skipped, nil
+}
+
+// mergeDNSAddress - adds the address of an A or AAAA record to the address entry of the same name,
+// returns false when the entry already has an address of that family
+func mergeDNSAddress(entry *models.DNSEntry, other models.DNSEntry) bool {
+	if entry.RecordType() != models.DNSRecordA || other.RecordType() != models.DNSRecordA {
+		return false
+	}
+	if other.Address != "" && entry.Address == "" && net.ParseIP(other.Address) != nil {
+		entry.Address = other.Address
+		return true
+	}
+	if other.Address6 != "" && entry.Address6 == "" {
+		entry.Address6 = other.Address6
+		return true
+	}
+	return false
+}
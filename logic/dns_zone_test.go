@@ -0,0 +1,148 @@
+package logic
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDNSZoneImportExport(t *testing.T) {
+	t.Setenv("EMBEDDED_DNS", "true")
+	database.InitializeDatabase()
+	defer database.CloseDB()
+	network := models.Network{NetID: "zonenet", AddressRange: "10.107.0.0/24"}
+	assert.Nil(t, SaveNetwork(&network))
+	defer func() {
+		_ = database.DeleteRecord(database.NETWORKS_TABLE_NAME, network.NetID)
+	}()
+	_, err := CreateDNS(models.DNSEntry{Name: "existing.corp.example", Network: "zonenet", Address: "10.107.0.5"})
+	assert.Nil(t, err)
+	defer DeleteDNS("existing.corp.example", "zonenet")
+	_, err = CreateDNS(models.DNSEntry{Name: "nas", Network: "zonenet", Address: "10.107.0.7"})
+	assert.Nil(t, err)
+	defer DeleteDNS("nas", "zonenet")
+	client := models.ExtClient{ClientID: "laptop", Network: "zonenet", Address: "10.107.0.30"}
+	assert.Nil(t, SaveExtClient(&client))
+	defer DeleteExtClient(client.Network, client.ClientID)
+
+	zone := `$ORIGIN corp.example.
+$TTL 3600
+@        IN SOA ns1 hostmaster 1 7200 3600 1209600 3600
+@        IN NS  ns1
+app      IN A    10.107.0.10
+app      IN AAAA fd00:107::10
+app      IN A    10.107.0.11
+www  300 IN CNAME app
+_ldap._tcp IN SRV 0 100 389 dc1.corp.example.
+@        IN MX  10 mail
+@        IN MX  20 mail
+@        IN MX  10 mail
+info     IN TXT "hello world"
+mail     IN A   10.107.0.25
+mail     IN TXT "mail relay"
+www      IN TXT "alias"
+existing IN A   10.107.0.6
+host     IN HINFO "x86" "linux"
+`
+	cleanup := func() {
+		for _, name := range []string{"app.corp.example", "www.corp.example", "_ldap._tcp.corp.example", "corp.example", "info.corp.example", "mail.corp.example"} {
+			_ = DeleteDNS(name, "zonenet")
+		}
+	}
+	defer cleanup()
+
+	t.Run("DryRun", func(t *testing.T) {
+		result, err := ImportDNSZone("zonenet", models.DNSZoneImportRequest{Zone: zone, Origin: "corp.example", DryRun: true})
+		assert.Nil(t, err)
+		assert.True(t, result.DryRun)
+		assert.Len(t, result.Created, 8)
+		assert.Len(t, result.Conflicts, 1)
+		assert.Equal(t, "existing.corp.example", result.Conflicts[0].Entry.Name)
+		// the second A record of app, the repeated MX record, the TXT record next to the www CNAME and the HINFO record
+		assert.Len(t, result.Skipped, 4)
+		num, _ := GetDNSEntryNum("app.corp.example", "zonenet")
+		assert.Zero(t, num)
+	})
+	t.Run("Import", func(t *testing.T) {
+		result, err := ImportDNSZone("zonenet", models.DNSZoneImportRequest{Zone: zone, Origin: "corp.example"})
+		assert.Nil(t, err)
+		assert.Len(t, result.Created, 8)
+		custom, err := GetCustomDNS("zonenet")
+		assert.Nil(t, err)
+		byName := map[string]models.DNSEntry{}
+		for _, entry := range custom {
+			byName[entry.Name] = entry
+		}
+		assert.Equal(t, "10.107.0.10", byName["app.corp.example"].Address)
+		assert.Equal(t, "fd00:107::10", byName["app.corp.example"].Address6)
+		assert.Equal(t, uint32(3600), byName["app.corp.example"].TTL)
+		assert.Equal(t, models.DNSRecordCNAME, byName["www.corp.example"].Type)
+		assert.Equal(t, "app.corp.example", byName["www.corp.example"].Target)
+		assert.Equal(t, uint16(389), byName["_ldap._tcp.corp.example"].Port)
+		assert.Equal(t, "mail.corp.example", byName["corp.example"].Target)
+		assert.Equal(t, []string{"hello world"}, byName["info.corp.example"].Text)
+
+		// importing again only reports conflicts
+		result, err = ImportDNSZone("zonenet", models.DNSZoneImportRequest{Zone: zone, Origin: "corp.example"})
+		assert.Nil(t, err)
+		assert.Empty(t, result.Created)
+		assert.Len(t, result.Conflicts, 9)
+	})
+	t.Run("Export", func(t *testing.T) {
+		exported, err := ExportDNSZone("zonenet", "corp.example")
+		assert.Nil(t, err)
+		assert.Contains(t, exported, "$ORIGIN corp.example.")
+		assert.Contains(t, exported, "app.corp.example.\t3600\tIN\tA\t10.107.0.10")
+		assert.Contains(t, exported, "www.corp.example.\t300\tIN\tCNAME\tapp.corp.example.")
+		assert.Contains(t, exported, "_ldap._tcp.corp.example.\t3600\tIN\tSRV\t0 100 389 dc1.corp.example.")
+		assert.Contains(t, exported, "info.corp.example.\t3600\tIN\tTXT\t\"hello world\"")
+		// custom entries outside the origin are kept as absolute names, the ext client's name is not exported
+		assert.Contains(t, exported, "nas.\t60\tIN\tA\t10.107.0.7")
+		assert.Contains(t, exported, "; skipped laptop.zonenet., outside the origin")
+
+		parsed, skipped, err := parseDNSZone("zonenet", "corp.example.", strings.NewReader(exported))
+		assert.Nil(t, err)
+		assert.Empty(t, skipped)
+		custom, err := GetCustomDNS("zonenet")
+		assert.Nil(t, err)
+		var entries []models.DNSEntry
+		for _, p := range parsed {
+			entries = append(entries, p.entry)
+		}
+		assert.ElementsMatch(t, custom, entries)
+
+		// an export parses back into the same entries
+		result, err := ImportDNSZone("zonenet", models.DNSZoneImportRequest{Zone: exported, DryRun: true})
+		assert.Nil(t, err)
+		assert.Empty(t, result.Created)
+		assert.Len(t, result.Conflicts, 10)
+		assert.Empty(t, result.Skipped)
+	})
+	t.Run("ExportWithoutOrigin", func(t *testing.T) {
+		// with no default domain the origin is the network name and bare custom names fall outside it
+		exported, err := ExportDNSZone("zonenet", "")
+		assert.Nil(t, err)
+		assert.Contains(t, exported, "$ORIGIN zonenet.")
+		assert.Contains(t, exported, "laptop.zonenet.\t60\tIN\tA\t10.107.0.30")
+		assert.Contains(t, exported, "nas.\t60\tIN\tA\t10.107.0.7")
+		assert.Contains(t, exported, "app.corp.example.\t3600\tIN\tA\t10.107.0.10")
+
+		assert.Nil(t, DeleteDNS("nas", "zonenet"))
+		result, err := ImportDNSZone("zonenet", models.DNSZoneImportRequest{Zone: exported})
+		assert.Nil(t, err)
+		// the ext client's name comes back as a conflict
+		assert.Len(t, result.Conflicts, 10)
+		assert.Len(t, result.Created, 1)
+		assert.Equal(t, "nas", result.Created[0].Name)
+		assert.Equal(t, "10.107.0.7", result.Created[0].Address)
+	})
+	t.Run("InvalidZone", func(t *testing.T) {
+		_, err := ImportDNSZone("zonenet", models.DNSZoneImportRequest{Zone: "app IN A not-an-ip"})
+		assert.NotNil(t, err)
+		_, err = ImportDNSZone("missingnet", models.DNSZoneImportRequest{Zone: zone})
+		assert.NotNil(t, err)
+	})
+}
@@ -1,19 +1,26 @@
 package logic
 
 import (
+	"context"
 	b64 "encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"net"
+	"regexp"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/db"
+	"github.com/gravitl/netmaker/logger"
 	"github.com/gravitl/netmaker/models"
+	"github.com/gravitl/netmaker/schema"
 	"github.com/gravitl/netmaker/servercfg"
 	"golang.org/x/exp/slices"
+	"golang.org/x/exp/slog"
 )
 
 // EnrollmentErrors - struct for holding EnrollmentKey error messages
@@ -24,6 +31,7 @@ var EnrollmentErrors = struct {
 	NoUsesRemaining    error
 	FailedToTokenize   error
 	FailedToDeTokenize error
+	ConstraintViolated error
 }{
 	InvalidCreate:      fmt.Errorf("failed to create enrollment key. paramters invalid"),
 	NoKeyFound:         fmt.Errorf("no enrollmentkey found"),
@@ -31,6 +39,7 @@ var EnrollmentErrors = struct {
 	NoUsesRemaining:    fmt.Errorf("no uses remaining"),
 	FailedToTokenize:   fmt.Errorf("failed to tokenize"),
 	FailedToDeTokenize: fmt.Errorf("failed to detokenize"),
+	ConstraintViolated: fmt.Errorf("enrollment key constraint violated"),
 }
 var (
 	enrollmentkeyCacheMutex = &sync.RWMutex{}
@@ -38,7 +47,7 @@ var (
 )
 
 // CreateEnrollmentKey - creates a new enrollment key in db
-func CreateEnrollmentKey(uses int, expiration time.Time, networks, tags []string, groups []models.TagID, unlimited bool, relay uuid.UUID, defaultKey, autoEgress bool) (*models.EnrollmentKey, error) {
+func CreateEnrollmentKey(uses int, expiration time.Time, networks, tags []string, groups []models.TagID, unlimited bool, relay uuid.UUID, defaultKey, autoEgress bool, constraints models.EnrollmentKeyConstraints, profile *models.HostProvisioningProfile, attestation *models.EnrollmentKeyAttestation) (*models.EnrollmentKey, error) {
 	newKeyID, err := getUniqueEnrollmentID()
 	if err != nil {
 		return nil, err
@@ -55,6 +64,9 @@ func CreateEnrollmentKey(uses int, expiration time.Time, networks, tags []string
 		Groups:        groups,
 		Default:       defaultKey,
 		AutoEgress:    autoEgress,
+		Constraints:   constraints,
+		Profile:       profile,
+		Attestation:   attestation,
 	}
 	if uses > 0 {
 		k.UsesRemaining = uses
@@ -89,15 +101,26 @@ func CreateEnrollmentKey(uses int, expiration time.Time, networks, tags []string
 	if err = upsertEnrollmentKey(k); err != nil {
 		return nil, err
 	}
+	warnSourceCIDRsWithoutProxies(k)
 	return k, nil
 }
 
-// UpdateEnrollmentKey - updates an existing enrollment key's associated relay
-func UpdateEnrollmentKey(keyId string, relayId uuid.UUID, groups []models.TagID) (*models.EnrollmentKey, error) {
+// UpdateEnrollmentKey - updates an existing enrollment key's associated relay, groups, constraints, profile and attestation
+func UpdateEnrollmentKey(keyId string, relayId uuid.UUID, groups []models.TagID, constraints models.EnrollmentKeyConstraints,
+	profile *models.HostProvisioningProfile, attestation *models.EnrollmentKeyAttestation) (*models.EnrollmentKey, error) {
 	key, err := GetEnrollmentKey(keyId)
 	if err != nil {
 		return nil, err
 	}
+	if err := constraints.Validate(); err != nil {
+		return nil, err
+	}
+	if err := profile.Validate(); err != nil {
+		return nil, err
+	}
+	if err := attestation.Validate(); err != nil {
+		return nil, err
+	}
 
 	if relayId != uuid.Nil {
 		relayNode, err := GetNodeByID(relayId.String())
@@ -114,13 +137,25 @@ func UpdateEnrollmentKey(keyId string, relayId uuid.UUID, groups []models.TagID)
 
 	key.Relay = relayId
 	key.Groups = groups
+	key.Constraints = constraints
+	key.Profile = profile
+	key.Attestation = attestation
 	if err = upsertEnrollmentKey(&key); err != nil {
 		return nil, err
 	}
+	warnSourceCIDRsWithoutProxies(&key)
 
 	return &key, nil
 }
 
+// warnSourceCIDRsWithoutProxies - warns that the source cidrs of a key are matched against the address of the
+// reverse proxy in front of the server, e.g. caddy, until TRUSTED_PROXIES lets ParseRequestIP see the client's
+func warnSourceCIDRsWithoutProxies(k *models.EnrollmentKey) {
+	if len(k.Constraints.SourceCIDRs) > 0 && len(servercfg.GetTrustedProxies()) == 0 {
+		slog.Warn("enrollment key has source cidrs but TRUSTED_PROXIES is not set, behind a reverse proxy no host will match them", "key", k.Value)
+	}
+}
+
 // GetAllEnrollmentKeys - fetches all enrollment keys from DB
 func GetAllEnrollmentKeys() ([]models.EnrollmentKey, error) {
 	currentKeys, err := getEnrollmentKeysMap()
@@ -171,9 +206,33 @@ func DeleteEnrollmentKey(value string, force bool) error {
 	return err
 }
 
-// TryToUseEnrollmentKey - checks first if key can be decremented
-// returns true if it is decremented or isvalid
-func TryToUseEnrollmentKey(k *models.EnrollmentKey) bool {
+// TryToUseEnrollmentKey - checks first if the host satisfies the key's constraints
+// and if key can be decremented, returns true if it is decremented or isvalid
+func TryToUseEnrollmentKey(k *models.EnrollmentKey, h *models.Host, sourceIP string) bool {
+	if err := CheckEnrollmentKeyConstraints(k, h, sourceIP, time.Now()); err != nil {
+		logger.Log(0, "host", h.ID.String(), h.Name, "rejected by enrollment key constraints:", err.Error())
+		LogEvent(&models.Event{
+			Action: models.EnrollmentViolation,
+			Source: models.Subject{
+				ID:   h.ID.String(),
+				Name: h.Name,
+				Type: models.DeviceSub,
+				Info: map[string]string{
+					"source_ip": sourceIP,
+					"os":        h.OS,
+					"reason":    err.Error(),
+				},
+			},
+			TriggeredBy: h.Name,
+			Target: models.Subject{
+				ID:   k.Value,
+				Name: enrollmentKeyName(k),
+				Type: models.EnrollmentKeySub,
+			},
+			Origin: models.Api,
+		})
+		return false
+	}
 	key, err := decrementEnrollmentKey(k.Value)
 	if err != nil {
 		if errors.Is(err, EnrollmentErrors.NoUsesRemaining) {
@@ -186,6 +245,160 @@ func TryToUseEnrollmentKey(k *models.EnrollmentKey) bool {
 	return false
 }
 
+// CheckEnrollmentKeyConstraints - checks the registering host and the source
+// of its request against the constraints configured on the key,
+// sourceIP comes from ParseRequestIP so clients can't pick it with forwarding headers
+func CheckEnrollmentKeyConstraints(k *models.EnrollmentKey, h *models.Host, sourceIP string, now time.Time) error {
+	c := k.Constraints
+	if len(c.SourceCIDRs) > 0 {
+		ip := net.ParseIP(sourceIP)
+		if ip == nil {
+			return fmt.Errorf("%w: unknown source ip", EnrollmentErrors.ConstraintViolated)
+		}
+		allowed := false
+		for _, cidr := range c.SourceCIDRs {
+			_, ipNet, err := net.ParseCIDR(cidr)
+			if err == nil && ipNet.Contains(ip) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			if len(servercfg.GetTrustedProxies()) == 0 {
+				return fmt.Errorf("%w: source ip %s not in allowed ranges, TRUSTED_PROXIES is not set so behind a reverse proxy the source ip is the proxy's", EnrollmentErrors.ConstraintViolated, sourceIP)
+			}
+			return fmt.Errorf("%w: source ip %s not in allowed ranges", EnrollmentErrors.ConstraintViolated, sourceIP)
+		}
+	}
+	if c.HostNamePattern != "" {
+		re, err := regexp.Compile(c.HostNamePattern)
+		if err != nil || !re.MatchString(h.Name) {
+			return fmt.Errorf("%w: host name %s does not match pattern", EnrollmentErrors.ConstraintViolated, h.Name)
+		}
+	}
+	if len(c.AllowedOS) > 0 && !slices.Contains(c.AllowedOS, h.OS) {
+		return fmt.Errorf("%w: os %s not allowed", EnrollmentErrors.ConstraintViolated, h.OS)
+	}
+	if c.AllowedHours != nil && !c.AllowedHours.Contains(now) {
+		return fmt.Errorf("%w: outside of allowed hours", EnrollmentErrors.ConstraintViolated)
+	}
+	return nil
+}
+
+// ApplyHostProvisioningProfile - applies the host level settings of a provisioning profile,
+// returns true if the host was changed; Ephemeral is only applied when the host is created
+// so an existing host re-enrolling with an ephemeral key is not removed once offline
+func ApplyHostProvisioningProfile(h *models.Host, p *models.HostProvisioningProfile) bool {
+	if p == nil {
+		return false
+	}
+	orig := *h
+	if p.MTU > 0 {
+		h.MTU = p.MTU
+	}
+	if p.PersistentKeepalive > 0 {
+		h.PersistentKeepalive = time.Duration(p.PersistentKeepalive) * time.Second
+	}
+	if p.Interface != "" {
+		h.Interface = p.Interface
+	}
+	if p.IsStaticPort {
+		h.IsStaticPort = true
+	}
+	if p.ListenPort > 0 {
+		h.ListenPort = p.ListenPort
+	}
+	if p.AutoUpdate != nil {
+		h.AutoUpdate = *p.AutoUpdate
+	}
+	if p.Verbosity != nil {
+		h.Verbosity = *p.Verbosity
+	}
+	if p.HostNameTemplate != "" {
+		h.Name = RenderHostNameTemplate(p.HostNameTemplate, h)
+	}
+	return h.MTU != orig.MTU || h.PersistentKeepalive != orig.PersistentKeepalive ||
+		h.Interface != orig.Interface || h.IsStaticPort != orig.IsStaticPort ||
+		h.ListenPort != orig.ListenPort || h.AutoUpdate != orig.AutoUpdate ||
+		h.Verbosity != orig.Verbosity || h.Name != orig.Name
+}
+
+// ApplyNodeProvisioningProfile - applies the node level settings of a provisioning profile
+// to a node that just joined a network
+func ApplyNodeProvisioningProfile(node *models.Node, p *models.HostProvisioningProfile) {
+	if p == nil {
+		return
+	}
+	if p.NodeExpiration > 0 {
+		node.ExpirationDateTime = time.Now().Add(time.Duration(p.NodeExpiration) * time.Second)
+		if err := UpsertNode(node); err != nil {
+			slog.Error("failed to set node expiration", "nodeid", node.ID.String(), "error", err)
+		}
+	}
+	if p.Gateway {
+		if gwNode, err := CreateGateway(node.Network, node.ID.String(), models.CreateGwReq{}); err != nil {
+			slog.Error("failed to make node a gateway", "nodeid", node.ID.String(), "error", err)
+		} else {
+			*node = gwNode
+		}
+	}
+	if p.FailOver {
+		if err := CreateFailOver(*node); err != nil {
+			slog.Error("failed to make node a failover", "nodeid", node.ID.String(), "error", err)
+		}
+	}
+}
+
+// RenderHostNameTemplate - renders a host name template, supported placeholders are
+// {name}, {os}, {mac}, {mac4} (last 4 hex digits of the mac address) and {id4}
+func RenderHostNameTemplate(tmpl string, h *models.Host) string {
+	mac := strings.ReplaceAll(h.MacAddress.String(), ":", "")
+	id := strings.ReplaceAll(h.ID.String(), "-", "")
+	mac4 := id[:4]
+	if len(mac) >= 4 {
+		mac4 = mac[len(mac)-4:]
+	}
+	return strings.NewReplacer(
+		"{name}", h.Name,
+		"{os}", h.OS,
+		"{mac}", mac,
+		"{mac4}", mac4,
+		"{id4}", id[:4],
+	).Replace(tmpl)
+}
+
+// RecordEnrollmentKeyUsage - persists a record of a host registering with the given key,
+// identity is the verified cloud instance identity for attested registrations
+func RecordEnrollmentKeyUsage(k *models.EnrollmentKey, h *models.Host, sourceIP string, identity *models.CloudIdentity) error {
+	usage := schema.EnrollmentKeyUsage{
+		ID:        uuid.New().String(),
+		KeyID:     k.Value,
+		HostID:    h.ID.String(),
+		HostName:  h.Name,
+		PublicIP:  sourceIP,
+		Networks:  k.Networks,
+		Tags:      k.Groups,
+		TimeStamp: time.Now().UTC(),
+	}
+	if identity != nil {
+		usage.InstanceID = instanceUsageID(*identity)
+		usage.AttestationDigest = identity.Digest
+	}
+	return usage.Create(db.WithContext(context.TODO()))
+}
+
+// GetEnrollmentKeyUsage - fetches the usage history of a key, most recent first
+func GetEnrollmentKeyUsage(keyID string) ([]schema.EnrollmentKeyUsage, error) {
+	usages, err := (&schema.EnrollmentKeyUsage{KeyID: keyID}).ListByKey(db.WithContext(context.TODO()))
+	if err != nil {
+		return nil, err
+	}
+	if usages == nil {
+		usages = []schema.EnrollmentKeyUsage{}
+	}
+	return usages, nil
+}
+
 // Tokenize - tokenizes an enrollment key to be used via registration
 // and attaches it to the Token field on the struct
 func Tokenize(k *models.EnrollmentKey, serverAddr string) error {
@@ -246,6 +459,13 @@ func decrementEnrollmentKey(value string) (*models.EnrollmentKey, error) {
 	return &k, nil
 }
 
+func enrollmentKeyName(k *models.EnrollmentKey) string {
+	if len(k.Tags) > 0 {
+		return k.Tags[0]
+	}
+	return k.Value
+}
+
 func upsertEnrollmentKey(k *models.EnrollmentKey) error {
 	if k == nil {
 		return EnrollmentErrors.InvalidKey
@@ -3,6 +3,9 @@ package logic
 import (
 	"github.com/gravitl/netmaker/db"
 	"github.com/gravitl/netmaker/schema"
+	"net"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 	"time"
 
@@ -19,35 +22,35 @@ func TestCreateEnrollmentKey(t *testing.T) {
 	database.InitializeDatabase()
 	defer database.CloseDB()
 	t.Run("Can_Not_Create_Key", func(t *testing.T) {
-		newKey, err := CreateEnrollmentKey(0, time.Time{}, nil, nil, nil, false, uuid.Nil, false, false)
+		newKey, err := CreateEnrollmentKey(0, time.Time{}, nil, nil, nil, false, uuid.Nil, false, false, models.EnrollmentKeyConstraints{}, nil, nil)
 		assert.Nil(t, newKey)
 		assert.NotNil(t, err)
 		assert.ErrorIs(t, err, models.ErrInvalidEnrollmentKey)
 	})
 	t.Run("Can_Create_Key_Uses", func(t *testing.T) {
-		newKey, err := CreateEnrollmentKey(1, time.Time{}, nil, nil, nil, false, uuid.Nil, false, false)
+		newKey, err := CreateEnrollmentKey(1, time.Time{}, nil, nil, nil, false, uuid.Nil, false, false, models.EnrollmentKeyConstraints{}, nil, nil)
 		assert.Nil(t, err)
 		assert.Equal(t, 1, newKey.UsesRemaining)
 		assert.True(t, newKey.IsValid())
 	})
 	t.Run("Can_Create_Key_Time", func(t *testing.T) {
-		newKey, err := CreateEnrollmentKey(0, time.Now().Add(time.Minute), nil, nil, nil, false, uuid.Nil, false, false)
+		newKey, err := CreateEnrollmentKey(0, time.Now().Add(time.Minute), nil, nil, nil, false, uuid.Nil, false, false, models.EnrollmentKeyConstraints{}, nil, nil)
 		assert.Nil(t, err)
 		assert.True(t, newKey.IsValid())
 	})
 	t.Run("Can_Create_Key_Unlimited", func(t *testing.T) {
-		newKey, err := CreateEnrollmentKey(0, time.Time{}, nil, nil, nil, true, uuid.Nil, false, false)
+		newKey, err := CreateEnrollmentKey(0, time.Time{}, nil, nil, nil, true, uuid.Nil, false, false, models.EnrollmentKeyConstraints{}, nil, nil)
 		assert.Nil(t, err)
 		assert.True(t, newKey.IsValid())
 	})
 	t.Run("Can_Create_Key_WithNetworks", func(t *testing.T) {
-		newKey, err := CreateEnrollmentKey(0, time.Time{}, []string{"mynet", "skynet"}, nil, nil, true, uuid.Nil, false, false)
+		newKey, err := CreateEnrollmentKey(0, time.Time{}, []string{"mynet", "skynet"}, nil, nil, true, uuid.Nil, false, false, models.EnrollmentKeyConstraints{}, nil, nil)
 		assert.Nil(t, err)
 		assert.True(t, newKey.IsValid())
 		assert.True(t, len(newKey.Networks) == 2)
 	})
 	t.Run("Can_Create_Key_WithTags", func(t *testing.T) {
-		newKey, err := CreateEnrollmentKey(0, time.Time{}, nil, []string{"tag1", "tag2"}, nil, true, uuid.Nil, false, false)
+		newKey, err := CreateEnrollmentKey(0, time.Time{}, nil, []string{"tag1", "tag2"}, nil, true, uuid.Nil, false, false, models.EnrollmentKeyConstraints{}, nil, nil)
 		assert.Nil(t, err)
 		assert.True(t, newKey.IsValid())
 		assert.True(t, len(newKey.Tags) == 2)
@@ -70,7 +73,7 @@ func TestDelete_EnrollmentKey(t *testing.T) {
 
 	database.InitializeDatabase()
 	defer database.CloseDB()
-	newKey, _ := CreateEnrollmentKey(0, time.Time{}, []string{"mynet", "skynet"}, nil, nil, true, uuid.Nil, false, false)
+	newKey, _ := CreateEnrollmentKey(0, time.Time{}, []string{"mynet", "skynet"}, nil, nil, true, uuid.Nil, false, false, models.EnrollmentKeyConstraints{}, nil, nil)
 	t.Run("Can_Delete_Key", func(t *testing.T) {
 		assert.True(t, newKey.IsValid())
 		err := DeleteEnrollmentKey(newKey.Value, false)
@@ -94,7 +97,7 @@ func TestDecrement_EnrollmentKey(t *testing.T) {
 
 	database.InitializeDatabase()
 	defer database.CloseDB()
-	newKey, _ := CreateEnrollmentKey(1, time.Time{}, nil, nil, nil, false, uuid.Nil, false, false)
+	newKey, _ := CreateEnrollmentKey(1, time.Time{}, nil, nil, nil, false, uuid.Nil, false, false, models.EnrollmentKeyConstraints{}, nil, nil)
 	t.Run("Check_initial_uses", func(t *testing.T) {
 		assert.True(t, newKey.IsValid())
 		assert.Equal(t, newKey.UsesRemaining, 1)
@@ -121,34 +124,181 @@ func TestUsability_EnrollmentKey(t *testing.T) {
 
 	database.InitializeDatabase()
 	defer database.CloseDB()
-	key1, _ := CreateEnrollmentKey(1, time.Time{}, nil, nil, nil, false, uuid.Nil, false, false)
-	key2, _ := CreateEnrollmentKey(0, time.Now().Add(time.Minute<<4), nil, nil, nil, false, uuid.Nil, false, false)
-	key3, _ := CreateEnrollmentKey(0, time.Time{}, nil, nil, nil, true, uuid.Nil, false, false)
+	key1, _ := CreateEnrollmentKey(1, time.Time{}, nil, nil, nil, false, uuid.Nil, false, false, models.EnrollmentKeyConstraints{}, nil, nil)
+	key2, _ := CreateEnrollmentKey(0, time.Now().Add(time.Minute<<4), nil, nil, nil, false, uuid.Nil, false, false, models.EnrollmentKeyConstraints{}, nil, nil)
+	key3, _ := CreateEnrollmentKey(0, time.Time{}, nil, nil, nil, true, uuid.Nil, false, false, models.EnrollmentKeyConstraints{}, nil, nil)
 	t.Run("Check if valid use key can be used", func(t *testing.T) {
 		assert.Equal(t, key1.UsesRemaining, 1)
-		ok := TryToUseEnrollmentKey(key1)
+		ok := TryToUseEnrollmentKey(key1, &models.Host{}, "")
 		assert.True(t, ok)
 		assert.Equal(t, 0, key1.UsesRemaining)
 	})
 
 	t.Run("Check if valid time key can be used", func(t *testing.T) {
 		assert.True(t, !key2.Expiration.IsZero())
-		ok := TryToUseEnrollmentKey(key2)
+		ok := TryToUseEnrollmentKey(key2, &models.Host{}, "")
 		assert.True(t, ok)
 	})
 
 	t.Run("Check if valid unlimited key can be used", func(t *testing.T) {
 		assert.True(t, key3.Unlimited)
-		ok := TryToUseEnrollmentKey(key3)
+		ok := TryToUseEnrollmentKey(key3, &models.Host{}, "")
 		assert.True(t, ok)
 	})
 
 	t.Run("check invalid key can not be used", func(t *testing.T) {
-		ok := TryToUseEnrollmentKey(key1)
+		ok := TryToUseEnrollmentKey(key1, &models.Host{}, "")
 		assert.False(t, ok)
 	})
 }
 
+func TestConstraints_EnrollmentKey(t *testing.T) {
+	db.InitializeDB(schema.ListModels()...)
+	defer db.CloseDB()
+
+	database.InitializeDatabase()
+	defer database.CloseDB()
+	constraints := models.EnrollmentKeyConstraints{
+		SourceCIDRs:     []string{"10.0.0.0/16"},
+		HostNamePattern: "^edge-[a-z0-9]+$",
+		AllowedOS:       []string{"linux"},
+		AllowedHours:    &models.EnrollmentKeyHours{Start: 22, End: 6},
+	}
+	t.Run("Can_Not_Create_Key_With_Invalid_Constraints", func(t *testing.T) {
+		newKey, err := CreateEnrollmentKey(0, time.Time{}, nil, nil, nil, true, uuid.Nil, false, false,
+			models.EnrollmentKeyConstraints{SourceCIDRs: []string{"10.0.0.300/8"}}, nil, nil)
+		assert.Nil(t, newKey)
+		assert.ErrorIs(t, err, models.ErrInvalidKeyConstraints)
+		newKey, err = CreateEnrollmentKey(0, time.Time{}, nil, nil, nil, true, uuid.Nil, false, false,
+			models.EnrollmentKeyConstraints{AllowedHours: &models.EnrollmentKeyHours{Start: 4, End: 4}}, nil, nil)
+		assert.Nil(t, newKey)
+		assert.ErrorIs(t, err, models.ErrInvalidKeyConstraints)
+	})
+	key, err := CreateEnrollmentKey(0, time.Time{}, nil, nil, nil, true, uuid.Nil, false, false, constraints, nil, nil)
+	assert.Nil(t, err)
+	night := time.Date(2024, 1, 1, 23, 0, 0, 0, time.UTC)
+	host := &models.Host{Name: "edge-01", OS: "linux"}
+	t.Run("Allowed_Host", func(t *testing.T) {
+		assert.Nil(t, CheckEnrollmentKeyConstraints(key, host, "10.0.3.4", night))
+		assert.Nil(t, CheckEnrollmentKeyConstraints(key, host, "10.0.3.4", night.Add(time.Hour*6)))
+	})
+	t.Run("Source_Outside_CIDRs", func(t *testing.T) {
+		err := CheckEnrollmentKeyConstraints(key, host, "192.168.1.1", night)
+		assert.ErrorIs(t, err, EnrollmentErrors.ConstraintViolated)
+		err = CheckEnrollmentKeyConstraints(key, host, "", night)
+		assert.ErrorIs(t, err, EnrollmentErrors.ConstraintViolated)
+	})
+	t.Run("Source_Outside_CIDRs_Without_Trusted_Proxies", func(t *testing.T) {
+		t.Setenv("TRUSTED_PROXIES", "")
+		err := CheckEnrollmentKeyConstraints(key, host, "172.18.0.2", night)
+		assert.ErrorIs(t, err, EnrollmentErrors.ConstraintViolated)
+		assert.Contains(t, err.Error(), "TRUSTED_PROXIES is not set")
+		t.Setenv("TRUSTED_PROXIES", "172.18.0.0/16")
+		err = CheckEnrollmentKeyConstraints(key, host, "192.168.1.1", night)
+		assert.ErrorIs(t, err, EnrollmentErrors.ConstraintViolated)
+		assert.NotContains(t, err.Error(), "TRUSTED_PROXIES")
+	})
+	t.Run("Name_Does_Not_Match", func(t *testing.T) {
+		err := CheckEnrollmentKeyConstraints(key, &models.Host{Name: "laptop", OS: "linux"}, "10.0.3.4", night)
+		assert.ErrorIs(t, err, EnrollmentErrors.ConstraintViolated)
+	})
+	t.Run("OS_Not_Allowed", func(t *testing.T) {
+		err := CheckEnrollmentKeyConstraints(key, &models.Host{Name: "edge-01", OS: "windows"}, "10.0.3.4", night)
+		assert.ErrorIs(t, err, EnrollmentErrors.ConstraintViolated)
+	})
+	t.Run("Outside_Allowed_Hours", func(t *testing.T) {
+		err := CheckEnrollmentKeyConstraints(key, host, "10.0.3.4", night.Add(time.Hour*12))
+		assert.ErrorIs(t, err, EnrollmentErrors.ConstraintViolated)
+	})
+	t.Run("Violating_Host_Can_Not_Use_Key", func(t *testing.T) {
+		assert.False(t, TryToUseEnrollmentKey(key, host, "192.168.1.1"))
+	})
+	t.Run("Forwarded_Headers_Do_Not_Set_Source", func(t *testing.T) {
+		t.Setenv("TRUSTED_PROXIES", "")
+		r := httptest.NewRequest(http.MethodPost, "/", nil)
+		r.RemoteAddr = "192.168.1.1:4242"
+		r.Header.Set("X-Real-IP", "10.0.3.4")
+		r.Header.Set("X-Forwarded-For", "10.0.3.4")
+		sourceIP, _ := ParseRequestIP(r)
+		err := CheckEnrollmentKeyConstraints(key, host, sourceIP, night)
+		assert.ErrorIs(t, err, EnrollmentErrors.ConstraintViolated)
+	})
+	removeAllEnrollments()
+}
+
+func TestUsageHistory_EnrollmentKey(t *testing.T) {
+	db.InitializeDB(schema.ListModels()...)
+	defer db.CloseDB()
+
+	database.InitializeDatabase()
+	defer database.CloseDB()
+	key, _ := CreateEnrollmentKey(0, time.Time{}, []string{"mynet"}, nil, []models.TagID{"mynet.tag"}, true, uuid.Nil, false, false, models.EnrollmentKeyConstraints{}, nil, nil)
+	host := &models.Host{ID: uuid.New(), Name: "edge-01"}
+	t.Run("Empty_History", func(t *testing.T) {
+		usages, err := GetEnrollmentKeyUsage(key.Value)
+		assert.Nil(t, err)
+		assert.Equal(t, 0, len(usages))
+	})
+	t.Run("Records_Usage", func(t *testing.T) {
+		err := RecordEnrollmentKeyUsage(key, host, "203.0.113.7", nil)
+		assert.Nil(t, err)
+		usages, err := GetEnrollmentKeyUsage(key.Value)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, len(usages))
+		assert.Equal(t, host.ID.String(), usages[0].HostID)
+		assert.Equal(t, "203.0.113.7", usages[0].PublicIP)
+		assert.Equal(t, []string{"mynet"}, []string(usages[0].Networks))
+		assert.Equal(t, []models.TagID{"mynet.tag"}, []models.TagID(usages[0].Tags))
+	})
+	removeAllEnrollments()
+}
+
+func TestProvisioningProfile_EnrollmentKey(t *testing.T) {
+	mac, _ := net.ParseMAC("00:1a:2b:3c:4d:5e")
+	host := &models.Host{
+		ID:         uuid.MustParse("f3a9c1d2-0000-0000-0000-000000000000"),
+		Name:       "raspberrypi",
+		OS:         "linux",
+		MacAddress: mac,
+		MTU:        1420,
+	}
+	t.Run("Render_Host_Name_Template", func(t *testing.T) {
+		assert.Equal(t, "edge-4d5e", RenderHostNameTemplate("edge-{mac4}", host))
+		assert.Equal(t, "linux-raspberrypi-f3a9", RenderHostNameTemplate("{os}-{name}-{id4}", host))
+		assert.Equal(t, "edge-f3a9", RenderHostNameTemplate("edge-{mac4}", &models.Host{ID: host.ID}))
+	})
+	t.Run("Nil_Profile_Does_Not_Change_Host", func(t *testing.T) {
+		assert.False(t, ApplyHostProvisioningProfile(host, nil))
+	})
+	t.Run("Apply_Host_Profile", func(t *testing.T) {
+		verbosity := 2
+		changed := ApplyHostProvisioningProfile(host, &models.HostProvisioningProfile{
+			MTU:                 1280,
+			PersistentKeepalive: 25,
+			ListenPort:          51821,
+			IsStaticPort:        true,
+			Verbosity:           &verbosity,
+			HostNameTemplate:    "edge-{mac4}",
+		})
+		assert.True(t, changed)
+		assert.Equal(t, 1280, host.MTU)
+		assert.Equal(t, 25*time.Second, host.PersistentKeepalive)
+		assert.Equal(t, 51821, host.ListenPort)
+		assert.True(t, host.IsStaticPort)
+		assert.Equal(t, 2, host.Verbosity)
+		assert.Equal(t, "edge-4d5e", host.Name)
+	})
+	t.Run("Invalid_Profile", func(t *testing.T) {
+		err := (&models.HostProvisioningProfile{MTU: -1}).Validate()
+		assert.ErrorIs(t, err, models.ErrInvalidKeyProfile)
+		for _, iface := range []string{"../etc", "netmaker interface", "averylonginterfacename", ".."} {
+			err = (&models.HostProvisioningProfile{Interface: iface}).Validate()
+			assert.ErrorIs(t, err, models.ErrInvalidKeyProfile, iface)
+		}
+		assert.Nil(t, (&models.HostProvisioningProfile{Interface: "netmaker-1"}).Validate())
+	})
+}
+
 func removeAllEnrollments() {
 	database.DeleteAllRecords(database.ENROLLMENT_KEYS_TABLE_NAME)
 }
@@ -162,7 +312,7 @@ func TestTokenize_EnrollmentKeys(t *testing.T) {
 
 	database.InitializeDatabase()
 	defer database.CloseDB()
-	newKey, _ := CreateEnrollmentKey(0, time.Time{}, []string{"mynet", "skynet"}, nil, nil, true, uuid.Nil, false, false)
+	newKey, _ := CreateEnrollmentKey(0, time.Time{}, []string{"mynet", "skynet"}, nil, nil, true, uuid.Nil, false, false, models.EnrollmentKeyConstraints{}, nil, nil)
 	const defaultValue = "MwE5MwE5MwE5MwE5MwE5MwE5MwE5MwE5"
 	const b64value = "eyJzZXJ2ZXIiOiJhcGkubXlzZXJ2ZXIuY29tIiwidmFsdWUiOiJNd0U1TXdFNU13RTVNd0U1TXdFNU13RTVNd0U1TXdFNSJ9"
 	const serverAddr = "api.myserver.com"
@@ -198,7 +348,7 @@ func TestDeTokenize_EnrollmentKeys(t *testing.T) {
 
 	database.InitializeDatabase()
 	defer database.CloseDB()
-	newKey, _ := CreateEnrollmentKey(0, time.Time{}, []string{"mynet", "skynet"}, nil, nil, true, uuid.Nil, false, false)
+	newKey, _ := CreateEnrollmentKey(0, time.Time{}, []string{"mynet", "skynet"}, nil, nil, true, uuid.Nil, false, false, models.EnrollmentKeyConstraints{}, nil, nil)
 	const b64Value = "eyJzZXJ2ZXIiOiJhcGkubXlzZXJ2ZXIuY29tIiwidmFsdWUiOiJNd0U1TXdFNU13RTVNd0U1TXdFNU13RTVNd0U1TXdFNSJ9"
 	const serverAddr = "api.myserver.com"
 
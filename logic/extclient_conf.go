@@ -0,0 +1,556 @@
+package logic
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/gravitl/netmaker/db"
+	"github.com/gravitl/netmaker/models"
+	"github.com/gravitl/netmaker/schema"
+	"github.com/skip2/go-qrcode"
+)
+
+// ExtClientConfRenderer - renders an ext client config in a given output format
+type ExtClientConfRenderer struct {
+	ContentType string
+	Extension   string
+	Render      func(c *models.ExtClientConf) ([]byte, error)
+}
+
+var extClientConfRenderers = map[string]ExtClientConfRenderer{
+	"mobileconfig": {
+		ContentType: "application/x-apple-aspen-config",
+		Extension:   ".mobileconfig",
+		Render:      renderMobileConfig,
+	},
+	"nmconnection": {
+		ContentType: "text/plain",
+		Extension:   ".nmconnection",
+		Render:      renderNMConnection,
+	},
+	"openwrt": {
+		ContentType: "text/x-shellscript",
+		Extension:   ".sh",
+		Render:      renderOpenWrtUCI,
+	},
+	"mikrotik": {
+		ContentType: "text/plain",
+		Extension:   ".rsc",
+		Render:      renderMikroTik,
+	},
+	"networkd": {
+		ContentType: "application/zip",
+		Extension:   ".zip",
+		Render:      renderNetworkd,
+	},
+	"windows": {
+		ContentType: "application/config",
+		Extension:   ".conf",
+		Render:      renderWindowsConf,
+	},
+}
+
+var confNameRegex = regexp.MustCompile(`[^a-zA-Z0-9_=+.-]`)
+
+var (
+	// ErrInvalidPreferredIP - the preferred endpoint ip is not an address of the client's gateway
+	ErrInvalidPreferredIP = errors.New("preferred endpoint ip is not associated with the RAG")
+	// ErrUnknownConfFormat - no renderer is registered for the requested config format
+	ErrUnknownConfFormat = errors.New("unknown ext client config format")
+)
+
+// GetExtClientConf - builds the config of an ext client from the client, its gateway and its network,
+// a preferred ip replaces the gateway's endpoint and must be one of the gateway's addresses
+func GetExtClientConf(client *models.ExtClient, preferredIP string) (models.ExtClientConf, error) {
+	return buildExtClientConf(client, preferredIP, false)
+}
+
+// GetExtClientHAConf - builds the config of an ext client handed out by the HA gateway selection,
+// it routes everything through gateways behind an internet gateway and only uses the gateway's DNS
+func GetExtClientHAConf(client *models.ExtClient) (models.ExtClientConf, error) {
+	return buildExtClientConf(client, "", true)
+}
+
+func buildExtClientConf(client *models.ExtClient, preferredIP string, ha bool) (models.ExtClientConf, error) {
+	gwnode, err := GetNodeByID(client.IngressGatewayID)
+	if err != nil {
+		return models.ExtClientConf{}, fmt.Errorf("failed to get ingress gateway node [%s] info: %w", client.IngressGatewayID, err)
+	}
+	eli, _ := (&schema.Egress{Network: gwnode.Network}).ListByNetwork(db.WithContext(context.TODO()))
+	acls, _ := ListAclsByNetwork(models.NetworkID(client.Network))
+	GetNodeEgressInfo(&gwnode, eli, acls)
+	host, err := GetHost(gwnode.HostID.String())
+	if err != nil {
+		return models.ExtClientConf{}, fmt.Errorf("failed to get host for ingress gateway node [%s] info: %w", client.IngressGatewayID, err)
+	}
+	network, err := GetParentNetwork(client.Network)
+	if err != nil {
+		return models.ExtClientConf{}, fmt.Errorf("could not retrieve ingress gateway network %s: %w", client.Network, err)
+	}
+	conf := models.ExtClientConf{
+		ClientID:      client.ClientID,
+		Network:       client.Network,
+		Addresses:     []string{},
+		PrivateKey:    client.PrivateKey,
+		MTU:           1420,
+		DNS:           []string{},
+		PeerPublicKey: host.PublicKey.String(),
+		AllowedIPs:    []string{},
+		EndpointPort:  host.ListenPort,
+	}
+
+	if preferredIP != "" {
+		allowedPreferredIps := []string{}
+		for i := range gwnode.AdditionalRagIps {
+			allowedPreferredIps = append(allowedPreferredIps, gwnode.AdditionalRagIps[i].String())
+		}
+		allowedPreferredIps = append(allowedPreferredIps, host.EndpointIP.String())
+		allowedPreferredIps = append(allowedPreferredIps, host.EndpointIPv6.String())
+		if !slices.Contains(allowedPreferredIps, preferredIP) {
+			return models.ExtClientConf{}, ErrInvalidPreferredIP
+		}
+		conf.EndpointHost = preferredIP
+	} else if host.EndpointIP.To4() == nil {
+		conf.EndpointHost = host.EndpointIPv6.String()
+	} else {
+		conf.EndpointHost = host.EndpointIP.String()
+	}
+
+	if client.Address != "" {
+		conf.Addresses = append(conf.Addresses, client.Address+"/32")
+	}
+	if client.Address6 != "" {
+		conf.Addresses = append(conf.Addresses, client.Address6+"/128")
+	}
+
+	if network.DefaultKeepalive != 0 {
+		conf.PersistentKeepalive = int(network.DefaultKeepalive)
+	}
+	if gwnode.IngressPersistentKeepalive != 0 {
+		conf.PersistentKeepalive = int(gwnode.IngressPersistentKeepalive)
+	}
+
+	conf.PresharedKey = GetExtClientGwPresharedKey(client, host)
+
+	if IsInternetGw(gwnode) || (ha && gwnode.InternetGwID != "") {
+		conf.AllowedIPs = append(conf.AllowedIPs, "0.0.0.0/0")
+		if gwnode.Address6.IP != nil && client.Address6 != "" {
+			conf.AllowedIPs = append(conf.AllowedIPs, "::/0")
+		}
+	} else {
+		conf.AllowedIPs = append(conf.AllowedIPs, splitConfList(network.AddressRange)...)
+		conf.AllowedIPs = append(conf.AllowedIPs, splitConfList(network.AddressRange6)...)
+		if egressGatewayRanges, err := GetEgressRangesOnNetwork(client); err == nil {
+			conf.AllowedIPs = append(conf.AllowedIPs, egressGatewayRanges...)
+		}
+	}
+
+	if client.DNS != "" {
+		conf.DNS = splitConfList(client.DNS)
+	} else if ha {
+		conf.DNS = splitConfList(gwnode.IngressDNS)
+	} else {
+		conf.DNS = append(splitConfList(gwnode.IngressDNS), network.NameServers...)
+	}
+
+	if host.MTU != 0 {
+		conf.MTU = host.MTU
+	}
+	if gwnode.IngressMTU != 0 {
+		conf.MTU = int(gwnode.IngressMTU)
+	}
+
+	if client.PostUp != "" {
+		conf.PostUp = strings.Split(client.PostUp, "\n")
+	}
+	if client.PostDown != "" {
+		conf.PostDown = strings.Split(client.PostDown, "\n")
+	}
+	return conf, nil
+}
+
+// RenderExtClientConf - renders an ext client config as a wg-quick file, a qr code of it
+// or in one of the registered formats; the file name is empty for qr codes
+func RenderExtClientConf(c *models.ExtClientConf, format string) (data []byte, contentType, fileName string, err error) {
+	switch format {
+	case "qr":
+		data, err = qrcode.Encode(RenderWgQuickConf(c, false), qrcode.Medium, 220)
+		return data, "image/png", "", err
+	case "file":
+		return []byte(RenderWgQuickConf(c, true)), "application/config", c.ClientID + ".conf", nil
+	}
+	renderer, ok := GetExtClientConfRenderer(format)
+	if !ok {
+		return nil, "", "", ErrUnknownConfFormat
+	}
+	data, err = renderer.Render(c)
+	return data, renderer.ContentType, ExtClientConfFileName(c, renderer), err
+}
+
+// splitConfList - splits a comma separated config value, dropping empty entries
+func splitConfList(value string) []string {
+	list := []string{}
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// RegisterExtClientConfRenderer - adds or replaces the renderer used for an ext client config format
+func RegisterExtClientConfRenderer(format string, renderer ExtClientConfRenderer) {
+	extClientConfRenderers[format] = renderer
+}
+
+// GetExtClientConfRenderer - fetches the renderer for an ext client config format
+func GetExtClientConfRenderer(format string) (ExtClientConfRenderer, bool) {
+	renderer, ok := extClientConfRenderers[format]
+	return renderer, ok
+}
+
+// ListExtClientConfFormats - lists the registered ext client config formats
+func ListExtClientConfFormats() []string {
+	formats := make([]string, 0, len(extClientConfRenderers))
+	for format := range extClientConfRenderers {
+		formats = append(formats, format)
+	}
+	sort.Strings(formats)
+	return formats
+}
+
+// ExtClientConfFileName - the name of the file an ext client config is downloaded as,
+// limited to 32 characters as wireguard on windows uses it as the tunnel name
+func ExtClientConfFileName(c *models.ExtClientConf, renderer ExtClientConfRenderer) string {
+	name := confNameRegex.ReplaceAllString(c.ClientID, "_")
+	if len(name) > 32 {
+		name = name[:32]
+	}
+	return name + renderer.Extension
+}
+
+// RenderWgQuickConf - renders an ext client config in the wg-quick format
+func RenderWgQuickConf(c *models.ExtClientConf, withScripts bool) string {
+	var b strings.Builder
+	b.WriteString("[Interface]\n")
+	fmt.Fprintf(&b, "Address = %s\n", strings.Join(c.Addresses, ","))
+	fmt.Fprintf(&b, "PrivateKey = %s\n", c.PrivateKey)
+	fmt.Fprintf(&b, "MTU = %d\n", c.MTU)
+	if len(c.DNS) > 0 {
+		fmt.Fprintf(&b, "DNS = %s\n", strings.Join(c.DNS, ","))
+	}
+	if withScripts {
+		for _, cmd := range c.PostUp {
+			fmt.Fprintf(&b, "PostUp = %s\n", cmd)
+		}
+		for _, cmd := range c.PostDown {
+			fmt.Fprintf(&b, "PostDown = %s\n", cmd)
+		}
+	}
+	b.WriteString("\n[Peer]\n")
+	fmt.Fprintf(&b, "PublicKey = %s\n", c.PeerPublicKey)
+	if c.PresharedKey != "" {
+		fmt.Fprintf(&b, "PresharedKey = %s\n", c.PresharedKey)
+	}
+	fmt.Fprintf(&b, "AllowedIPs = %s\n", strings.Join(c.AllowedIPs, ","))
+	fmt.Fprintf(&b, "Endpoint = %s\n", extClientEndpoint(c))
+	if c.PersistentKeepalive > 0 {
+		fmt.Fprintf(&b, "PersistentKeepalive = %d\n", c.PersistentKeepalive)
+	}
+	return b.String()
+}
+
+func extClientEndpoint(c *models.ExtClientConf) string {
+	return net.JoinHostPort(c.EndpointHost, strconv.Itoa(c.EndpointPort))
+}
+
+// extClientIfaceName - a valid linux interface name (at most 15 characters) for the client's network
+func extClientIfaceName(c *models.ExtClientConf) string {
+	name := "nm-" + strings.ReplaceAll(confNameRegex.ReplaceAllString(c.Network, ""), ".", "")
+	if len(name) > 15 {
+		name = name[:15]
+	}
+	return name
+}
+
+// splitByFamily - splits addresses or CIDRs into their ipv4 and ipv6 parts
+func splitByFamily(addrs []string) (v4, v6 []string) {
+	for _, addr := range addrs {
+		ip := net.ParseIP(addr)
+		if ip == nil {
+			ip, _, _ = net.ParseCIDR(addr)
+		}
+		if ip == nil {
+			continue
+		}
+		if ip.To4() != nil {
+			v4 = append(v4, addr)
+		} else {
+			v6 = append(v6, addr)
+		}
+	}
+	return
+}
+
+func xmlEscape(s string) string {
+	var b bytes.Buffer
+	_ = xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+// renderMobileConfig - an apple configuration profile for the wireguard app on ios and macos
+func renderMobileConfig(c *models.ExtClientConf) ([]byte, error) {
+	// derive the payload uuids from the client so re-downloading replaces the installed profile
+	profileUUID := uuid.NewSHA1(uuid.NameSpaceOID, []byte("netmaker-profile:"+c.Network+":"+c.ClientID))
+	vpnUUID := uuid.NewSHA1(uuid.NameSpaceOID, []byte("netmaker-vpn:"+c.Network+":"+c.ClientID))
+	name := xmlEscape(c.ClientID)
+	identifier := "com.netmaker." + confNameRegex.ReplaceAllString(c.Network, "") + "." +
+		confNameRegex.ReplaceAllString(c.ClientID, "")
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>PayloadDisplayName</key>
+	<string>` + name + `</string>
+	<key>PayloadType</key>
+	<string>Configuration</string>
+	<key>PayloadVersion</key>
+	<integer>1</integer>
+	<key>PayloadIdentifier</key>
+	<string>` + xmlEscape(identifier) + `</string>
+	<key>PayloadUUID</key>
+	<string>` + strings.ToUpper(profileUUID.String()) + `</string>
+	<key>PayloadContent</key>
+	<array>
+		<dict>
+			<key>PayloadDisplayName</key>
+			<string>VPN</string>
+			<key>PayloadType</key>
+			<string>com.apple.vpn.managed</string>
+			<key>PayloadVersion</key>
+			<integer>1</integer>
+			<key>PayloadIdentifier</key>
+			<string>` + xmlEscape(identifier) + `.vpn</string>
+			<key>PayloadUUID</key>
+			<string>` + strings.ToUpper(vpnUUID.String()) + `</string>
+			<key>UserDefinedName</key>
+			<string>` + name + `</string>
+			<key>VPNType</key>
+			<string>VPN</string>
+			<key>VPNSubType</key>
+			<string>com.wireguard.ios</string>
+			<key>VendorConfig</key>
+			<dict>
+				<key>WgQuickConfig</key>
+				<string>` + xmlEscape(RenderWgQuickConf(c, false)) + `</string>
+			</dict>
+			<key>VPN</key>
+			<dict>
+				<key>RemoteAddress</key>
+				<string>` + xmlEscape(extClientEndpoint(c)) + `</string>
+				<key>AuthenticationMethod</key>
+				<string>Password</string>
+			</dict>
+		</dict>
+	</array>
+</dict>
+</plist>
+`)
+	return []byte(b.String()), nil
+}
+
+// renderNMConnection - a NetworkManager keyfile, to be placed in /etc/NetworkManager/system-connections
+func renderNMConnection(c *models.ExtClientConf) ([]byte, error) {
+	var b strings.Builder
+	b.WriteString("[connection]\n")
+	fmt.Fprintf(&b, "id=%s\n", c.ClientID)
+	fmt.Fprintf(&b, "uuid=%s\n", uuid.NewSHA1(uuid.NameSpaceOID, []byte("netmaker-nm:"+c.Network+":"+c.ClientID)))
+	b.WriteString("type=wireguard\n")
+	fmt.Fprintf(&b, "interface-name=%s\n", extClientIfaceName(c))
+	b.WriteString("\n[wireguard]\n")
+	fmt.Fprintf(&b, "private-key=%s\n", c.PrivateKey)
+	fmt.Fprintf(&b, "mtu=%d\n", c.MTU)
+	fmt.Fprintf(&b, "\n[wireguard-peer.%s]\n", c.PeerPublicKey)
+	fmt.Fprintf(&b, "endpoint=%s\n", extClientEndpoint(c))
+	if c.PresharedKey != "" {
+		fmt.Fprintf(&b, "preshared-key=%s\npreshared-key-flags=0\n", c.PresharedKey)
+	}
+	fmt.Fprintf(&b, "allowed-ips=%s;\n", strings.Join(c.AllowedIPs, ";"))
+	if c.PersistentKeepalive > 0 {
+		fmt.Fprintf(&b, "persistent-keepalive=%d\n", c.PersistentKeepalive)
+	}
+	addrs4, addrs6 := splitByFamily(c.Addresses)
+	dns4, dns6 := splitByFamily(c.DNS)
+	for _, family := range []struct {
+		section string
+		addrs   []string
+		dns     []string
+	}{{"ipv4", addrs4, dns4}, {"ipv6", addrs6, dns6}} {
+		fmt.Fprintf(&b, "\n[%s]\n", family.section)
+		if len(family.addrs) == 0 {
+			b.WriteString("method=disabled\n")
+			continue
+		}
+		for i, addr := range family.addrs {
+			fmt.Fprintf(&b, "address%d=%s\n", i+1, addr)
+		}
+		if len(family.dns) > 0 {
+			fmt.Fprintf(&b, "dns=%s;\n", strings.Join(family.dns, ";"))
+		}
+		b.WriteString("method=manual\n")
+	}
+	return []byte(b.String()), nil
+}
+
+// renderOpenWrtUCI - a shell script of uci commands that creates the interface on an OpenWrt router
+func renderOpenWrtUCI(c *models.ExtClientConf) ([]byte, error) {
+	iface := strings.ReplaceAll(extClientIfaceName(c), "-", "_")
+	peer := iface + "_peer"
+	var b strings.Builder
+	b.WriteString("#!/bin/sh\n")
+	fmt.Fprintf(&b, "# netmaker remote access client %s on network %s\n", c.ClientID, c.Network)
+	fmt.Fprintf(&b, "uci -q delete network.%s\n", iface)
+	fmt.Fprintf(&b, "uci -q delete network.%s\n", peer)
+	fmt.Fprintf(&b, "uci set network.%s=interface\n", iface)
+	fmt.Fprintf(&b, "uci set network.%s.proto='wireguard'\n", iface)
+	fmt.Fprintf(&b, "uci set network.%s.private_key='%s'\n", iface, c.PrivateKey)
+	fmt.Fprintf(&b, "uci set network.%s.mtu='%d'\n", iface, c.MTU)
+	for _, addr := range c.Addresses {
+		fmt.Fprintf(&b, "uci add_list network.%s.addresses='%s'\n", iface, addr)
+	}
+	for _, dns := range c.DNS {
+		fmt.Fprintf(&b, "uci add_list network.%s.dns='%s'\n", iface, dns)
+	}
+	fmt.Fprintf(&b, "uci set network.%s=wireguard_%s\n", peer, iface)
+	fmt.Fprintf(&b, "uci set network.%s.description='%s'\n", peer, c.Network)
+	fmt.Fprintf(&b, "uci set network.%s.public_key='%s'\n", peer, c.PeerPublicKey)
+	if c.PresharedKey != "" {
+		fmt.Fprintf(&b, "uci set network.%s.preshared_key='%s'\n", peer, c.PresharedKey)
+	}
+	fmt.Fprintf(&b, "uci set network.%s.endpoint_host='%s'\n", peer, c.EndpointHost)
+	fmt.Fprintf(&b, "uci set network.%s.endpoint_port='%d'\n", peer, c.EndpointPort)
+	if c.PersistentKeepalive > 0 {
+		fmt.Fprintf(&b, "uci set network.%s.persistent_keepalive='%d'\n", peer, c.PersistentKeepalive)
+	}
+	fmt.Fprintf(&b, "uci set network.%s.route_allowed_ips='1'\n", peer)
+	for _, allowed := range c.AllowedIPs {
+		fmt.Fprintf(&b, "uci add_list network.%s.allowed_ips='%s'\n", peer, allowed)
+	}
+	b.WriteString("uci commit network\n")
+	b.WriteString("/etc/init.d/network reload\n")
+	return []byte(b.String()), nil
+}
+
+// renderMikroTik - a RouterOS v7 script that creates the interface, peer, addresses and routes
+func renderMikroTik(c *models.ExtClientConf) ([]byte, error) {
+	iface := extClientIfaceName(c)
+	var b strings.Builder
+	fmt.Fprintf(&b, "# netmaker remote access client %s on network %s\n", c.ClientID, c.Network)
+	fmt.Fprintf(&b, "/interface wireguard add name=%s mtu=%d private-key=\"%s\"\n", iface, c.MTU, c.PrivateKey)
+	fmt.Fprintf(&b, "/interface wireguard peers add interface=%s public-key=\"%s\" endpoint-address=%s endpoint-port=%d allowed-address=%s",
+		iface, c.PeerPublicKey, c.EndpointHost, c.EndpointPort, strings.Join(c.AllowedIPs, ","))
+	if c.PersistentKeepalive > 0 {
+		fmt.Fprintf(&b, " persistent-keepalive=%ds", c.PersistentKeepalive)
+	}
+	if c.PresharedKey != "" {
+		fmt.Fprintf(&b, " preshared-key=\"%s\"", c.PresharedKey)
+	}
+	b.WriteString("\n")
+	addrs4, addrs6 := splitByFamily(c.Addresses)
+	for _, addr := range addrs4 {
+		fmt.Fprintf(&b, "/ip address add address=%s interface=%s\n", addr, iface)
+	}
+	for _, addr := range addrs6 {
+		fmt.Fprintf(&b, "/ipv6 address add address=%s interface=%s advertise=no\n", addr, iface)
+	}
+	routes4, routes6 := splitByFamily(c.AllowedIPs)
+	for _, route := range routes4 {
+		fmt.Fprintf(&b, "/ip route add dst-address=%s gateway=%s\n", route, iface)
+	}
+	for _, route := range routes6 {
+		fmt.Fprintf(&b, "/ipv6 route add dst-address=%s gateway=%s\n", route, iface)
+	}
+	if len(c.DNS) > 0 {
+		// the router's resolver is global, so only suggest the servers
+		fmt.Fprintf(&b, "# /ip dns set servers=%s\n", strings.Join(c.DNS, ","))
+	}
+	return []byte(b.String()), nil
+}
+
+// renderNetworkd - a zip holding the systemd-networkd .netdev and .network files
+func renderNetworkd(c *models.ExtClientConf) ([]byte, error) {
+	iface := extClientIfaceName(c)
+	var netdev strings.Builder
+	netdev.WriteString("# the private key is readable from this file, install it with mode 0640 and group systemd-network\n")
+	netdev.WriteString("[NetDev]\n")
+	fmt.Fprintf(&netdev, "Name=%s\n", iface)
+	netdev.WriteString("Kind=wireguard\n")
+	fmt.Fprintf(&netdev, "MTUBytes=%d\n", c.MTU)
+	netdev.WriteString("\n[WireGuard]\n")
+	fmt.Fprintf(&netdev, "PrivateKey=%s\n", c.PrivateKey)
+	netdev.WriteString("\n[WireGuardPeer]\n")
+	fmt.Fprintf(&netdev, "PublicKey=%s\n", c.PeerPublicKey)
+	if c.PresharedKey != "" {
+		fmt.Fprintf(&netdev, "PresharedKey=%s\n", c.PresharedKey)
+	}
+	fmt.Fprintf(&netdev, "AllowedIPs=%s\n", strings.Join(c.AllowedIPs, ","))
+	fmt.Fprintf(&netdev, "Endpoint=%s\n", extClientEndpoint(c))
+	if c.PersistentKeepalive > 0 {
+		fmt.Fprintf(&netdev, "PersistentKeepalive=%d\n", c.PersistentKeepalive)
+	}
+
+	var network strings.Builder
+	network.WriteString("[Match]\n")
+	fmt.Fprintf(&network, "Name=%s\n", iface)
+	network.WriteString("\n[Network]\n")
+	for _, addr := range c.Addresses {
+		fmt.Fprintf(&network, "Address=%s\n", addr)
+	}
+	for _, dns := range c.DNS {
+		fmt.Fprintf(&network, "DNS=%s\n", dns)
+	}
+	for _, route := range c.AllowedIPs {
+		fmt.Fprintf(&network, "\n[Route]\nDestination=%s\n", route)
+	}
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, f := range []struct {
+		name    string
+		content string
+	}{
+		{"99-" + iface + ".netdev", netdev.String()},
+		{"99-" + iface + ".network", network.String()},
+	} {
+		w, err := zw.Create(f.name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err = w.Write([]byte(f.content)); err != nil {
+			return nil, err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// renderWindowsConf - a config for the wireguard windows tunnel service, installed with
+// wireguard.exe /installtunnelservice, which refuses PostUp/PostDown scripts by default
+func renderWindowsConf(c *models.ExtClientConf) ([]byte, error) {
+	conf := "# install with: wireguard.exe /installtunnelservice <path to this file>\n" + RenderWgQuickConf(c, false)
+	return []byte(strings.ReplaceAll(conf, "\n", "\r\n")), nil
+}
@@ -0,0 +1,178 @@
+package logic
+
+import (
+	"archive/zip"
+	"bytes"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/db"
+	"github.com/gravitl/netmaker/models"
+	"github.com/gravitl/netmaker/schema"
+	"github.com/stretchr/testify/assert"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+func testExtClientConf() *models.ExtClientConf {
+	return &models.ExtClientConf{
+		ClientID:            "laptop",
+		Network:             "netmaker",
+		Addresses:           []string{"10.10.10.5/32", "fd00::5/128"},
+		PrivateKey:          "cHJpdmF0ZQ==",
+		MTU:                 1420,
+		DNS:                 []string{"1.1.1.1", "2606:4700:4700::1111"},
+		PostUp:              []string{"echo up"},
+		PeerPublicKey:       "cHVibGlj",
+		AllowedIPs:          []string{"10.10.10.0/24", "fd00::/64"},
+		EndpointHost:        "2001:db8::1",
+		EndpointPort:        51821,
+		PersistentKeepalive: 20,
+	}
+}
+
+func TestExtClientConfRenderers(t *testing.T) {
+	conf := testExtClientConf()
+	for _, format := range ListExtClientConfFormats() {
+		renderer, ok := GetExtClientConfRenderer(format)
+		assert.True(t, ok)
+		data, err := renderer.Render(conf)
+		assert.Nil(t, err, format)
+		assert.NotEmpty(t, data, format)
+		assert.Equal(t, "laptop"+renderer.Extension, ExtClientConfFileName(conf, renderer))
+	}
+
+	t.Run("WgQuick", func(t *testing.T) {
+		wg := RenderWgQuickConf(conf, false)
+		assert.Contains(t, wg, "Endpoint = [2001:db8::1]:51821\n")
+		assert.NotContains(t, wg, "PostUp")
+		assert.Contains(t, RenderWgQuickConf(conf, true), "PostUp = echo up\n")
+	})
+
+	t.Run("NMConnection", func(t *testing.T) {
+		data, _ := renderNMConnection(conf)
+		assert.Contains(t, string(data), "[ipv4]\naddress1=10.10.10.5/32\ndns=1.1.1.1;\nmethod=manual\n")
+		assert.Contains(t, string(data), "[ipv6]\naddress1=fd00::5/128\ndns=2606:4700:4700::1111;\nmethod=manual\n")
+		assert.Contains(t, string(data), "interface-name=nm-netmaker\n")
+	})
+
+	t.Run("Windows", func(t *testing.T) {
+		data, _ := renderWindowsConf(conf)
+		assert.Contains(t, string(data), "[Peer]\r\n")
+		assert.NotContains(t, string(data), "PostUp")
+	})
+
+	t.Run("Networkd", func(t *testing.T) {
+		data, _ := renderNetworkd(conf)
+		zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+		assert.Nil(t, err)
+		names := []string{}
+		for _, f := range zr.File {
+			names = append(names, f.Name)
+		}
+		assert.Equal(t, []string{"99-nm-netmaker.netdev", "99-nm-netmaker.network"}, names)
+	})
+
+	t.Run("Register", func(t *testing.T) {
+		RegisterExtClientConfRenderer("custom", ExtClientConfRenderer{
+			ContentType: "text/plain",
+			Extension:   ".txt",
+			Render: func(c *models.ExtClientConf) ([]byte, error) {
+				return []byte(strings.ToUpper(c.ClientID)), nil
+			},
+		})
+		defer delete(extClientConfRenderers, "custom")
+		renderer, ok := GetExtClientConfRenderer("custom")
+		assert.True(t, ok)
+		data, _ := renderer.Render(conf)
+		assert.Equal(t, "LAPTOP", string(data))
+	})
+}
+
+func TestGetExtClientConf(t *testing.T) {
+	db.InitializeDB(schema.ListModels()...)
+	defer db.CloseDB()
+
+	database.InitializeDatabase()
+	defer database.CloseDB()
+	network := models.Network{NetID: "confnet", AddressRange: "10.115.0.0/24", NameServers: []string{"9.9.9.9"}}
+	assert.Nil(t, SaveNetwork(&network))
+	defer func() {
+		_ = database.DeleteRecord(database.NETWORKS_TABLE_NAME, network.NetID)
+	}()
+	key, err := wgtypes.GeneratePrivateKey()
+	assert.Nil(t, err)
+	host := models.Host{
+		ID:         uuid.New(),
+		Name:       "gateway",
+		EndpointIP: net.ParseIP("203.0.113.10"),
+		ListenPort: 51821,
+		PublicKey:  key.PublicKey(),
+		MTU:        1400,
+	}
+	gw := models.Node{
+		CommonNode: models.CommonNode{
+			ID:               uuid.New(),
+			HostID:           host.ID,
+			Network:          "confnet",
+			IsIngressGateway: true,
+			IngressDNS:       "10.0.0.53",
+		},
+		IngressPersistentKeepalive: 25,
+	}
+	assert.Nil(t, UpsertNode(&gw))
+	host.Nodes = []string{gw.ID.String()}
+	assert.Nil(t, CreateHost(&host))
+	defer func() {
+		_ = DeleteNodeByID(&gw)
+		_ = RemoveHostByID(host.ID.String())
+	}()
+	client := models.ExtClient{
+		ClientID:         "phone",
+		Network:          "confnet",
+		IngressGatewayID: gw.ID.String(),
+		Address:          "10.115.0.10",
+		PrivateKey:       "cHJpdmF0ZQ==",
+		PostUp:           "echo up",
+	}
+
+	conf, err := GetExtClientConf(&client, "")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"10.115.0.10/32"}, conf.Addresses)
+	assert.Equal(t, []string{"10.115.0.0/24"}, conf.AllowedIPs)
+	assert.Equal(t, []string{"10.0.0.53", "9.9.9.9"}, conf.DNS)
+	assert.Equal(t, "203.0.113.10", conf.EndpointHost)
+	assert.Equal(t, 51821, conf.EndpointPort)
+	assert.Equal(t, 1400, conf.MTU)
+	assert.Equal(t, 25, conf.PersistentKeepalive)
+	assert.Equal(t, key.PublicKey().String(), conf.PeerPublicKey)
+
+	_, err = GetExtClientConf(&client, "198.51.100.1")
+	assert.ErrorIs(t, err, ErrInvalidPreferredIP)
+
+	data, contentType, fileName, err := RenderExtClientConf(&conf, "file")
+	assert.Nil(t, err)
+	assert.Equal(t, "application/config", contentType)
+	assert.Equal(t, "phone.conf", fileName)
+	assert.Contains(t, string(data), "Endpoint = 203.0.113.10:51821\n")
+	assert.Contains(t, string(data), "PostUp = echo up\n")
+	_, contentType, fileName, err = RenderExtClientConf(&conf, "qr")
+	assert.Nil(t, err)
+	assert.Equal(t, "image/png", contentType)
+	assert.Empty(t, fileName)
+	_, _, _, err = RenderExtClientConf(&conf, "bogus")
+	assert.ErrorIs(t, err, ErrUnknownConfFormat)
+
+	// HA configs only use the gateway's DNS and route everything through a gateway behind an internet gateway
+	conf, err = GetExtClientHAConf(&client)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"10.0.0.53"}, conf.DNS)
+	assert.Equal(t, []string{"10.115.0.0/24"}, conf.AllowedIPs)
+	gw.InternetGwID = uuid.NewString()
+	assert.Nil(t, UpsertNode(&gw))
+	conf, err = GetExtClientHAConf(&client)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"0.0.0.0/0"}, conf.AllowedIPs)
+}
@@ -0,0 +1,131 @@
+package logic
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gravitl/netmaker/db"
+	"github.com/gravitl/netmaker/models"
+	"github.com/gravitl/netmaker/schema"
+	"github.com/gravitl/netmaker/servercfg"
+)
+
+const (
+	// DefaultExtClientConfigLinkTTL - how long a config link stays valid when no ttl is requested
+	DefaultExtClientConfigLinkTTL = 24 * time.Hour
+	// MaxExtClientConfigLinkTTL - the longest a config link may stay valid
+	MaxExtClientConfigLinkTTL = 30 * 24 * time.Hour
+)
+
+var (
+	// ErrExtClientConfigLinkUnavailable - returned for revoked, expired or used up config links
+	ErrExtClientConfigLinkUnavailable = errors.New("config link is no longer available")
+	// ErrInvalidExtClientConfigLink - returned for malformed config link requests
+	ErrInvalidExtClientConfigLink = errors.New("invalid config link request")
+
+	extClientConfigLinkMutex = &sync.Mutex{}
+)
+
+// SendExtClientConfigLink - emails a config download link to its recipient
+var SendExtClientConfigLink = func(link schema.ExtClientConfigLink, url string) error {
+	return errors.New("emailing config links is not supported")
+}
+
+// IsExtClientConfFormat - checks if a config can be downloaded in the format through a link
+func IsExtClientConfFormat(format string) bool {
+	if format == "file" || format == "qr" {
+		return true
+	}
+	_, ok := GetExtClientConfRenderer(format)
+	return ok
+}
+
+// CreateExtClientConfigLink - mints a signed download link for the config of an ext client, returns the link and its token
+func CreateExtClientConfigLink(client *models.ExtClient, req models.ExtClientConfigLinkReq, createdBy string) (schema.ExtClientConfigLink, string, error) {
+	if req.Format == "" {
+		req.Format = "file"
+	}
+	ttl := time.Duration(req.TTL) * time.Second
+	if ttl == 0 {
+		ttl = DefaultExtClientConfigLinkTTL
+	}
+	if !IsExtClientConfFormat(req.Format) || ttl < 0 || ttl > MaxExtClientConfigLinkTTL || req.MaxDownloads < 0 {
+		return schema.ExtClientConfigLink{}, "", ErrInvalidExtClientConfigLink
+	}
+	now := time.Now().UTC()
+	link := schema.ExtClientConfigLink{
+		ID:           uuid.New().String(),
+		ClientID:     client.ClientID,
+		Network:      client.Network,
+		Format:       req.Format,
+		MaxDownloads: req.MaxDownloads,
+		Recipient:    req.Recipient,
+		CreatedBy:    createdBy,
+		CreatedAt:    now,
+		ExpiresAt:    now.Add(ttl),
+	}
+	token, err := CreateExtClientConfigLinkToken(link.ID, link.ExpiresAt)
+	if err != nil {
+		return link, "", err
+	}
+	return link, token, link.Create(db.WithContext(context.TODO()))
+}
+
+// GetExtClientConfigLink - verifies a config link token and gets the link if it can still be downloaded,
+// without counting a download
+func GetExtClientConfigLink(token string) (schema.ExtClientConfigLink, error) {
+	extClientConfigLinkMutex.Lock()
+	defer extClientConfigLinkMutex.Unlock()
+	return getUsableExtClientConfigLink(token, time.Now().UTC())
+}
+
+// ConsumeExtClientConfigLink - verifies a config link token and counts the download
+func ConsumeExtClientConfigLink(token string) (schema.ExtClientConfigLink, error) {
+	extClientConfigLinkMutex.Lock()
+	defer extClientConfigLinkMutex.Unlock()
+	now := time.Now().UTC()
+	link, err := getUsableExtClientConfigLink(token, now)
+	if err != nil {
+		return link, err
+	}
+	link.Downloads++
+	link.LastDownloadedAt = now
+	return link, link.Update(db.WithContext(context.TODO()))
+}
+
+// getUsableExtClientConfigLink - gets the link of a token unless it is revoked, expired or used up
+func getUsableExtClientConfigLink(token string, now time.Time) (schema.ExtClientConfigLink, error) {
+	id, err := VerifyExtClientConfigLinkToken(token)
+	if err != nil {
+		return schema.ExtClientConfigLink{}, ErrExtClientConfigLinkUnavailable
+	}
+	link := schema.ExtClientConfigLink{ID: id}
+	if err := link.Get(db.WithContext(context.TODO())); err != nil {
+		return link, ErrExtClientConfigLinkUnavailable
+	}
+	if link.Revoked || !now.Before(link.ExpiresAt) || (link.MaxDownloads > 0 && link.Downloads >= link.MaxDownloads) {
+		return link, ErrExtClientConfigLinkUnavailable
+	}
+	return link, nil
+}
+
+// RevokeExtClientConfigLink - stops a config link from working
+func RevokeExtClientConfigLink(id string) (schema.ExtClientConfigLink, error) {
+	extClientConfigLinkMutex.Lock()
+	defer extClientConfigLinkMutex.Unlock()
+	link := schema.ExtClientConfigLink{ID: id}
+	if err := link.Get(db.WithContext(context.TODO())); err != nil {
+		return link, err
+	}
+	link.Revoked = true
+	return link, link.Update(db.WithContext(context.TODO()))
+}
+
+// ExtClientConfigLinkURL - the public url of a config link
+func ExtClientConfigLinkURL(token string) string {
+	return fmt.Sprintf("https://%s/api/v1/extclient_config/%s", servercfg.GetAPIConnString(), token)
+}
@@ -0,0 +1,67 @@
+package logic
+
+import (
+	"context"
+	"testing"
+
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/db"
+	"github.com/gravitl/netmaker/models"
+	"github.com/gravitl/netmaker/schema"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExtClientConfigLinks(t *testing.T) {
+	db.InitializeDB(schema.ListModels()...)
+	defer db.CloseDB()
+	database.InitializeDatabase()
+	defer database.CloseDB()
+	SetJWTSecret()
+	client := models.ExtClient{ClientID: "link-test", Network: "linknet"}
+	defer (&schema.ExtClientConfigLink{ClientID: client.ClientID, Network: client.Network}).DeleteByClient(db.WithContext(context.TODO()))
+
+	t.Run("InvalidRequest", func(t *testing.T) {
+		_, _, err := CreateExtClientConfigLink(&client, models.ExtClientConfigLinkReq{Format: "bogus"}, "admin")
+		assert.ErrorIs(t, err, ErrInvalidExtClientConfigLink)
+		_, _, err = CreateExtClientConfigLink(&client, models.ExtClientConfigLinkReq{TTL: -1}, "admin")
+		assert.ErrorIs(t, err, ErrInvalidExtClientConfigLink)
+		_, _, err = CreateExtClientConfigLink(&client, models.ExtClientConfigLinkReq{MaxDownloads: -1}, "admin")
+		assert.ErrorIs(t, err, ErrInvalidExtClientConfigLink)
+	})
+	t.Run("SingleUse", func(t *testing.T) {
+		link, token, err := CreateExtClientConfigLink(&client, models.ExtClientConfigLinkReq{MaxDownloads: 1}, "admin")
+		assert.Nil(t, err)
+		assert.Equal(t, "file", link.Format)
+		// looking the link up does not count as a download
+		found, err := GetExtClientConfigLink(token)
+		assert.Nil(t, err)
+		assert.Equal(t, 0, found.Downloads)
+		consumed, err := ConsumeExtClientConfigLink(token)
+		assert.Nil(t, err)
+		assert.Equal(t, link.ID, consumed.ID)
+		assert.Equal(t, 1, consumed.Downloads)
+		_, err = ConsumeExtClientConfigLink(token)
+		assert.ErrorIs(t, err, ErrExtClientConfigLinkUnavailable)
+		_, err = GetExtClientConfigLink(token)
+		assert.ErrorIs(t, err, ErrExtClientConfigLinkUnavailable)
+	})
+	t.Run("Revoked", func(t *testing.T) {
+		link, token, err := CreateExtClientConfigLink(&client, models.ExtClientConfigLinkReq{}, "admin")
+		assert.Nil(t, err)
+		_, err = ConsumeExtClientConfigLink(token)
+		assert.Nil(t, err)
+		_, err = RevokeExtClientConfigLink(link.ID)
+		assert.Nil(t, err)
+		_, err = ConsumeExtClientConfigLink(token)
+		assert.ErrorIs(t, err, ErrExtClientConfigLinkUnavailable)
+	})
+	t.Run("BadToken", func(t *testing.T) {
+		_, err := ConsumeExtClientConfigLink("not-a-token")
+		assert.ErrorIs(t, err, ErrExtClientConfigLinkUnavailable)
+		// a session token must not open a config link
+		token, err := CreateUserJWT("admin", models.SuperAdminRole)
+		assert.Nil(t, err)
+		_, err = ConsumeExtClientConfigLink(token)
+		assert.ErrorIs(t, err, ErrExtClientConfigLinkUnavailable)
+	})
+}
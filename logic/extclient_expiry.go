@@ -0,0 +1,159 @@
+package logic
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/models"
+	"github.com/gravitl/netmaker/servercfg"
+	"golang.org/x/exp/slog"
+)
+
+// ExtClientExpiryCheckInterval - how often ext clients are checked for expiry
+const ExtClientExpiryCheckInterval = 5 * time.Minute
+
+// NotifyExtClientExpiry - warns the owner of an ext client that it is about to expire
+var NotifyExtClientExpiry = func(client models.ExtClient, expiresAt time.Time) error { return nil }
+
+// GetExtClientExpiryTime - returns when an ext client expires, zero if it never does
+func GetExtClientExpiryTime(client *models.ExtClient) time.Time {
+	var expiresAt time.Time
+	earliest := func(t time.Time) {
+		if expiresAt.IsZero() || t.Before(expiresAt) {
+			expiresAt = t
+		}
+	}
+	created := client.CreatedAt
+	if created.IsZero() {
+		// clients created before expiry existed only record their creation as last modified
+		created = time.Unix(client.LastModified, 0)
+	}
+	if !client.Expiry.ExpiresAt.IsZero() {
+		earliest(client.Expiry.ExpiresAt)
+	}
+	if client.Expiry.TTL > 0 {
+		earliest(created.Add(time.Duration(client.Expiry.TTL) * time.Second))
+	}
+	if client.Expiry.InactivityTimeout > 0 {
+		lastUsed := client.LastHandshake
+		if lastUsed.Before(created) {
+			lastUsed = created
+		}
+		earliest(lastUsed.Add(time.Duration(client.Expiry.InactivityTimeout) * time.Second))
+	}
+	return expiresAt
+}
+
+// IsExtClientExpiryNotificationDue - checks if the owner of an ext client should be warned of its expiry
+func IsExtClientExpiryNotificationDue(client *models.ExtClient, expiresAt, now time.Time) bool {
+	if client.Expiry.NotifyBefore <= 0 || client.OwnerID == "" || expiresAt.IsZero() || !now.Before(expiresAt) {
+		return false
+	}
+	notifyAt := expiresAt.Add(-time.Duration(client.Expiry.NotifyBefore) * time.Second)
+	if now.Before(notifyAt) {
+		return false
+	}
+	// an inactivity based expiry moves with every handshake, so warn again once it was pushed back
+	return client.Expiry.NotifiedAt.IsZero() || client.Expiry.NotifiedAt.Before(notifyAt)
+}
+
+// GetExtClientExpiryAction - the action applied to an expired ext client, disabling it by default
+func GetExtClientExpiryAction(client *models.ExtClient) models.ExtClientExpiryAction {
+	if client.Expiry.Action == "" {
+		return models.ExtClientExpiryDisable
+	}
+	return client.Expiry.Action
+}
+
+// UpdateExtClientLastHandshake - records the last handshake seen for an ext client,
+// without marking the network as modified since peers are unaffected
+func UpdateExtClientLastHandshake(client *models.ExtClient, t time.Time) error {
+	client.LastHandshake = t
+	return storeExtClient(client)
+}
+
+// SetExtClientNotifiedAt - records when the owner of an ext client was warned of its expiry
+func SetExtClientNotifiedAt(client *models.ExtClient, t time.Time) error {
+	client.Expiry.NotifiedAt = t
+	return storeExtClient(client)
+}
+
+func storeExtClient(client *models.ExtClient) error {
+	key, err := GetRecordKey(client.ClientID, client.Network)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(client)
+	if err != nil {
+		return err
+	}
+	if err = database.Insert(key, string(data), database.EXT_CLIENT_TABLE_NAME); err != nil {
+		return err
+	}
+	if servercfg.CacheEnabled() {
+		storeExtClientInCache(key, *client)
+	}
+	return nil
+}
+
+// AddExtClientExpiryHook - adds the hook disabling or deleting expired ext clients
+func AddExtClientExpiryHook() {
+	HookManagerCh <- models.HookDetails{
+		Hook:     extClientExpiryHook,
+		Interval: ExtClientExpiryCheckInterval,
+	}
+}
+
+// extClientExpiryHook - disables or deletes ext clients that outlived their expiry
+// and warns owners of clients that are about to expire
+func extClientExpiryHook() error {
+	clients, err := GetAllExtClients()
+	if err != nil && !database.IsEmptyRecord(err) {
+		return err
+	}
+	now := time.Now()
+	for i := range clients {
+		client := clients[i]
+		if !client.Expiry.IsSet() {
+			continue
+		}
+		expiresAt := GetExtClientExpiryTime(&client)
+		if now.Before(expiresAt) {
+			if IsExtClientExpiryNotificationDue(&client, expiresAt, now) {
+				if err := NotifyExtClientExpiry(client, expiresAt); err != nil {
+					slog.Error("failed to notify ext client owner of expiry", "client", client.ClientID, "owner", client.OwnerID, "error", err)
+					continue
+				}
+				if err := SetExtClientNotifiedAt(&client, now); err != nil {
+					slog.Error("failed to record ext client expiry notification", "client", client.ClientID, "error", err)
+				}
+			}
+			continue
+		}
+		switch GetExtClientExpiryAction(&client) {
+		case models.ExtClientExpiryDelete:
+			slog.Info("deleting expired ext client", "client", client.ClientID, "network", client.Network)
+			if err := DeleteExtClientAndCleanup(client); err != nil {
+				slog.Error("failed to delete expired ext client", "client", client.ClientID, "error", err)
+				continue
+			}
+			if servercfg.IsDNSMode() {
+				SetDNS()
+			}
+		default:
+			if !client.Enabled {
+				continue
+			}
+			slog.Info("disabling expired ext client", "client", client.ClientID, "network", client.Network)
+			if _, err := ToggleExtClientConnectivity(&client, false); err != nil {
+				slog.Error("failed to disable expired ext client", "client", client.ClientID, "error", err)
+				continue
+			}
+		}
+		if err := PublishDeletedClientPeerUpdate(&client); err != nil {
+			slog.Error("error removing expired ext client from peers", "client", client.ClientID, "error", err)
+		}
+	}
+	return nil
+}
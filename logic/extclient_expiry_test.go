@@ -0,0 +1,45 @@
+package logic
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gravitl/netmaker/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetExtClientExpiryTime(t *testing.T) {
+	created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	client := models.ExtClient{CreatedAt: created}
+	assert.True(t, GetExtClientExpiryTime(&client).IsZero())
+
+	client.Expiry.TTL = 3600
+	assert.Equal(t, created.Add(time.Hour), GetExtClientExpiryTime(&client))
+
+	client.Expiry.ExpiresAt = created.Add(time.Minute)
+	assert.Equal(t, created.Add(time.Minute), GetExtClientExpiryTime(&client))
+
+	client.Expiry = models.ExtClientExpiry{InactivityTimeout: 600}
+	assert.Equal(t, created.Add(10*time.Minute), GetExtClientExpiryTime(&client))
+	client.LastHandshake = created.Add(time.Hour)
+	assert.Equal(t, created.Add(70*time.Minute), GetExtClientExpiryTime(&client))
+
+	legacy := models.ExtClient{LastModified: created.Unix(), Expiry: models.ExtClientExpiry{TTL: 60}}
+	assert.Equal(t, created.Add(time.Minute).Unix(), GetExtClientExpiryTime(&legacy).Unix())
+}
+
+func TestIsExtClientExpiryNotificationDue(t *testing.T) {
+	now := time.Now()
+	client := models.ExtClient{OwnerID: "user@example.com", Expiry: models.ExtClientExpiry{NotifyBefore: 3600}}
+	assert.False(t, IsExtClientExpiryNotificationDue(&client, now.Add(2*time.Hour), now))
+	assert.True(t, IsExtClientExpiryNotificationDue(&client, now.Add(30*time.Minute), now))
+	assert.False(t, IsExtClientExpiryNotificationDue(&client, now.Add(-time.Minute), now))
+
+	client.Expiry.NotifiedAt = now.Add(-10 * time.Minute)
+	assert.False(t, IsExtClientExpiryNotificationDue(&client, now.Add(30*time.Minute), now))
+	// expiry pushed back by a handshake after the warning was sent
+	assert.True(t, IsExtClientExpiryNotificationDue(&client, now.Add(55*time.Minute), now))
+
+	client.Expiry.NotifyBefore = 0
+	assert.False(t, IsExtClientExpiryNotificationDue(&client, now.Add(30*time.Minute), now))
+}
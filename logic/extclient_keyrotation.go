@@ -0,0 +1,217 @@
+package logic
+
+import (
+	"errors"
+	"net"
+	"time"
+
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/logger"
+	"github.com/gravitl/netmaker/models"
+	"golang.org/x/exp/slog"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+// ExtClientKeyRotationCheckInterval - how often ext clients are checked for key rotation
+const ExtClientKeyRotationCheckInterval = 5 * time.Minute
+
+// DefaultExtClientKeyOverlap - how long the previous key is accepted when a policy sets no overlap
+const DefaultExtClientKeyOverlap = 24 * time.Hour
+
+// NotifyExtClientKeyRotation - tells the owner of an ext client that a new config is available
+var NotifyExtClientKeyRotation = func(client models.ExtClient) error { return nil }
+
+// ErrExtClientKeyRotationUnsupported - returned for rotation policies on servers that can't hand the addresses
+// of an ext client over from its previous key
+var ErrExtClientKeyRotationUnsupported = errors.New("scheduled ext client key rotation needs gateway handshake tracking")
+
+// IsExtClientKeyHandoverTracked - checks if the gateways report the handshakes of rotated ext client keys,
+// the addresses then stay routed to the previous key until the new one connects. Keys are only
+// rotated on a schedule when they are, as there is no overlap otherwise
+var IsExtClientKeyHandoverTracked = func() bool { return false }
+
+// ValidateExtClientKeyRotation - checks a rotation policy is usable on this server
+func ValidateExtClientKeyRotation(policy *models.KeyRotationPolicy) error {
+	if err := policy.Validate(); err != nil {
+		return err
+	}
+	if policy != nil && policy.Interval > 0 && !IsExtClientKeyHandoverTracked() {
+		return ErrExtClientKeyRotationUnsupported
+	}
+	return nil
+}
+
+// GetExtClientKeyRotationPolicy - the rotation policy of an ext client, falling back to its network's
+func GetExtClientKeyRotationPolicy(client *models.ExtClient, network *models.Network) models.KeyRotationPolicy {
+	if client.KeyRotation.Interval > 0 {
+		return client.KeyRotation
+	}
+	if network != nil {
+		return network.ExtClientKeyRotation
+	}
+	return models.KeyRotationPolicy{}
+}
+
+// IsExtClientKeyRotationDue - checks if the keypair of an ext client should be replaced
+func IsExtClientKeyRotationDue(client *models.ExtClient, policy models.KeyRotationPolicy, now time.Time) bool {
+	// clients that brought their own public key have no private key for us to replace
+	if policy.Interval <= 0 || client.PrivateKey == "" || client.PreviousPublicKey != "" ||
+		!IsExtClientKeyHandoverTracked() {
+		return false
+	}
+	rotatedAt := client.KeyRotatedAt
+	if rotatedAt.IsZero() {
+		rotatedAt = client.CreatedAt
+	}
+	if rotatedAt.IsZero() {
+		rotatedAt = time.Unix(client.LastModified, 0)
+	}
+	return !now.Before(rotatedAt.Add(time.Duration(policy.Interval) * time.Second))
+}
+
+// IsExtClientKeyHandoverActive - checks if the gateway still accepts the previous key of an ext client
+func IsExtClientKeyHandoverActive(client *models.ExtClient, now time.Time) bool {
+	return client.PreviousPublicKey != "" && now.Before(client.PreviousKeyExpiresAt)
+}
+
+// RotateExtClientKey - generates a new keypair for an ext client, the current public key
+// stays accepted on the gateway for the overlap window
+func RotateExtClientKey(client *models.ExtClient, overlap time.Duration) error {
+	privateKey, err := wgtypes.GeneratePrivateKey()
+	if err != nil {
+		return err
+	}
+	if overlap <= 0 {
+		overlap = DefaultExtClientKeyOverlap
+	}
+	now := time.Now()
+	client.PreviousPublicKey = client.PublicKey
+	client.PreviousKeyExpiresAt = now.Add(overlap)
+	client.PrivateKey = privateKey.String()
+	client.PublicKey = privateKey.PublicKey().String()
+	client.KeyRotatedAt = now
+	return SaveExtClient(client)
+}
+
+// CompleteExtClientKeyHandover - stops accepting the previous key of an ext client,
+// returns a copy of the client holding the previous key so it can be removed from its gateway
+func CompleteExtClientKeyHandover(client *models.ExtClient) (models.ExtClient, error) {
+	previous := *client
+	previous.PublicKey = client.PreviousPublicKey
+	client.PreviousPublicKey = ""
+	client.PreviousKeyExpiresAt = time.Time{}
+	return previous, SaveExtClient(client)
+}
+
+// GetExtClientHandoverPeers - the peers of ext client keys kept on a gateway during a key handover next to the key
+// the addresses are routed to, they carry no allowed ips
+func GetExtClientHandoverPeers(node *models.Node, host *models.Host) []wgtypes.PeerConfig {
+	var peers []wgtypes.PeerConfig
+	extPeers, err := GetNetworkExtClients(node.Network)
+	if err != nil {
+		return peers
+	}
+	usePresharedKeys := IsPresharedKeyEnabled(node.Network)
+	now := time.Now()
+	for i := range extPeers {
+		extPeer := extPeers[i]
+		if extPeer.IngressGatewayID != node.ID.String() || !extPeer.Enabled ||
+			!IsExtClientKeyHandoverActive(&extPeer, now) {
+			continue
+		}
+		handoverKey := extPeer.PublicKey
+		if extClientRoutingKey(&extPeer) == extPeer.PublicKey {
+			handoverKey = extPeer.PreviousPublicKey
+		}
+		pubkey, err := wgtypes.ParseKey(handoverKey)
+		if err != nil {
+			logger.Log(1, "error parsing ext pub key:", err.Error())
+			continue
+		}
+		peer := wgtypes.PeerConfig{
+			PublicKey:         pubkey,
+			ReplaceAllowedIPs: true,
+			AllowedIPs:        []net.IPNet{},
+		}
+		if usePresharedKeys {
+			peer.PresharedKey = GetExtClientPresharedKey(&extPeer, host)
+		}
+		peers = append(peers, peer)
+	}
+	return peers
+}
+
+// extClientRoutingKey - the public key the addresses of an ext client are routed to
+func extClientRoutingKey(client *models.ExtClient) string {
+	if IsExtClientKeyHandoverTracked() && IsExtClientKeyHandoverActive(client, time.Now()) {
+		return client.PreviousPublicKey
+	}
+	return client.PublicKey
+}
+
+// AddExtClientKeyRotationHook - adds the hook rotating ext client keys
+func AddExtClientKeyRotationHook() {
+	HookManagerCh <- models.HookDetails{
+		Hook:     extClientKeyRotationHook,
+		Interval: ExtClientKeyRotationCheckInterval,
+	}
+}
+
+// extClientKeyRotationHook - rotates ext client keypairs according to their rotation policy
+// and stops accepting previous keys once their overlap window ended
+func extClientKeyRotationHook() error {
+	clients, err := GetAllExtClients()
+	if err != nil && !database.IsEmptyRecord(err) {
+		return err
+	}
+	now := time.Now()
+	networks := make(map[string]*models.Network)
+	var rotated bool
+	var previousKeys []models.ExtClient
+	for i := range clients {
+		client := clients[i]
+		if client.PreviousPublicKey != "" {
+			if IsExtClientKeyHandoverActive(&client, now) {
+				continue
+			}
+			previous, err := CompleteExtClientKeyHandover(&client)
+			if err != nil {
+				slog.Error("failed to remove previous ext client key", "client", client.ClientID, "error", err)
+				continue
+			}
+			previousKeys = append(previousKeys, previous)
+			continue
+		}
+		network, ok := networks[client.Network]
+		if !ok {
+			if n, err := GetNetwork(client.Network); err == nil {
+				network = &n
+			}
+			networks[client.Network] = network
+		}
+		policy := GetExtClientKeyRotationPolicy(&client, network)
+		if !IsExtClientKeyRotationDue(&client, policy, now) {
+			continue
+		}
+		slog.Info("rotating ext client key", "client", client.ClientID, "network", client.Network)
+		if err := RotateExtClientKey(&client, time.Duration(policy.Overlap)*time.Second); err != nil {
+			slog.Error("failed to rotate ext client key", "client", client.ClientID, "error", err)
+			continue
+		}
+		rotated = true
+		if err := NotifyExtClientKeyRotation(client); err != nil {
+			slog.Error("failed to notify ext client owner of key rotation", "client", client.ClientID, "owner", client.OwnerID, "error", err)
+		}
+	}
+	if rotated {
+		if err := PublishPeerUpdate(false); err != nil {
+			slog.Error("error publishing peer update for rotated ext client keys", "error", err)
+		}
+	}
+	for i := range previousKeys {
+		if err := PublishDeletedClientPeerUpdate(&previousKeys[i]); err != nil {
+			slog.Error("error removing previous ext client key from peers", "client", previousKeys[i].ClientID, "error", err)
+		}
+	}
+	return nil
+}
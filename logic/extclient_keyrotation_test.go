@@ -0,0 +1,92 @@
+package logic
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/db"
+	"github.com/gravitl/netmaker/models"
+	"github.com/gravitl/netmaker/schema"
+	"github.com/stretchr/testify/assert"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+func TestGetExtClientKeyRotationPolicy(t *testing.T) {
+	network := models.Network{ExtClientKeyRotation: models.KeyRotationPolicy{Interval: 86400, Overlap: 3600}}
+	client := models.ExtClient{}
+	assert.Equal(t, network.ExtClientKeyRotation, GetExtClientKeyRotationPolicy(&client, &network))
+	assert.Equal(t, models.KeyRotationPolicy{}, GetExtClientKeyRotationPolicy(&client, nil))
+
+	client.KeyRotation = models.KeyRotationPolicy{Interval: 3600, Overlap: 60}
+	assert.Equal(t, client.KeyRotation, GetExtClientKeyRotationPolicy(&client, &network))
+
+	assert.Nil(t, client.KeyRotation.Validate())
+	assert.ErrorIs(t, ValidateExtClientKeyRotation(&client.KeyRotation), ErrExtClientKeyRotationUnsupported)
+	assert.Nil(t, ValidateExtClientKeyRotation(&models.KeyRotationPolicy{}))
+	assert.ErrorIs(t, (&models.KeyRotationPolicy{Interval: 60, Overlap: 60}).Validate(), models.ErrInvalidKeyRotationPolicy)
+	assert.ErrorIs(t, (&models.KeyRotationPolicy{Interval: -1}).Validate(), models.ErrInvalidKeyRotationPolicy)
+}
+
+func TestIsExtClientKeyRotationDue(t *testing.T) {
+	now := time.Now()
+	policy := models.KeyRotationPolicy{Interval: 3600}
+	client := models.ExtClient{PrivateKey: "key", CreatedAt: now.Add(-30 * time.Minute)}
+	assert.False(t, IsExtClientKeyRotationDue(&client, policy, now))
+	client.CreatedAt = now.Add(-2 * time.Hour)
+	// without handshake tracking the previous key couldn't overlap with the new one
+	assert.False(t, IsExtClientKeyRotationDue(&client, policy, now))
+	IsExtClientKeyHandoverTracked = func() bool { return true }
+	defer func() {
+		IsExtClientKeyHandoverTracked = func() bool { return false }
+	}()
+	assert.True(t, IsExtClientKeyRotationDue(&client, policy, now))
+	client.KeyRotatedAt = now.Add(-time.Minute)
+	assert.False(t, IsExtClientKeyRotationDue(&client, policy, now))
+	assert.False(t, IsExtClientKeyRotationDue(&client, models.KeyRotationPolicy{}, now.Add(48*time.Hour)))
+
+	// clients with their own public key can't be rotated
+	client.PrivateKey = ""
+	assert.False(t, IsExtClientKeyRotationDue(&client, policy, now.Add(48*time.Hour)))
+}
+
+func TestRotateExtClientKey(t *testing.T) {
+	db.InitializeDB(schema.ListModels()...)
+	defer db.CloseDB()
+	database.InitializeDatabase()
+	defer database.CloseDB()
+	network := models.Network{NetID: "rotatenet", AddressRange: "10.101.0.0/24"}
+	err := SaveNetwork(&network)
+	assert.Nil(t, err)
+	privateKey, _ := wgtypes.GeneratePrivateKey()
+	client := models.ExtClient{
+		ClientID:   "rotate-test",
+		Network:    "rotatenet",
+		PrivateKey: privateKey.String(),
+		PublicKey:  privateKey.PublicKey().String(),
+	}
+	err = RotateExtClientKey(&client, time.Hour)
+	assert.Nil(t, err)
+	assert.Equal(t, privateKey.PublicKey().String(), client.PreviousPublicKey)
+	assert.NotEqual(t, privateKey.PublicKey().String(), client.PublicKey)
+	assert.True(t, IsExtClientKeyHandoverActive(&client, time.Now()))
+	assert.False(t, IsExtClientKeyHandoverActive(&client, time.Now().Add(2*time.Hour)))
+	// without handshake reports the addresses move to the new key right away
+	assert.Equal(t, client.PublicKey, extClientRoutingKey(&client))
+	IsExtClientKeyHandoverTracked = func() bool { return true }
+	assert.Equal(t, client.PreviousPublicKey, extClientRoutingKey(&client))
+	IsExtClientKeyHandoverTracked = func() bool { return false }
+
+	previous, err := CompleteExtClientKeyHandover(&client)
+	assert.Nil(t, err)
+	assert.Equal(t, privateKey.PublicKey().String(), previous.PublicKey)
+	assert.Empty(t, client.PreviousPublicKey)
+	assert.Equal(t, client.PublicKey, extClientRoutingKey(&client))
+
+	stored, err := GetExtClient(client.ClientID, client.Network)
+	assert.Nil(t, err)
+	assert.Equal(t, client.PublicKey, stored.PublicKey)
+	assert.Empty(t, stored.PreviousPublicKey)
+	_ = DeleteExtClient(client.Network, client.ClientID)
+	_ = database.DeleteRecord(database.NETWORKS_TABLE_NAME, network.NetID)
+}
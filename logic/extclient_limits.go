@@ -0,0 +1,179 @@
+package logic
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gravitl/netmaker/models"
+	"golang.org/x/exp/slog"
+)
+
+// ErrExtClientLimitReached - returned when a user owns as many ext clients as their roles and groups allow
+var ErrExtClientLimitReached = errors.New("device limit reached")
+
+// ExtClientLimitNotifyInterval - how often the admins are told about the same user hitting their device limits
+const ExtClientLimitNotifyInterval = time.Hour
+
+// NotifyExtClientLimitReached - tells the admins that a user hit one of their device limits
+var NotifyExtClientLimitReached = func(user models.User, scope string, limit int) error { return nil }
+
+var (
+	extClientLimitNotifyMutex = &sync.Mutex{}
+	extClientLimitNotifiedAt  = make(map[string]time.Time)
+)
+
+// GetUserExtClientLimits - the device limits of a user on a network, the most permissive limit
+// set by the user's platform role, network roles and groups applies, roles and groups that leave a limit unset don't affect it
+func GetUserExtClientLimits(user *models.User, network models.NetworkID) models.ExtClientLimits {
+	var limits models.ExtClientLimits
+	if user.PlatformRoleID == models.SuperAdminRole || user.PlatformRoleID == models.AdminRole {
+		return limits
+	}
+	// an unset limit is 0, so the larger of two limits is the more permissive one that was set
+	merge := func(l models.ExtClientLimits) {
+		limits.PerUser = max(limits.PerUser, l.PerUser)
+		limits.PerNetwork = max(limits.PerNetwork, l.PerNetwork)
+		limits.PerGateway = max(limits.PerGateway, l.PerGateway)
+	}
+	mergeRoles := func(roles map[models.NetworkID]map[models.UserRoleID]struct{}) {
+		for _, netID := range []models.NetworkID{network, models.AllNetworks} {
+			for roleID := range roles[netID] {
+				if role, err := GetRole(roleID); err == nil {
+					merge(role.DeviceLimits)
+				}
+			}
+		}
+	}
+	if role, err := GetRole(user.PlatformRoleID); err == nil {
+		merge(role.DeviceLimits)
+	}
+	mergeRoles(user.NetworkRoles)
+	for groupID := range user.UserGroups {
+		group, err := GetUserGroup(groupID)
+		if err != nil {
+			continue
+		}
+		merge(group.DeviceLimits)
+		mergeRoles(group.NetworkRoles)
+	}
+	return limits
+}
+
+// CheckUserExtClientLimits - checks a user may create another ext client on the gateway,
+// the admins are notified when a limit is hit, at most once per user and notify interval
+func CheckUserExtClientLimits(user *models.User, network, gatewayID string) error {
+	limits := GetUserExtClientLimits(user, models.NetworkID(network))
+	if limits == (models.ExtClientLimits{}) {
+		return nil
+	}
+	clients, err := GetUserExtClients(user.UserName)
+	if err != nil {
+		return err
+	}
+	var onNetwork, onGateway int
+	for _, client := range clients {
+		if client.Network == network {
+			onNetwork++
+		}
+		if client.IngressGatewayID == gatewayID {
+			onGateway++
+		}
+	}
+	scope, limit := "", 0
+	switch {
+	case limits.PerUser > 0 && len(clients) >= limits.PerUser:
+		scope, limit = "user", limits.PerUser
+	case limits.PerNetwork > 0 && onNetwork >= limits.PerNetwork:
+		scope, limit = "network "+network, limits.PerNetwork
+	case limits.PerGateway > 0 && onGateway >= limits.PerGateway:
+		scope, limit = "gateway", limits.PerGateway
+	default:
+		return nil
+	}
+	if isExtClientLimitNotificationDue(user.UserName, time.Now()) {
+		go func() {
+			if err := NotifyExtClientLimitReached(*user, scope, limit); err != nil {
+				slog.Error("failed to notify admins of device limit", "user", user.UserName, "error", err)
+			}
+		}()
+	}
+	return fmt.Errorf("%w: %d devices per %s", ErrExtClientLimitReached, limit, scope)
+}
+
+// isExtClientLimitNotificationDue - checks if the admins were not told about the user within the notify interval,
+// recording the notification when they were not
+func isExtClientLimitNotificationDue(username string, now time.Time) bool {
+	extClientLimitNotifyMutex.Lock()
+	defer extClientLimitNotifyMutex.Unlock()
+	for name, notifiedAt := range extClientLimitNotifiedAt {
+		if now.Sub(notifiedAt) >= ExtClientLimitNotifyInterval {
+			delete(extClientLimitNotifiedAt, name)
+		}
+	}
+	if _, ok := extClientLimitNotifiedAt[username]; ok {
+		return false
+	}
+	extClientLimitNotifiedAt[username] = now
+	return true
+}
+
+// GetUserExtClients - gets the ext clients owned by a user
+func GetUserExtClients(username string) ([]models.ExtClient, error) {
+	var userClients []models.ExtClient
+	clients, err := GetAllExtClients()
+	if err != nil {
+		return userClients, err
+	}
+	for _, client := range clients {
+		if client.OwnerID == username {
+			userClients = append(userClients, client)
+		}
+	}
+	return userClients, nil
+}
+
+// GetUserRacDevices - groups the ext clients of a user by the device they were created from
+func GetUserRacDevices(username string) ([]models.RacDevice, error) {
+	clients, err := GetUserExtClients(username)
+	if err != nil {
+		return nil, err
+	}
+	devices := make(map[string]*models.RacDevice)
+	for _, client := range clients {
+		id := RacDeviceID(&client)
+		device, ok := devices[id]
+		if !ok {
+			device = &models.RacDevice{
+				ID:                   id,
+				RemoteAccessClientID: client.RemoteAccessClientID,
+				DeviceName:           client.DeviceName,
+				Os:                   client.Os,
+			}
+			devices[id] = device
+		}
+		if client.Enabled {
+			device.Enabled = true
+		}
+		client.PrivateKey = ""
+		device.Clients = append(device.Clients, client)
+	}
+	result := make([]models.RacDevice, 0, len(devices))
+	for _, device := range devices {
+		result = append(result, *device)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].ID < result[j].ID
+	})
+	return result, nil
+}
+
+// RacDeviceID - the id of the device an ext client belongs to
+func RacDeviceID(client *models.ExtClient) string {
+	if client.RemoteAccessClientID != "" {
+		return client.RemoteAccessClientID
+	}
+	return client.ClientID
+}
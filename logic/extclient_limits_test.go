@@ -0,0 +1,102 @@
+package logic
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/db"
+	"github.com/gravitl/netmaker/models"
+	"github.com/gravitl/netmaker/schema"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckUserExtClientLimits(t *testing.T) {
+	db.InitializeDB(schema.ListModels()...)
+	defer db.CloseDB()
+	database.InitializeDatabase()
+	defer database.CloseDB()
+	saveRole := func(role models.UserRolePermissionTemplate) {
+		data, _ := json.Marshal(role)
+		assert.Nil(t, database.Insert(role.ID.String(), string(data), database.USER_PERMISSIONS_TABLE_NAME))
+	}
+	saveRole(models.UserRolePermissionTemplate{ID: "limit-platform", DeviceLimits: models.ExtClientLimits{PerUser: 3, PerGateway: 1}})
+	saveRole(models.UserRolePermissionTemplate{ID: "limit-net", NetworkID: "limitnet", DeviceLimits: models.ExtClientLimits{PerUser: 2, PerGateway: 1}})
+	saveRole(models.UserRolePermissionTemplate{ID: "limit-open", NetworkID: "opennet", DeviceLimits: models.ExtClientLimits{PerGateway: 2}})
+	defer func() {
+		_ = database.DeleteRecord(database.USER_PERMISSIONS_TABLE_NAME, "limit-platform")
+		_ = database.DeleteRecord(database.USER_PERMISSIONS_TABLE_NAME, "limit-net")
+		_ = database.DeleteRecord(database.USER_PERMISSIONS_TABLE_NAME, "limit-open")
+	}()
+	user := models.User{
+		UserName:       "limited",
+		PlatformRoleID: "limit-platform",
+		NetworkRoles: map[models.NetworkID]map[models.UserRoleID]struct{}{
+			"limitnet": {"limit-net": {}},
+			"opennet":  {"limit-open": {}},
+		},
+	}
+	assert.Equal(t, models.ExtClientLimits{PerUser: 3, PerGateway: 1}, GetUserExtClientLimits(&user, "limitnet"))
+	assert.Equal(t, models.ExtClientLimits{PerUser: 3, PerGateway: 1}, GetUserExtClientLimits(&user, "othernet"))
+	// a role without a per user limit leaves the platform role's one
+	assert.Equal(t, models.ExtClientLimits{PerUser: 3, PerGateway: 2}, GetUserExtClientLimits(&user, "opennet"))
+
+	t.Run("Group", func(t *testing.T) {
+		saveRole(models.UserRolePermissionTemplate{ID: models.PlatformUser})
+		defer func() {
+			_ = database.DeleteRecord(database.USER_PERMISSIONS_TABLE_NAME, models.PlatformUser.String())
+		}()
+		defaultGetUserGroup := GetUserGroup
+		GetUserGroup = func(groupID models.UserGroupID) (models.UserGroup, error) {
+			return models.UserGroup{ID: groupID, DeviceLimits: models.ExtClientLimits{PerUser: 2}}, nil
+		}
+		defer func() {
+			GetUserGroup = defaultGetUserGroup
+		}()
+		member := models.User{
+			UserName:       "grouped",
+			PlatformRoleID: models.PlatformUser,
+			UserGroups:     map[models.UserGroupID]struct{}{"contractors": {}},
+		}
+		// the default platform role sets no limits and leaves the group's one
+		assert.Equal(t, models.ExtClientLimits{PerUser: 2}, GetUserExtClientLimits(&member, "limitnet"))
+	})
+	admin := user
+	admin.PlatformRoleID = models.AdminRole
+	assert.Equal(t, models.ExtClientLimits{}, GetUserExtClientLimits(&admin, "limitnet"))
+
+	network := models.Network{NetID: "limitnet", AddressRange: "10.102.0.0/24"}
+	assert.Nil(t, SaveNetwork(&network))
+	defer func() {
+		_ = database.DeleteRecord(database.NETWORKS_TABLE_NAME, network.NetID)
+	}()
+	clients := []models.ExtClient{
+		{ClientID: "limited-1", Network: "limitnet", OwnerID: "limited", IngressGatewayID: "gw1", RemoteAccessClientID: "laptop"},
+		{ClientID: "limited-2", Network: "limitnet", OwnerID: "limited", IngressGatewayID: "gw2", RemoteAccessClientID: "laptop"},
+		{ClientID: "other-1", Network: "limitnet", OwnerID: "other", IngressGatewayID: "gw1"},
+	}
+	for i := range clients {
+		assert.Nil(t, SaveExtClient(&clients[i]))
+		defer DeleteExtClient(clients[i].Network, clients[i].ClientID)
+	}
+	assert.True(t, errors.Is(CheckUserExtClientLimits(&user, "limitnet", "gw1"), ErrExtClientLimitReached))
+	assert.Nil(t, CheckUserExtClientLimits(&user, "limitnet", "gw3"))
+	user.PlatformRoleID = "limit-net-only"
+	assert.True(t, errors.Is(CheckUserExtClientLimits(&user, "limitnet", "gw3"), ErrExtClientLimitReached))
+
+	// the admins hear about a user once per notify interval
+	now := time.Now()
+	username := "limited-" + uuid.NewString()
+	assert.True(t, isExtClientLimitNotificationDue(username, now))
+	assert.False(t, isExtClientLimitNotificationDue(username, now.Add(time.Minute)))
+	assert.True(t, isExtClientLimitNotificationDue(username, now.Add(ExtClientLimitNotifyInterval)))
+
+	devices, err := GetUserRacDevices("limited")
+	assert.Nil(t, err)
+	assert.Len(t, devices, 1)
+	assert.Equal(t, "laptop", devices[0].ID)
+	assert.Len(t, devices[0].Clients, 2)
+}
@@ -0,0 +1,193 @@
+package logic
+
+import (
+	"context"
+	"errors"
+	"net"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gravitl/netmaker/db"
+	"github.com/gravitl/netmaker/models"
+	"github.com/gravitl/netmaker/schema"
+	"golang.org/x/exp/slog"
+	"gorm.io/gorm"
+)
+
+// ExtClientQuotaCheckInterval - how often quota suspended ext clients are checked for the end of their period
+const ExtClientQuotaCheckInterval = time.Minute
+
+// QuotaPeriodStart - the start of the quota period containing now
+func QuotaPeriodStart(period models.QuotaPeriod, now time.Time) time.Time {
+	now = now.UTC()
+	if period == models.QuotaPeriodMonth {
+		return time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	}
+	return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// QuotaPeriodEnd - the end of the quota period containing now
+func QuotaPeriodEnd(period models.QuotaPeriod, now time.Time) time.Time {
+	start := QuotaPeriodStart(period, now)
+	if period == models.QuotaPeriodMonth {
+		return start.AddDate(0, 1, 0)
+	}
+	return start.AddDate(0, 0, 1)
+}
+
+// RecordExtClientUsage - adds the traffic between two gateway reports to the daily usage of an ext client
+func RecordExtClientUsage(client *models.ExtClient, previous, current models.Metric, now time.Time) error {
+	sent := counterDelta(previous.LastTotalSent, current.TotalSent)
+	received := counterDelta(previous.LastTotalReceived, current.TotalReceived)
+	if sent == 0 && received == 0 {
+		return nil
+	}
+	ctx := db.WithContext(context.TODO())
+	usage := schema.ExtClientUsage{
+		ClientID: client.ClientID,
+		Network:  client.Network,
+		Day:      QuotaPeriodStart(models.QuotaPeriodDay, now),
+	}
+	err := usage.Get(ctx)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	usage.OwnerID = client.OwnerID
+	usage.BytesSent += sent
+	usage.BytesReceived += received
+	if err != nil {
+		usage.ID = uuid.New().String()
+		return usage.Create(ctx)
+	}
+	return usage.Update(ctx)
+}
+
+// GetExtClientQuotaUsage - the bytes an ext client used in the current period of the quota
+func GetExtClientQuotaUsage(client *models.ExtClient, quota models.ExtClientQuota, now time.Time) (int64, error) {
+	return (&schema.ExtClientUsage{
+		ClientID: client.ClientID,
+		Network:  client.Network,
+	}).Total(db.WithContext(context.TODO()), QuotaPeriodStart(quota.Period, now))
+}
+
+// GetUserQuotaUsage - the bytes the ext clients of a user used in the current period of the quota
+func GetUserQuotaUsage(username string, quota models.ExtClientQuota, now time.Time) (int64, error) {
+	return (&schema.ExtClientUsage{
+		OwnerID: username,
+	}).Total(db.WithContext(context.TODO()), QuotaPeriodStart(quota.Period, now))
+}
+
+// CheckExtClientQuota - checks the ext client and its owner are within their quotas,
+// returns when the client may connect again if a quota is used up
+func CheckExtClientQuota(client *models.ExtClient, now time.Time) (exceeded bool, until time.Time, err error) {
+	check := func(quota models.ExtClientQuota, usage func() (int64, error)) error {
+		if quota.Bytes <= 0 {
+			return nil
+		}
+		used, err := usage()
+		if err != nil {
+			return err
+		}
+		if used >= quota.Bytes {
+			exceeded = true
+			if end := QuotaPeriodEnd(quota.Period, now); end.After(until) {
+				until = end
+			}
+		}
+		return nil
+	}
+	err = check(client.Quota, func() (int64, error) {
+		return GetExtClientQuotaUsage(client, client.Quota, now)
+	})
+	if err != nil || client.OwnerID == "" {
+		return
+	}
+	owner, userErr := GetUser(client.OwnerID)
+	if userErr != nil {
+		return
+	}
+	err = check(owner.ExtClientQuota, func() (int64, error) {
+		return GetUserQuotaUsage(owner.UserName, owner.ExtClientQuota, now)
+	})
+	return
+}
+
+// SuspendExtClientForQuota - disables an ext client until the end of its quota period
+func SuspendExtClientForQuota(client *models.ExtClient, until time.Time) (models.ExtClient, error) {
+	client.QuotaSuspendedUntil = until
+	return ToggleExtClientConnectivity(client, false)
+}
+
+// ResumeQuotaSuspendedExtClients - re-enables the ext clients whose quota period has ended
+func ResumeQuotaSuspendedExtClients(now time.Time) ([]models.ExtClient, error) {
+	var resumed []models.ExtClient
+	clients, err := GetAllExtClients()
+	if err != nil {
+		return resumed, err
+	}
+	for i := range clients {
+		client := clients[i]
+		if client.QuotaSuspendedUntil.IsZero() || now.Before(client.QuotaSuspendedUntil) {
+			continue
+		}
+		client.QuotaSuspendedUntil = time.Time{}
+		if client.Enabled {
+			// re-enabled by an admin in the meantime
+			if err := SaveExtClient(&client); err != nil {
+				slog.Error("failed to clear ext client quota suspension", "client", client.ClientID, "error", err)
+			}
+			continue
+		}
+		newClient, err := ToggleExtClientConnectivity(&client, true)
+		if err != nil {
+			slog.Error("failed to resume quota suspended ext client", "client", client.ClientID, "error", err)
+			continue
+		}
+		resumed = append(resumed, newClient)
+	}
+	return resumed, nil
+}
+
+// GetExtClientRateLimit - the rate limit of an ext client, unset directions fall back to its owner's limit
+func GetExtClientRateLimit(client *models.ExtClient) models.ExtClientRateLimit {
+	limit := client.RateLimit
+	if (limit.Upload > 0 && limit.Download > 0) || client.OwnerID == "" {
+		return limit
+	}
+	owner, err := GetUser(client.OwnerID)
+	if err != nil {
+		return limit
+	}
+	if limit.Upload == 0 {
+		limit.Upload = owner.ExtClientRateLimit.Upload
+	}
+	if limit.Download == 0 {
+		limit.Download = owner.ExtClientRateLimit.Download
+	}
+	return limit
+}
+
+// GetIngressRateLimits - the rate limits of the ext clients on an ingress gateway
+func GetIngressRateLimits(node models.Node) []models.ExtClientRateLimitRule {
+	var rules []models.ExtClientRateLimitRule
+	clients, err := GetExtClientsByID(node.ID.String(), node.Network)
+	if err != nil {
+		return rules
+	}
+	for i := range clients {
+		if !clients[i].Enabled {
+			continue
+		}
+		limit := GetExtClientRateLimit(&clients[i])
+		if limit == (models.ExtClientRateLimit{}) {
+			continue
+		}
+		rules = append(rules, models.ExtClientRateLimitRule{
+			ClientID:           clients[i].ClientID,
+			Address:            net.ParseIP(clients[i].Address),
+			Address6:           net.ParseIP(clients[i].Address6),
+			ExtClientRateLimit: limit,
+		})
+	}
+	return rules
+}
@@ -0,0 +1,118 @@
+package logic
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/db"
+	"github.com/gravitl/netmaker/models"
+	"github.com/gravitl/netmaker/schema"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQuotaPeriod(t *testing.T) {
+	now := time.Date(2024, 2, 29, 18, 30, 0, 0, time.UTC)
+	assert.Equal(t, time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC), QuotaPeriodStart(models.QuotaPeriodDay, now))
+	assert.Equal(t, time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), QuotaPeriodEnd(models.QuotaPeriodDay, now))
+	assert.Equal(t, time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC), QuotaPeriodStart(models.QuotaPeriodMonth, now))
+	assert.Equal(t, time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), QuotaPeriodEnd(models.QuotaPeriodMonth, now))
+
+	assert.Nil(t, (&models.ExtClientQuota{Bytes: 1 << 30, Period: models.QuotaPeriodMonth}).Validate())
+	assert.Nil(t, (&models.ExtClientQuota{}).Validate())
+	assert.ErrorIs(t, (&models.ExtClientQuota{Bytes: 1 << 30}).Validate(), models.ErrInvalidExtClientQuota)
+	assert.ErrorIs(t, (&models.ExtClientQuota{Bytes: -1, Period: models.QuotaPeriodDay}).Validate(), models.ErrInvalidExtClientQuota)
+	assert.ErrorIs(t, (&models.ExtClientRateLimit{Upload: -1}).Validate(), models.ErrInvalidExtClientQuota)
+}
+
+func TestExtClientQuota(t *testing.T) {
+	db.InitializeDB(schema.ListModels()...)
+	defer db.CloseDB()
+	database.InitializeDatabase()
+	defer database.CloseDB()
+	ctx := db.WithContext(context.TODO())
+	cleanup := func() {
+		db.FromContext(ctx).Table((&schema.ExtClientUsage{}).Table()).Where("network = ?", "quotanet").Delete(&schema.ExtClientUsage{})
+	}
+	cleanup()
+	defer cleanup()
+	network := models.Network{NetID: "quotanet", AddressRange: "10.103.0.0/24"}
+	assert.Nil(t, SaveNetwork(&network))
+	defer func() {
+		_ = database.DeleteRecord(database.NETWORKS_TABLE_NAME, network.NetID)
+	}()
+	user := models.User{
+		UserName:           "quota-user",
+		ExtClientQuota:     models.ExtClientQuota{Bytes: 1000, Period: models.QuotaPeriodMonth},
+		ExtClientRateLimit: models.ExtClientRateLimit{Upload: 512, Download: 2048},
+	}
+	data, _ := json.Marshal(user)
+	assert.Nil(t, database.Insert(user.UserName, string(data), database.USERS_TABLE_NAME))
+	defer func() {
+		_ = database.DeleteRecord(database.USERS_TABLE_NAME, user.UserName)
+	}()
+	now := time.Date(2024, 5, 10, 12, 0, 0, 0, time.UTC)
+	client := models.ExtClient{
+		ClientID:  "quota-1",
+		Network:   "quotanet",
+		OwnerID:   user.UserName,
+		Enabled:   true,
+		Quota:     models.ExtClientQuota{Bytes: 300, Period: models.QuotaPeriodDay},
+		RateLimit: models.ExtClientRateLimit{Download: 1024},
+	}
+	assert.Nil(t, SaveExtClient(&client))
+	defer DeleteExtClient(client.Network, client.ClientID)
+
+	t.Run("RateLimit", func(t *testing.T) {
+		// the client's own limit wins, unset directions fall back to the owner
+		assert.Equal(t, models.ExtClientRateLimit{Upload: 512, Download: 1024}, GetExtClientRateLimit(&client))
+	})
+	t.Run("ClientQuota", func(t *testing.T) {
+		assert.Nil(t, RecordExtClientUsage(&client, models.Metric{}, models.Metric{TotalSent: 100, TotalReceived: 100}, now.AddDate(0, 0, -1)))
+		assert.Nil(t, RecordExtClientUsage(&client, models.Metric{}, models.Metric{TotalSent: 100, TotalReceived: 50}, now))
+		previous := models.Metric{LastTotalSent: 100, LastTotalReceived: 50}
+		// counters restart when the gateway re-adds the peer
+		assert.Nil(t, RecordExtClientUsage(&client, previous, models.Metric{TotalSent: 20, TotalReceived: 10}, now))
+		used, err := GetExtClientQuotaUsage(&client, client.Quota, now)
+		assert.Nil(t, err)
+		assert.Equal(t, int64(180), used)
+		exceeded, _, err := CheckExtClientQuota(&client, now)
+		assert.Nil(t, err)
+		assert.False(t, exceeded)
+
+		assert.Nil(t, RecordExtClientUsage(&client, models.Metric{LastTotalSent: 20, LastTotalReceived: 10}, models.Metric{TotalSent: 150, TotalReceived: 10}, now))
+		exceeded, until, err := CheckExtClientQuota(&client, now)
+		assert.Nil(t, err)
+		assert.True(t, exceeded)
+		assert.Equal(t, time.Date(2024, 5, 11, 0, 0, 0, 0, time.UTC), until)
+	})
+	t.Run("UserQuota", func(t *testing.T) {
+		other := models.ExtClient{ClientID: "quota-2", Network: "quotanet", OwnerID: user.UserName, Enabled: true}
+		exceeded, _, err := CheckExtClientQuota(&other, now)
+		assert.Nil(t, err)
+		assert.False(t, exceeded)
+		assert.Nil(t, RecordExtClientUsage(&other, models.Metric{}, models.Metric{TotalSent: 600}, now))
+		exceeded, until, err := CheckExtClientQuota(&other, now)
+		assert.Nil(t, err)
+		assert.True(t, exceeded)
+		assert.Equal(t, time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC), until)
+	})
+	t.Run("SuspendAndResume", func(t *testing.T) {
+		until := time.Now().Add(time.Hour)
+		suspended, err := SuspendExtClientForQuota(&client, until)
+		assert.Nil(t, err)
+		assert.False(t, suspended.Enabled)
+		resumed, err := ResumeQuotaSuspendedExtClients(time.Now())
+		assert.Nil(t, err)
+		assert.Empty(t, resumed)
+		resumed, err = ResumeQuotaSuspendedExtClients(until)
+		assert.Nil(t, err)
+		assert.Len(t, resumed, 1)
+		stored, err := GetExtClient(client.ClientID, client.Network)
+		assert.Nil(t, err)
+		assert.True(t, stored.Enabled)
+		assert.True(t, stored.QuotaSuspendedUntil.IsZero())
+	})
+}
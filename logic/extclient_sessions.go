@@ -0,0 +1,87 @@
+package logic
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gravitl/netmaker/db"
+	"github.com/gravitl/netmaker/models"
+	"github.com/gravitl/netmaker/schema"
+	"gorm.io/gorm"
+)
+
+// ExtClientSessionCheckInterval - how often sessions without recent gateway reports are closed
+const ExtClientSessionCheckInterval = 5 * time.Minute
+
+// GetExtClientSessionTimeout - how long a session stays open without a gateway report, a few missed metric intervals
+func GetExtClientSessionTimeout() time.Duration {
+	return 3 * GetMetricIntervalInMinutes()
+}
+
+// RecordExtClientSession - updates the session history of an ext client from a gateway metric,
+// a session starts with the first connected report and ends with the first disconnected or missing one
+func RecordExtClientSession(client *models.ExtClient, gatewayID string, metric models.Metric, now time.Time) error {
+	ctx := db.WithContext(context.TODO())
+	session := schema.ExtClientSession{ClientID: client.ClientID, Network: client.Network}
+	err := session.GetActive(ctx)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	active := err == nil
+	if active && (!metric.Connected || session.GatewayID != gatewayID ||
+		now.Sub(session.LastSeenAt) > GetExtClientSessionTimeout()) {
+		// the client disconnected, moved to another gateway or was not reported for a while
+		session.Active = false
+		if err := session.Update(ctx); err != nil {
+			return err
+		}
+		active = false
+	}
+	if !metric.Connected {
+		return nil
+	}
+	if !active {
+		session = schema.ExtClientSession{
+			ID:                uuid.New().String(),
+			ClientID:          client.ClientID,
+			Network:           client.Network,
+			OwnerID:           client.OwnerID,
+			GatewayID:         gatewayID,
+			StartedAt:         now,
+			LastTotalSent:     metric.TotalSent,
+			LastTotalReceived: metric.TotalReceived,
+			Active:            true,
+		}
+	}
+	session.LastSeenAt = now
+	session.BytesSent += counterDelta(session.LastTotalSent, metric.TotalSent)
+	session.BytesReceived += counterDelta(session.LastTotalReceived, metric.TotalReceived)
+	session.LastTotalSent = metric.TotalSent
+	session.LastTotalReceived = metric.TotalReceived
+	if client.PublicEndpoint != "" && client.PublicEndpoint != session.PublicEndpoint {
+		session.PublicEndpoint = client.PublicEndpoint
+		session.Endpoints = append(session.Endpoints, schema.ExtClientSessionEndpoint{
+			Endpoint: client.PublicEndpoint,
+			SeenAt:   now,
+		})
+	}
+	if !active {
+		return session.Create(ctx)
+	}
+	return session.Update(ctx)
+}
+
+// counterDelta - the traffic since the last reading of a gateway counter, which restarts when the peer is re-added
+func counterDelta(last, current int64) int64 {
+	if current < last {
+		return current
+	}
+	return current - last
+}
+
+// CloseStaleExtClientSessions - ends the sessions of ext clients whose gateway stopped reporting them
+func CloseStaleExtClientSessions(now time.Time) error {
+	return (&schema.ExtClientSession{}).CloseStale(db.WithContext(context.TODO()), now.Add(-GetExtClientSessionTimeout()))
+}
@@ -0,0 +1,93 @@
+package logic
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gravitl/netmaker/db"
+	"github.com/gravitl/netmaker/models"
+	"github.com/gravitl/netmaker/schema"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecordExtClientSession(t *testing.T) {
+	db.InitializeDB(schema.ListModels()...)
+	defer db.CloseDB()
+	ctx := db.WithContext(context.TODO())
+	cleanup := func() {
+		db.FromContext(ctx).Table((&schema.ExtClientSession{}).Table()).Where("network = ?", "sessionnet").Delete(&schema.ExtClientSession{})
+	}
+	cleanup()
+	defer cleanup()
+	start := time.Date(2024, 1, 1, 2, 0, 0, 0, time.UTC)
+	client := models.ExtClient{ClientID: "session-test", Network: "sessionnet", OwnerID: "auditee", PublicEndpoint: "198.51.100.1"}
+
+	err := RecordExtClientSession(&client, "gw1", models.Metric{Connected: true, TotalSent: 100, TotalReceived: 50}, start)
+	assert.Nil(t, err)
+	client.PublicEndpoint = "198.51.100.2"
+	err = RecordExtClientSession(&client, "gw1", models.Metric{Connected: true, TotalSent: 400, TotalReceived: 150}, start.Add(10*time.Minute))
+	assert.Nil(t, err)
+	// counters restart when the gateway re-adds the peer
+	err = RecordExtClientSession(&client, "gw1", models.Metric{Connected: true, TotalSent: 10, TotalReceived: 20}, start.Add(20*time.Minute))
+	assert.Nil(t, err)
+	err = RecordExtClientSession(&client, "gw1", models.Metric{}, start.Add(30*time.Minute))
+	assert.Nil(t, err)
+
+	sessions, err := (&schema.ExtClientSession{Network: "sessionnet", OwnerID: "auditee"}).List(ctx, time.Time{}, time.Time{})
+	assert.Nil(t, err)
+	assert.Len(t, sessions, 1)
+	session := sessions[0]
+	assert.False(t, session.Active)
+	assert.Equal(t, "gw1", session.GatewayID)
+	assert.True(t, start.Equal(session.StartedAt))
+	assert.True(t, start.Add(20*time.Minute).Equal(session.LastSeenAt))
+	assert.Equal(t, int64(310), session.BytesSent)
+	assert.Equal(t, int64(120), session.BytesReceived)
+	assert.Len(t, session.Endpoints, 2)
+	assert.Equal(t, "198.51.100.2", session.PublicEndpoint)
+
+	// moving to another gateway starts a new session
+	err = RecordExtClientSession(&client, "gw1", models.Metric{Connected: true}, start.Add(3*time.Hour))
+	assert.Nil(t, err)
+	err = RecordExtClientSession(&client, "gw2", models.Metric{Connected: true}, start.Add(3*time.Hour+10*time.Minute))
+	assert.Nil(t, err)
+	sessions, err = (&schema.ExtClientSession{ClientID: client.ClientID}).List(ctx, time.Time{}, time.Time{})
+	assert.Nil(t, err)
+	assert.Len(t, sessions, 3)
+
+	sessions, err = (&schema.ExtClientSession{Network: "sessionnet", GatewayID: "gw2"}).List(ctx, start, start.Add(5*time.Hour))
+	assert.Nil(t, err)
+	assert.Len(t, sessions, 1)
+	assert.True(t, sessions[0].Active)
+
+	// who was connected between 2:05 and 2:08
+	sessions, err = (&schema.ExtClientSession{Network: "sessionnet"}).List(ctx, start.Add(5*time.Minute), start.Add(8*time.Minute))
+	assert.Nil(t, err)
+	assert.Len(t, sessions, 1)
+	sessions, err = (&schema.ExtClientSession{Network: "sessionnet", GatewayID: "gw1"}).List(ctx, start.Add(150*time.Minute), start.Add(170*time.Minute))
+	assert.Nil(t, err)
+	assert.Len(t, sessions, 0)
+	// either end of the window alone
+	sessions, err = (&schema.ExtClientSession{Network: "sessionnet"}).List(ctx, start.Add(3*time.Hour), time.Time{})
+	assert.Nil(t, err)
+	assert.Len(t, sessions, 2)
+	sessions, err = (&schema.ExtClientSession{Network: "sessionnet"}).List(ctx, time.Time{}, start.Add(time.Hour))
+	assert.Nil(t, err)
+	assert.Len(t, sessions, 1)
+
+	// a session the gateway stopped reporting is not extended by a later report
+	err = RecordExtClientSession(&client, "gw2", models.Metric{Connected: true}, start.Add(5*time.Hour))
+	assert.Nil(t, err)
+	sessions, err = (&schema.ExtClientSession{Network: "sessionnet", GatewayID: "gw2"}).List(ctx, time.Time{}, time.Time{})
+	assert.Nil(t, err)
+	assert.Len(t, sessions, 2)
+	assert.True(t, sessions[0].Active)
+	assert.False(t, sessions[1].Active)
+
+	// and is closed once the reports are missing
+	assert.Nil(t, CloseStaleExtClientSessions(start.Add(7*time.Hour)))
+	sessions, err = (&schema.ExtClientSession{Network: "sessionnet", GatewayID: "gw2"}).List(ctx, time.Time{}, time.Time{})
+	assert.Nil(t, err)
+	assert.False(t, sessions[0].Active)
+}
@@ -136,6 +136,7 @@ func DeleteExtClient(network string, clientid string) error {
 	if err != nil {
 		return err
 	}
+	InvalidateDNSSnapshot()
 	if servercfg.CacheEnabled() {
 		// recycle ip address
 		if extClient.Address != "" {
@@ -194,6 +195,12 @@ func DeleteExtClientAndCleanup(extClient models.ExtClient) error {
 		slog.Error("DeleteExtClientAndCleanup-update network acls:", "Error", err.Error())
 		return err
 	}
+	if err = DeleteExtClientPresharedKeys(&extClient); err != nil {
+		slog.Error("DeleteExtClientAndCleanup-remove pre-shared keys:", "Error", err.Error())
+	}
+	if err = (&schema.ExtClientConfigLink{ClientID: extClient.ClientID, Network: extClient.Network}).DeleteByClient(db.WithContext(context.TODO())); err != nil {
+		slog.Error("DeleteExtClientAndCleanup-remove config links:", "Error", err.Error())
+	}
 
 	return nil
 }
@@ -350,6 +357,7 @@ func CreateExtClient(extclient *models.ExtClient) error {
 	}
 
 	extclient.LastModified = time.Now().Unix()
+	extclient.CreatedAt = time.Now()
 	return SaveExtClient(extclient)
 }
 
@@ -391,6 +399,7 @@ func SaveExtClient(extclient *models.ExtClient) error {
 	if err = database.Insert(key, string(data), database.EXT_CLIENT_TABLE_NAME); err != nil {
 		return err
 	}
+	InvalidateDNSSnapshot()
 	if servercfg.CacheEnabled() {
 		storeExtClientInCache(key, *extclient)
 		if _, ok := allocatedIpMap[extclient.Network]; ok {
@@ -412,6 +421,9 @@ func UpdateExtClient(old *models.ExtClient, update *models.CustomExtClient) mode
 	new.ClientID = update.ClientID
 	if update.PublicKey != "" && old.PublicKey != update.PublicKey {
 		new.PublicKey = update.PublicKey
+		// a replaced key ends any pending handover
+		new.PreviousPublicKey = ""
+		new.PreviousKeyExpiresAt = time.Time{}
 	}
 	if update.DNS != old.DNS {
 		new.DNS = update.DNS
@@ -427,6 +439,22 @@ func UpdateExtClient(old *models.ExtClient, update *models.CustomExtClient) mode
 	new.PostUp = strings.Replace(update.PostUp, "\r\n", "\n", -1)
 	new.PostDown = strings.Replace(update.PostDown, "\r\n", "\n", -1)
 	new.Tags = update.Tags
+	if old.Location.Country != "" || old.Location.ASN != 0 {
+		new.Tags = KeepGeoTags(update.Tags, old)
+	}
+	if update.Expiry != nil {
+		new.Expiry = *update.Expiry
+		new.Expiry.NotifiedAt = time.Time{}
+	}
+	if update.KeyRotation != nil {
+		new.KeyRotation = *update.KeyRotation
+	}
+	if update.Quota != nil {
+		new.Quota = *update.Quota
+	}
+	if update.RateLimit != nil {
+		new.RateLimit = *update.RateLimit
+	}
 	return new
 }
 
@@ -526,6 +554,7 @@ func GetExtPeers(node, peer *models.Node) ([]wgtypes.PeerConfig, []models.IDandA
 	if err != nil {
 		return peers, idsAndAddr, egressRoutes, err
 	}
+	usePresharedKeys := IsPresharedKeyEnabled(node.Network)
 	for _, extPeer := range extPeers {
 		extPeer := extPeer
 		if !IsClientNodeAllowed(&extPeer, peer.ID.String()) {
@@ -541,13 +570,15 @@ func GetExtPeers(node, peer *models.Node) ([]wgtypes.PeerConfig, []models.IDandA
 			}
 		}
 
-		pubkey, err := wgtypes.ParseKey(extPeer.PublicKey)
+		// during a key handover the addresses stay with the previous key until the new one is in use
+		routingKey := extClientRoutingKey(&extPeer)
+		pubkey, err := wgtypes.ParseKey(routingKey)
 		if err != nil {
 			logger.Log(1, "error parsing ext pub key:", err.Error())
 			continue
 		}
 
-		if host.PublicKey.String() == extPeer.PublicKey ||
+		if host.PublicKey.String() == routingKey ||
 			extPeer.IngressGatewayID != node.ID.String() || !extPeer.Enabled {
 			continue
 		}
@@ -579,7 +610,9 @@ func GetExtPeers(node, peer *models.Node) ([]wgtypes.PeerConfig, []models.IDandA
 				allowedips = append(allowedips, *cidr)
 			}
 		}
-		egressRoutes = append(egressRoutes, getExtPeerEgressRoute(*node, extPeer)...)
+		routingPeer := extPeer
+		routingPeer.PublicKey = routingKey
+		egressRoutes = append(egressRoutes, getExtPeerEgressRoute(*node, routingPeer)...)
 		primaryAddr := extPeer.Address
 		if primaryAddr == "" {
 			primaryAddr = extPeer.Address6
@@ -589,6 +622,12 @@ func GetExtPeers(node, peer *models.Node) ([]wgtypes.PeerConfig, []models.IDandA
 			ReplaceAllowedIPs: true,
 			AllowedIPs:        allowedips,
 		}
+		if usePresharedKeys {
+			peer.PresharedKey = GetExtClientPresharedKey(&extPeer, host)
+		} else {
+			// a zero key removes a pre-shared key set while the network used them
+			peer.PresharedKey = &wgtypes.Key{}
+		}
 		peers = append(peers, peer)
 		idsAndAddr = append(idsAndAddr, models.IDandAddr{
 			ID:          peer.PublicKey.String(),
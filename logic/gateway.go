@@ -7,6 +7,7 @@ import (
 	"sort"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/gravitl/netmaker/database"
 	"github.com/gravitl/netmaker/logger"
 	"github.com/gravitl/netmaker/models"
@@ -166,9 +167,41 @@ func DeleteEgressGateway(network, nodeid string) (models.Node, error) {
 	return node, nil
 }
 
+// CreateGateway - makes the node an ingress gateway and a relay of the requested nodes,
+// resets the failover of the relayed nodes and keeps the gateway host on a static port
+func CreateGateway(netid, nodeid string, req models.CreateGwReq) (models.Node, error) {
+	if _, err := CreateIngressGateway(netid, nodeid, req.IngressRequest); err != nil {
+		return models.Node{}, fmt.Errorf("failed to create gateway: %w", err)
+	}
+	req.RelayRequest.NetID = netid
+	req.RelayRequest.NodeID = nodeid
+	_, relayNode, err := CreateRelay(req.RelayRequest)
+	if err != nil {
+		return models.Node{}, fmt.Errorf("failed to create relay: %w", err)
+	}
+	for _, relayedNodeID := range relayNode.RelayedNodes {
+		relayedNode, err := GetNodeByID(relayedNodeID)
+		if err == nil && relayedNode.FailedOverBy != uuid.Nil {
+			go ResetFailedOverPeer(&relayedNode)
+		}
+	}
+	host, err := GetHost(relayNode.HostID.String())
+	if err != nil {
+		return relayNode, err
+	}
+	host.IsStaticPort = true
+	if err := UpsertHost(host); err != nil {
+		return relayNode, err
+	}
+	return relayNode, nil
+}
+
 // CreateIngressGateway - creates an ingress gateway
 func CreateIngressGateway(netid string, nodeid string, ingress models.IngressRequest) (models.Node, error) {
 
+	if err := ingress.SessionPolicy.Validate(); err != nil {
+		return models.Node{}, err
+	}
 	node, err := GetNodeByID(nodeid)
 	if err != nil {
 		return models.Node{}, err
@@ -205,6 +238,7 @@ func CreateIngressGateway(netid string, nodeid string, ingress models.IngressReq
 	if ingress.MTU != 0 {
 		node.IngressMTU = ingress.MTU
 	}
+	node.IngressSessionPolicy = ingress.SessionPolicy
 	if servercfg.IsPro {
 		if _, exists := FailOverExists(node.Network); exists {
 			ResetFailedOverPeer(&node)
@@ -0,0 +1,276 @@
+package logic
+
+import (
+	"net"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gravitl/netmaker/models"
+	"github.com/gravitl/netmaker/servercfg"
+	"github.com/oschwald/maxminddb-golang"
+	"golang.org/x/exp/slog"
+)
+
+// GeoLocationRefreshInterval - how long a location is trusted before the same endpoint is looked up again
+const GeoLocationRefreshInterval = 24 * time.Hour
+
+var (
+	geoIPReaders []*maxminddb.Reader
+	geoIPMutex   = &sync.RWMutex{}
+)
+
+// geoIPRecord - the fields read from MaxMind and DB-IP city, country and ASN databases
+type geoIPRecord struct {
+	Country struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"country"`
+	City struct {
+		Names map[string]string `maxminddb:"names"`
+	} `maxminddb:"city"`
+	ASN   uint   `maxminddb:"autonomous_system_number"`
+	ASOrg string `maxminddb:"autonomous_system_organization"`
+}
+
+// InitGeoIP - opens the GeoIP databases set in the server config,
+// several comma separated files are merged so a city and an ASN database can be combined
+func InitGeoIP() error {
+	geoIPMutex.Lock()
+	defer geoIPMutex.Unlock()
+	for _, reader := range geoIPReaders {
+		reader.Close()
+	}
+	geoIPReaders = nil
+	for _, path := range strings.Split(servercfg.GetGeoIPDBPath(), ",") {
+		if path = strings.TrimSpace(path); path == "" {
+			continue
+		}
+		reader, err := maxminddb.Open(path)
+		if err != nil {
+			return err
+		}
+		slog.Info("loaded GeoIP database", "path", path, "type", reader.Metadata.DatabaseType)
+		geoIPReaders = append(geoIPReaders, reader)
+	}
+	return nil
+}
+
+// IsGeoIPEnabled - checks if a GeoIP database is loaded
+func IsGeoIPEnabled() bool {
+	geoIPMutex.RLock()
+	defer geoIPMutex.RUnlock()
+	return len(geoIPReaders) > 0
+}
+
+// LookupGeoLocation - locates an ip in the GeoIP databases
+var LookupGeoLocation = func(ip net.IP) (models.GeoLocation, bool) {
+	geoIPMutex.RLock()
+	defer geoIPMutex.RUnlock()
+	location := models.GeoLocation{IP: ip.String()}
+	var found bool
+	for _, reader := range geoIPReaders {
+		var record geoIPRecord
+		if err := reader.Lookup(ip, &record); err != nil {
+			slog.Debug("GeoIP lookup failed", "ip", ip, "error", err)
+			continue
+		}
+		if record.Country.ISOCode != "" {
+			location.Country = record.Country.ISOCode
+			found = true
+		}
+		if city := record.City.Names["en"]; city != "" {
+			location.City = city
+		}
+		if record.ASN != 0 {
+			location.ASN = record.ASN
+			location.ASOrg = record.ASOrg
+			found = true
+		}
+	}
+	return location, found
+}
+
+// ParseEndpointIP - gets the ip of an endpoint given as an ip or ip:port
+func ParseEndpointIP(endpoint string) net.IP {
+	if host, _, err := net.SplitHostPort(endpoint); err == nil {
+		endpoint = host
+	}
+	return net.ParseIP(strings.Trim(endpoint, "[]"))
+}
+
+// IsGeoBlocked - checks if VPN access is refused from a location
+func IsGeoBlocked(location models.GeoLocation) bool {
+	if location.Country == "" {
+		return false
+	}
+	return slices.Contains(servercfg.GetGeoIPBlockedCountries(), strings.ToUpper(location.Country))
+}
+
+// IsGeoBlockedIP - checks if VPN access is refused from an ip
+func IsGeoBlockedIP(ip net.IP) bool {
+	if ip == nil || !IsGeoIPEnabled() {
+		return false
+	}
+	location, _ := LookupGeoLocation(ip)
+	return IsGeoBlocked(location)
+}
+
+// ParseGeoFilter - reads the country, city and asn list filters of a request
+func ParseGeoFilter(query url.Values) models.GeoFilter {
+	filter := models.GeoFilter{
+		Country: query.Get("country"),
+		City:    query.Get("city"),
+	}
+	if asn, err := strconv.ParseUint(query.Get("asn"), 10, 32); err == nil {
+		filter.ASN = uint(asn)
+	}
+	return filter
+}
+
+// locateEndpoint - looks up an endpoint unless the known location is recent,
+// returns true if the location changed other than in its lookup time
+func locateEndpoint(ip net.IP, current models.GeoLocation, now time.Time) (models.GeoLocation, bool) {
+	if ip == nil || !IsGeoIPEnabled() {
+		return current, false
+	}
+	if current.IP == ip.String() && now.Sub(current.UpdatedAt) < GeoLocationRefreshInterval {
+		return current, false
+	}
+	location, _ := LookupGeoLocation(ip)
+	location.UpdatedAt = now
+	return location, !location.SameAs(current)
+}
+
+// KeepGeoTags - carries the server assigned geo tags of an ext client over to its updated tags
+func KeepGeoTags(tags map[models.TagID]struct{}, client *models.ExtClient) map[models.TagID]struct{} {
+	if tags == nil {
+		tags = make(map[models.TagID]struct{})
+	}
+	setGeoTags(tags, client.Network, client.Location)
+	return tags
+}
+
+// KeepNodeGeoTags - carries the server assigned geo tags of a node's host over to the node's updated tags
+func KeepNodeGeoTags(tags map[models.TagID]struct{}, node *models.Node, host *models.Host) map[models.TagID]struct{} {
+	if tags == nil {
+		tags = make(map[models.TagID]struct{})
+	}
+	setGeoTags(tags, node.Network, host.Location)
+	return tags
+}
+
+// setGeoTags - replaces the server assigned geo tags of a device, returns true if the tags changed
+func setGeoTags(tags map[models.TagID]struct{}, network string, location models.GeoLocation) bool {
+	var changed bool
+	prefix := network + "."
+	geoTags := make(map[models.TagID]struct{})
+	for _, name := range location.GeoTagNames() {
+		geoTags[models.TagID(prefix+name)] = struct{}{}
+	}
+	for tagID := range tags {
+		if _, ok := geoTags[tagID]; ok {
+			continue
+		}
+		name := strings.TrimPrefix(tagID.String(), prefix)
+		if strings.HasPrefix(name, models.GeoCountryTagPrefix) || strings.HasPrefix(name, models.GeoASNTagPrefix) {
+			delete(tags, tagID)
+			changed = true
+		}
+	}
+	for tagID := range geoTags {
+		if _, ok := tags[tagID]; ok {
+			continue
+		}
+		tags[tagID] = struct{}{}
+		changed = true
+	}
+	return changed
+}
+
+// UpdateHostLocation - locates the public endpoint of a host and tags its nodes with the location,
+// returns true if the location or the tags changed
+func UpdateHostLocation(host *models.Host) bool {
+	if !IsGeoIPEnabled() {
+		return false
+	}
+	ip := host.EndpointIP
+	if ip == nil || ip.IsUnspecified() {
+		ip = host.EndpointIPv6
+	}
+	location, changed := locateEndpoint(ip, host.Location, time.Now())
+	if location != host.Location {
+		host.Location = location
+		if err := UpsertHost(host); err != nil {
+			slog.Error("failed to save host location", "host", host.ID, "error", err)
+			return false
+		}
+	}
+	// tags dropped by node updates or missing on nodes that just joined a network are restored on every check-in
+	for _, nodeID := range host.Nodes {
+		node, err := GetNodeByID(nodeID)
+		if err != nil {
+			continue
+		}
+		if node.Tags == nil {
+			node.Tags = make(map[models.TagID]struct{})
+		}
+		if setGeoTags(node.Tags, node.Network, location) {
+			changed = true
+			if err := UpsertNode(&node); err != nil {
+				slog.Error("failed to save node geo tags", "node", node.ID, "error", err)
+			}
+		}
+	}
+	return changed
+}
+
+// UpdateExtClientLocation - locates the public endpoint of an ext client and tags it with the location,
+// the client is saved when persist is set, returns true if the location or the tags changed
+func UpdateExtClientLocation(client *models.ExtClient, persist bool) bool {
+	location, changed := locateEndpoint(ParseEndpointIP(client.PublicEndpoint), client.Location, time.Now())
+	if location == client.Location {
+		return false
+	}
+	client.Location = location
+	if location.Country != "" {
+		client.Country = location.Country
+	}
+	if client.Tags == nil {
+		client.Tags = make(map[models.TagID]struct{})
+	}
+	if setGeoTags(client.Tags, client.Network, location) {
+		changed = true
+	}
+	if persist {
+		if err := SaveExtClient(client); err != nil {
+			slog.Error("failed to save ext client location", "client", client.ClientID, "error", err)
+			return false
+		}
+	}
+	return changed
+}
+
+// FilterExtClientsByLocation - keeps the ext clients located where the filter asks
+func FilterExtClientsByLocation(clients []models.ExtClient, filter models.GeoFilter) []models.ExtClient {
+	filtered := []models.ExtClient{}
+	for _, client := range clients {
+		if filter.Match(client.Location) {
+			filtered = append(filtered, client)
+		}
+	}
+	return filtered
+}
+
+// FilterHostsByLocation - keeps the hosts located where the filter asks
+func FilterHostsByLocation(hosts []models.Host, filter models.GeoFilter) []models.Host {
+	filtered := []models.Host{}
+	for _, host := range hosts {
+		if filter.Match(host.Location) {
+			filtered = append(filtered, host)
+		}
+	}
+	return filtered
+}
@@ -0,0 +1,143 @@
+package logic
+
+import (
+	"net"
+	"net/url"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/db"
+	"github.com/gravitl/netmaker/models"
+	"github.com/gravitl/netmaker/schema"
+	"github.com/oschwald/maxminddb-golang"
+	"github.com/stretchr/testify/assert"
+)
+
+// stubGeoIP - replaces the GeoIP databases with fixed locations for the test
+func stubGeoIP(t *testing.T, locations map[string]models.GeoLocation) {
+	readers, lookup := geoIPReaders, LookupGeoLocation
+	geoIPReaders = make([]*maxminddb.Reader, 1)
+	LookupGeoLocation = func(ip net.IP) (models.GeoLocation, bool) {
+		location, ok := locations[ip.String()]
+		location.IP = ip.String()
+		return location, ok
+	}
+	t.Cleanup(func() {
+		geoIPReaders, LookupGeoLocation = readers, lookup
+	})
+}
+
+func TestParseEndpointIP(t *testing.T) {
+	assert.Equal(t, "203.0.113.7", ParseEndpointIP("203.0.113.7").String())
+	assert.Equal(t, "203.0.113.7", ParseEndpointIP("203.0.113.7:51820").String())
+	assert.Equal(t, "2001:db8::1", ParseEndpointIP("[2001:db8::1]:51820").String())
+	assert.Nil(t, ParseEndpointIP(""))
+}
+
+func TestGeoFilter(t *testing.T) {
+	location := models.GeoLocation{Country: "DE", City: "Berlin", ASN: 3320}
+	filter := ParseGeoFilter(url.Values{"country": {"de"}, "asn": {"3320"}})
+	assert.True(t, filter.IsSet())
+	assert.True(t, filter.Match(location))
+	assert.False(t, models.GeoFilter{City: "Munich"}.Match(location))
+	assert.False(t, ParseGeoFilter(url.Values{}).IsSet())
+
+	clients := []models.ExtClient{{ClientID: "a", Location: location}, {ClientID: "b"}}
+	assert.Len(t, FilterExtClientsByLocation(clients, filter), 1)
+}
+
+func TestIsGeoBlocked(t *testing.T) {
+	t.Setenv("GEOIP_BLOCKED_COUNTRIES", "kp, IR")
+	assert.True(t, IsGeoBlocked(models.GeoLocation{Country: "KP"}))
+	assert.True(t, IsGeoBlocked(models.GeoLocation{Country: "ir"}))
+	assert.False(t, IsGeoBlocked(models.GeoLocation{Country: "DE"}))
+	assert.False(t, IsGeoBlocked(models.GeoLocation{}))
+}
+
+func TestUpdateExtClientLocation(t *testing.T) {
+	stubGeoIP(t, map[string]models.GeoLocation{
+		"203.0.113.7":  {Country: "DE", City: "Berlin", ASN: 3320, ASOrg: "Deutsche Telekom AG"},
+		"198.51.100.9": {Country: "FR", City: "Paris", ASN: 3215},
+	})
+	client := models.ExtClient{
+		ClientID:       "geo-test",
+		Network:        "geonet",
+		PublicEndpoint: "203.0.113.7",
+		Tags:           map[models.TagID]struct{}{"geonet.developers": {}},
+	}
+	assert.True(t, UpdateExtClientLocation(&client, false))
+	assert.Equal(t, "DE", client.Country)
+	assert.Equal(t, "Berlin", client.Location.City)
+	assert.Equal(t, map[models.TagID]struct{}{
+		"geonet.developers":     {},
+		"geonet.geo-country-de": {},
+		"geonet.geo-asn-3320":   {},
+	}, client.Tags)
+	// recent lookups are not repeated
+	assert.False(t, UpdateExtClientLocation(&client, false))
+
+	client.PublicEndpoint = "198.51.100.9:51820"
+	assert.True(t, UpdateExtClientLocation(&client, false))
+	assert.Equal(t, "FR", client.Country)
+	assert.Equal(t, map[models.TagID]struct{}{
+		"geonet.developers":     {},
+		"geonet.geo-country-fr": {},
+		"geonet.geo-asn-3215":   {},
+	}, client.Tags)
+
+	// geo tags survive updates replacing the tags
+	updated := UpdateExtClient(&client, &models.CustomExtClient{
+		ClientID: client.ClientID,
+		Tags:     map[models.TagID]struct{}{"geonet.ops": {}},
+	})
+	assert.Equal(t, map[models.TagID]struct{}{
+		"geonet.ops":            {},
+		"geonet.geo-country-fr": {},
+		"geonet.geo-asn-3215":   {},
+	}, updated.Tags)
+}
+
+func TestUpdateHostLocation(t *testing.T) {
+	db.InitializeDB(schema.ListModels()...)
+	defer db.CloseDB()
+	database.InitializeDatabase()
+	defer database.CloseDB()
+	stubGeoIP(t, map[string]models.GeoLocation{
+		"203.0.113.7": {Country: "DE", City: "Berlin", ASN: 3320},
+	})
+	host := models.Host{ID: uuid.New(), Name: "geo-host", EndpointIP: net.ParseIP("203.0.113.7")}
+	node := models.Node{CommonNode: models.CommonNode{ID: uuid.New(), HostID: host.ID, Network: "geonet"}}
+	assert.Nil(t, UpsertNode(&node))
+	host.Nodes = []string{node.ID.String()}
+	assert.Nil(t, CreateHost(&host))
+	defer func() {
+		_ = DeleteNodeByID(&node)
+		_ = RemoveHostByID(host.ID.String())
+	}()
+	geoTags := map[models.TagID]struct{}{
+		"geonet.geo-country-de": {},
+		"geonet.geo-asn-3320":   {},
+	}
+
+	assert.True(t, UpdateHostLocation(&host))
+	stored, err := GetNodeByID(node.ID.String())
+	assert.Nil(t, err)
+	assert.Equal(t, geoTags, stored.Tags)
+
+	// tags dropped while the location stays the same are restored on the next check-in
+	stored.Tags = map[models.TagID]struct{}{"geonet.ops": {}}
+	assert.Nil(t, UpsertNode(&stored))
+	assert.True(t, UpdateHostLocation(&host))
+	stored, err = GetNodeByID(node.ID.String())
+	assert.Nil(t, err)
+	assert.Len(t, stored.Tags, 3)
+	assert.False(t, UpdateHostLocation(&host))
+
+	// node updates replacing the tags keep the geo tags
+	assert.Equal(t, map[models.TagID]struct{}{
+		"geonet.web":            {},
+		"geonet.geo-country-de": {},
+		"geonet.geo-asn-3320":   {},
+	}, KeepNodeGeoTags(map[models.TagID]struct{}{"geonet.web": {}}, &stored, &host))
+}
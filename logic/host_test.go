@@ -5,9 +5,11 @@ import (
 	"fmt"
 	"github.com/gravitl/netmaker/db"
 	"github.com/gravitl/netmaker/schema"
+	"github.com/gravitl/netmaker/servercfg"
 	"net"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/gravitl/netmaker/database"
@@ -69,3 +71,87 @@ func TestCheckPorts(t *testing.T) {
 	})
 
 }
+
+func TestEphemeralHosts(t *testing.T) {
+	hosts := []models.Host{
+		{ID: uuid.New()},
+		{ID: uuid.New(), IsEphemeral: true},
+		{ID: uuid.New(), IsEphemeral: true},
+	}
+	t.Run("excluded from limits", func(t *testing.T) {
+		is := is.New(t)
+		is.Equal(CountPersistentHosts(hosts), 1)
+	})
+	t.Run("kept while joining", func(t *testing.T) {
+		is := is.New(t)
+		hosts[1].LastCheckIn = time.Now()
+		is.Equal(IsEphemeralHostExpired(&hosts[1], time.Minute), false)
+		hosts[1].LastCheckIn = time.Now().Add(-time.Hour)
+		is.Equal(IsEphemeralHostExpired(&hosts[1], time.Minute), true)
+	})
+	t.Run("profile does not make a host ephemeral", func(t *testing.T) {
+		is := is.New(t)
+		ApplyHostProvisioningProfile(&hosts[0], &models.HostProvisioningProfile{Ephemeral: true})
+		is.Equal(hosts[0].IsEphemeral, false)
+	})
+}
+
+func TestDeleteEphemeralHosts(t *testing.T) {
+	// deleted nodes are removed from egress routes in the background, so the db is left open for them
+	db.InitializeDB(schema.ListModels()...)
+
+	database.InitializeDatabase()
+	defer database.CloseDB()
+	network := models.Network{NetID: "ephemeralnet", AddressRange: "10.114.0.0/24"}
+	is := is.New(t)
+	is.NoErr(SaveNetwork(&network))
+	defer func() {
+		_ = database.DeleteRecord(database.NETWORKS_TABLE_NAME, network.NetID)
+	}()
+	offline := time.Now().Add(-time.Hour)
+	newHost := func(ephemeral bool, lastCheckIn time.Time) *models.Host {
+		h := &models.Host{ID: uuid.New(), Name: "ephemeral-test", IsEphemeral: ephemeral}
+		node := models.Node{
+			CommonNode:  models.CommonNode{ID: uuid.New(), HostID: h.ID, Network: network.NetID},
+			LastCheckIn: lastCheckIn,
+		}
+		is.NoErr(UpsertNode(&node))
+		h.Nodes = []string{node.ID.String()}
+		is.NoErr(CreateHost(h))
+		return h
+	}
+	expired := newHost(true, offline)
+	online := newHost(true, time.Now())
+	persistent := newHost(false, offline)
+	// never joined a network and stopped checking in
+	nodeless := &models.Host{ID: uuid.New(), Name: "ephemeral-test", IsEphemeral: true}
+	is.NoErr(CreateHost(nodeless))
+	nodeless.LastCheckIn = offline
+	is.NoErr(UpsertHost(nodeless))
+	defer func() {
+		for _, h := range []*models.Host{online, persistent} {
+			for _, node := range GetHostNodes(h) {
+				_ = DeleteNodeByID(&node)
+			}
+			_ = RemoveHostByID(h.ID.String())
+		}
+	}()
+	timeout := servercfg.GetEphemeralHostTimeout()
+	is.Equal(IsEphemeralHostExpired(expired, timeout), true)
+	is.Equal(IsEphemeralHostExpired(online, timeout), false)
+	is.Equal(IsEphemeralHostExpired(nodeless, timeout), true)
+
+	peerUpdate := make(chan *models.Node, 10)
+	deleteExpiredEphemeralHosts(peerUpdate)
+	is.Equal(len(peerUpdate), 1)
+	deleted := <-peerUpdate
+	is.Equal(deleted.HostID, expired.ID)
+	_, err := GetHost(expired.ID.String())
+	is.True(err != nil)
+	_, err = GetHost(nodeless.ID.String())
+	is.True(err != nil)
+	_, err = GetHost(online.ID.String())
+	is.NoErr(err)
+	_, err = GetHost(persistent.ID.String())
+	is.NoErr(err)
+}
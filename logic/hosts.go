@@ -1,12 +1,14 @@
 package logic
 
 import (
+	"context"
 	"crypto/md5"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"sort"
 	"sync"
+	"time"
 
 	"github.com/google/uuid"
 	"golang.org/x/crypto/bcrypt"
@@ -210,12 +212,15 @@ func GetHostByPubKey(hostPubKey string) (*models.Host, error) {
 
 // CreateHost - creates a host if not exist
 func CreateHost(h *models.Host) error {
-	hosts, hErr := GetAllHosts()
-	clients, cErr := GetAllExtClients()
-	if (hErr != nil && !database.IsEmptyRecord(hErr)) ||
-		(cErr != nil && !database.IsEmptyRecord(cErr)) ||
-		len(hosts)+len(clients) >= MachinesLimit {
-		return errors.New("free tier limits exceeded on machines")
+	// ephemeral hosts do not count towards the machines limit
+	if !h.IsEphemeral {
+		hosts, hErr := GetAllHosts()
+		clients, cErr := GetAllExtClients()
+		if (hErr != nil && !database.IsEmptyRecord(hErr)) ||
+			(cErr != nil && !database.IsEmptyRecord(cErr)) ||
+			CountPersistentHosts(hosts)+len(clients) >= MachinesLimit {
+			return errors.New("free tier limits exceeded on machines")
+		}
 	}
 	_, err := GetHost(h.ID.String())
 	if (err != nil && !database.IsEmptyRecord(err)) || (err == nil) {
@@ -229,6 +234,7 @@ func CreateHost(h *models.Host) error {
 	}
 	h.HostPass = string(hash)
 	h.AutoUpdate = AutoUpdateEnabled()
+	h.LastCheckIn = time.Now().UTC()
 
 	if GetServerSettings().ManageDNS {
 		h.DNS = "yes"
@@ -251,6 +257,7 @@ func UpdateHost(newHost, currentHost *models.Host) {
 	newHost.Nodes = currentHost.Nodes
 	newHost.PublicKey = currentHost.PublicKey
 	newHost.TrafficKeyPublic = currentHost.TrafficKeyPublic
+	newHost.LastCheckIn = currentHost.LastCheckIn
 	// changeable fields
 	if len(newHost.Version) == 0 {
 		newHost.Version = currentHost.Version
@@ -366,6 +373,9 @@ func RemoveHost(h *models.Host, forceDelete bool) error {
 	if servercfg.CacheEnabled() {
 		deleteHostFromCache(h.ID.String())
 	}
+	if err := DeletePresharedKeys(h.ID.String()); err != nil {
+		slog.Error("failed to remove pre-shared keys of host", "hostid", h.ID.String(), "error", err)
+	}
 	go func() {
 		if servercfg.IsDNSMode() {
 			SetDNS()
@@ -375,6 +385,87 @@ func RemoveHost(h *models.Host, forceDelete bool) error {
 	return nil
 }
 
+// CountPersistentHosts - counts the hosts that are not ephemeral
+func CountPersistentHosts(hosts []models.Host) (count int) {
+	for i := range hosts {
+		if !hosts[i].IsEphemeral {
+			count++
+		}
+	}
+	return
+}
+
+// DeleteEphemeralHosts - removes ephemeral hosts, along with their nodes, once all of
+// their nodes have been offline for longer than the ephemeral host timeout
+func DeleteEphemeralHosts(ctx context.Context, peerUpdate chan *models.Node) {
+	ticker := time.NewTicker(time.Minute)
+	for {
+		select {
+		case <-ctx.Done():
+			ticker.Stop()
+			return
+		case <-ticker.C:
+			deleteExpiredEphemeralHosts(peerUpdate)
+		}
+	}
+}
+
+// deleteExpiredEphemeralHosts - deletes the ephemeral hosts whose nodes all stopped checking in
+func deleteExpiredEphemeralHosts(peerUpdate chan *models.Node) {
+	hosts, err := GetAllHosts()
+	if err != nil {
+		slog.Error("failed to retrieve all hosts", "error", err.Error())
+		return
+	}
+	var deleted bool
+	for i := range hosts {
+		host := &hosts[i]
+		if !host.IsEphemeral || !IsEphemeralHostExpired(host, servercfg.GetEphemeralHostTimeout()) {
+			continue
+		}
+		slog.Info("deleting offline ephemeral host", "hostid", host.ID.String(), "name", host.Name)
+		for _, node := range GetHostNodes(host) {
+			node := node
+			if err := DeleteNode(&node, true); err != nil {
+				slog.Error("failed to delete ephemeral host node", "nodeid", node.ID.String(), "error", err.Error())
+				continue
+			}
+			node.PendingDelete = true
+			node.Action = models.NODE_DELETE
+			peerUpdate <- &node
+		}
+		host, err = GetHost(host.ID.String())
+		if err != nil {
+			continue
+		}
+		if err := RemoveHost(host, true); err != nil {
+			slog.Error("failed to delete ephemeral host", "hostid", host.ID.String(), "error", err.Error())
+			continue
+		}
+		deleted = true
+	}
+	if deleted && servercfg.IsDNSMode() {
+		if err := SetDNS(); err != nil {
+			slog.Error("failed to set dns after deleting ephemeral hosts", "error", err.Error())
+		}
+	}
+}
+
+// IsEphemeralHostExpired - checks if every node of a host has not checked in for longer than timeout,
+// a host without nodes expires when the host itself has not checked in for longer than timeout
+func IsEphemeralHostExpired(h *models.Host, timeout time.Duration) bool {
+	nodes := GetHostNodes(h)
+	if len(nodes) == 0 {
+		return time.Since(h.LastCheckIn) > timeout
+	}
+	for _, node := range nodes {
+		if time.Since(node.LastCheckIn) <= timeout {
+			return false
+		}
+	}
+	return true
+}
+
 // RemoveHostByID - removes a given host by id from server
 func RemoveHostByID(hostID string) error {
 
@@ -385,6 +476,9 @@ func RemoveHostByID(hostID string) error {
 	if servercfg.CacheEnabled() {
 		deleteHostFromCache(hostID)
 	}
+	if err := DeletePresharedKeys(hostID); err != nil {
+		slog.Error("failed to remove pre-shared keys of host", "hostid", hostID, "error", err)
+	}
 	return nil
 }
 
@@ -417,6 +511,24 @@ func UpdateHostNetwork(h *models.Host, network string, add bool) (*models.Node,
 	}
 }
 
+// RemoveHostFromNetwork - deletes the node of a host in a network and publishes the updates for it,
+// the node is nil when the host is not part of the network
+func RemoveHostFromNetwork(h *models.Host, network string, force bool) (*models.Node, error) {
+	node, err := UpdateHostNetwork(h, network, false)
+	if err != nil {
+		return nil, err
+	}
+	var gwClients []models.ExtClient
+	if node.IsIngressGateway {
+		gwClients = GetGwExtclients(node.ID.String(), node.Network)
+	}
+	if err := DeleteNode(node, force); err != nil {
+		return node, err
+	}
+	go PublishMqUpdatesForDeletedNode(*node, true, gwClients)
+	return node, nil
+}
+
 // AssociateNodeToHost - associates and creates a node with a given host
 // should be the only way nodes get created as of 0.18
 func AssociateNodeToHost(n *models.Node, h *models.Host) error {
@@ -105,6 +105,34 @@ func CreateUserJWT(username string, role models.UserRoleID) (response string, er
 	return "", err
 }
 
+// CreateExtClientConfigLinkToken - creates the signed token of an ext client config download link
+func CreateExtClientConfigLinkToken(linkID string, expiresAt time.Time) (string, error) {
+	claims := &jwt.RegisteredClaims{
+		Issuer:    "Netmaker",
+		Subject:   fmt.Sprintf("extclient_config|%s", linkID),
+		IssuedAt:  jwt.NewNumericDate(time.Now()),
+		ExpiresAt: jwt.NewNumericDate(expiresAt),
+		ID:        linkID,
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString(jwtSecretKey)
+}
+
+// VerifyExtClientConfigLinkToken - verifies the token of an ext client config download link, returns the link id
+func VerifyExtClientConfigLinkToken(tokenString string) (string, error) {
+	claims := &jwt.RegisteredClaims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		return jwtSecretKey, nil
+	})
+	if err != nil {
+		return "", err
+	}
+	if !token.Valid || claims.ID == "" || claims.Subject != fmt.Sprintf("extclient_config|%s", claims.ID) {
+		return "", errors.New("invalid config link")
+	}
+	return claims.ID, nil
+}
+
 func GetUserNameFromToken(authtoken string) (username string, err error) {
 	claims := &models.UserClaims{}
 	var tokenSplit = strings.Split(authtoken, " ")
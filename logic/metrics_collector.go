@@ -0,0 +1,146 @@
+package logic
+
+import (
+	"strconv"
+
+	"github.com/gravitl/netmaker/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/exp/slog"
+)
+
+var (
+	peerLabels = []string{"network", "node_id", "node_name", "peer_id", "peer_name"}
+
+	peerLatencyDesc = prometheus.NewDesc(prometheus.BuildFQName(metrics.Namespace, "peer", "latency_milliseconds"),
+		"Latency to the peer measured by the node.", peerLabels, nil)
+	peerReceivedDesc = prometheus.NewDesc(prometheus.BuildFQName(metrics.Namespace, "peer", "received_bytes_total"),
+		"Bytes the node received from the peer.", peerLabels, nil)
+	peerSentDesc = prometheus.NewDesc(prometheus.BuildFQName(metrics.Namespace, "peer", "sent_bytes_total"),
+		"Bytes the node sent to the peer.", peerLabels, nil)
+	peerUptimeDesc = prometheus.NewDesc(prometheus.BuildFQName(metrics.Namespace, "peer", "uptime_seconds"),
+		"Time the peer was reachable from the node.", peerLabels, nil)
+	peerUptimeRatioDesc = prometheus.NewDesc(prometheus.BuildFQName(metrics.Namespace, "peer", "uptime_ratio"),
+		"Share of checks the peer was reachable from the node.", peerLabels, nil)
+	peerConnectedDesc = prometheus.NewDesc(prometheus.BuildFQName(metrics.Namespace, "peer", "connected"),
+		"Whether the peer is reachable from the node.", peerLabels, nil)
+	nodeStatusDesc = prometheus.NewDesc(prometheus.BuildFQName(metrics.Namespace, "node", "status"),
+		"Status of a node, the series of its current status is 1.", []string{"network", "node_id", "node_name", "status"}, nil)
+	hostsDesc = prometheus.NewDesc(prometheus.BuildFQName(metrics.Namespace, "", "hosts"),
+		"Hosts by netclient version and operating system.", []string{"version", "os"}, nil)
+	extClientsDesc = prometheus.NewDesc(prometheus.BuildFQName(metrics.Namespace, "", "ext_clients"),
+		"Ext clients by network and whether they are enabled.", []string{"network", "enabled"}, nil)
+	cacheEntriesDesc = prometheus.NewDesc(prometheus.BuildFQName(metrics.Namespace, "", "cache_entries"),
+		"Entries held by the server's in-memory caches.", []string{"cache"}, nil)
+)
+
+// metricsCollector - reports the networks' nodes, hosts, ext clients and peer metrics when /metrics is scraped
+type metricsCollector struct{}
+
+func init() {
+	metrics.Registry.MustRegister(metricsCollector{})
+}
+
+// Describe - implements prometheus.Collector
+func (metricsCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, desc := range []*prometheus.Desc{
+		peerLatencyDesc, peerReceivedDesc, peerSentDesc, peerUptimeDesc, peerUptimeRatioDesc, peerConnectedDesc,
+		nodeStatusDesc, hostsDesc, extClientsDesc, cacheEntriesDesc,
+	} {
+		ch <- desc
+	}
+}
+
+// Collect - implements prometheus.Collector
+func (metricsCollector) Collect(ch chan<- prometheus.Metric) {
+	collectNodeMetrics(ch)
+	collectHostMetrics(ch)
+	collectExtClientMetrics(ch)
+	collectCacheMetrics(ch)
+}
+
+func collectNodeMetrics(ch chan<- prometheus.Metric) {
+	nodes, err := GetAllNodes()
+	if err != nil {
+		slog.Error("failed to get nodes for metrics", "error", err)
+		return
+	}
+	for _, node := range AddStatusToNodes(nodes, true) {
+		nodeID, nodeName := node.ID.String(), node.ID.String()
+		if host, err := GetHost(node.HostID.String()); err == nil {
+			nodeName = host.Name
+		}
+		ch <- prometheus.MustNewConstMetric(nodeStatusDesc, prometheus.GaugeValue, 1, node.Network, nodeID, nodeName, string(node.Status))
+		nodeMetrics, err := GetMetrics(nodeID)
+		if err != nil || nodeMetrics == nil {
+			continue
+		}
+		for peerID, metric := range nodeMetrics.Connectivity {
+			labels := []string{node.Network, nodeID, nodeName, peerID, metric.NodeName}
+			ch <- prometheus.MustNewConstMetric(peerLatencyDesc, prometheus.GaugeValue, float64(metric.Latency), labels...)
+			ch <- prometheus.MustNewConstMetric(peerReceivedDesc, prometheus.CounterValue, float64(metric.TotalReceived), labels...)
+			ch <- prometheus.MustNewConstMetric(peerSentDesc, prometheus.CounterValue, float64(metric.TotalSent), labels...)
+			ch <- prometheus.MustNewConstMetric(peerUptimeDesc, prometheus.GaugeValue, metric.ActualUptime.Seconds(), labels...)
+			ch <- prometheus.MustNewConstMetric(peerUptimeRatioDesc, prometheus.GaugeValue, metric.PercentUp/100, labels...)
+			connected := 0.0
+			if metric.Connected {
+				connected = 1
+			}
+			ch <- prometheus.MustNewConstMetric(peerConnectedDesc, prometheus.GaugeValue, connected, labels...)
+		}
+	}
+}
+
+func collectHostMetrics(ch chan<- prometheus.Metric) {
+	hosts, err := GetAllHosts()
+	if err != nil {
+		slog.Error("failed to get hosts for metrics", "error", err)
+		return
+	}
+	counts := make(map[[2]string]int)
+	for _, host := range hosts {
+		counts[[2]string{host.Version, host.OS}]++
+	}
+	for key, count := range counts {
+		ch <- prometheus.MustNewConstMetric(hostsDesc, prometheus.GaugeValue, float64(count), key[0], key[1])
+	}
+}
+
+func collectExtClientMetrics(ch chan<- prometheus.Metric) {
+	extclients, err := GetAllExtClients()
+	if err != nil {
+		slog.Error("failed to get ext clients for metrics", "error", err)
+		return
+	}
+	counts := make(map[[2]string]int)
+	for _, extclient := range extclients {
+		counts[[2]string{extclient.Network, strconv.FormatBool(extclient.Enabled)}]++
+	}
+	for key, count := range counts {
+		ch <- prometheus.MustNewConstMetric(extClientsDesc, prometheus.GaugeValue, float64(count), key[0], key[1])
+	}
+}
+
+func collectCacheMetrics(ch chan<- prometheus.Metric) {
+	sizes := map[string]int{}
+	nodeCacheMutex.RLock()
+	sizes["nodes"] = len(nodesCacheMap)
+	nodeCacheMutex.RUnlock()
+	hostCacheMutex.RLock()
+	sizes["hosts"] = len(hostsCacheMap)
+	hostCacheMutex.RUnlock()
+	extClientCacheMutex.RLock()
+	sizes["ext_clients"] = len(extClientCacheMap)
+	extClientCacheMutex.RUnlock()
+	networkCacheMutex.RLock()
+	sizes["networks"] = len(networkCacheMap)
+	networkCacheMutex.RUnlock()
+	aclCacheMutex.RLock()
+	sizes["acls"] = len(aclCacheMap)
+	aclCacheMutex.RUnlock()
+	enrollmentkeyCacheMutex.RLock()
+	sizes["enrollment_keys"] = len(enrollmentkeyCacheMap)
+	enrollmentkeyCacheMutex.RUnlock()
+	for cache, size := range sizes {
+		ch <- prometheus.MustNewConstMetric(cacheEntriesDesc, prometheus.GaugeValue, float64(size), cache)
+	}
+}
@@ -0,0 +1,45 @@
+package logic
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/db"
+	"github.com/gravitl/netmaker/metrics"
+	"github.com/gravitl/netmaker/models"
+	"github.com/gravitl/netmaker/schema"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetricsCollector(t *testing.T) {
+	db.InitializeDB(schema.ListModels()...)
+	defer db.CloseDB()
+
+	database.InitializeDatabase()
+	defer database.CloseDB()
+	network := models.Network{NetID: "promnet", AddressRange: "10.112.0.0/24"}
+	assert.Nil(t, SaveNetwork(&network))
+	defer func() {
+		_ = database.DeleteRecord(database.NETWORKS_TABLE_NAME, network.NetID)
+	}()
+	for _, client := range []models.ExtClient{
+		{ClientID: "phone", Network: "promnet", Address: "10.112.0.10", Enabled: true},
+		{ClientID: "tablet", Network: "promnet", Address: "10.112.0.11", Enabled: true},
+		{ClientID: "old", Network: "promnet", Address: "10.112.0.12"},
+	} {
+		assert.Nil(t, SaveExtClient(&client))
+		defer DeleteExtClient(client.Network, client.ClientID)
+	}
+
+	resp := httptest.NewRecorder()
+	metrics.Handler().ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Equal(t, http.StatusOK, resp.Code)
+	body := resp.Body.String()
+	assert.Contains(t, body, `netmaker_ext_clients{enabled="true",network="promnet"} 2`)
+	assert.Contains(t, body, `netmaker_ext_clients{enabled="false",network="promnet"} 1`)
+	assert.Contains(t, body, `netmaker_cache_entries{cache="ext_clients"}`)
+	assert.Contains(t, body, `netmaker_db_operation_duration_seconds_count{operation="insert",table="extclients"}`)
+	assert.Contains(t, body, "netmaker_peer_update_duration_seconds_bucket")
+}
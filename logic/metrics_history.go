@@ -0,0 +1,305 @@
+package logic
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gravitl/netmaker/db"
+	"github.com/gravitl/netmaker/models"
+	"github.com/gravitl/netmaker/schema"
+	"gorm.io/gorm"
+)
+
+// MetricsHistoryDownsampleInterval - how often raw samples are rolled up and expired samples are deleted
+const MetricsHistoryDownsampleInterval = 5 * time.Minute
+
+// ErrInvalidMetricsHistoryQuery - the metrics history query does not select a series
+var ErrInvalidMetricsHistoryQuery = errors.New("invalid metrics history query")
+
+// metricsHistoryBaseline - the peer metrics of a node at its last raw sample, the next sample's traffic is counted from them
+type metricsHistoryBaseline struct {
+	at    time.Time
+	peers map[string]models.Metric
+}
+
+var (
+	metricsHistoryMutex     = &sync.Mutex{}
+	metricsHistoryBaselines = make(map[string]metricsHistoryBaseline)
+)
+
+// metricsSampleKey - a peer of a node at a time bucket
+type metricsSampleKey struct {
+	network, nodeID, peerID string
+	timestamp               time.Time
+}
+
+// RecordMetricsHistory - stores a raw sample for each peer of the node's updated metrics,
+// at most one per node within the configured resolution
+func RecordMetricsHistory(nodeID string, metrics *models.Metrics, now time.Time) error {
+	if metrics == nil || len(metrics.Connectivity) == 0 {
+		return nil
+	}
+	node, err := GetNodeByID(nodeID)
+	if err != nil {
+		return err
+	}
+	now = now.UTC()
+	metricsHistoryMutex.Lock()
+	baseline, ok := metricsHistoryBaselines[nodeID]
+	if ok && now.Sub(baseline.at) < GetMetricsHistoryResolution() {
+		metricsHistoryMutex.Unlock()
+		return nil
+	}
+	peers := make(map[string]models.Metric, len(metrics.Connectivity))
+	for peerID, metric := range metrics.Connectivity {
+		peers[peerID] = metric
+	}
+	metricsHistoryBaselines[nodeID] = metricsHistoryBaseline{at: now, peers: peers}
+	metricsHistoryMutex.Unlock()
+
+	samples := make([]schema.MetricsSample, 0, len(peers))
+	for peerID, metric := range peers {
+		sample := schema.MetricsSample{
+			ID:         uuid.New().String(),
+			Resolution: schema.MetricsResolutionRaw,
+			Network:    node.Network,
+			NodeID:     nodeID,
+			PeerID:     peerID,
+			PeerName:   metric.NodeName,
+			Timestamp:  now,
+			Samples:    1,
+		}
+		if metric.Connected {
+			sample.Connected = 1
+			sample.Latency = float64(metric.Latency)
+		}
+		// the traffic is only known from the second sample on, the first one covers no time
+		if previous, found := baseline.peers[peerID]; ok && found {
+			sample.Seconds = now.Sub(baseline.at).Seconds()
+			sample.BytesReceived = metricsCounterDelta(previous.TotalReceived, metric.TotalReceived)
+			sample.BytesSent = metricsCounterDelta(previous.TotalSent, metric.TotalSent)
+		}
+		samples = append(samples, sample)
+	}
+	return (&schema.MetricsSample{}).CreateBatch(db.WithContext(context.TODO()), samples)
+}
+
+// DeleteMetricsHistoryBaseline - forgets the last raw sample of a deleted node
+func DeleteMetricsHistoryBaseline(nodeID string) {
+	metricsHistoryMutex.Lock()
+	delete(metricsHistoryBaselines, nodeID)
+	metricsHistoryMutex.Unlock()
+}
+
+// metricsCounterDelta - the bytes transferred between two readings of a counter, a reset counter starts over from zero
+func metricsCounterDelta(previous, current int64) int64 {
+	if current < previous {
+		return current
+	}
+	return current - previous
+}
+
+// DownsampleMetricsHistory - rolls the complete raw buckets up into 5m samples and the 5m samples into 1h samples,
+// then deletes the samples of each resolution past their retention
+func DownsampleMetricsHistory(now time.Time) error {
+	ctx := db.WithContext(context.TODO())
+	now = now.UTC()
+	if err := rollUpMetricsSamples(ctx, schema.MetricsResolutionRaw, schema.MetricsResolution5m, 5*time.Minute, now); err != nil {
+		return err
+	}
+	if err := rollUpMetricsSamples(ctx, schema.MetricsResolution5m, schema.MetricsResolution1h, time.Hour, now); err != nil {
+		return err
+	}
+	raw, fiveMinutes, hourly := GetMetricsHistoryRetention()
+	for resolution, retention := range map[string]time.Duration{
+		schema.MetricsResolutionRaw: raw,
+		schema.MetricsResolution5m:  fiveMinutes,
+		schema.MetricsResolution1h:  hourly,
+	} {
+		if err := (&schema.MetricsSample{Resolution: resolution}).DeleteOlderThan(ctx, now.Add(-retention)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rollUpMetricsSamples - merges the samples of the from resolution into buckets of the to resolution,
+// starting after the last bucket rolled up and ending before the bucket in progress
+func rollUpMetricsSamples(ctx context.Context, from, to string, bucket time.Duration, now time.Time) error {
+	var start time.Time
+	latest := schema.MetricsSample{Resolution: to}
+	if err := latest.Latest(ctx); err == nil {
+		start = latest.Timestamp.Add(bucket)
+	} else if errors.Is(err, gorm.ErrRecordNotFound) {
+		earliest := schema.MetricsSample{Resolution: from}
+		if err := earliest.Earliest(ctx); err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil
+			}
+			return err
+		}
+		start = earliest.Timestamp.Truncate(bucket)
+	} else {
+		return err
+	}
+	end := now.Truncate(bucket)
+	if !start.Before(end) {
+		return nil
+	}
+	samples, err := (&schema.MetricsSample{Resolution: from}).List(ctx, start, end)
+	if err != nil {
+		return err
+	}
+	rolledUp := []schema.MetricsSample{}
+	for _, sample := range mergeMetricsSamples(samples, bucket) {
+		sample.ID = uuid.New().String()
+		sample.Resolution = to
+		rolledUp = append(rolledUp, sample)
+	}
+	return (&schema.MetricsSample{}).CreateBatch(ctx, rolledUp)
+}
+
+// mergeMetricsSamples - merges the samples of each node and peer into buckets of the given size
+func mergeMetricsSamples(samples []schema.MetricsSample, bucket time.Duration) []schema.MetricsSample {
+	merged := make(map[metricsSampleKey]*schema.MetricsSample)
+	keys := []metricsSampleKey{}
+	for _, sample := range samples {
+		key := metricsSampleKey{
+			network:   sample.Network,
+			nodeID:    sample.NodeID,
+			peerID:    sample.PeerID,
+			timestamp: sample.Timestamp.Truncate(bucket),
+		}
+		current, ok := merged[key]
+		if !ok {
+			current = &schema.MetricsSample{
+				Network:   sample.Network,
+				NodeID:    sample.NodeID,
+				PeerID:    sample.PeerID,
+				Timestamp: key.timestamp,
+			}
+			merged[key] = current
+			keys = append(keys, key)
+		}
+		mergeMetricsSample(current, sample)
+	}
+	result := make([]schema.MetricsSample, 0, len(keys))
+	for _, key := range keys {
+		result = append(result, *merged[key])
+	}
+	return result
+}
+
+// mergeMetricsSample - adds a sample to a bucket, the latency stays the average of the connected samples
+func mergeMetricsSample(bucket *schema.MetricsSample, sample schema.MetricsSample) {
+	if sample.Connected > 0 {
+		bucket.Latency = (bucket.Latency*float64(bucket.Connected) + sample.Latency*float64(sample.Connected)) /
+			float64(bucket.Connected+sample.Connected)
+	}
+	bucket.Connected += sample.Connected
+	bucket.Samples += sample.Samples
+	bucket.Seconds += sample.Seconds
+	bucket.BytesReceived += sample.BytesReceived
+	bucket.BytesSent += sample.BytesSent
+	if sample.PeerName != "" {
+		bucket.PeerName = sample.PeerName
+	}
+}
+
+// GetMetricsHistory - gets the latency, throughput and uptime series over the requested time range of
+// a node pair (node and peer), a node's peers, a gateway's ext clients (node and gateway) or a network's nodes,
+// the resolution is the finest one still kept for the start of the range unless requested
+func GetMetricsHistory(req models.MetricsHistory) (models.MetricsHistory, error) {
+	now := time.Now().UTC()
+	if req.To.IsZero() {
+		req.To = now
+	}
+	if req.From.IsZero() {
+		req.From = req.To.Add(-24 * time.Hour)
+	}
+	req.From, req.To = req.From.UTC(), req.To.UTC()
+	if req.Network == "" || !req.From.Before(req.To) ||
+		(req.PeerID != "" && req.NodeID == "") || (req.Gateway && (req.NodeID == "" || req.PeerID != "")) {
+		return req, ErrInvalidMetricsHistoryQuery
+	}
+	raw, fiveMinutes, _ := GetMetricsHistoryRetention()
+	if req.Resolution == "" {
+		switch {
+		case !req.From.Before(now.Add(-raw)):
+			req.Resolution = schema.MetricsResolutionRaw
+		case !req.From.Before(now.Add(-fiveMinutes)):
+			req.Resolution = schema.MetricsResolution5m
+		default:
+			req.Resolution = schema.MetricsResolution1h
+		}
+	}
+	var bucket time.Duration
+	switch req.Resolution {
+	case schema.MetricsResolutionRaw:
+		bucket = GetMetricsHistoryResolution()
+	case schema.MetricsResolution5m:
+		bucket = 5 * time.Minute
+	case schema.MetricsResolution1h:
+		bucket = time.Hour
+	default:
+		return req, ErrInvalidMetricsHistoryQuery
+	}
+	req.Points = []models.MetricsHistoryPoint{}
+
+	var peers []string
+	if req.Gateway {
+		clients, err := GetExtClientsByID(req.NodeID, req.Network)
+		if err != nil {
+			return req, err
+		}
+		if len(clients) == 0 {
+			return req, nil
+		}
+		for _, client := range clients {
+			peers = append(peers, client.ClientID)
+		}
+	}
+	filter := schema.MetricsSample{
+		Resolution: req.Resolution,
+		Network:    req.Network,
+		NodeID:     req.NodeID,
+		PeerID:     req.PeerID,
+	}
+	samples, err := filter.List(db.WithContext(context.TODO()), req.From, req.To, peers...)
+	if err != nil {
+		return req, err
+	}
+
+	// the throughput of a bucket adds up the rates of its peers, latency and uptime merge over them
+	points := make(map[time.Time]*models.MetricsHistoryPoint)
+	totals := make(map[time.Time]*schema.MetricsSample)
+	for _, sample := range mergeMetricsSamples(samples, bucket) {
+		point, ok := points[sample.Timestamp]
+		if !ok {
+			point = &models.MetricsHistoryPoint{Timestamp: sample.Timestamp}
+			points[sample.Timestamp] = point
+			totals[sample.Timestamp] = &schema.MetricsSample{}
+		}
+		if sample.Seconds > 0 {
+			point.ReceivedRate += float64(sample.BytesReceived) / sample.Seconds
+			point.SentRate += float64(sample.BytesSent) / sample.Seconds
+		}
+		mergeMetricsSample(totals[sample.Timestamp], sample)
+	}
+	for timestamp, point := range points {
+		total := totals[timestamp]
+		point.Latency = total.Latency
+		if total.Samples > 0 {
+			point.Uptime = float64(total.Connected) / float64(total.Samples)
+		}
+		req.Points = append(req.Points, *point)
+	}
+	sort.Slice(req.Points, func(i, j int) bool {
+		return req.Points[i].Timestamp.Before(req.Points[j].Timestamp)
+	})
+	return req, nil
+}
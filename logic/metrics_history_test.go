@@ -0,0 +1,118 @@
+package logic
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/db"
+	"github.com/gravitl/netmaker/models"
+	"github.com/gravitl/netmaker/schema"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetricsHistory(t *testing.T) {
+	db.InitializeDB(schema.ListModels()...)
+	defer db.CloseDB()
+
+	database.InitializeDatabase()
+	defer database.CloseDB()
+	ctx := db.WithContext(context.TODO())
+	network := models.Network{NetID: "historynet", AddressRange: "10.113.0.0/24"}
+	assert.Nil(t, SaveNetwork(&network))
+	defer func() {
+		_ = database.DeleteRecord(database.NETWORKS_TABLE_NAME, network.NetID)
+	}()
+	node := models.Node{CommonNode: models.CommonNode{ID: uuid.New(), Network: "historynet"}}
+	assert.Nil(t, UpsertNode(&node))
+	nodeID := node.ID.String()
+	defer func() {
+		_ = database.DeleteRecord(database.NODES_TABLE_NAME, nodeID)
+		DeleteMetricsHistoryBaseline(nodeID)
+		for _, resolution := range []string{schema.MetricsResolutionRaw, schema.MetricsResolution5m, schema.MetricsResolution1h} {
+			_ = (&schema.MetricsSample{Resolution: resolution}).DeleteOlderThan(ctx, time.Now().Add(time.Hour*24*365))
+		}
+	}()
+
+	report := func(at time.Time, received, sent, latency int64, connected bool) {
+		assert.Nil(t, RecordMetricsHistory(nodeID, &models.Metrics{Connectivity: map[string]models.Metric{
+			"peer": {NodeName: "peer", TotalReceived: received, TotalSent: sent, Latency: latency, Connected: connected},
+		}}, at))
+	}
+	start := time.Now().UTC().Truncate(time.Hour).Add(-2 * time.Hour)
+	report(start, 1000, 500, 10, true)
+	// within the resolution of the last sample
+	report(start.Add(30*time.Second), 5000, 5000, 99, true)
+	report(start.Add(2*time.Minute), 13000, 6500, 30, true)
+	report(start.Add(4*time.Minute), 13000, 6500, 0, false)
+	report(start.Add(6*time.Minute), 25000, 6500, 20, true)
+
+	t.Run("Raw", func(t *testing.T) {
+		history, err := GetMetricsHistory(models.MetricsHistory{
+			Network:    "historynet",
+			NodeID:     nodeID,
+			PeerID:     "peer",
+			From:       start,
+			To:         start.Add(10 * time.Minute),
+			Resolution: schema.MetricsResolutionRaw,
+		})
+		assert.Nil(t, err)
+		assert.Len(t, history.Points, 4)
+		assert.Equal(t, models.MetricsHistoryPoint{Timestamp: start.Add(2 * time.Minute), Latency: 30, ReceivedRate: 100, SentRate: 50, Uptime: 1}, history.Points[1])
+		assert.Equal(t, 0.0, history.Points[2].Uptime)
+	})
+	t.Run("Downsampled", func(t *testing.T) {
+		assert.Nil(t, DownsampleMetricsHistory(time.Now()))
+		history, err := GetMetricsHistory(models.MetricsHistory{
+			Network:    "historynet",
+			From:       start,
+			To:         start.Add(time.Hour),
+			Resolution: schema.MetricsResolution5m,
+		})
+		assert.Nil(t, err)
+		assert.Len(t, history.Points, 2)
+		assert.Equal(t, models.MetricsHistoryPoint{Timestamp: start, Latency: 20, ReceivedRate: 50, SentRate: 25, Uptime: 2.0 / 3}, history.Points[0])
+		assert.Equal(t, 100.0, history.Points[1].ReceivedRate)
+
+		history, err = GetMetricsHistory(models.MetricsHistory{
+			Network:    "historynet",
+			NodeID:     nodeID,
+			From:       start,
+			To:         start.Add(time.Hour),
+			Resolution: schema.MetricsResolution1h,
+		})
+		assert.Nil(t, err)
+		assert.Len(t, history.Points, 1)
+		assert.Equal(t, 0.75, history.Points[0].Uptime)
+		assert.InDelta(t, 24000.0/360, history.Points[0].ReceivedRate, 0.001)
+
+		// rolling up again does not count the samples twice
+		assert.Nil(t, DownsampleMetricsHistory(time.Now()))
+		samples, err := (&schema.MetricsSample{Resolution: schema.MetricsResolution1h}).List(ctx, start, start.Add(time.Hour))
+		assert.Nil(t, err)
+		assert.Len(t, samples, 1)
+	})
+	t.Run("Retention", func(t *testing.T) {
+		assert.Nil(t, DownsampleMetricsHistory(time.Now().Add(48*time.Hour)))
+		samples, err := (&schema.MetricsSample{Resolution: schema.MetricsResolutionRaw}).List(ctx, start, start.Add(time.Hour))
+		assert.Nil(t, err)
+		assert.Empty(t, samples)
+		samples, err = (&schema.MetricsSample{Resolution: schema.MetricsResolution5m}).List(ctx, start, start.Add(time.Hour))
+		assert.Nil(t, err)
+		assert.Len(t, samples, 2)
+	})
+	t.Run("Invalid", func(t *testing.T) {
+		for _, req := range []models.MetricsHistory{
+			{NodeID: nodeID},
+			{Network: "historynet", PeerID: "peer"},
+			{Network: "historynet", Gateway: true},
+			{Network: "historynet", From: start, To: start.Add(-time.Hour)},
+			{Network: "historynet", Resolution: "1d"},
+		} {
+			_, err := GetMetricsHistory(req)
+			assert.ErrorIs(t, err, ErrInvalidMetricsHistoryQuery)
+		}
+	})
+}
@@ -199,6 +199,7 @@ func DeleteNetwork(network string, force bool, done chan struct{}) error {
 		if err != nil {
 			return err
 		}
+		InvalidateDNSSnapshot()
 		if servercfg.CacheEnabled() {
 			deleteNetworkFromCache(network)
 		}
@@ -228,6 +229,7 @@ func DeleteNetwork(network string, force bool, done chan struct{}) error {
 		if err != nil {
 			return
 		}
+		InvalidateDNSSnapshot()
 		if servercfg.CacheEnabled() {
 			deleteNetworkFromCache(network)
 		}
@@ -289,6 +291,7 @@ func CreateNetwork(network models.Network) (models.Network, error) {
 	if err = database.Insert(network.NetID, string(data), database.NETWORKS_TABLE_NAME); err != nil {
 		return models.Network{}, err
 	}
+	InvalidateDNSSnapshot()
 	if servercfg.CacheEnabled() {
 		storeNetworkInCache(network.NetID, network)
 	}
@@ -303,6 +306,9 @@ func CreateNetwork(network models.Network) (models.Network, error) {
 		uuid.Nil,
 		true,
 		false,
+		models.EnrollmentKeyConstraints{},
+		nil,
+		nil,
 	)
 
 	return network, nil
@@ -641,6 +647,7 @@ func UpdateNetwork(currentNetwork *models.Network, newNetwork *models.Network) (
 		newNetwork.SetNetworkLastModified()
 		err = database.Insert(newNetwork.NetID, string(data), database.NETWORKS_TABLE_NAME)
 		if err == nil {
+			InvalidateDNSSnapshot()
 			if servercfg.CacheEnabled() {
 				storeNetworkInCache(newNetwork.NetID, *newNetwork)
 			}
@@ -703,9 +710,10 @@ func ValidateNetwork(network *models.Network, isUpdate bool) error {
 		for _, e := range err.(validator.ValidationErrors) {
 			fmt.Println(e)
 		}
+		return err
 	}
 
-	return err
+	return ValidateDNSForwardRules(network.NetID, network.DNSForwardRules)
 }
 
 // ParseNetwork - parses a network into a model
@@ -724,6 +732,7 @@ func SaveNetwork(network *models.Network) error {
 	if err := database.Insert(network.NetID, string(data), database.NETWORKS_TABLE_NAME); err != nil {
 		return err
 	}
+	InvalidateDNSSnapshot()
 	if servercfg.CacheEnabled() {
 		storeNetworkInCache(network.NetID, *network)
 	}
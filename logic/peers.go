@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"net"
 	"net/netip"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -13,6 +14,7 @@ import (
 	"github.com/gravitl/netmaker/db"
 	"github.com/gravitl/netmaker/logger"
 	"github.com/gravitl/netmaker/logic/acls/nodeacls"
+	"github.com/gravitl/netmaker/metrics"
 	"github.com/gravitl/netmaker/models"
 	"github.com/gravitl/netmaker/schema"
 	"github.com/gravitl/netmaker/servercfg"
@@ -143,6 +145,10 @@ func GetPeerUpdateForHost(network string, host *models.Host, allNodes []models.N
 	if host == nil {
 		return models.HostPeerUpdate{}, errors.New("host is nil")
 	}
+	start := time.Now()
+	defer func() {
+		metrics.PeerUpdateDuration.Observe(time.Since(start).Seconds())
+	}()
 
 	// track which nodes are deleted
 	// after peer calculation, if peer not in list, add delete config of peer
@@ -183,6 +189,7 @@ func GetPeerUpdateForHost(network string, host *models.Host, allNodes []models.N
 
 	slog.Debug("peer update for host", "hostId", host.ID.String())
 	peerIndexMap := make(map[string]int)
+	var handoverPeers []wgtypes.PeerConfig
 	for _, nodeID := range host.Nodes {
 		networkAllowAll := true
 		nodeID := nodeID
@@ -240,6 +247,14 @@ func GetPeerUpdateForHost(network string, host *models.Host, allNodes []models.N
 			continue
 		}
 		hostPeerUpdate.NameServers = append(hostPeerUpdate.NameServers, networkSettings.NameServers...)
+		for _, rule := range GetDNSForwardRules(&networkSettings) {
+			if !slices.ContainsFunc(hostPeerUpdate.DNSForwardRules, func(r models.DNSForwardRule) bool {
+				return strings.EqualFold(r.Domain, rule.Domain)
+			}) {
+				hostPeerUpdate.DNSForwardRules = append(hostPeerUpdate.DNSForwardRules, rule)
+			}
+		}
+		usePresharedKeys := networkSettings.PresharedKeys == "yes"
 		currentPeers := GetNetworkNodesMemory(allNodes, node.Network)
 		for _, peer := range currentPeers {
 			peer := peer
@@ -382,6 +397,10 @@ func GetPeerUpdateForHost(network string, host *models.Host, allNodes []models.N
 				peerConfig.AllowedIPs = GetAllowedIPs(&node, &peer, nil) // only append allowed IPs if valid connection
 			}
 
+			if usePresharedKeys {
+				peerConfig.PresharedKey = GetHostPresharedKey(host, peerHost)
+			}
+
 			var nodePeer wgtypes.PeerConfig
 			if _, ok := peerIndexMap[peerHost.PublicKey.String()]; !ok {
 				hostPeerUpdate.Peers = append(hostPeerUpdate.Peers, peerConfig)
@@ -399,6 +418,10 @@ func GetPeerUpdateForHost(network string, host *models.Host, allNodes []models.N
 				hostPeerUpdate.Peers[peerIndexMap[peerHost.PublicKey.String()]].AllowedIPs = peerAllowedIPs
 				hostPeerUpdate.Peers[peerIndexMap[peerHost.PublicKey.String()]].Remove = false
 				hostPeerUpdate.Peers[peerIndexMap[peerHost.PublicKey.String()]].Endpoint = peerConfig.Endpoint
+				// a peer shared with a network using pre-shared keys keeps that key
+				if peerConfig.PresharedKey != nil && *peerConfig.PresharedKey != (wgtypes.Key{}) {
+					hostPeerUpdate.Peers[peerIndexMap[peerHost.PublicKey.String()]].PresharedKey = peerConfig.PresharedKey
+				}
 				hostPeerUpdate.HostNetworkInfo[peerHost.PublicKey.String()] = models.HostNetworkInfo{
 					Interfaces:   peerHost.Interfaces,
 					ListenPort:   peerHost.ListenPort,
@@ -427,19 +450,26 @@ func GetPeerUpdateForHost(network string, host *models.Host, allNodes []models.N
 			hostPeerUpdate.FwUpdate.IsIngressGw = true
 			extPeers, extPeerIDAndAddrs, egressRoutes, err = GetExtPeers(&node, &node)
 			if err == nil {
+				ingFwUpdate := models.IngressInfo{
+					IngressID: node.ID.String(),
+					Network:   node.NetworkRange,
+					Network6:  node.NetworkRange6,
+				}
 				if !defaultDevicePolicy.Enabled || !defaultUserPolicy.Enabled {
-					ingFwUpdate := models.IngressInfo{
-						IngressID:     node.ID.String(),
-						Network:       node.NetworkRange,
-						Network6:      node.NetworkRange6,
-						StaticNodeIps: GetStaticNodeIps(node),
-						Rules:         GetFwRulesOnIngressGateway(node),
-					}
+					ingFwUpdate.StaticNodeIps = GetStaticNodeIps(node)
+					ingFwUpdate.Rules = GetFwRulesOnIngressGateway(node)
 					ingFwUpdate.EgressRanges, ingFwUpdate.EgressRanges6 = getExtpeerEgressRanges(node)
 					hostPeerUpdate.FwUpdate.IngressInfo[node.ID.String()] = ingFwUpdate
 				}
+				if rateLimits := GetIngressRateLimits(node); len(rateLimits) > 0 {
+					if hostPeerUpdate.FwUpdate.IngressRateLimits == nil {
+						hostPeerUpdate.FwUpdate.IngressRateLimits = make(map[string][]models.ExtClientRateLimitRule)
+					}
+					hostPeerUpdate.FwUpdate.IngressRateLimits[node.ID.String()] = rateLimits
+				}
 				hostPeerUpdate.EgressRoutes = append(hostPeerUpdate.EgressRoutes, egressRoutes...)
 				hostPeerUpdate.Peers = append(hostPeerUpdate.Peers, extPeers...)
+				handoverPeers = append(handoverPeers, GetExtClientHandoverPeers(&node, host)...)
 				for _, extPeerIdAndAddr := range extPeerIDAndAddrs {
 					extPeerIdAndAddr := extPeerIdAndAddr
 					if node.Network == network {
@@ -529,8 +559,14 @@ func GetPeerUpdateForHost(network string, host *models.Host, allNodes []models.N
 		if len(peer.AllowedIPs) == 0 {
 			peer.Remove = true
 		}
+		// a zero key removes a pre-shared key set while a network used them
+		if peer.PresharedKey == nil {
+			peer.PresharedKey = &wgtypes.Key{}
+		}
 		hostPeerUpdate.Peers[i] = peer
 	}
+	// handover peers are added after the removal check as they deliberately carry no allowed ips
+	hostPeerUpdate.Peers = append(hostPeerUpdate.Peers, handoverPeers...)
 	if deletedNode != nil && host.OS != models.OS_Types.IoT {
 		peerHost, err := GetHost(deletedNode.HostID.String())
 		if err == nil && host.ID != peerHost.ID {
@@ -562,6 +598,14 @@ func GetPeerUpdateForHost(network string, host *models.Host, allNodes []models.N
 					Remove:    true,
 				})
 			}
+			if deletedClient.PreviousPublicKey != "" && deletedClient.PreviousPublicKey != deletedClient.PublicKey {
+				if key, err := wgtypes.ParseKey(deletedClient.PreviousPublicKey); err == nil {
+					hostPeerUpdate.Peers = append(hostPeerUpdate.Peers, wgtypes.PeerConfig{
+						PublicKey: key,
+						Remove:    true,
+					})
+				}
+			}
 		}
 	}
 	return hostPeerUpdate, nil
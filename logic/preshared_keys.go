@@ -0,0 +1,187 @@
+package logic
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gravitl/netmaker/db"
+	"github.com/gravitl/netmaker/models"
+	"github.com/gravitl/netmaker/schema"
+	"github.com/gravitl/netmaker/servercfg"
+	"golang.org/x/exp/slog"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+// PresharedKeyRotationCheckInterval - how often pre-shared keys are checked for rotation
+const PresharedKeyRotationCheckInterval = time.Hour
+
+var (
+	presharedKeyMutex = &sync.Mutex{}
+	presharedKeyCache = make(map[string]wgtypes.Key)
+)
+
+// IsPresharedKeyEnabled - checks if peers on a network use pre-shared keys
+func IsPresharedKeyEnabled(network string) bool {
+	n, err := GetNetwork(network)
+	if err != nil {
+		return false
+	}
+	return n.PresharedKeys == "yes"
+}
+
+// GetPresharedKey - gets the pre-shared key of a pair of peers, generating it on first use
+func GetPresharedKey(peerA, peerB string) (*wgtypes.Key, error) {
+	id := presharedKeyPairID(peerA, peerB)
+	presharedKeyMutex.Lock()
+	defer presharedKeyMutex.Unlock()
+	if key, ok := presharedKeyCache[id]; ok {
+		return &key, nil
+	}
+	psk := schema.PresharedKey{ID: id}
+	if err := psk.Get(db.WithContext(context.TODO())); err == nil {
+		key, err := wgtypes.ParseKey(psk.Key)
+		if err != nil {
+			return nil, err
+		}
+		presharedKeyCache[id] = key
+		return &key, nil
+	}
+	key, err := wgtypes.GenerateKey()
+	if err != nil {
+		return nil, err
+	}
+	pair := strings.SplitN(id, "|", 2)
+	psk = schema.PresharedKey{
+		ID:        id,
+		PeerA:     pair[0],
+		PeerB:     pair[1],
+		Key:       key.String(),
+		CreatedAt: time.Now().UTC(),
+	}
+	if err = psk.Create(db.WithContext(context.TODO())); err != nil {
+		return nil, err
+	}
+	presharedKeyCache[id] = key
+	return &key, nil
+}
+
+// GetHostPresharedKey - gets the pre-shared key between two hosts
+func GetHostPresharedKey(host, peerHost *models.Host) *wgtypes.Key {
+	key, err := GetPresharedKey(host.ID.String(), peerHost.ID.String())
+	if err != nil {
+		slog.Error("failed to get pre-shared key", "host", host.ID.String(), "peer", peerHost.ID.String(), "error", err)
+		return nil
+	}
+	return key
+}
+
+// GetExtClientPresharedKey - gets the pre-shared key between an ext client and its gateway host
+func GetExtClientPresharedKey(client *models.ExtClient, gwHost *models.Host) *wgtypes.Key {
+	key, err := GetPresharedKey(extClientPeerID(client), gwHost.ID.String())
+	if err != nil {
+		slog.Error("failed to get ext client pre-shared key", "client", client.ClientID, "error", err)
+		return nil
+	}
+	return key
+}
+
+// GetExtClientGwPresharedKey - gets the pre-shared key an ext client uses with its gateway host,
+// empty if the client's network doesn't use pre-shared keys
+func GetExtClientGwPresharedKey(client *models.ExtClient, gwHost *models.Host) string {
+	if !IsPresharedKeyEnabled(client.Network) {
+		return ""
+	}
+	if psk := GetExtClientPresharedKey(client, gwHost); psk != nil {
+		return psk.String()
+	}
+	return ""
+}
+
+// RotatePresharedKeys - regenerates the pre-shared keys between hosts older than the rotation interval,
+// returns true if any key was rotated. Keys of ext clients stay fixed as their downloaded configs carry them
+func RotatePresharedKeys() (bool, error) {
+	keys, err := (&schema.PresharedKey{}).ListAll(db.WithContext(context.TODO()))
+	if err != nil {
+		return false, err
+	}
+	rotated := false
+	interval := servercfg.GetPresharedKeyRotationInterval()
+	for i := range keys {
+		psk := keys[i]
+		if isExtClientPeerID(psk.PeerA) || isExtClientPeerID(psk.PeerB) || time.Since(psk.CreatedAt) < interval {
+			continue
+		}
+		key, err := wgtypes.GenerateKey()
+		if err != nil {
+			return rotated, err
+		}
+		psk.Key = key.String()
+		psk.CreatedAt = time.Now().UTC()
+		presharedKeyMutex.Lock()
+		err = psk.Update(db.WithContext(context.TODO()))
+		if err == nil {
+			presharedKeyCache[psk.ID] = key
+		}
+		presharedKeyMutex.Unlock()
+		if err != nil {
+			return rotated, err
+		}
+		rotated = true
+	}
+	return rotated, nil
+}
+
+// DeletePresharedKeys - removes all pre-shared keys of a host or ext client
+func DeletePresharedKeys(peer string) error {
+	presharedKeyMutex.Lock()
+	defer presharedKeyMutex.Unlock()
+	for id := range presharedKeyCache {
+		pair := strings.SplitN(id, "|", 2)
+		if pair[0] == peer || pair[1] == peer {
+			delete(presharedKeyCache, id)
+		}
+	}
+	return (&schema.PresharedKey{}).DeleteByPeer(db.WithContext(context.TODO()), peer)
+}
+
+// DeleteExtClientPresharedKeys - removes the pre-shared keys of an ext client
+func DeleteExtClientPresharedKeys(client *models.ExtClient) error {
+	return DeletePresharedKeys(extClientPeerID(client))
+}
+
+func extClientPeerID(client *models.ExtClient) string {
+	return "extclient:" + client.Network + ":" + client.ClientID
+}
+
+func isExtClientPeerID(id string) bool {
+	return strings.HasPrefix(id, "extclient:")
+}
+
+// presharedKeyPairID - the id of a pair of peers, independent of their order
+func presharedKeyPairID(peerA, peerB string) string {
+	pair := []string{peerA, peerB}
+	sort.Strings(pair)
+	return pair[0] + "|" + pair[1]
+}
+
+// AddPresharedKeyRotationHook - adds the hook rotating expired pre-shared keys
+func AddPresharedKeyRotationHook() {
+	HookManagerCh <- models.HookDetails{
+		Hook:     presharedKeyRotationHook,
+		Interval: PresharedKeyRotationCheckInterval,
+	}
+}
+
+// presharedKeyRotationHook - rotates expired pre-shared keys between hosts and pushes them to the hosts
+func presharedKeyRotationHook() error {
+	rotated, err := RotatePresharedKeys()
+	if rotated {
+		if err := PublishPeerUpdate(false); err != nil {
+			slog.Error("failed to publish rotated pre-shared keys", "error", err)
+		}
+	}
+	return err
+}
@@ -0,0 +1,157 @@
+package logic
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gravitl/netmaker/database"
+	"github.com/gravitl/netmaker/db"
+	"github.com/gravitl/netmaker/models"
+	"github.com/gravitl/netmaker/schema"
+	"github.com/gravitl/netmaker/servercfg"
+	"github.com/stretchr/testify/assert"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+func TestPresharedKeys(t *testing.T) {
+	db.InitializeDB(schema.ListModels()...)
+	defer db.CloseDB()
+
+	hostA := &models.Host{ID: uuid.New()}
+	hostB := &models.Host{ID: uuid.New()}
+	hostC := &models.Host{ID: uuid.New()}
+	defer DeletePresharedKeys(hostA.ID.String())
+	defer DeletePresharedKeys(hostC.ID.String())
+
+	ab := GetHostPresharedKey(hostA, hostB)
+	assert.NotNil(t, ab)
+	assert.Equal(t, ab, GetHostPresharedKey(hostB, hostA))
+	ac := GetHostPresharedKey(hostA, hostC)
+	assert.NotNil(t, ac)
+	assert.NotEqual(t, ab, ac)
+
+	client := &models.ExtClient{ClientID: "laptop", Network: "skynet"}
+	ca := GetExtClientPresharedKey(client, hostA)
+	assert.NotNil(t, ca)
+	assert.Nil(t, DeleteExtClientPresharedKeys(client))
+	assert.NotEqual(t, ca, GetExtClientPresharedKey(client, hostA))
+	assert.Nil(t, DeleteExtClientPresharedKeys(client))
+
+	assert.Nil(t, DeletePresharedKeys(hostB.ID.String()))
+	assert.NotEqual(t, ab, GetHostPresharedKey(hostA, hostB))
+	assert.Equal(t, ac, GetHostPresharedKey(hostC, hostA))
+
+	t.Run("Rotation", func(t *testing.T) {
+		client := &models.ExtClient{ClientID: "rotating-" + uuid.NewString()[:8], Network: "skynet"}
+		defer DeleteExtClientPresharedKeys(client)
+		hostD := &models.Host{ID: uuid.New()}
+		defer DeletePresharedKeys(hostD.ID.String())
+		clientKey := GetExtClientPresharedKey(client, hostA)
+		hostKey := GetHostPresharedKey(hostA, hostD)
+		assert.NotNil(t, clientKey)
+		assert.NotNil(t, hostKey)
+		ctx := db.WithContext(context.TODO())
+		for _, id := range []string{
+			presharedKeyPairID(extClientPeerID(client), hostA.ID.String()),
+			presharedKeyPairID(hostA.ID.String(), hostD.ID.String()),
+		} {
+			psk := schema.PresharedKey{ID: id}
+			assert.Nil(t, psk.Get(ctx))
+			psk.CreatedAt = time.Now().Add(-servercfg.GetPresharedKeyRotationInterval() - time.Hour)
+			assert.Nil(t, psk.Update(ctx))
+		}
+
+		rotated, err := RotatePresharedKeys()
+		assert.Nil(t, err)
+		assert.True(t, rotated)
+		assert.NotEqual(t, hostKey, GetHostPresharedKey(hostA, hostD))
+		// downloaded ext client configs keep working
+		assert.Equal(t, clientKey, GetExtClientPresharedKey(client, hostA))
+	})
+}
+
+func TestPresharedKeysAcrossNetworks(t *testing.T) {
+	db.InitializeDB(schema.ListModels()...)
+	defer db.CloseDB()
+	database.InitializeDatabase()
+	defer database.CloseDB()
+
+	networks := []models.Network{
+		{NetID: "pskon", AddressRange: "10.116.0.0/24", PresharedKeys: "yes"},
+		{NetID: "pskoff", AddressRange: "10.117.0.0/24", PresharedKeys: "no"},
+	}
+	for i := range networks {
+		assert.Nil(t, SaveNetwork(&networks[i]))
+	}
+	defer func() {
+		for _, network := range networks {
+			_ = database.DeleteRecord(database.NETWORKS_TABLE_NAME, network.NetID)
+		}
+	}()
+
+	var hosts []*models.Host
+	var allNodes []models.Node
+	for i := 0; i < 2; i++ {
+		key, err := wgtypes.GeneratePrivateKey()
+		assert.Nil(t, err)
+		host := &models.Host{ID: uuid.New(), PublicKey: key.PublicKey(), ListenPort: 51821}
+		for j, network := range networks {
+			_, cidr, _ := net.ParseCIDR(network.AddressRange)
+			node := models.Node{CommonNode: models.CommonNode{
+				ID:           uuid.New(),
+				HostID:       host.ID,
+				Network:      network.NetID,
+				Connected:    true,
+				NetworkRange: *cidr,
+				Address:      net.IPNet{IP: net.IPv4(10, byte(116+j), 0, byte(10+i)), Mask: net.CIDRMask(32, 32)},
+			}}
+			assert.Nil(t, UpsertNode(&node))
+			host.Nodes = append(host.Nodes, node.ID.String())
+			allNodes = append(allNodes, node)
+		}
+		assert.Nil(t, CreateHost(host))
+		hosts = append(hosts, host)
+	}
+	defer func() {
+		for i := range allNodes {
+			_ = DeleteNodeByID(&allNodes[i])
+		}
+		for _, host := range hosts {
+			_ = RemoveHostByID(host.ID.String())
+			_ = DeletePresharedKeys(host.ID.String())
+		}
+	}()
+
+	psk := GetHostPresharedKey(hosts[0], hosts[1])
+	assert.NotNil(t, psk)
+	peerKey := func(host, peerHost *models.Host) *wgtypes.Key {
+		update, err := GetPeerUpdateForHost("", host, allNodes, nil, nil)
+		assert.Nil(t, err)
+		for _, peer := range update.Peers {
+			if peer.PublicKey == peerHost.PublicKey {
+				return peer.PresharedKey
+			}
+		}
+		return nil
+	}
+	// both hosts use the key of the shared network using pre-shared keys, whichever network comes last
+	assert.Equal(t, psk, peerKey(hosts[0], hosts[1]))
+	hosts[1].Nodes[0], hosts[1].Nodes[1] = hosts[1].Nodes[1], hosts[1].Nodes[0]
+	assert.Equal(t, psk, peerKey(hosts[1], hosts[0]))
+
+	t.Run("ExtClient", func(t *testing.T) {
+		client := &models.ExtClient{ClientID: "rac-" + uuid.NewString()[:8], Network: "pskon"}
+		defer DeleteExtClientPresharedKeys(client)
+		assert.Equal(t, GetExtClientPresharedKey(client, hosts[0]).String(), GetExtClientGwPresharedKey(client, hosts[0]))
+		client.Network = "pskoff"
+		assert.Empty(t, GetExtClientGwPresharedKey(client, hosts[0]))
+	})
+	t.Run("Disabled", func(t *testing.T) {
+		hosts[0].Nodes = hosts[0].Nodes[1:]
+		hosts[1].Nodes = []string{allNodes[3].ID.String()}
+		assert.Equal(t, &wgtypes.Key{}, peerKey(hosts[0], hosts[1]))
+	})
+}
@@ -0,0 +1,13 @@
+package logic
+
+import "github.com/gravitl/netmaker/models"
+
+// PublishPeerUpdate - publishes a peer update to all the hosts, set by the mq package
+var PublishPeerUpdate = func(replacePeers bool) error { return nil }
+
+// PublishDeletedClientPeerUpdate - publishes a peer update to all the hosts with a removed ext client
+// to account for, set by the mq package
+var PublishDeletedClientPeerUpdate = func(delClient *models.ExtClient) error { return nil }
+
+// PublishMqUpdatesForDeletedNode - publishes the updates for a deleted node, set by the mq package
+var PublishMqUpdatesForDeletedNode = func(node models.Node, sendNodeUpdate bool, gwClients []models.ExtClient) {}
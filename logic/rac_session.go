@@ -0,0 +1,42 @@
+package logic
+
+import (
+	"errors"
+	"time"
+
+	"github.com/gravitl/netmaker/models"
+)
+
+// ErrReauthRequired - returned when a gateway requires a more recent login to issue a config
+var ErrReauthRequired = errors.New("re-authentication required, please log in again")
+
+// ApplyRacSessionPolicy - enforces the session policy of a gateway on a remote access config issued to a user,
+// a session bound config is deleted once its session ends unless it is renewed before
+func ApplyRacSessionPolicy(user *models.User, gw *models.Node, client *models.ExtClient, now time.Time) error {
+	policy := gw.IngressSessionPolicy
+	if policy.ReauthWindow > 0 && now.Sub(user.LastLoginTime) > time.Duration(policy.ReauthWindow)*time.Minute {
+		return ErrReauthRequired
+	}
+	if policy.SessionDuration > 0 {
+		client.Expiry.ExpiresAt = now.Add(time.Duration(policy.SessionDuration) * time.Hour)
+		client.Expiry.Action = models.ExtClientExpiryDelete
+		client.Expiry.NotifiedAt = time.Time{}
+	}
+	return nil
+}
+
+// RenewRacSession - extends the session of an existing remote access config
+func RenewRacSession(user *models.User, gw *models.Node, client *models.ExtClient) error {
+	if err := ApplyRacSessionPolicy(user, gw, client, time.Now()); err != nil {
+		return err
+	}
+	if gw.IngressSessionPolicy.SessionDuration == 0 {
+		return nil
+	}
+	stored, err := GetExtClient(client.ClientID, client.Network)
+	if err != nil {
+		return err
+	}
+	stored.Expiry = client.Expiry
+	return SaveExtClient(&stored)
+}
@@ -0,0 +1,43 @@
+package logic
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gravitl/netmaker/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApplyRacSessionPolicy(t *testing.T) {
+	now := time.Now()
+	user := models.User{UserName: "remote", LastLoginTime: now.Add(-time.Hour)}
+	gw := models.Node{}
+	client := models.ExtClient{}
+	assert.Nil(t, ApplyRacSessionPolicy(&user, &gw, &client, now))
+	assert.False(t, client.Expiry.IsSet())
+
+	gw.IngressSessionPolicy = models.RacSessionPolicy{ReauthWindow: 15, SessionDuration: 8}
+	assert.ErrorIs(t, ApplyRacSessionPolicy(&user, &gw, &client, now), ErrReauthRequired)
+	assert.False(t, client.Expiry.IsSet())
+
+	user.LastLoginTime = now.Add(-5 * time.Minute)
+	assert.Nil(t, ApplyRacSessionPolicy(&user, &gw, &client, now))
+	assert.Equal(t, now.Add(8*time.Hour), client.Expiry.ExpiresAt)
+	assert.Equal(t, models.ExtClientExpiryDelete, GetExtClientExpiryAction(&client))
+
+	// renewing moves the end of the session
+	assert.Nil(t, ApplyRacSessionPolicy(&user, &gw, &client, now.Add(time.Minute)))
+	assert.Equal(t, now.Add(8*time.Hour+time.Minute), client.Expiry.ExpiresAt)
+
+	assert.Nil(t, gw.IngressSessionPolicy.Validate())
+	assert.ErrorIs(t, (&models.RacSessionPolicy{SessionDuration: 25}).Validate(), models.ErrInvalidRacSessionPolicy)
+	assert.ErrorIs(t, (&models.RacSessionPolicy{ReauthWindow: -1}).Validate(), models.ErrInvalidRacSessionPolicy)
+}
+
+func TestCreateIngressGatewaySessionPolicy(t *testing.T) {
+	// the policy is checked before the node is looked up
+	_, err := CreateIngressGateway("net", "missing-node", models.IngressRequest{SessionPolicy: models.RacSessionPolicy{SessionDuration: 25}})
+	assert.ErrorIs(t, err, models.ErrInvalidRacSessionPolicy)
+	_, err = CreateGateway("net", "missing-node", models.CreateGwReq{IngressRequest: models.IngressRequest{SessionPolicy: models.RacSessionPolicy{ReauthWindow: -1}}})
+	assert.ErrorIs(t, err, models.ErrInvalidRacSessionPolicy)
+}
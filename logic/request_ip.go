@@ -0,0 +1,65 @@
+package logic
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/gravitl/netmaker/netclient/ncutils"
+	"github.com/gravitl/netmaker/servercfg"
+)
+
+// ParseRequestIP - gets the public ip a request came from, the forwarding headers are only used
+// for requests relayed by one of the trusted proxies
+func ParseRequestIP(r *http.Request) (string, error) {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return "", err
+	}
+	if isTrustedProxy(net.ParseIP(ip)) {
+		// Get Public IP from header
+		if realIP := net.ParseIP(strings.TrimSpace(r.Header.Get("X-REAL-IP"))); realIP != nil && !ncutils.IpIsPrivate(realIP) {
+			return realIP.String(), nil
+		}
+		// the rightmost address not added by a trusted proxy is the client's, the ones before it can be forged
+		forwardips := strings.Split(r.Header.Get("X-FORWARDED-FOR"), ",")
+		for i := len(forwardips) - 1; i >= 0; i-- {
+			forwardip := net.ParseIP(strings.TrimSpace(forwardips[i]))
+			if forwardip == nil || isTrustedProxy(forwardip) {
+				continue
+			}
+			if !ncutils.IpIsPrivate(forwardip) {
+				return forwardip.String(), nil
+			}
+			break
+		}
+	}
+	ipnet := net.ParseIP(ip)
+	if ipnet != nil {
+		if ncutils.IpIsPrivate(ipnet) {
+			return ip, fmt.Errorf("ip is a private address")
+		}
+		return ip, nil
+	}
+	return "", fmt.Errorf("no ip found")
+}
+
+// isTrustedProxy - checks if an ip is one of the configured trusted proxies
+func isTrustedProxy(ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+	for _, proxy := range servercfg.GetTrustedProxies() {
+		if _, cidr, err := net.ParseCIDR(proxy); err == nil {
+			if cidr.Contains(ip) {
+				return true
+			}
+			continue
+		}
+		if proxyIP := net.ParseIP(proxy); proxyIP != nil && proxyIP.Equal(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,41 @@
+package logic
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseRequestIP(t *testing.T) {
+	request := func(remoteAddr, realIP, forwardedFor string) *http.Request {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.RemoteAddr = remoteAddr
+		if realIP != "" {
+			r.Header.Set("X-Real-IP", realIP)
+		}
+		if forwardedFor != "" {
+			r.Header.Set("X-Forwarded-For", forwardedFor)
+		}
+		return r
+	}
+	// without trusted proxies the headers are ignored
+	t.Setenv("TRUSTED_PROXIES", "")
+	ip, err := ParseRequestIP(request("203.0.113.7:4242", "198.51.100.9", "198.51.100.9"))
+	assert.Nil(t, err)
+	assert.Equal(t, "203.0.113.7", ip)
+
+	t.Setenv("TRUSTED_PROXIES", "10.0.0.0/8, 192.0.2.1")
+	ip, err = ParseRequestIP(request("10.1.2.3:4242", "198.51.100.9", ""))
+	assert.Nil(t, err)
+	assert.Equal(t, "198.51.100.9", ip)
+	// a forged leading address is skipped for the one the proxies added
+	ip, err = ParseRequestIP(request("192.0.2.1:4242", "", "198.51.100.1, 203.0.113.7, 10.4.4.4"))
+	assert.Nil(t, err)
+	assert.Equal(t, "203.0.113.7", ip)
+	// other clients can't claim to be a proxy
+	ip, err = ParseRequestIP(request("203.0.113.8:4242", "198.51.100.9", ""))
+	assert.Nil(t, err)
+	assert.Equal(t, "203.0.113.8", ip)
+}
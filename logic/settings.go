@@ -46,6 +46,11 @@ func UpsertServerSettings(s models.ServerSettings) error {
 
 func ValidateNewSettings(req models.ServerSettings) bool {
 	// TODO: add checks for different fields
+	if req.MetricsHistoryResolution != "" {
+		if _, err := time.ParseDuration(req.MetricsHistoryResolution); err != nil {
+			return false
+		}
+	}
 	return true
 }
 
@@ -292,6 +297,31 @@ func GetIDPSyncInterval() time.Duration {
 	return syncInterval
 }
 
+// GetMetricsHistoryResolution - the minimum time between two raw samples of the metrics history
+func GetMetricsHistoryResolution() time.Duration {
+	resolution, err := time.ParseDuration(GetServerSettings().MetricsHistoryResolution)
+	if err != nil || resolution <= 0 {
+		return time.Minute
+	}
+	return resolution
+}
+
+// GetMetricsHistoryRetention - how long the metrics history keeps the samples of each resolution
+func GetMetricsHistoryRetention() (raw, fiveMinutes, hourly time.Duration) {
+	settings := GetServerSettings()
+	raw, fiveMinutes, hourly = 24*time.Hour, 7*24*time.Hour, 90*24*time.Hour
+	if settings.MetricsRawRetentionInHours > 0 {
+		raw = time.Duration(settings.MetricsRawRetentionInHours) * time.Hour
+	}
+	if settings.Metrics5mRetentionInDays > 0 {
+		fiveMinutes = time.Duration(settings.Metrics5mRetentionInDays) * 24 * time.Hour
+	}
+	if settings.Metrics1hRetentionInDays > 0 {
+		hourly = time.Duration(settings.Metrics1hRetentionInDays) * 24 * time.Hour
+	}
+	return
+}
+
 // GetMetricsPort - get metrics port
 func GetMetricsPort() int {
 	return GetServerSettings().MetricsPort
@@ -41,15 +41,17 @@ func GetReturnUser(username string) (models.ReturnUser, error) {
 // ToReturnUser - gets a user as a return user
 func ToReturnUser(user models.User) models.ReturnUser {
 	return models.ReturnUser{
-		UserName:        user.UserName,
-		DisplayName:     user.DisplayName,
-		AccountDisabled: user.AccountDisabled,
-		AuthType:        user.AuthType,
-		RemoteGwIDs:     user.RemoteGwIDs,
-		UserGroups:      user.UserGroups,
-		PlatformRoleID:  user.PlatformRoleID,
-		NetworkRoles:    user.NetworkRoles,
-		LastLoginTime:   user.LastLoginTime,
+		UserName:           user.UserName,
+		DisplayName:        user.DisplayName,
+		AccountDisabled:    user.AccountDisabled,
+		AuthType:           user.AuthType,
+		RemoteGwIDs:        user.RemoteGwIDs,
+		UserGroups:         user.UserGroups,
+		PlatformRoleID:     user.PlatformRoleID,
+		NetworkRoles:       user.NetworkRoles,
+		LastLoginTime:      user.LastLoginTime,
+		ExtClientQuota:     user.ExtClientQuota,
+		ExtClientRateLimit: user.ExtClientRateLimit,
 	}
 }
 
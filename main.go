@@ -89,6 +89,9 @@ func startHooks() {
 	if err != nil {
 		logger.Log(1, "Timer error occurred: ", err.Error())
 	}
+	logic.AddExtClientExpiryHook()
+	logic.AddExtClientKeyRotationHook()
+	logic.AddPresharedKeyRotationHook()
 	logic.EnterpriseCheck()
 }
 
@@ -134,8 +137,12 @@ func initialize() { // Client Mode Prereq Check
 	if err != nil {
 		logger.FatalLog("error setting defaults: ", err.Error())
 	}
+	if err = logic.InitGeoIP(); err != nil {
+		logger.Log(0, "error loading GeoIP database: ", err.Error())
+	}
+	logic.CheckGlobalDNSForwardRules()
 
-	if servercfg.IsDNSMode() {
+	if servercfg.IsDNSMode() && !servercfg.IsEmbeddedDNS() {
 		err := functions.SetDNSDir()
 		if err != nil {
 			logger.FatalLog(err.Error())
@@ -150,6 +157,11 @@ func startControllers(wg *sync.WaitGroup, ctx context.Context) {
 		if err != nil {
 			logger.Log(0, "error occurred initializing DNS: ", err.Error())
 		}
+		if servercfg.IsEmbeddedDNS() {
+			wg.Add(1)
+			go logic.StartDNSServer(ctx, wg)
+			go logic.ManageDNSFilters(ctx)
+		}
 	}
 
 	//Run Rest Server
@@ -178,6 +190,8 @@ func startControllers(wg *sync.WaitGroup, ctx context.Context) {
 
 	wg.Add(1)
 	go logic.StartHookManager(ctx, wg)
+	go logic.ManageDNSSync(ctx)
+	go logic.ManageTagDNS(ctx)
 }
 
 // Should we be using a context vice a waitgroup????????????
@@ -197,6 +211,7 @@ func runMessageQueue(wg *sync.WaitGroup, ctx context.Context) {
 		peerUpdate := make(chan *models.Node, 100)
 		go logic.ManageZombies(ctx, peerUpdate)
 		go logic.DeleteExpiredNodes(ctx, peerUpdate)
+		go logic.DeleteEphemeralHosts(ctx, peerUpdate)
 		for nodeUpdate := range peerUpdate {
 			if nodeUpdate == nil {
 				continue
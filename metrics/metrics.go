@@ -0,0 +1,61 @@
+// Package metrics holds the prometheus registry the server exposes on /metrics
+// and the instruments of the server's own internals
+package metrics
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Namespace - prefix of every series the server exposes
+const Namespace = "netmaker"
+
+// Registry - the registry served on /metrics, collectors of the server's state register here
+var Registry = prometheus.NewRegistry()
+
+var (
+	// PeerUpdateDuration - time spent computing the peer update of a host
+	PeerUpdateDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: Namespace,
+		Name:      "peer_update_duration_seconds",
+		Help:      "Time spent computing the peer update of a host.",
+		Buckets:   prometheus.ExponentialBuckets(0.001, 2, 14),
+	})
+	// MQPublishFailures - messages the server failed to publish to the broker by topic
+	MQPublishFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "mq_publish_failures_total",
+		Help:      "Messages the server failed to publish to the broker.",
+	}, []string{"topic"})
+	// DBOperationDuration - latency of database operations by operation and table
+	DBOperationDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: Namespace,
+		Name:      "db_operation_duration_seconds",
+		Help:      "Latency of database operations.",
+		Buckets:   prometheus.ExponentialBuckets(0.0005, 2, 14),
+	}, []string{"operation", "table"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		PeerUpdateDuration,
+		MQPublishFailures,
+		DBOperationDuration,
+	)
+}
+
+// Handler - serves the registry in the prometheus or openmetrics text format
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{EnableOpenMetrics: true})
+}
+
+// ObserveDB - records the latency of a database operation started at start
+func ObserveDB(operation, table string, start time.Time) {
+	DBOperationDuration.WithLabelValues(operation, table).Observe(time.Since(start).Seconds())
+}
@@ -159,6 +159,9 @@ func updateEnrollmentKeys() {
 			uuid.Nil,
 			true,
 			false,
+			models.EnrollmentKeyConstraints{},
+			nil,
+			nil,
 		)
 
 	}
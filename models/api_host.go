@@ -8,30 +8,32 @@ import (
 
 // ApiHost - the host struct for API usage
 type ApiHost struct {
-	ID                  string     `json:"id"`
-	Verbosity           int        `json:"verbosity"`
-	FirewallInUse       string     `json:"firewallinuse"`
-	Version             string     `json:"version"`
-	Name                string     `json:"name"`
-	OS                  string     `json:"os"`
-	Debug               bool       `json:"debug"`
-	IsStaticPort        bool       `json:"isstaticport"`
-	IsStatic            bool       `json:"isstatic"`
-	ListenPort          int        `json:"listenport"`
-	WgPublicListenPort  int        `json:"wg_public_listen_port" yaml:"wg_public_listen_port"`
-	MTU                 int        `json:"mtu"                   yaml:"mtu"`
-	Interfaces          []ApiIface `json:"interfaces"            yaml:"interfaces"`
-	DefaultInterface    string     `json:"defaultinterface"      yaml:"defautlinterface"`
-	EndpointIP          string     `json:"endpointip"            yaml:"endpointip"`
-	EndpointIPv6        string     `json:"endpointipv6"            yaml:"endpointipv6"`
-	PublicKey           string     `json:"publickey"`
-	MacAddress          string     `json:"macaddress"`
-	Nodes               []string   `json:"nodes"`
-	IsDefault           bool       `json:"isdefault"             yaml:"isdefault"`
-	NatType             string     `json:"nat_type"              yaml:"nat_type"`
-	PersistentKeepalive int        `json:"persistentkeepalive"   yaml:"persistentkeepalive"`
-	AutoUpdate          bool       `json:"autoupdate"              yaml:"autoupdate"`
-	DNS                 string     `json:"dns"               yaml:"dns"`
+	ID                  string      `json:"id"`
+	Verbosity           int         `json:"verbosity"`
+	FirewallInUse       string      `json:"firewallinuse"`
+	Version             string      `json:"version"`
+	Name                string      `json:"name"`
+	OS                  string      `json:"os"`
+	Debug               bool        `json:"debug"`
+	IsStaticPort        bool        `json:"isstaticport"`
+	IsStatic            bool        `json:"isstatic"`
+	ListenPort          int         `json:"listenport"`
+	WgPublicListenPort  int         `json:"wg_public_listen_port" yaml:"wg_public_listen_port"`
+	MTU                 int         `json:"mtu"                   yaml:"mtu"`
+	Interfaces          []ApiIface  `json:"interfaces"            yaml:"interfaces"`
+	DefaultInterface    string      `json:"defaultinterface"      yaml:"defautlinterface"`
+	EndpointIP          string      `json:"endpointip"            yaml:"endpointip"`
+	EndpointIPv6        string      `json:"endpointipv6"            yaml:"endpointipv6"`
+	PublicKey           string      `json:"publickey"`
+	MacAddress          string      `json:"macaddress"`
+	Nodes               []string    `json:"nodes"`
+	IsDefault           bool        `json:"isdefault"             yaml:"isdefault"`
+	IsEphemeral         bool        `json:"isephemeral"           yaml:"isephemeral"`
+	NatType             string      `json:"nat_type"              yaml:"nat_type"`
+	PersistentKeepalive int         `json:"persistentkeepalive"   yaml:"persistentkeepalive"`
+	AutoUpdate          bool        `json:"autoupdate"              yaml:"autoupdate"`
+	DNS                 string      `json:"dns"               yaml:"dns"`
+	Location            GeoLocation `json:"location"          yaml:"location"`
 }
 
 // ApiIface - the interface struct for API usage
@@ -76,10 +78,12 @@ func (h *Host) ConvertNMHostToAPI() *ApiHost {
 	a.Verbosity = h.Verbosity
 	a.Version = h.Version
 	a.IsDefault = h.IsDefault
+	a.IsEphemeral = h.IsEphemeral
 	a.NatType = h.NatType
 	a.PersistentKeepalive = int(h.PersistentKeepalive.Seconds())
 	a.AutoUpdate = h.AutoUpdate
 	a.DNS = h.DNS
+	a.Location = h.Location
 	return &a
 }
 
@@ -121,10 +125,13 @@ func (a *ApiHost) ConvertAPIHostToNMHost(currentHost *Host) *Host {
 	h.TrafficKeyPublic = currentHost.TrafficKeyPublic
 	h.OS = currentHost.OS
 	h.IsDefault = a.IsDefault
+	h.IsEphemeral = currentHost.IsEphemeral
 	h.NatType = currentHost.NatType
 	h.TurnEndpoint = currentHost.TurnEndpoint
 	h.PersistentKeepalive = time.Duration(a.PersistentKeepalive) * time.Second
 	h.AutoUpdate = a.AutoUpdate
 	h.DNS = strings.ToLower(a.DNS)
+	h.Location = currentHost.Location
+	h.LastCheckIn = currentHost.LastCheckIn
 	return &h
 }
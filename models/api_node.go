@@ -44,6 +44,7 @@ type ApiNode struct {
 	IngressDns                    string              `json:"ingressdns"`
 	IngressPersistentKeepalive    int32               `json:"ingresspersistentkeepalive"`
 	IngressMTU                    int32               `json:"ingressmtu"`
+	IngressSessionPolicy          RacSessionPolicy    `json:"ingress_session_policy"`
 	Server                        string              `json:"server"`
 	Connected                     bool                `json:"connected"`
 	PendingDelete                 bool                `json:"pendingdelete"`
@@ -85,6 +86,7 @@ func (a *ApiNode) ConvertToServerNode(currentNode *Node) *Node {
 	convertedNode.IngressGatewayRange6 = currentNode.IngressGatewayRange6
 	convertedNode.IngressDNS = a.IngressDns
 	convertedNode.IngressPersistentKeepalive = a.IngressPersistentKeepalive
+	convertedNode.IngressSessionPolicy = a.IngressSessionPolicy
 	convertedNode.IngressMTU = a.IngressMTU
 	convertedNode.IsInternetGateway = a.IsInternetGateway
 	convertedNode.InternetGwID = currentNode.InternetGwID
@@ -191,6 +193,7 @@ func (nm *Node) ConvertToAPINode() *ApiNode {
 	apiNode.IsIngressGateway = nm.IsIngressGateway
 	apiNode.IngressDns = nm.IngressDNS
 	apiNode.IngressPersistentKeepalive = nm.IngressPersistentKeepalive
+	apiNode.IngressSessionPolicy = nm.IngressSessionPolicy
 	apiNode.IngressMTU = nm.IngressMTU
 	apiNode.Server = nm.Server
 	apiNode.Connected = nm.Connected
@@ -0,0 +1,39 @@
+package models
+
+// CloudProvider - a cloud whose instance identity documents can be used for host registration
+type CloudProvider string
+
+const (
+	AWSCloud   CloudProvider = "aws"
+	GCPCloud   CloudProvider = "gcp"
+	AzureCloud CloudProvider = "azure"
+)
+
+// EnrollmentKeyAttestation - rules that let hosts register with an enrollment key by
+// presenting a signed cloud instance identity document instead of the key itself
+type EnrollmentKeyAttestation struct {
+	Provider CloudProvider `json:"provider"`
+	Accounts []string      `json:"accounts"` // aws account ids, gcp project ids or azure subscription ids
+	Regions  []string      `json:"regions"`  // aws regions or gcp zones, not reported by azure
+	Audience string        `json:"audience"` // audience expected in gcp identity tokens, required for gcp
+}
+
+// AttestedRegisterRequest - a host registration backed by a cloud instance identity document
+type AttestedRegisterRequest struct {
+	Host     Host          `json:"host"`
+	Provider CloudProvider `json:"provider"`
+	// Document - the base64 aws pkcs7 signature, the gcp identity jwt
+	// or the base64 pkcs7 signature of the azure attested data document
+	Document string `json:"document"`
+}
+
+// CloudIdentity - the verified identity of a cloud instance
+type CloudIdentity struct {
+	Provider   CloudProvider `json:"provider"`
+	Account    string        `json:"account"`
+	Region     string        `json:"region"`
+	InstanceID string        `json:"instance_id"`
+	Audience   string        `json:"audience,omitempty"`
+	// Digest - hash of the document's signature, a document is only accepted once
+	Digest string `json:"-"`
+}
@@ -1,6 +1,14 @@
 // TODO:  Either add a returnNetwork and returnKey, or delete this
 package models
 
+import (
+	"errors"
+	"net"
+	"regexp"
+	"strings"
+	"time"
+)
+
 // DNSUpdateAction identifies the action to be performed with the dns update data
 type DNSUpdateAction int
 
@@ -40,10 +48,112 @@ type DNSUpdate struct {
 	NewAddress string
 }
 
-// DNSEntry - a DNS entry represented as struct
+// DNSRecordType - the type of a DNS entry
+type DNSRecordType string
+
+const (
+	// DNSRecordA - an address record, answered as A and AAAA from Address and Address6
+	DNSRecordA DNSRecordType = "A"
+	// DNSRecordCNAME - an alias of Target
+	DNSRecordCNAME DNSRecordType = "CNAME"
+	// DNSRecordSRV - a service located at Target and Port
+	DNSRecordSRV DNSRecordType = "SRV"
+	// DNSRecordTXT - free form Text
+	DNSRecordTXT DNSRecordType = "TXT"
+	// DNSRecordMX - a mail exchanger at Target
+	DNSRecordMX DNSRecordType = "MX"
+	// DNSRecordPTR - the reverse name of an address pointing at Target, generated from the address records
+	DNSRecordPTR DNSRecordType = "PTR"
+)
+
+// DNSEntry - a DNS entry represented as struct,
+// names may start with a *. label to match any name below it
 type DNSEntry struct {
-	Address  string `json:"address" validate:"omitempty,ip"`
-	Address6 string `json:"address6" validate:"omitempty,ip"`
-	Name     string `json:"name" validate:"required,name_unique,min=1,max=192,whitespace"`
-	Network  string `json:"network" validate:"network_exists"`
+	Address  string        `json:"address" validate:"omitempty,ip"`
+	Address6 string        `json:"address6" validate:"omitempty,ip"`
+	Name     string        `json:"name" validate:"required,name_unique,min=1,max=192,whitespace"`
+	Network  string        `json:"network" validate:"network_exists"`
+	Type     DNSRecordType `json:"type,omitempty" validate:"omitempty,oneof=A CNAME SRV TXT MX"`
+	TTL      uint32        `json:"ttl,omitempty" validate:"omitempty,max=604800"`
+	Target   string        `json:"target,omitempty" validate:"omitempty,max=253"`
+	Priority uint16        `json:"priority,omitempty"`
+	Weight   uint16        `json:"weight,omitempty"`
+	Port     uint16        `json:"port,omitempty"`
+	Text     []string      `json:"text,omitempty" validate:"dive,max=255"`
+}
+
+// RecordType - gets the type of the entry, entries without one are address records
+func (e *DNSEntry) RecordType() DNSRecordType {
+	if e.Type == "" {
+		return DNSRecordA
+	}
+	return e.Type
+}
+
+// IsWildcard - checks if the entry matches any name below it
+func (e *DNSEntry) IsWildcard() bool {
+	return strings.HasPrefix(e.Name, "*.")
+}
+
+// ErrInvalidDNSForwardRule - a forwarding rule without a valid domain or nameservers
+var ErrInvalidDNSForwardRule = errors.New("invalid dns forwarding rule")
+
+var dnsDomainRegex = regexp.MustCompile(`^[A-Za-z0-9_-]+(\.[A-Za-z0-9_-]+)*\.?$`)
+
+// DNSForwardRule - sends the queries for a domain and the names below it to its own nameservers,
+// e.g. an on-prem domain resolved through an egress gateway
+type DNSForwardRule struct {
+	Domain      string   `json:"domain"`
+	NameServers []string `json:"nameservers"`
+}
+
+// Validate - checks the domain and that every nameserver is an ip, optionally with a port
+func (r *DNSForwardRule) Validate() error {
+	if !dnsDomainRegex.MatchString(r.Domain) || len(r.NameServers) == 0 {
+		return ErrInvalidDNSForwardRule
+	}
+	for _, ns := range r.NameServers {
+		if host, _, err := net.SplitHostPort(ns); err == nil {
+			ns = host
+		}
+		if net.ParseIP(ns) == nil {
+			return ErrInvalidDNSForwardRule
+		}
+	}
+	return nil
+}
+
+// DNSZoneImportRequest - a zone file in RFC 1035 format to import into a network's custom entries
+type DNSZoneImportRequest struct {
+	Zone string `json:"zone"`
+	// Origin - the origin of relative names, defaults to the default domain or else the network name
+	Origin string `json:"origin"`
+	DryRun bool   `json:"dry_run"`
+}
+
+// DNSZoneImport - the outcome of a zone import, entries are only created when it is not a dry run
+type DNSZoneImport struct {
+	DryRun    bool              `json:"dry_run"`
+	Created   []DNSEntry        `json:"created"`
+	Conflicts []DNSZoneConflict `json:"conflicts"`
+	Skipped   []DNSZoneConflict `json:"skipped"`
+}
+
+// DNSZoneConflict - a record of a zone file that was not imported and why
+type DNSZoneConflict struct {
+	Record string   `json:"record"`
+	Entry  DNSEntry `json:"entry"`
+	Reason string   `json:"reason"`
+}
+
+// DNSFilterStats - the queries a client sent the server's DNS resolver and how many a filter policy blocked
+type DNSFilterStats struct {
+	ClientID      string    `json:"client_id"`
+	Network       string    `json:"network"`
+	Address       string    `json:"address"`
+	Queries       uint64    `json:"queries"`
+	Blocked       uint64    `json:"blocked"`
+	LastBlocked   string    `json:"last_blocked"`
+	LastBlockedAt time.Time `json:"last_blocked_at"`
+	LastQueryAt   time.Time `json:"last_query_at"`
 }
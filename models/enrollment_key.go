@@ -3,6 +3,8 @@ package models
 import (
 	"errors"
 	"fmt"
+	"net"
+	"regexp"
 	"time"
 
 	"github.com/google/uuid"
@@ -21,8 +23,14 @@ var (
 	ErrNilTagsEnrollmentKey      = errors.New("enrollment key tags is nil")
 	ErrInvalidEnrollmentKey      = errors.New("enrollment key is not valid")
 	ErrInvalidEnrollmentKeyValue = errors.New("enrollment key value is not valid")
+	ErrInvalidKeyConstraints     = errors.New("enrollment key constraints are not valid")
+	ErrInvalidKeyProfile         = errors.New("enrollment key provisioning profile is not valid")
+	ErrInvalidKeyAttestation     = errors.New("enrollment key attestation is not valid")
 )
 
+// interfaceNameRegex - a network interface name the way linux accepts it
+var interfaceNameRegex = regexp.MustCompile(`^[a-zA-Z0-9_.-]{1,15}$`)
+
 // KeyType - the type of enrollment key
 type KeyType int
 
@@ -43,31 +51,73 @@ const EnrollmentKeyLength = 32
 
 // EnrollmentKey - the key used to register hosts and join them to specific networks
 type EnrollmentKey struct {
-	Expiration    time.Time `json:"expiration"`
-	UsesRemaining int       `json:"uses_remaining"`
-	Value         string    `json:"value"`
-	Networks      []string  `json:"networks"`
-	Unlimited     bool      `json:"unlimited"`
-	Tags          []string  `json:"tags"`
-	Token         string    `json:"token,omitempty"` // B64 value of EnrollmentToken
-	Type          KeyType   `json:"type"`
-	Relay         uuid.UUID `json:"relay"`
-	Groups        []TagID   `json:"groups"`
-	Default       bool      `json:"default"`
-	AutoEgress    bool      `json:"auto_egress"`
+	Expiration    time.Time                 `json:"expiration"`
+	UsesRemaining int                       `json:"uses_remaining"`
+	Value         string                    `json:"value"`
+	Networks      []string                  `json:"networks"`
+	Unlimited     bool                      `json:"unlimited"`
+	Tags          []string                  `json:"tags"`
+	Token         string                    `json:"token,omitempty"` // B64 value of EnrollmentToken
+	Type          KeyType                   `json:"type"`
+	Relay         uuid.UUID                 `json:"relay"`
+	Groups        []TagID                   `json:"groups"`
+	Default       bool                      `json:"default"`
+	AutoEgress    bool                      `json:"auto_egress"`
+	Constraints   EnrollmentKeyConstraints  `json:"constraints"`
+	Profile       *HostProvisioningProfile  `json:"profile,omitempty"`
+	Attestation   *EnrollmentKeyAttestation `json:"attestation,omitempty"`
 }
 
 // APIEnrollmentKey - used to create enrollment keys via API
 type APIEnrollmentKey struct {
-	Expiration    int64    `json:"expiration" swaggertype:"primitive,integer" format:"int64"`
-	UsesRemaining int      `json:"uses_remaining"`
-	Networks      []string `json:"networks"`
-	Unlimited     bool     `json:"unlimited"`
-	Tags          []string `json:"tags" validate:"required,dive,min=3,max=32"`
-	Type          KeyType  `json:"type"`
-	Relay         string   `json:"relay"`
-	Groups        []TagID  `json:"groups"`
-	AutoEgress    bool     `json:"auto_egress"`
+	Expiration    int64                     `json:"expiration" swaggertype:"primitive,integer" format:"int64"`
+	UsesRemaining int                       `json:"uses_remaining"`
+	Networks      []string                  `json:"networks"`
+	Unlimited     bool                      `json:"unlimited"`
+	Tags          []string                  `json:"tags" validate:"required,dive,min=3,max=32"`
+	Type          KeyType                   `json:"type"`
+	Relay         string                    `json:"relay"`
+	Groups        []TagID                   `json:"groups"`
+	AutoEgress    bool                      `json:"auto_egress"`
+	Constraints   EnrollmentKeyConstraints  `json:"constraints"`
+	Profile       *HostProvisioningProfile  `json:"profile,omitempty"`
+	Attestation   *EnrollmentKeyAttestation `json:"attestation,omitempty"`
+}
+
+// EnrollmentKeyConstraints - additional conditions a registering host must satisfy
+// before an enrollment key can be used; empty fields are not enforced
+type EnrollmentKeyConstraints struct {
+	// SourceCIDRs - ranges the public ip of a registering host must be in, behind a reverse proxy
+	// the server only sees the client's ip when the proxy is listed in TRUSTED_PROXIES
+	SourceCIDRs     []string            `json:"source_cidrs"`
+	HostNamePattern string              `json:"hostname_pattern"`
+	AllowedOS       []string            `json:"allowed_os"`
+	AllowedHours    *EnrollmentKeyHours `json:"allowed_hours,omitempty"`
+}
+
+// HostProvisioningProfile - host and node settings applied to hosts joining networks with an enrollment key;
+// zero values leave the host's own settings untouched
+type HostProvisioningProfile struct {
+	MTU                 int    `json:"mtu"`
+	PersistentKeepalive int    `json:"persistent_keepalive"` // seconds
+	Interface           string `json:"interface"`
+	IsStaticPort        bool   `json:"is_static_port"`
+	ListenPort          int    `json:"listen_port"`
+	AutoUpdate          *bool  `json:"auto_update,omitempty"`
+	Verbosity           *int   `json:"verbosity,omitempty"`
+	HostNameTemplate    string `json:"host_name_template"` // e.g. edge-{mac4}
+	NodeExpiration      int64  `json:"node_expiration"`    // seconds until nodes created on join expire
+	Gateway             bool   `json:"gateway"`
+	FailOver            bool   `json:"fail_over"`
+	Ephemeral           bool   `json:"ephemeral"` // remove the host once it goes offline
+}
+
+// EnrollmentKeyHours - daily window during which an enrollment key may be used,
+// a window with End before Start wraps around midnight
+type EnrollmentKeyHours struct {
+	Start    int    `json:"start"`    // hour of day the window opens, 0-23
+	End      int    `json:"end"`      // hour of day the window closes, 0-24
+	TimeZone string `json:"timezone"` // IANA time zone name, defaults to UTC
 }
 
 // RegisterResponse - the response to a successful enrollment register
@@ -76,6 +126,13 @@ type RegisterResponse struct {
 	RequestedHost Host         `json:"requested_host"`
 }
 
+// RevokedHostNetwork - a host removed from a network when revoking the hosts enrolled with a key
+type RevokedHostNetwork struct {
+	HostID   string `json:"host_id"`
+	HostName string `json:"host_name"`
+	Network  string `json:"network"`
+}
+
 // EnrollmentKey.IsValid - checks if the key is still valid to use
 func (k *EnrollmentKey) IsValid() bool {
 	if k == nil {
@@ -106,8 +163,104 @@ func (k *EnrollmentKey) Validate() error {
 	if len(k.Value) != EnrollmentKeyLength {
 		return fmt.Errorf("%w: length not %d characters", ErrInvalidEnrollmentKeyValue, EnrollmentKeyLength)
 	}
+	if err := k.Constraints.Validate(); err != nil {
+		return err
+	}
+	if err := k.Profile.Validate(); err != nil {
+		return err
+	}
+	if err := k.Attestation.Validate(); err != nil {
+		return err
+	}
 	if !k.IsValid() {
 		return fmt.Errorf("%w: uses remaining: %d, expiration: %s, unlimited: %t", ErrInvalidEnrollmentKey, k.UsesRemaining, k.Expiration, k.Unlimited)
 	}
 	return nil
 }
+
+// EnrollmentKeyConstraints.Validate - checks the constraints are well formed
+func (c *EnrollmentKeyConstraints) Validate() error {
+	for _, cidr := range c.SourceCIDRs {
+		if _, _, err := net.ParseCIDR(cidr); err != nil {
+			return fmt.Errorf("%w: invalid source cidr %s", ErrInvalidKeyConstraints, cidr)
+		}
+	}
+	if c.HostNamePattern != "" {
+		if _, err := regexp.Compile(c.HostNamePattern); err != nil {
+			return fmt.Errorf("%w: invalid hostname pattern: %s", ErrInvalidKeyConstraints, err.Error())
+		}
+	}
+	if c.AllowedHours != nil {
+		h := c.AllowedHours
+		if h.Start < 0 || h.Start > 23 || h.End < 0 || h.End > 24 || h.Start == h.End {
+			return fmt.Errorf("%w: invalid allowed hours %d-%d", ErrInvalidKeyConstraints, h.Start, h.End)
+		}
+		if _, err := h.Location(); err != nil {
+			return fmt.Errorf("%w: invalid time zone %s", ErrInvalidKeyConstraints, h.TimeZone)
+		}
+	}
+	return nil
+}
+
+// HostProvisioningProfile.Validate - checks the profile settings are in range, a nil profile is valid
+func (p *HostProvisioningProfile) Validate() error {
+	if p == nil {
+		return nil
+	}
+	if p.MTU < 0 || p.MTU > 65535 {
+		return fmt.Errorf("%w: invalid mtu %d", ErrInvalidKeyProfile, p.MTU)
+	}
+	if p.ListenPort < 0 || p.ListenPort > 65535 {
+		return fmt.Errorf("%w: invalid listen port %d", ErrInvalidKeyProfile, p.ListenPort)
+	}
+	if p.PersistentKeepalive < 0 || p.NodeExpiration < 0 {
+		return fmt.Errorf("%w: durations must not be negative", ErrInvalidKeyProfile)
+	}
+	if p.Verbosity != nil && (*p.Verbosity < 0 || *p.Verbosity > 4) {
+		return fmt.Errorf("%w: invalid verbosity %d", ErrInvalidKeyProfile, *p.Verbosity)
+	}
+	if p.Interface != "" && (!interfaceNameRegex.MatchString(p.Interface) || p.Interface == "." || p.Interface == "..") {
+		return fmt.Errorf("%w: invalid interface name %s", ErrInvalidKeyProfile, p.Interface)
+	}
+	return nil
+}
+
+// EnrollmentKeyAttestation.Validate - checks the attestation rules, a nil attestation is valid
+func (a *EnrollmentKeyAttestation) Validate() error {
+	if a == nil {
+		return nil
+	}
+	switch a.Provider {
+	case AWSCloud, GCPCloud, AzureCloud:
+	default:
+		return fmt.Errorf("%w: unknown provider %s", ErrInvalidKeyAttestation, a.Provider)
+	}
+	if len(a.Accounts) == 0 {
+		return fmt.Errorf("%w: at least one account is required", ErrInvalidKeyAttestation)
+	}
+	if a.Provider == GCPCloud && a.Audience == "" {
+		return fmt.Errorf("%w: an audience is required for gcp", ErrInvalidKeyAttestation)
+	}
+	return nil
+}
+
+// EnrollmentKeyHours.Location - returns the time zone of the window
+func (h *EnrollmentKeyHours) Location() (*time.Location, error) {
+	if h.TimeZone == "" {
+		return time.UTC, nil
+	}
+	return time.LoadLocation(h.TimeZone)
+}
+
+// EnrollmentKeyHours.Contains - checks if the given time falls inside the window
+func (h *EnrollmentKeyHours) Contains(t time.Time) bool {
+	loc, err := h.Location()
+	if err != nil {
+		return false
+	}
+	hour := t.In(loc).Hour()
+	if h.Start < h.End {
+		return hour >= h.Start && hour < h.End
+	}
+	return hour >= h.Start || hour < h.End
+}
@@ -3,21 +3,23 @@ package models
 type Action string
 
 const (
-	Create            Action = "CREATE"
-	Update            Action = "UPDATE"
-	Delete            Action = "DELETE"
-	DeleteAll         Action = "DELETE_ALL"
-	Login             Action = "LOGIN"
-	LogOut            Action = "LOGOUT"
-	Connect           Action = "CONNECT"
-	Sync              Action = "SYNC"
-	RefreshKey        Action = "REFRESH_KEY"
-	RefreshAllKeys    Action = "REFRESH_ALL_KEYS"
-	SyncAll           Action = "SYNC_ALL"
-	UpgradeAll        Action = "UPGRADE_ALL"
-	Disconnect        Action = "DISCONNECT"
-	JoinHostToNet     Action = "JOIN_HOST_TO_NETWORK"
-	RemoveHostFromNet Action = "REMOVE_HOST_FROM_NETWORK"
+	Create              Action = "CREATE"
+	Update              Action = "UPDATE"
+	Delete              Action = "DELETE"
+	DeleteAll           Action = "DELETE_ALL"
+	Login               Action = "LOGIN"
+	LogOut              Action = "LOGOUT"
+	Connect             Action = "CONNECT"
+	Sync                Action = "SYNC"
+	RefreshKey          Action = "REFRESH_KEY"
+	RefreshAllKeys      Action = "REFRESH_ALL_KEYS"
+	SyncAll             Action = "SYNC_ALL"
+	UpgradeAll          Action = "UPGRADE_ALL"
+	Disconnect          Action = "DISCONNECT"
+	JoinHostToNet       Action = "JOIN_HOST_TO_NETWORK"
+	RemoveHostFromNet   Action = "REMOVE_HOST_FROM_NETWORK"
+	EnrollmentViolation Action = "ENROLLMENT_VIOLATION"
+	Download            Action = "DOWNLOAD"
 )
 
 type SubjectType string
@@ -40,6 +42,8 @@ const (
 	DashboardSub       SubjectType = "DASHBOARD"
 	EnrollmentKeySub   SubjectType = "ENROLLMENT_KEY"
 	ClientAppSub       SubjectType = "CLIENT-APP"
+	ConfigLinkSub      SubjectType = "CONFIG_LINK"
+	DNSFilterSub       SubjectType = "DNS_FILTER"
 )
 
 func (sub SubjectType) String() string {
@@ -1,6 +1,10 @@
 package models
 
-import "sync"
+import (
+	"errors"
+	"sync"
+	"time"
+)
 
 // ExtClient - struct for external clients
 type ExtClient struct {
@@ -27,6 +31,17 @@ type ExtClient struct {
 	DeviceName             string              `json:"device_name"`
 	PublicEndpoint         string              `json:"public_endpoint"`
 	Country                string              `json:"country"`
+	CreatedAt              time.Time           `json:"created_at"`
+	LastHandshake          time.Time           `json:"last_handshake"`
+	Expiry                 ExtClientExpiry     `json:"expiry"`
+	KeyRotation            KeyRotationPolicy   `json:"key_rotation"`
+	KeyRotatedAt           time.Time           `json:"key_rotated_at"`
+	PreviousPublicKey      string              `json:"previous_publickey,omitempty"`
+	PreviousKeyExpiresAt   time.Time           `json:"previous_key_expires_at,omitempty"`
+	Quota                  ExtClientQuota      `json:"quota"`
+	RateLimit              ExtClientRateLimit  `json:"rate_limit"`
+	QuotaSuspendedUntil    time.Time           `json:"quota_suspended_until,omitempty"`
+	Location               GeoLocation         `json:"location"`
 	Mutex                  *sync.Mutex         `json:"-"`
 }
 
@@ -47,6 +62,138 @@ type CustomExtClient struct {
 	IsAlreadyConnectedToInetGw bool                `json:"is_already_connected_to_inet_gw"`
 	PublicEndpoint             string              `json:"public_endpoint"`
 	Country                    string              `json:"country"`
+	Expiry                     *ExtClientExpiry    `json:"expiry,omitempty"`
+	KeyRotation                *KeyRotationPolicy  `json:"key_rotation,omitempty"`
+	Quota                      *ExtClientQuota     `json:"quota,omitempty"`
+	RateLimit                  *ExtClientRateLimit `json:"rate_limit,omitempty"`
+}
+
+// ExtClientExpiryAction - what happens to an ext client once it expires
+type ExtClientExpiryAction string
+
+const (
+	// ExtClientExpiryDisable - the expired client is disabled
+	ExtClientExpiryDisable ExtClientExpiryAction = "disable"
+	// ExtClientExpiryDelete - the expired client is deleted
+	ExtClientExpiryDelete ExtClientExpiryAction = "delete"
+)
+
+// ErrInvalidExtClientExpiry - returned for malformed ext client expiry settings
+var ErrInvalidExtClientExpiry = errors.New("invalid ext client expiry")
+
+// ExtClientExpiry - lifetime of an ext client, the earliest of the configured limits applies
+type ExtClientExpiry struct {
+	ExpiresAt time.Time `json:"expires_at"`
+	// TTL - seconds the client lives after creation
+	TTL int64 `json:"ttl"`
+	// InactivityTimeout - seconds without a handshake, counted from creation if the client never connected
+	InactivityTimeout int64                 `json:"inactivity_timeout"`
+	Action            ExtClientExpiryAction `json:"action"`
+	// NotifyBefore - seconds before expiry at which the owner is emailed, 0 disables the warning
+	NotifyBefore int64     `json:"notify_before"`
+	NotifiedAt   time.Time `json:"notified_at,omitempty"`
+}
+
+// Validate - checks the expiry settings are usable
+func (e *ExtClientExpiry) Validate() error {
+	if e == nil {
+		return nil
+	}
+	if e.TTL < 0 || e.InactivityTimeout < 0 || e.NotifyBefore < 0 {
+		return ErrInvalidExtClientExpiry
+	}
+	switch e.Action {
+	case "", ExtClientExpiryDisable, ExtClientExpiryDelete:
+	default:
+		return ErrInvalidExtClientExpiry
+	}
+	return nil
+}
+
+// IsSet - checks if any lifetime limit is configured
+func (e *ExtClientExpiry) IsSet() bool {
+	return !e.ExpiresAt.IsZero() || e.TTL > 0 || e.InactivityTimeout > 0
+}
+
+// ErrInvalidKeyRotationPolicy - returned for malformed key rotation policies
+var ErrInvalidKeyRotationPolicy = errors.New("invalid key rotation policy")
+
+// KeyRotationPolicy - how often an ext client keypair is replaced
+type KeyRotationPolicy struct {
+	// Interval - seconds between rotations, 0 disables rotation
+	Interval int64 `json:"interval"`
+	// Overlap - seconds the previous public key stays accepted on the gateway after a rotation
+	Overlap int64 `json:"overlap"`
+}
+
+// Validate - checks the rotation policy is usable
+func (p *KeyRotationPolicy) Validate() error {
+	if p == nil {
+		return nil
+	}
+	if p.Interval < 0 || p.Overlap < 0 || (p.Interval > 0 && p.Overlap >= p.Interval) {
+		return ErrInvalidKeyRotationPolicy
+	}
+	return nil
+}
+
+// QuotaPeriod - the period a traffic quota is counted over
+type QuotaPeriod string
+
+const (
+	// QuotaPeriodDay - the quota resets at midnight UTC
+	QuotaPeriodDay QuotaPeriod = "day"
+	// QuotaPeriodMonth - the quota resets on the first of the month UTC
+	QuotaPeriodMonth QuotaPeriod = "month"
+)
+
+// ErrInvalidExtClientQuota - returned for malformed quotas and rate limits
+var ErrInvalidExtClientQuota = errors.New("invalid ext client quota")
+
+// ExtClientQuota - the traffic an ext client or user may pull through the gateways per period
+type ExtClientQuota struct {
+	// Bytes - bytes sent and received per period, 0 disables the quota
+	Bytes  int64       `json:"bytes"`
+	Period QuotaPeriod `json:"period"`
+}
+
+// Validate - checks the quota is usable
+func (q *ExtClientQuota) Validate() error {
+	if q == nil {
+		return nil
+	}
+	if q.Bytes < 0 {
+		return ErrInvalidExtClientQuota
+	}
+	switch q.Period {
+	case QuotaPeriodDay, QuotaPeriodMonth:
+	case "":
+		if q.Bytes > 0 {
+			return ErrInvalidExtClientQuota
+		}
+	default:
+		return ErrInvalidExtClientQuota
+	}
+	return nil
+}
+
+// ExtClientRateLimit - the bandwidth ingress gateways shape an ext client's traffic to
+type ExtClientRateLimit struct {
+	// Upload - kbit/s from the client, 0 is unlimited
+	Upload int64 `json:"upload"`
+	// Download - kbit/s to the client, 0 is unlimited
+	Download int64 `json:"download"`
+}
+
+// Validate - checks the rate limit is usable
+func (l *ExtClientRateLimit) Validate() error {
+	if l == nil {
+		return nil
+	}
+	if l.Upload < 0 || l.Download < 0 {
+		return ErrInvalidExtClientQuota
+	}
+	return nil
 }
 
 func (ext *ExtClient) ConvertToStaticNode() Node {
@@ -66,3 +213,40 @@ func (ext *ExtClient) ConvertToStaticNode() Node {
 		Mutex:      ext.Mutex,
 	}
 }
+
+// ExtClientConfigLinkReq - request to mint a download link for an ext client config
+type ExtClientConfigLinkReq struct {
+	Format string `json:"format"`
+	// TTL - seconds the link stays valid
+	TTL int64 `json:"ttl"`
+	// MaxDownloads - 1 for a single use link, 0 allows any number of downloads until the link expires
+	MaxDownloads int `json:"max_downloads"`
+	// Recipient - email address the link is sent to, optional
+	Recipient string `json:"recipient"`
+}
+
+// ExtClientConfigLinkResp - a minted config download link
+type ExtClientConfigLinkResp struct {
+	ID        string    `json:"id"`
+	URL       string    `json:"url"`
+	ExpiresAt time.Time `json:"expires_at"`
+	Emailed   bool      `json:"emailed"`
+}
+
+// ExtClientConf - the resolved wireguard settings of an ext client, used to render its config
+type ExtClientConf struct {
+	ClientID            string   `json:"clientid"`
+	Network             string   `json:"network"`
+	Addresses           []string `json:"addresses"` // client addresses in CIDR notation
+	PrivateKey          string   `json:"privatekey"`
+	MTU                 int      `json:"mtu"`
+	DNS                 []string `json:"dns"`
+	PostUp              []string `json:"postup"`
+	PostDown            []string `json:"postdown"`
+	PeerPublicKey       string   `json:"peer_publickey"`
+	PresharedKey        string   `json:"presharedkey,omitempty"`
+	AllowedIPs          []string `json:"allowed_ips"`
+	EndpointHost        string   `json:"endpoint_host"` // without brackets for ipv6
+	EndpointPort        int      `json:"endpoint_port"`
+	PersistentKeepalive int      `json:"persistentkeepalive"`
+}
@@ -0,0 +1,69 @@
+package models
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// GeoCountryTagPrefix - prefix of the tags the server assigns by country, e.g. geo-country-de
+	GeoCountryTagPrefix = "geo-country-"
+	// GeoASNTagPrefix - prefix of the tags the server assigns by autonomous system, e.g. geo-asn-3320
+	GeoASNTagPrefix = "geo-asn-"
+)
+
+// GeoLocation - where a public endpoint is located according to the GeoIP database
+type GeoLocation struct {
+	IP string `json:"ip" yaml:"ip"`
+	// Country - ISO 3166-1 alpha-2 code
+	Country   string    `json:"country" yaml:"country"`
+	City      string    `json:"city" yaml:"city"`
+	ASN       uint      `json:"asn" yaml:"asn"`
+	ASOrg     string    `json:"as_org" yaml:"as_org"`
+	UpdatedAt time.Time `json:"updated_at" yaml:"updated_at"`
+}
+
+// SameAs - checks if two locations differ only in when they were looked up
+func (l GeoLocation) SameAs(other GeoLocation) bool {
+	l.UpdatedAt, other.UpdatedAt = time.Time{}, time.Time{}
+	return l == other
+}
+
+// GeoFilter - filters devices by the location of their public endpoint
+type GeoFilter struct {
+	Country string
+	City    string
+	ASN     uint
+}
+
+// IsSet - checks if the filter restricts anything
+func (f GeoFilter) IsSet() bool {
+	return f != GeoFilter{}
+}
+
+// Match - checks if a location passes the filter
+func (f GeoFilter) Match(l GeoLocation) bool {
+	if f.Country != "" && !strings.EqualFold(f.Country, l.Country) {
+		return false
+	}
+	if f.City != "" && !strings.EqualFold(f.City, l.City) {
+		return false
+	}
+	if f.ASN != 0 && f.ASN != l.ASN {
+		return false
+	}
+	return true
+}
+
+// GeoTagNames - the names of the tags a location assigns
+func (l GeoLocation) GeoTagNames() []string {
+	var names []string
+	if l.Country != "" {
+		names = append(names, GeoCountryTagPrefix+strings.ToLower(l.Country))
+	}
+	if l.ASN != 0 {
+		names = append(names, GeoASNTagPrefix+strconv.FormatUint(uint64(l.ASN), 10))
+	}
+	return names
+}
@@ -69,10 +69,13 @@ type Host struct {
 	IsStaticPort        bool             `json:"isstaticport"            yaml:"isstaticport"`
 	IsStatic            bool             `json:"isstatic"        yaml:"isstatic"`
 	IsDefault           bool             `json:"isdefault"               yaml:"isdefault"`
+	IsEphemeral         bool             `json:"isephemeral"             yaml:"isephemeral"`
 	DNS                 string           `json:"dns_status"               yaml:"dns_status"`
 	NatType             string           `json:"nat_type,omitempty"      yaml:"nat_type,omitempty"`
 	TurnEndpoint        *netip.AddrPort  `json:"turn_endpoint,omitempty" yaml:"turn_endpoint,omitempty"`
 	PersistentKeepalive time.Duration    `json:"persistentkeepalive" swaggertype:"primitive,integer" format:"int64" yaml:"persistentkeepalive"`
+	Location            GeoLocation      `json:"location"                yaml:"location"`
+	LastCheckIn         time.Time        `json:"lastcheckin"             yaml:"lastcheckin"`
 }
 
 // FormatBool converts a boolean to a [yes|no] string
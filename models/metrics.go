@@ -25,6 +25,7 @@ type Metric struct {
 	ActualUptime      time.Duration `json:"actualuptime" swaggertype:"primitive,integer" format:"int64" bson:"actualuptime" yaml:"actualuptime"`
 	PercentUp         float64       `json:"percentup" bson:"percentup" yaml:"percentup"`
 	Connected         bool          `json:"connected" bson:"connected" yaml:"connected"`
+	Endpoint          string        `json:"endpoint,omitempty" bson:"endpoint" yaml:"endpoint"`
 }
 
 // IDandAddr - struct to hold ID and primary Address
@@ -61,3 +62,27 @@ type MetricsMap map[string]Metrics
 type NetworkMetrics struct {
 	Nodes MetricsMap `json:"nodes" bson:"nodes" yaml:"nodes"`
 }
+
+// MetricsHistoryPoint - the latency, throughput and uptime of a time bucket of the metrics history
+type MetricsHistoryPoint struct {
+	Timestamp time.Time `json:"timestamp"`
+	// Latency - average latency in milliseconds while connected
+	Latency float64 `json:"latency"`
+	// ReceivedRate and SentRate - throughput in bytes per second
+	ReceivedRate float64 `json:"received_rate"`
+	SentRate     float64 `json:"sent_rate"`
+	// Uptime - share of the samples the peers were connected, between 0 and 1
+	Uptime float64 `json:"uptime"`
+}
+
+// MetricsHistory - the metrics series of a node pair, node, gateway or network over a time range
+type MetricsHistory struct {
+	Network    string                `json:"network"`
+	NodeID     string                `json:"node_id,omitempty"`
+	PeerID     string                `json:"peer_id,omitempty"`
+	Gateway    bool                  `json:"gateway,omitempty"`
+	Resolution string                `json:"resolution"`
+	From       time.Time             `json:"from"`
+	To         time.Time             `json:"to"`
+	Points     []MetricsHistoryPoint `json:"points"`
+}
@@ -28,6 +28,7 @@ type HostPeerUpdate struct {
 	FwUpdate        FwUpdate              `json:"fw_update"`
 	ReplacePeers    bool                  `json:"replace_peers"`
 	NameServers     []string              `json:"name_servers"`
+	DNSForwardRules []DNSForwardRule      `json:"dns_forward_rules"`
 	ServerConfig
 	OldPeerUpdateFields
 }
@@ -57,6 +58,14 @@ type IngressInfo struct {
 	EgressRanges6 []net.IPNet `json:"egress_ranges6"`
 }
 
+// ExtClientRateLimitRule - the bandwidth limit of an ext client on its gateway
+type ExtClientRateLimitRule struct {
+	ClientID string `json:"client_id"`
+	Address  net.IP `json:"address"`
+	Address6 net.IP `json:"address6"`
+	ExtClientRateLimit
+}
+
 // EgressInfo - struct for egress info
 type EgressInfo struct {
 	EgressID      string               `json:"egress_id" yaml:"egress_id"`
@@ -113,6 +122,9 @@ type FwUpdate struct {
 	EgressInfo      map[string]EgressInfo  `json:"egress_info"`
 	IngressInfo     map[string]IngressInfo `json:"ingress_info"`
 	AclRules        map[string]AclRule     `json:"acl_rules"`
+	// IngressRateLimits - the bandwidth limits of the ext clients by gateway node id, sent apart from
+	// IngressInfo as an entry there means the gateway's ACLs are restricted
+	IngressRateLimits map[string][]ExtClientRateLimitRule `json:"ingress_rate_limits,omitempty"`
 }
 
 // FailOverMeReq - struct for failover req
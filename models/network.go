@@ -25,6 +25,20 @@ type Network struct {
 	DefaultMTU          int32    `json:"defaultmtu" bson:"defaultmtu"`
 	DefaultACL          string   `json:"defaultacl" bson:"defaultacl" yaml:"defaultacl" validate:"checkyesorno"`
 	NameServers         []string `json:"dns_nameservers"`
+	PresharedKeys       string   `json:"presharedkeys" bson:"presharedkeys" validate:"omitempty,checkyesorno"`
+	// DNSForwardRules - domains resolved by their own nameservers instead of the upstream resolvers
+	DNSForwardRules []DNSForwardRule `json:"dns_forward_rules" bson:"dns_forward_rules"`
+	// ExtClientKeyRotation - default key rotation of the network's ext clients
+	ExtClientKeyRotation KeyRotationPolicy `json:"extclient_key_rotation" bson:"extclient_key_rotation"`
+}
+
+// NetworkUpdate - a network update request, the settings added after the update api was in use
+// are pointers so requests that leave them out keep the network's current values
+type NetworkUpdate struct {
+	Network
+	PresharedKeys        *string            `json:"presharedkeys"`
+	DNSForwardRules      *[]DNSForwardRule  `json:"dns_forward_rules"`
+	ExtClientKeyRotation *KeyRotationPolicy `json:"extclient_key_rotation"`
 }
 
 // SaveData - sensitive fields of a network that should be kept the same
@@ -92,6 +106,11 @@ func (network *Network) SetDefaults() (upsert bool) {
 		network.DefaultACL = "yes"
 		upsert = true
 	}
+
+	if network.PresharedKeys == "" {
+		network.PresharedKeys = "no"
+		upsert = true
+	}
 	return
 }
 
@@ -44,4 +44,8 @@ type ServerSettings struct {
 	TextSize                       string   `json:"text_size"`
 	ReducedMotion                  bool     `json:"reduced_motion"`
 	AuditLogsRetentionPeriodInDays int      `json:"audit_logs_retention_period"`
+	MetricsHistoryResolution       string   `json:"metrics_history_resolution"`
+	MetricsRawRetentionInHours     int      `json:"metrics_raw_retention"`
+	Metrics5mRetentionInDays       int      `json:"metrics_5m_retention"`
+	Metrics1hRetentionInDays       int      `json:"metrics_1h_retention"`
 }
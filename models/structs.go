@@ -1,6 +1,7 @@
 package models
 
 import (
+	"errors"
 	"net"
 	"strings"
 	"time"
@@ -39,6 +40,7 @@ type UserRemoteGws struct {
 	IsInternetGateway bool       `json:"is_internet_gateway"`
 	GwClient          ExtClient  `json:"gw_client"`
 	GwPeerPublicKey   string     `json:"gw_peer_public_key"`
+	GwPresharedKey    string     `json:"gw_preshared_key,omitempty"`
 	GwListenPort      int        `json:"gw_listen_port"`
 	Metadata          string     `json:"metadata"`
 	AllowedEndpoints  []string   `json:"allowed_endpoints"`
@@ -183,11 +185,31 @@ type HostRelayRequest struct {
 
 // IngressRequest - ingress request struct
 type IngressRequest struct {
-	ExtclientDNS        string `json:"extclientdns"`
-	IsInternetGateway   bool   `json:"is_internet_gw"`
-	Metadata            string `json:"metadata"`
-	PersistentKeepalive int32  `json:"persistentkeepalive"`
-	MTU                 int32  `json:"mtu"`
+	ExtclientDNS        string           `json:"extclientdns"`
+	IsInternetGateway   bool             `json:"is_internet_gw"`
+	Metadata            string           `json:"metadata"`
+	PersistentKeepalive int32            `json:"persistentkeepalive"`
+	MTU                 int32            `json:"mtu"`
+	SessionPolicy       RacSessionPolicy `json:"session_policy"`
+}
+
+// ErrInvalidRacSessionPolicy - returned for malformed gateway session policies
+var ErrInvalidRacSessionPolicy = errors.New("invalid remote access session policy")
+
+// RacSessionPolicy - how remote access configs of a gateway are issued, the zero value issues long-lived configs
+type RacSessionPolicy struct {
+	// ReauthWindow - minutes since the user's last login within which configs are issued, 0 accepts any valid token
+	ReauthWindow int `json:"reauth_window"`
+	// SessionDuration - hours an issued config stays valid unless it is renewed, 0 never expires it
+	SessionDuration int `json:"session_duration"`
+}
+
+// Validate - checks the session policy is usable
+func (p *RacSessionPolicy) Validate() error {
+	if p.ReauthWindow < 0 || p.SessionDuration < 0 || p.SessionDuration > 24 {
+		return ErrInvalidRacSessionPolicy
+	}
+	return nil
 }
 
 // InetNodeReq - exit node request struct
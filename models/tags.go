@@ -27,13 +27,23 @@ type Tag struct {
 	ColorCode string    `json:"color_code"`
 	CreatedBy string    `json:"created_by"`
 	CreatedAt time.Time `json:"created_at"`
+	// DNSServices - services published as SRV records under the DNS name of the tag
+	DNSServices []TagDNSService `json:"dns_services,omitempty"`
+}
+
+// TagDNSService - a service the nodes of a tag offer, resolvable as _<service>._<protocol>.<tag>.<network>
+type TagDNSService struct {
+	Service  string `json:"service"`
+	Protocol string `json:"protocol"`
+	Port     uint16 `json:"port"`
 }
 
 type CreateTagReq struct {
-	TagName     string    `json:"tag_name"`
-	Network     NetworkID `json:"network"`
-	ColorCode   string    `json:"color_code"`
-	TaggedNodes []ApiNode `json:"tagged_nodes"`
+	TagName     string          `json:"tag_name"`
+	Network     NetworkID       `json:"network"`
+	ColorCode   string          `json:"color_code"`
+	TaggedNodes []ApiNode       `json:"tagged_nodes"`
+	DNSServices []TagDNSService `json:"dns_services"`
 }
 
 type TagListResp struct {
@@ -137,6 +137,30 @@ type UserRolePermissionTemplate struct {
 	NetworkID           NetworkID                                   `json:"network_id"`
 	NetworkLevelAccess  map[RsrcType]map[RsrcID]RsrcPermissionScope `json:"network_level_access"`
 	GlobalLevelAccess   map[RsrcType]map[RsrcID]RsrcPermissionScope `json:"global_level_access"`
+	DeviceLimits        ExtClientLimits                             `json:"device_limits"`
+}
+
+// ExtClientLimits - the maximum number of ext clients a user may own, 0 leaves a scope unset
+type ExtClientLimits struct {
+	PerUser    int `json:"per_user"`
+	PerNetwork int `json:"per_network"`
+	PerGateway int `json:"per_gateway"`
+}
+
+// RacDevice - the ext clients a user created from one device
+type RacDevice struct {
+	ID                   string      `json:"id"` // remote access client id, the client id for configs created without one
+	RemoteAccessClientID string      `json:"remote_access_client_id"`
+	DeviceName           string      `json:"device_name"`
+	Os                   string      `json:"os"`
+	Enabled              bool        `json:"enabled"`
+	Clients              []ExtClient `json:"clients"`
+}
+
+// RacDeviceUpdate - the changes a user may apply to their own device
+type RacDeviceUpdate struct {
+	DeviceName string `json:"device_name"`
+	Enabled    *bool  `json:"enabled"`
 }
 
 type CreateGroupReq struct {
@@ -151,6 +175,7 @@ type UserGroup struct {
 	Name                       string                                `json:"name"`
 	NetworkRoles               map[NetworkID]map[UserRoleID]struct{} `json:"network_roles"`
 	MetaData                   string                                `json:"meta_data"`
+	DeviceLimits               ExtClientLimits                       `json:"device_limits"`
 }
 
 // User struct - struct for Users
@@ -168,6 +193,16 @@ type User struct {
 	PlatformRoleID             UserRoleID                            `json:"platform_role_id"`
 	NetworkRoles               map[NetworkID]map[UserRoleID]struct{} `json:"network_roles"`
 	LastLoginTime              time.Time                             `json:"last_login_time"`
+	ExtClientQuota             ExtClientQuota                        `json:"extclient_quota"`
+	ExtClientRateLimit         ExtClientRateLimit                    `json:"extclient_rate_limit"`
+}
+
+// UserUpdate - a user update request, the ext client limits are pointers
+// so profile updates that leave them out keep the limits an admin set
+type UserUpdate struct {
+	User
+	ExtClientQuota     *ExtClientQuota     `json:"extclient_quota"`
+	ExtClientRateLimit *ExtClientRateLimit `json:"extclient_rate_limit"`
 }
 
 type ReturnUserWithRolesAndGroups struct {
@@ -190,6 +225,8 @@ type ReturnUser struct {
 	PlatformRoleID             UserRoleID                            `json:"platform_role_id"`
 	NetworkRoles               map[NetworkID]map[UserRoleID]struct{} `json:"network_roles"`
 	LastLoginTime              time.Time                             `json:"last_login_time"`
+	ExtClientQuota             ExtClientQuota                        `json:"extclient_quota"`
+	ExtClientRateLimit         ExtClientRateLimit                    `json:"extclient_rate_limit"`
 }
 
 // UserAuthParams - user auth params struct
@@ -2,6 +2,7 @@ package mq
 
 import (
 	"encoding/json"
+	"time"
 
 	mqtt "github.com/eclipse/paho.mqtt.golang"
 	"github.com/google/uuid"
@@ -236,6 +237,32 @@ func ClientPeerUpdate(client mqtt.Client, msg mqtt.Message) {
 	slog.Info("sent peer updates after signal received from", "id", id)
 }
 
+// disconnectGeoBlockedHost - disconnects the nodes of a host located in a blocked country,
+// returns true if any node was disconnected
+func disconnectGeoBlockedHost(host *models.Host) bool {
+	disconnected := false
+	for _, nodeID := range host.Nodes {
+		node, err := logic.GetNodeByID(nodeID)
+		if err != nil || !node.Connected {
+			continue
+		}
+		slog.Warn("disconnecting node of a host in a blocked country", "host", host.Name, "hostid", host.ID, "nodeid", node.ID, "country", host.Location.Country)
+		node.Connected = false
+		if err := logic.UpsertNode(&node); err != nil {
+			slog.Error("failed to disconnect node of a host in a blocked country", "nodeid", node.ID, "error", err)
+			continue
+		}
+		if err := NodeUpdate(&node); err != nil {
+			slog.Warn("failed to inform host of disconnected node", "host", host.Name, "nodeid", node.ID, "error", err)
+		}
+		if err := PublishDeletedNodePeerUpdate(&node); err != nil {
+			slog.Warn("failed to remove disconnected node from peers", "nodeid", node.ID, "error", err)
+		}
+		disconnected = true
+	}
+	return disconnected
+}
+
 func HandleHostCheckin(h, currentHost *models.Host) bool {
 	if h == nil {
 		return false
@@ -265,9 +292,13 @@ func HandleHostCheckin(h, currentHost *models.Host) bool {
 		h.Interfaces[i].AddressString = h.Interfaces[i].Address.String()
 	}
 	/// version or firewall in use change does not require a peerUpdate
-	if h.Version != currentHost.Version || h.FirewallInUse != currentHost.FirewallInUse {
+	// ephemeral hosts track their check-ins to be cleaned up when they have no nodes
+	if h.Version != currentHost.Version || h.FirewallInUse != currentHost.FirewallInUse || currentHost.IsEphemeral {
 		currentHost.FirewallInUse = h.FirewallInUse
 		currentHost.Version = h.Version
+		if currentHost.IsEphemeral {
+			currentHost.LastCheckIn = time.Now().UTC()
+		}
 		if err := logic.UpsertHost(currentHost); err != nil {
 			slog.Error("failed to update host after check-in", "name", h.Name, "id", h.ID, "error", err)
 			return false
@@ -299,6 +330,16 @@ func HandleHostCheckin(h, currentHost *models.Host) bool {
 		slog.Info("updated host after check-in", "name", currentHost.Name, "id", currentHost.ID)
 	}
 
+	if logic.UpdateHostLocation(currentHost) {
+		slog.Info("updated host location after check-in", "name", currentHost.Name, "id", currentHost.ID, "country", currentHost.Location.Country)
+		// the geo tags of the host's nodes changed
+		ifaceDelta = true
+	}
+	// checked on every check-in so nodes reconnected from a blocked country are dropped again
+	if logic.IsGeoBlocked(currentHost.Location) && disconnectGeoBlockedHost(currentHost) {
+		ifaceDelta = true
+	}
+
 	slog.Info("check-in processed for host", "name", h.Name, "id", h.ID)
 	return ifaceDelta
 }
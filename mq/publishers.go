@@ -15,6 +15,12 @@ import (
 	"golang.org/x/exp/slog"
 )
 
+func init() {
+	logic.PublishPeerUpdate = PublishPeerUpdate
+	logic.PublishDeletedClientPeerUpdate = PublishDeletedClientPeerUpdate
+	logic.PublishMqUpdatesForDeletedNode = PublishMqUpdatesForDeletedNode
+}
+
 // PublishPeerUpdate --- determines and publishes a peer update to all the hosts
 func PublishPeerUpdate(replacePeers bool) error {
 	if !servercfg.IsMessageQueueBackend() {
@@ -215,9 +221,11 @@ func PushMetricsToExporter(metrics models.Metrics) error {
 		return errors.New("failed to marshal metrics: " + err.Error())
 	}
 	if mqclient == nil || !mqclient.IsConnectionOpen() {
+		publishFailed("metrics_exporter")
 		return errors.New("cannot publish ... mqclient not connected")
 	}
 	if token := mqclient.Publish("metrics_exporter", 0, true, data); !token.WaitTimeout(MQ_TIMEOUT*time.Second) || token.Error() != nil {
+		publishFailed("metrics_exporter")
 		var err error
 		if token.Error() == nil {
 			err = errors.New("connection timeout")
@@ -273,16 +281,24 @@ func sendDNSSync() error {
 	return err
 }
 
+// PushSyncDNS - publishes the entries of a network for hosts to write to their hosts file,
+// aliases are sent as the addresses they point at
 func PushSyncDNS(dnsEntries []models.DNSEntry) error {
 	logger.Log(2, "----> Pushing Sync DNS")
+	dnsEntries = logic.GetHostsDNSEntries(dnsEntries)
+	if len(dnsEntries) == 0 {
+		return nil
+	}
 	data, err := json.Marshal(dnsEntries)
 	if err != nil {
 		return errors.New("failed to marshal DNS entries: " + err.Error())
 	}
 	if mqclient == nil || !mqclient.IsConnectionOpen() {
+		publishFailed("host/dns/sync")
 		return errors.New("cannot publish ... mqclient not connected")
 	}
 	if token := mqclient.Publish(fmt.Sprintf("host/dns/sync/%s", dnsEntries[0].Network), 0, true, data); !token.WaitTimeout(MQ_TIMEOUT*time.Second) || token.Error() != nil {
+		publishFailed("host/dns/sync")
 		var err error
 		if token.Error() == nil {
 			err = errors.New("connection timeout")
@@ -14,6 +14,7 @@ import (
 	"time"
 
 	"github.com/gravitl/netmaker/logic"
+	"github.com/gravitl/netmaker/metrics"
 	"github.com/gravitl/netmaker/models"
 	"github.com/gravitl/netmaker/netclient/ncutils"
 	"golang.org/x/exp/slog"
@@ -159,10 +160,12 @@ func publish(host *models.Host, dest string, msg []byte) error {
 	}
 
 	if mqclient == nil || !mqclient.IsConnectionOpen() {
+		publishFailed(dest)
 		return errors.New("cannot publish ... mqclient not connected")
 	}
 
 	if token := mqclient.Publish(dest, 0, true, encrypted); !token.WaitTimeout(MQ_TIMEOUT*time.Second) || token.Error() != nil {
+		publishFailed(dest)
 		var err error
 		if token.Error() == nil {
 			err = errors.New("connection timeout")
@@ -175,6 +178,15 @@ func publish(host *models.Host, dest string, msg []byte) error {
 	return nil
 }
 
+// publishFailed - counts a failed publish by the leading parts of its topic, the host and node ids are left out
+func publishFailed(topic string) {
+	parts := strings.Split(topic, "/")
+	if len(parts) > 2 {
+		parts = parts[:2]
+	}
+	metrics.MQPublishFailures.WithLabelValues(strings.Join(parts, "/")).Inc()
+}
+
 // decodes a message queue topic and returns the embedded node.ID
 func GetID(topic string) (string, error) {
 	parts := strings.Split(topic, "/")
@@ -0,0 +1,74 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/gravitl/netmaker/db"
+	"github.com/gravitl/netmaker/logic"
+	"github.com/gravitl/netmaker/models"
+	"github.com/gravitl/netmaker/schema"
+)
+
+func ExtClientSessionHandlers(r *mux.Router) {
+	r.HandleFunc("/api/v1/extclient/sessions", logic.SecurityCheck(true, http.HandlerFunc(listExtClientSessions))).Methods(http.MethodGet)
+}
+
+// @Summary     list ext client sessions, filtered by client, network, user or gateway.
+// @Router      /api/v1/extclient/sessions [get]
+// @Tags        Remote Access Client
+// @Param       client_id query string false "ext client id"
+// @Param       network query string false "network of the ext client"
+// @Param       username query string false "owner of the ext clients"
+// @Param       gateway_id query string false "gateway node id"
+// @Param       from_date query string false "sessions last seen connected after (RFC3339)"
+// @Param       to_date query string false "sessions started before (RFC3339)"
+// @Success     200 {object}  models.ReturnSuccessResponseWithJson
+// @Failure     400 {object} models.ErrorResponse
+// @Failure     500 {object} models.ErrorResponse
+func listExtClientSessions(w http.ResponseWriter, r *http.Request) {
+	filter := schema.ExtClientSession{
+		ClientID:  r.URL.Query().Get("client_id"),
+		Network:   r.URL.Query().Get("network"),
+		OwnerID:   r.URL.Query().Get("username"),
+		GatewayID: r.URL.Query().Get("gateway_id"),
+	}
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	pageSize, _ := strconv.Atoi(r.URL.Query().Get("per_page"))
+	ctx := db.WithContext(r.Context())
+	var err error
+	fromDateStr := r.URL.Query().Get("from_date")
+	toDateStr := r.URL.Query().Get("to_date")
+	var fromDate, toDate time.Time
+	if fromDateStr != "" {
+		fromDate, err = time.Parse(time.RFC3339, fromDateStr)
+		if err != nil {
+			logic.ReturnErrorResponse(w, r, models.ErrorResponse{
+				Code:    http.StatusBadRequest,
+				Message: err.Error(),
+			})
+			return
+		}
+	}
+	if toDateStr != "" {
+		toDate, err = time.Parse(time.RFC3339, toDateStr)
+		if err != nil {
+			logic.ReturnErrorResponse(w, r, models.ErrorResponse{
+				Code:    http.StatusBadRequest,
+				Message: err.Error(),
+			})
+			return
+		}
+	}
+	sessions, err := filter.List(db.SetPagination(ctx, page, pageSize), fromDate, toDate)
+	if err != nil {
+		logic.ReturnErrorResponse(w, r, models.ErrorResponse{
+			Code:    http.StatusInternalServerError,
+			Message: err.Error(),
+		})
+		return
+	}
+	logic.ReturnSuccessResponseWithJson(w, r, sessions, "successfully fetched ext client sessions")
+}
@@ -2,7 +2,9 @@ package controllers
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
+	"time"
 
 	proLogic "github.com/gravitl/netmaker/pro/logic"
 	"golang.org/x/exp/slog"
@@ -20,6 +22,53 @@ func MetricHandlers(r *mux.Router) {
 	r.HandleFunc("/api/metrics/{network}", logic.SecurityCheck(true, http.HandlerFunc(getNetworkNodesMetrics))).Methods(http.MethodGet)
 	r.HandleFunc("/api/metrics", logic.SecurityCheck(true, http.HandlerFunc(getAllMetrics))).Methods(http.MethodGet)
 	r.HandleFunc("/api/metrics-ext/{network}", logic.SecurityCheck(true, http.HandlerFunc(getNetworkExtMetrics))).Methods(http.MethodGet)
+	r.HandleFunc("/api/v1/metrics/history", logic.SecurityCheck(true, http.HandlerFunc(getMetricsHistory))).Methods(http.MethodGet)
+}
+
+// @Summary     Get the latency, throughput and uptime series of a node pair, node, gateway or network
+// @Router      /api/v1/metrics/history [get]
+// @Tags        Metrics
+// @Param       network query string true "network"
+// @Param       node query string false "node id, the series of its peers"
+// @Param       peer query string false "peer node or ext client id, the series of the node pair"
+// @Param       gateway query bool false "the series of the node's ext clients"
+// @Param       from_date query string false "start of the range (RFC3339), defaults to a day before to_date"
+// @Param       to_date query string false "end of the range (RFC3339), defaults to now"
+// @Param       resolution query string false "raw, 5m or 1h, defaults to the finest kept for the range"
+// @Success     200 {object} models.MetricsHistory
+// @Failure     400 {object} models.ErrorResponse
+// @Failure     500 {object} models.ErrorResponse
+func getMetricsHistory(w http.ResponseWriter, r *http.Request) {
+	req := models.MetricsHistory{
+		Network:    r.URL.Query().Get("network"),
+		NodeID:     r.URL.Query().Get("node"),
+		PeerID:     r.URL.Query().Get("peer"),
+		Gateway:    r.URL.Query().Get("gateway") == "true",
+		Resolution: r.URL.Query().Get("resolution"),
+	}
+	var err error
+	if fromDateStr := r.URL.Query().Get("from_date"); fromDateStr != "" {
+		if req.From, err = time.Parse(time.RFC3339, fromDateStr); err != nil {
+			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+			return
+		}
+	}
+	if toDateStr := r.URL.Query().Get("to_date"); toDateStr != "" {
+		if req.To, err = time.Parse(time.RFC3339, toDateStr); err != nil {
+			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+			return
+		}
+	}
+	history, err := logic.GetMetricsHistory(req)
+	if err != nil {
+		if errors.Is(err, logic.ErrInvalidMetricsHistoryQuery) {
+			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+			return
+		}
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return
+	}
+	logic.ReturnSuccessResponseWithJson(w, r, history, "fetched metrics history")
 }
 
 // get the metrics of a given node
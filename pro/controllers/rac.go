@@ -1,14 +1,170 @@
 package controllers
 
 import (
+	"encoding/json"
+	"errors"
 	"net/http"
 
 	"github.com/gorilla/mux"
 	"github.com/gravitl/netmaker/logic"
+	"github.com/gravitl/netmaker/models"
+	"github.com/gravitl/netmaker/mq"
+	"github.com/gravitl/netmaker/servercfg"
+	"golang.org/x/exp/slog"
 )
 
 func RacHandlers(r *mux.Router) {
 	r.HandleFunc("/api/v1/rac/networks", logic.SecurityCheck(false, http.HandlerFunc(getUserRemoteAccessNetworks))).Methods(http.MethodGet)
 	r.HandleFunc("/api/v1/rac/network/{network}/access_points", logic.SecurityCheck(false, http.HandlerFunc(getUserRemoteAccessNetworkGateways))).Methods(http.MethodGet)
 	r.HandleFunc("/api/v1/rac/access_point/{access_point_id}/config", logic.SecurityCheck(false, http.HandlerFunc(getRemoteAccessGatewayConf))).Methods(http.MethodGet)
+	r.HandleFunc("/api/v1/rac/devices", logic.SecurityCheck(false, http.HandlerFunc(listUserRacDevices))).Methods(http.MethodGet)
+	r.HandleFunc("/api/v1/rac/devices/{device_id}", logic.SecurityCheck(false, http.HandlerFunc(updateUserRacDevice))).Methods(http.MethodPut)
+	r.HandleFunc("/api/v1/rac/devices/{device_id}", logic.SecurityCheck(false, http.HandlerFunc(revokeUserRacDevice))).Methods(http.MethodDelete)
+}
+
+// @Summary     List the devices of the calling user
+// @Router      /api/v1/rac/devices [get]
+// @Tags        Remote Access Client
+// @Security    oauth2
+// @Success     200 {array} models.RacDevice
+// @Failure     500 {object} models.ErrorResponse
+func listUserRacDevices(w http.ResponseWriter, r *http.Request) {
+	devices, err := logic.GetUserRacDevices(r.Header.Get("user"))
+	if err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return
+	}
+	logic.ReturnSuccessResponseWithJson(w, r, devices, "fetched user devices")
+}
+
+// @Summary     Rename or disable a device of the calling user
+// @Router      /api/v1/rac/devices/{device_id} [put]
+// @Tags        Remote Access Client
+// @Security    oauth2
+// @Param       device_id path string true "remote access client id or client id of the device"
+// @Param       body body models.RacDeviceUpdate true "device changes"
+// @Success     200 {object} models.RacDevice
+// @Failure     400 {object} models.ErrorResponse
+// @Failure     403 {object} models.ErrorResponse
+// @Failure     404 {object} models.ErrorResponse
+// @Failure     500 {object} models.ErrorResponse
+func updateUserRacDevice(w http.ResponseWriter, r *http.Request) {
+	var update models.RacDeviceUpdate
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+		return
+	}
+	device, err := getUserRacDevice(r.Header.Get("user"), mux.Vars(r)["device_id"])
+	if err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "notfound"))
+		return
+	}
+	if update.Enabled != nil && *update.Enabled && !device.Enabled {
+		// a disabled device may have been disabled by an admin
+		logic.ReturnErrorResponse(w, r, logic.FormatError(errors.New("only admins can enable a device"), "forbidden"))
+		return
+	}
+	var disabled []models.ExtClient
+	for i := range device.Clients {
+		client, err := logic.GetExtClient(device.Clients[i].ClientID, device.Clients[i].Network)
+		if err != nil {
+			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+			return
+		}
+		if update.DeviceName != "" && update.DeviceName != client.DeviceName {
+			client.DeviceName = update.DeviceName
+			if err := logic.SaveExtClient(&client); err != nil {
+				logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+				return
+			}
+		}
+		if update.Enabled != nil && !*update.Enabled && client.Enabled {
+			if client, err = logic.ToggleExtClientConnectivity(&client, false); err != nil {
+				logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+				return
+			}
+			disabled = append(disabled, client)
+		}
+	}
+	go func() {
+		for i := range disabled {
+			if err := mq.PublishDeletedClientPeerUpdate(&disabled[i]); err != nil {
+				slog.Error("error removing disabled device from peers", "client", disabled[i].ClientID, "error", err)
+			}
+		}
+	}()
+	device, err = getUserRacDevice(r.Header.Get("user"), device.ID)
+	if err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+		return
+	}
+	logic.ReturnSuccessResponseWithJson(w, r, device, "updated device "+device.ID)
+}
+
+// @Summary     Revoke a device of the calling user, deleting all its configs
+// @Router      /api/v1/rac/devices/{device_id} [delete]
+// @Tags        Remote Access Client
+// @Security    oauth2
+// @Param       device_id path string true "remote access client id or client id of the device"
+// @Success     200 {object} models.SuccessResponse
+// @Failure     404 {object} models.ErrorResponse
+// @Failure     500 {object} models.ErrorResponse
+func revokeUserRacDevice(w http.ResponseWriter, r *http.Request) {
+	device, err := getUserRacDevice(r.Header.Get("user"), mux.Vars(r)["device_id"])
+	if err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "notfound"))
+		return
+	}
+	var revoked []models.ExtClient
+	for i := range device.Clients {
+		client, err := logic.GetExtClient(device.Clients[i].ClientID, device.Clients[i].Network)
+		if err != nil {
+			continue
+		}
+		if err := logic.DeleteExtClientAndCleanup(client); err != nil {
+			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+			return
+		}
+		revoked = append(revoked, client)
+		logic.LogEvent(&models.Event{
+			Action: models.Delete,
+			Source: models.Subject{
+				ID:   r.Header.Get("user"),
+				Name: r.Header.Get("user"),
+				Type: models.UserSub,
+			},
+			TriggeredBy: r.Header.Get("user"),
+			Target: models.Subject{
+				ID:   client.ClientID,
+				Name: client.ClientID,
+				Type: models.DeviceSub,
+			},
+			NetworkID: models.NetworkID(client.Network),
+			Origin:    models.ClientApp,
+		})
+	}
+	go func() {
+		for i := range revoked {
+			if err := mq.PublishDeletedClientPeerUpdate(&revoked[i]); err != nil {
+				slog.Error("error removing revoked device from peers", "client", revoked[i].ClientID, "error", err)
+			}
+		}
+		if servercfg.IsDNSMode() {
+			logic.SetDNS()
+		}
+	}()
+	logic.ReturnSuccessResponse(w, r, "revoked device "+device.ID)
+}
+
+func getUserRacDevice(username, deviceID string) (models.RacDevice, error) {
+	devices, err := logic.GetUserRacDevices(username)
+	if err != nil {
+		return models.RacDevice{}, err
+	}
+	for _, device := range devices {
+		if device.ID == deviceID {
+			return device, nil
+		}
+	}
+	return models.RacDevice{}, errors.New("device not found")
 }
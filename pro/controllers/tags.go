@@ -15,6 +15,7 @@ import (
 	"github.com/gravitl/netmaker/models"
 	"github.com/gravitl/netmaker/mq"
 	proLogic "github.com/gravitl/netmaker/pro/logic"
+	"github.com/gravitl/netmaker/servercfg"
 )
 
 func TagHandlers(r *mux.Router) {
@@ -85,12 +86,13 @@ func createTag(w http.ResponseWriter, r *http.Request) {
 	}
 	// check if tag exists
 	tag := models.Tag{
-		ID:        models.TagID(fmt.Sprintf("%s.%s", req.Network, req.TagName)),
-		TagName:   req.TagName,
-		Network:   req.Network,
-		CreatedBy: user.UserName,
-		ColorCode: req.ColorCode,
-		CreatedAt: time.Now().UTC(),
+		ID:          models.TagID(fmt.Sprintf("%s.%s", req.Network, req.TagName)),
+		TagName:     req.TagName,
+		Network:     req.Network,
+		CreatedBy:   user.UserName,
+		ColorCode:   req.ColorCode,
+		CreatedAt:   time.Now().UTC(),
+		DNSServices: req.DNSServices,
 	}
 	_, err = proLogic.GetTag(tag.ID)
 	if err == nil {
@@ -103,6 +105,11 @@ func createTag(w http.ResponseWriter, r *http.Request) {
 		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
 		return
 	}
+	err = logic.ValidateTagDNSServices(tag.DNSServices)
+	if err != nil {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+		return
+	}
 	err = proLogic.InsertTag(tag)
 	if err != nil {
 		logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
@@ -131,6 +138,9 @@ func createTag(w http.ResponseWriter, r *http.Request) {
 			node.Tags[tag.ID] = struct{}{}
 			logic.UpsertNode(&node)
 		}
+		if servercfg.IsDNSMode() {
+			logic.SetDNS()
+		}
 	}()
 	logic.LogEvent(&models.Event{
 		Action: models.Create,
@@ -199,6 +209,14 @@ func updateTag(w http.ResponseWriter, r *http.Request) {
 		NetworkID: tag.Network,
 		Origin:    models.Dashboard,
 	}
+	if updateTag.DNSServices != nil {
+		// validate before a rename replaces the tag
+		err = logic.ValidateTagDNSServices(updateTag.DNSServices)
+		if err != nil {
+			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+			return
+		}
+	}
 	updateTag.NewName = strings.TrimSpace(updateTag.NewName)
 	var newID models.TagID
 	if updateTag.NewName != "" {
@@ -227,12 +245,23 @@ func updateTag(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 	}
+	if updateTag.DNSServices != nil {
+		tag.DNSServices = updateTag.DNSServices
+		err = proLogic.UpsertTag(tag)
+		if err != nil {
+			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "badrequest"))
+			return
+		}
+	}
 	go func() {
 		proLogic.UpdateTag(updateTag, newID)
 		if updateTag.NewName != "" {
 			proLogic.UpdateDeviceTag(updateTag.ID, newID, tag.Network)
 		}
 		mq.PublishPeerUpdate(false)
+		if servercfg.IsDNSMode() {
+			logic.SetDNS()
+		}
 	}()
 	e.Diff.New = updateTag
 	logic.LogEvent(e)
@@ -277,6 +306,9 @@ func deleteTag(w http.ResponseWriter, r *http.Request) {
 		proLogic.RemoveDeviceTagFromAclPolicies(tag.ID, tag.Network)
 		logic.RemoveTagFromEnrollmentKeys(tag.ID)
 		mq.PublishPeerUpdate(false)
+		if servercfg.IsDNSMode() {
+			logic.SetDNS()
+		}
 	}()
 	logic.LogEvent(&models.Event{
 		Action: models.Delete,
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"net"
 	"net/http"
 	"net/url"
 	"strings"
@@ -1137,6 +1138,11 @@ func getRemoteAccessGatewayConf(w http.ResponseWriter, r *http.Request) {
 		logic.ReturnErrorResponse(w, r, logic.FormatError(errors.New("access denied"), "forbidden"))
 		return
 	}
+	sourceIP, _ := logic.ParseRequestIP(r)
+	if logic.IsGeoBlockedIP(net.ParseIP(sourceIP)) {
+		logic.ReturnErrorResponse(w, r, logic.FormatError(errors.New("access from this country is not allowed"), "forbidden"))
+		return
+	}
 	node, err := logic.GetNodeByID(remoteGwID)
 	if err != nil {
 		logic.ReturnErrorResponse(w, r, logic.FormatError(fmt.Errorf("failed to fetch gw node %s, error: %v", remoteGwID, err), "badrequest"))
@@ -1166,7 +1172,24 @@ func getRemoteAccessGatewayConf(w http.ResponseWriter, r *http.Request) {
 			userConf.AllowedIPs = logic.GetExtclientAllowedIPs(extClient)
 		}
 	}
-	if userConf.ClientID == "" {
+	if userConf.ClientID != "" {
+		if err := logic.RenewRacSession(user, &node, &userConf); err != nil {
+			if errors.Is(err, logic.ErrReauthRequired) {
+				logic.ReturnErrorResponse(w, r, logic.FormatError(err, "unauthorized"))
+				return
+			}
+			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "internal"))
+			return
+		}
+	} else {
+		if err := logic.CheckUserExtClientLimits(user, node.Network, node.ID.String()); err != nil {
+			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "forbidden"))
+			return
+		}
+		if err := logic.ApplyRacSessionPolicy(user, &node, &userConf, time.Now()); err != nil {
+			logic.ReturnErrorResponse(w, r, logic.FormatError(err, "unauthorized"))
+			return
+		}
 		// create a new conf
 		userConf.OwnerID = user.UserName
 		userConf.RemoteAccessClientID = req.RemoteAccessClientID
@@ -1199,6 +1222,8 @@ func getRemoteAccessGatewayConf(w http.ResponseWriter, r *http.Request) {
 		userConf.Tags = make(map[models.TagID]struct{})
 		// userConf.Tags[models.TagID(fmt.Sprintf("%s.%s", userConf.Network,
 		// 	models.RemoteAccessTagName))] = struct{}{}
+		userConf.PublicEndpoint = sourceIP
+		logic.UpdateExtClientLocation(&userConf, false)
 		if err = logic.CreateExtClient(&userConf); err != nil {
 			slog.Error(
 				"failed to create extclient",
@@ -1221,6 +1246,7 @@ func getRemoteAccessGatewayConf(w http.ResponseWriter, r *http.Request) {
 		Connected:         true,
 		IsInternetGateway: node.IsInternetGateway,
 		GwPeerPublicKey:   host.PublicKey.String(),
+		GwPresharedKey:    logic.GetExtClientGwPresharedKey(&userConf, host),
 		GwListenPort:      logic.GetPeerListenPort(host),
 		Metadata:          node.Metadata,
 		AllowedEndpoints:  getAllowedRagEndpoints(&node, host),
@@ -1319,6 +1345,7 @@ func getUserRemoteAccessGwsV1(w http.ResponseWriter, r *http.Request) {
 				Connected:         true,
 				IsInternetGateway: node.IsInternetGateway,
 				GwPeerPublicKey:   host.PublicKey.String(),
+				GwPresharedKey:    logic.GetExtClientGwPresharedKey(&extClient, host),
 				GwListenPort:      logic.GetPeerListenPort(host),
 				Metadata:          node.Metadata,
 				AllowedEndpoints:  getAllowedRagEndpoints(&node, host),
@@ -0,0 +1,57 @@
+package email
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gravitl/netmaker/schema"
+)
+
+// ExtClientConfigLinkMail - mail handing a config download link to someone without a dashboard account
+type ExtClientConfigLinkMail struct {
+	BodyBuilder EmailBodyBuilder
+	Link        schema.ExtClientConfigLink
+	URL         string
+}
+
+// GetSubject - gets the subject of the email
+func (m ExtClientConfigLinkMail) GetSubject(info Notification) string {
+	return "Your Netmaker VPN config"
+}
+
+// GetBody - gets the body of the email
+func (m ExtClientConfigLinkMail) GetBody(info Notification) string {
+	usage := "The link can be used until it expires"
+	if m.Link.MaxDownloads == 1 {
+		usage = "The link can be used once"
+	} else if m.Link.MaxDownloads > 1 {
+		usage = fmt.Sprintf("The link can be used %d times", m.Link.MaxDownloads)
+	}
+	return m.BodyBuilder.
+		WithParagraph("Hi,").
+		WithParagraph(fmt.Sprintf("A VPN config for network <b>%s</b> was shared with you.", m.Link.Network)).
+		WithHtml(fmt.Sprintf("<p>Click <a href=\"%s\">here</a> to download it.</p>", m.URL)).
+		WithParagraph(fmt.Sprintf("%s and expires on %s. Please do not forward it.",
+			usage, m.Link.ExpiresAt.UTC().Format(time.RFC1123))).
+		WithParagraph("Best Regards,").
+		WithParagraph("The Netmaker Team").
+		Build()
+}
+
+// SendExtClientConfigLink - emails a config download link to its recipient
+func SendExtClientConfigLink(link schema.ExtClientConfigLink, url string) error {
+	if !IsValid(link.Recipient) {
+		return errors.New("invalid recipient email address")
+	}
+	e := ExtClientConfigLinkMail{
+		BodyBuilder: &EmailBodyBuilderWithH1HeadlineAndImage{},
+		Link:        link,
+		URL:         url,
+	}
+	n := Notification{
+		RecipientMail: link.Recipient,
+	}
+	return GetClient().SendEmail(context.Background(), n, e)
+}
@@ -0,0 +1,56 @@
+package email
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/gravitl/netmaker/logic"
+	"github.com/gravitl/netmaker/models"
+	"golang.org/x/exp/slog"
+)
+
+// ExtClientExpiryMail - mail warning the owner of a remote access client that it is about to expire
+type ExtClientExpiryMail struct {
+	BodyBuilder EmailBodyBuilder
+	Client      models.ExtClient
+	ExpiresAt   time.Time
+}
+
+// GetSubject - gets the subject of the email
+func (m ExtClientExpiryMail) GetSubject(info Notification) string {
+	return fmt.Sprintf("Your VPN config %s is about to expire", m.Client.ClientID)
+}
+
+// GetBody - gets the body of the email
+func (m ExtClientExpiryMail) GetBody(info Notification) string {
+	outcome := "disabled"
+	if logic.GetExtClientExpiryAction(&m.Client) == models.ExtClientExpiryDelete {
+		outcome = "deleted"
+	}
+	return m.BodyBuilder.
+		WithParagraph("Hi,").
+		WithParagraph(fmt.Sprintf("Your VPN config <b>%s</b> on network <b>%s</b> will be %s on %s.",
+			m.Client.ClientID, m.Client.Network, outcome, m.ExpiresAt.UTC().Format(time.RFC1123))).
+		WithParagraph("If you still need access, please ask your administrator to extend it.").
+		WithParagraph("Best Regards,").
+		WithParagraph("The Netmaker Team").
+		Build()
+}
+
+// NotifyExtClientExpiry - emails the owner of an ext client about its upcoming expiry
+func NotifyExtClientExpiry(client models.ExtClient, expiresAt time.Time) error {
+	if !IsValid(client.OwnerID) {
+		slog.Warn("skipping ext client expiry notification, owner has no email address", "client", client.ClientID, "owner", client.OwnerID)
+		return nil
+	}
+	e := ExtClientExpiryMail{
+		BodyBuilder: &EmailBodyBuilderWithH1HeadlineAndImage{},
+		Client:      client,
+		ExpiresAt:   expiresAt,
+	}
+	n := Notification{
+		RecipientMail: client.OwnerID,
+	}
+	return GetClient().SendEmail(context.Background(), n, e)
+}
@@ -0,0 +1,50 @@
+package email
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/gravitl/netmaker/models"
+	"golang.org/x/exp/slog"
+)
+
+// ExtClientKeyRotationMail - mail telling the owner of a remote access client that its keys were rotated
+type ExtClientKeyRotationMail struct {
+	BodyBuilder EmailBodyBuilder
+	Client      models.ExtClient
+}
+
+// GetSubject - gets the subject of the email
+func (m ExtClientKeyRotationMail) GetSubject(info Notification) string {
+	return fmt.Sprintf("A new VPN config is available for %s", m.Client.ClientID)
+}
+
+// GetBody - gets the body of the email
+func (m ExtClientKeyRotationMail) GetBody(info Notification) string {
+	return m.BodyBuilder.
+		WithParagraph("Hi,").
+		WithParagraph(fmt.Sprintf("The keys of your VPN config <b>%s</b> on network <b>%s</b> were rotated.",
+			m.Client.ClientID, m.Client.Network)).
+		WithParagraph(fmt.Sprintf("Please download the new config before %s, the current one stops working afterwards. The Remote Access Client picks up the new config automatically.",
+			m.Client.PreviousKeyExpiresAt.UTC().Format(time.RFC1123))).
+		WithParagraph("Best Regards,").
+		WithParagraph("The Netmaker Team").
+		Build()
+}
+
+// NotifyExtClientKeyRotation - emails the owner of an ext client that a new config is available
+func NotifyExtClientKeyRotation(client models.ExtClient) error {
+	if !IsValid(client.OwnerID) {
+		slog.Warn("skipping ext client key rotation notification, owner has no email address", "client", client.ClientID, "owner", client.OwnerID)
+		return nil
+	}
+	e := ExtClientKeyRotationMail{
+		BodyBuilder: &EmailBodyBuilderWithH1HeadlineAndImage{},
+		Client:      client,
+	}
+	n := Notification{
+		RecipientMail: client.OwnerID,
+	}
+	return GetClient().SendEmail(context.Background(), n, e)
+}
@@ -0,0 +1,63 @@
+package email
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/gravitl/netmaker/logic"
+	"github.com/gravitl/netmaker/models"
+)
+
+// ExtClientLimitMail - mail telling an admin that a user hit a device limit
+type ExtClientLimitMail struct {
+	BodyBuilder EmailBodyBuilder
+	User        models.User
+	Scope       string
+	Limit       int
+}
+
+// GetSubject - gets the subject of the email
+func (m ExtClientLimitMail) GetSubject(info Notification) string {
+	return fmt.Sprintf("%s reached their device limit", m.User.UserName)
+}
+
+// GetBody - gets the body of the email
+func (m ExtClientLimitMail) GetBody(info Notification) string {
+	return m.BodyBuilder.
+		WithParagraph("Hi,").
+		WithParagraph(fmt.Sprintf("User <b>%s</b> tried to add a device but already has %d devices per %s.",
+			m.User.UserName, m.Limit, m.Scope)).
+		WithParagraph("Raise the device limits of their roles or groups, or ask them to revoke unused devices.").
+		WithParagraph("Best Regards,").
+		WithParagraph("The Netmaker Team").
+		Build()
+}
+
+// NotifyExtClientLimitReached - emails the admins that a user hit a device limit
+func NotifyExtClientLimitReached(user models.User, scope string, limit int) error {
+	users, err := logic.GetUsersDB()
+	if err != nil {
+		return err
+	}
+	e := ExtClientLimitMail{
+		BodyBuilder: &EmailBodyBuilderWithH1HeadlineAndImage{},
+		User:        user,
+		Scope:       scope,
+		Limit:       limit,
+	}
+	for _, admin := range users {
+		if admin.PlatformRoleID != models.SuperAdminRole && admin.PlatformRoleID != models.AdminRole {
+			continue
+		}
+		if !IsValid(admin.UserName) {
+			continue
+		}
+		n := Notification{
+			RecipientMail: admin.UserName,
+		}
+		if err := GetClient().SendEmail(context.Background(), n, e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
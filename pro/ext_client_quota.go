@@ -0,0 +1,39 @@
+//go:build ee
+// +build ee
+
+package pro
+
+import (
+	"time"
+
+	"github.com/gravitl/netmaker/logic"
+	"github.com/gravitl/netmaker/models"
+	"github.com/gravitl/netmaker/mq"
+	"golang.org/x/exp/slog"
+)
+
+// AddExtClientQuotaHooks - adds the hook re-enabling ext clients at the end of their quota period
+func AddExtClientQuotaHooks() {
+	slog.Debug("adding ext client quota hook")
+	logic.HookManagerCh <- models.HookDetails{
+		Hook:     extClientQuotaResetHook,
+		Interval: logic.ExtClientQuotaCheckInterval,
+	}
+}
+
+// extClientQuotaResetHook - re-enables the ext clients suspended for their quota once the period ended
+func extClientQuotaResetHook() error {
+	resumed, err := logic.ResumeQuotaSuspendedExtClients(time.Now())
+	if err != nil {
+		slog.Error("error resuming quota suspended ext clients", "error", err)
+		return err
+	}
+	if len(resumed) == 0 {
+		return nil
+	}
+	for _, client := range resumed {
+		slog.Info("re-enabled ext client at the end of its quota period", "client", client.ClientID, "owner", client.OwnerID)
+	}
+	go mq.PublishPeerUpdate(false)
+	return nil
+}
@@ -0,0 +1,30 @@
+//go:build ee
+// +build ee
+
+package pro
+
+import (
+	"time"
+
+	"github.com/gravitl/netmaker/logic"
+	"github.com/gravitl/netmaker/models"
+	"golang.org/x/exp/slog"
+)
+
+// AddExtClientSessionHooks - adds the hook closing the sessions of ext clients no longer reported by their gateway
+func AddExtClientSessionHooks() {
+	slog.Debug("adding ext client session hook")
+	logic.HookManagerCh <- models.HookDetails{
+		Hook:     extClientSessionHook,
+		Interval: logic.ExtClientSessionCheckInterval,
+	}
+}
+
+// extClientSessionHook - ends the sessions whose gateway missed its metrics reports
+func extClientSessionHook() error {
+	if err := logic.CloseStaleExtClientSessions(time.Now()); err != nil {
+		slog.Error("error closing stale ext client sessions", "error", err)
+		return err
+	}
+	return nil
+}
@@ -35,6 +35,7 @@ func InitPro() {
 		proControllers.InetHandlers,
 		proControllers.RacHandlers,
 		proControllers.EventHandlers,
+		proControllers.ExtClientSessionHandlers,
 		proControllers.TagHandlers,
 	)
 	controller.ListRoles = proControllers.ListRoles
@@ -85,6 +86,9 @@ func InitPro() {
 		if logic.GetRacAutoDisable() {
 			AddRacHooks()
 		}
+		AddExtClientQuotaHooks()
+		AddMetricsHistoryHooks()
+		AddExtClientSessionHooks()
 
 		var authProvider = auth.InitializeAuthProvider()
 		if authProvider != "" {
@@ -110,6 +114,7 @@ func InitPro() {
 	logic.SetClientACLs = proLogic.SetClientACLs
 	logic.UpdateProNodeACLs = proLogic.UpdateProNodeACLs
 	logic.GetMetrics = proLogic.GetMetrics
+	logic.IsExtClientKeyHandoverTracked = func() bool { return true }
 	logic.UpdateMetrics = proLogic.UpdateMetrics
 	logic.DeleteMetrics = proLogic.DeleteMetrics
 	logic.GetTrialEndDate = getTrialEndDate
@@ -145,11 +150,16 @@ func InitPro() {
 	logic.ResetAuthProvider = auth.ResetAuthProvider
 	logic.ResetIDPSyncHook = auth.ResetIDPSyncHook
 	logic.EmailInit = email.Init
+	logic.NotifyExtClientExpiry = email.NotifyExtClientExpiry
+	logic.NotifyExtClientKeyRotation = email.NotifyExtClientKeyRotation
+	logic.NotifyExtClientLimitReached = email.NotifyExtClientLimitReached
+	logic.SendExtClientConfigLink = email.SendExtClientConfigLink
 	logic.LogEvent = proLogic.LogEvent
 	logic.RemoveUserFromAclPolicy = proLogic.RemoveUserFromAclPolicy
 	logic.IsUserAllowedToCommunicate = proLogic.IsUserAllowedToCommunicate
 	logic.DeleteAllNetworkTags = proLogic.DeleteAllNetworkTags
 	logic.CreateDefaultTags = proLogic.CreateDefaultTags
+	logic.GetNetworkTagsWithNodes = proLogic.GetNetworkTagsWithNodes
 	logic.GetInetClientsFromAclPolicies = proLogic.GetInetClientsFromAclPolicies
 	logic.IsPeerAllowed = proLogic.IsPeerAllowed
 	logic.IsAclPolicyValid = proLogic.IsAclPolicyValid
@@ -103,6 +103,9 @@ func UpdateMetrics(nodeid string, metrics *models.Metrics) error {
 	if servercfg.CacheEnabled() {
 		storeMetricsInCache(nodeid, *metrics)
 	}
+	if err := logic.RecordMetricsHistory(nodeid, metrics, time.Now()); err != nil {
+		slog.Error("failed to record metrics history", "id", nodeid, "error", err)
+	}
 	return nil
 }
 
@@ -115,6 +118,7 @@ func DeleteMetrics(nodeid string) error {
 	if servercfg.CacheEnabled() {
 		deleteNetworkFromCache(nodeid)
 	}
+	logic.DeleteMetricsHistoryBaseline(nodeid)
 	return nil
 }
 
@@ -196,6 +200,44 @@ func updateNodeMetrics(currentNode *models.Node, newMetrics *models.Metrics) {
 		slog.Debug("[metrics] processing attached client", "client", attachedClients[i].ClientID, "public key", attachedClients[i].PublicKey)
 		clientMetric := newMetrics.Connectivity[attachedClients[i].PublicKey]
 		clientMetric.NodeName = attachedClients[i].ClientID
+		if clientMetric.Connected && attachedClients[i].Expiry.InactivityTimeout > 0 {
+			// the gateway only reports clients as connected after a recent handshake
+			if err := logic.UpdateExtClientLastHandshake(&attachedClients[i], time.Now()); err != nil {
+				slog.Error("failed to record ext client handshake", "client", attachedClients[i].ClientID, "error", err)
+			}
+		}
+		if err := logic.RecordExtClientSession(&attachedClients[i], currentNode.ID.String(), clientMetric, time.Now()); err != nil {
+			slog.Error("failed to record ext client session", "client", attachedClients[i].ClientID, "error", err)
+		}
+		if err := logic.RecordExtClientUsage(&attachedClients[i], oldMetrics.Connectivity[attachedClients[i].ClientID], clientMetric, time.Now()); err != nil {
+			slog.Error("failed to record ext client usage", "client", attachedClients[i].ClientID, "error", err)
+		}
+		if clientMetric.Connected && clientMetric.Endpoint != "" && clientMetric.Endpoint != attachedClients[i].PublicEndpoint {
+			// the endpoint the gateway sees the client at, the one stored at creation goes stale
+			attachedClients[i].PublicEndpoint = clientMetric.Endpoint
+			if err := logic.SaveExtClient(&attachedClients[i]); err != nil {
+				slog.Error("failed to save ext client endpoint", "client", attachedClients[i].ClientID, "error", err)
+			}
+		}
+		if clientMetric.Connected && logic.UpdateExtClientLocation(&attachedClients[i], true) {
+			enforceExtClientGeoBlock(&attachedClients[i])
+		}
+		if attachedClients[i].Enabled {
+			enforceExtClientQuota(&attachedClients[i])
+		}
+		if clientMetric.Connected && logic.IsExtClientKeyHandoverActive(&attachedClients[i], time.Now()) {
+			// the rotated key is in use, route the client's addresses to it and drop the previous key
+			previous, err := logic.CompleteExtClientKeyHandover(&attachedClients[i])
+			if err != nil {
+				slog.Error("failed to complete ext client key handover", "client", attachedClients[i].ClientID, "error", err)
+			} else {
+				go func() {
+					if err := mq.PublishDeletedClientPeerUpdate(&previous); err != nil {
+						slog.Error("error removing previous ext client key from peers", "client", previous.ClientID, "error", err)
+					}
+				}()
+			}
+		}
 		newMetrics.Connectivity[attachedClients[i].ClientID] = clientMetric
 		delete(newMetrics.Connectivity, attachedClients[i].PublicKey)
 		slog.Debug("[metrics] attached client metric", "metric", clientMetric)
@@ -237,3 +279,48 @@ func updateNodeMetrics(currentNode *models.Node, newMetrics *models.Metrics) {
 
 	slog.Debug("[metrics] node metrics data", "node ID", currentNode.ID, "metrics", newMetrics)
 }
+
+// enforceExtClientGeoBlock - disables an ext client that connected from a blocked country,
+// otherwise publishes the change of its geo tags
+func enforceExtClientGeoBlock(client *models.ExtClient) {
+	if !client.Enabled || !logic.IsGeoBlocked(client.Location) {
+		go mq.PublishPeerUpdate(false)
+		return
+	}
+	slog.Warn("disabling ext client connected from a blocked country", "client", client.ClientID, "owner", client.OwnerID, "country", client.Location.Country)
+	disabled, err := logic.ToggleExtClientConnectivity(client, false)
+	if err != nil {
+		slog.Error("failed to disable ext client from a blocked country", "client", client.ClientID, "error", err)
+		return
+	}
+	*client = disabled
+	go func() {
+		if err := mq.PublishDeletedClientPeerUpdate(&disabled); err != nil {
+			slog.Error("error removing ext client from a blocked country from peers", "client", disabled.ClientID, "error", err)
+		}
+	}()
+}
+
+// enforceExtClientQuota - disables an ext client that used up its own or its owner's quota
+func enforceExtClientQuota(client *models.ExtClient) {
+	exceeded, until, err := logic.CheckExtClientQuota(client, time.Now())
+	if err != nil {
+		slog.Error("failed to check ext client quota", "client", client.ClientID, "error", err)
+		return
+	}
+	if !exceeded {
+		return
+	}
+	slog.Info("disabling ext client over quota", "client", client.ClientID, "owner", client.OwnerID, "until", until)
+	suspended, err := logic.SuspendExtClientForQuota(client, until)
+	if err != nil {
+		slog.Error("failed to disable ext client over quota", "client", client.ClientID, "error", err)
+		return
+	}
+	*client = suspended
+	go func() {
+		if err := mq.PublishDeletedClientPeerUpdate(&suspended); err != nil {
+			slog.Error("error removing ext client over quota from peers", "client", suspended.ClientID, "error", err)
+		}
+	}()
+}
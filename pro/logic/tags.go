@@ -133,6 +133,15 @@ func ListTags() ([]models.Tag, error) {
 	return tags, nil
 }
 
+// GetNetworkTagsWithNodes - gets the tags of a network and the nodes and static nodes carrying each of them
+func GetNetworkTagsWithNodes(netID models.NetworkID) ([]models.Tag, map[models.TagID][]models.Node) {
+	tags, err := ListNetworkTags(netID)
+	if err != nil || len(tags) == 0 {
+		return nil, nil
+	}
+	return tags, GetTagMapWithNodesByNetwork(netID, true)
+}
+
 // ListTags - lists all tags from DB
 func ListNetworkTags(netID models.NetworkID) ([]models.Tag, error) {
 	tagMutex.RLock()
@@ -0,0 +1,30 @@
+//go:build ee
+// +build ee
+
+package pro
+
+import (
+	"time"
+
+	"github.com/gravitl/netmaker/logic"
+	"github.com/gravitl/netmaker/models"
+	"golang.org/x/exp/slog"
+)
+
+// AddMetricsHistoryHooks - adds the hook downsampling the metrics history and deleting expired samples
+func AddMetricsHistoryHooks() {
+	slog.Debug("adding metrics history hook")
+	logic.HookManagerCh <- models.HookDetails{
+		Hook:     metricsHistoryHook,
+		Interval: logic.MetricsHistoryDownsampleInterval,
+	}
+}
+
+// metricsHistoryHook - rolls up the raw metrics samples and applies the retention of each resolution
+func metricsHistoryHook() error {
+	if err := logic.DownsampleMetricsHistory(time.Now()); err != nil {
+		slog.Error("error downsampling metrics history", "error", err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package schema
+
+import (
+	"context"
+	"time"
+
+	"github.com/gravitl/netmaker/db"
+	"gorm.io/datatypes"
+)
+
+const dnsFilterPolicyTable = "dns_filter_policies"
+
+// DNSFilterPolicy - domains the server's DNS resolver refuses to resolve for the clients of some networks or user groups
+type DNSFilterPolicy struct {
+	ID   string `gorm:"primaryKey" json:"id"`
+	Name string `gorm:"name" json:"name"`
+	// Blocklists - local files or http(s) urls of hosts-format or plain domain lists
+	Blocklists datatypes.JSONSlice[string] `gorm:"blocklists" json:"blocklists"`
+	// Allowlist - domains resolved even when a blocklist has them
+	Allowlist  datatypes.JSONSlice[string] `gorm:"allowlist" json:"allowlist"`
+	Networks   datatypes.JSONSlice[string] `gorm:"networks" json:"networks"`
+	UserGroups datatypes.JSONSlice[string] `gorm:"user_groups" json:"user_groups"`
+	Enabled    bool                        `gorm:"enabled" json:"enabled"`
+	CreatedBy  string                      `gorm:"created_by" json:"created_by"`
+	CreatedAt  time.Time                   `gorm:"created_at" json:"created_at"`
+	UpdatedAt  time.Time                   `gorm:"updated_at" json:"updated_at"`
+}
+
+func (p *DNSFilterPolicy) Table() string {
+	return dnsFilterPolicyTable
+}
+
+func (p *DNSFilterPolicy) Get(ctx context.Context) error {
+	return db.FromContext(ctx).Table(p.Table()).Where("id = ?", p.ID).First(&p).Error
+}
+
+func (p *DNSFilterPolicy) ListAll(ctx context.Context) ([]DNSFilterPolicy, error) {
+	var policies []DNSFilterPolicy
+	err := db.FromContext(ctx).Table(p.Table()).Order("created_at").Find(&policies).Error
+	return policies, err
+}
+
+func (p *DNSFilterPolicy) Create(ctx context.Context) error {
+	return db.FromContext(ctx).Table(p.Table()).Create(&p).Error
+}
+
+// Update - replaces the policy, unlike Updates this also stores emptied lists and disabling
+func (p *DNSFilterPolicy) Update(ctx context.Context) error {
+	return db.FromContext(ctx).Table(p.Table()).Save(&p).Error
+}
+
+func (p *DNSFilterPolicy) Delete(ctx context.Context) error {
+	return db.FromContext(ctx).Table(p.Table()).Where("id = ?", p.ID).Delete(&DNSFilterPolicy{}).Error
+}
@@ -0,0 +1,52 @@
+package schema
+
+import (
+	"context"
+	"time"
+
+	"github.com/gravitl/netmaker/db"
+	"gorm.io/datatypes"
+)
+
+const dnsSyncConfigTable = "dns_sync_configs"
+
+// DNSSyncConfig - the external authoritative server a network's records are pushed to with RFC 2136 updates
+type DNSSyncConfig struct {
+	Network       string `gorm:"primaryKey" json:"network"`
+	Server        string `gorm:"server" json:"server"`
+	Zone          string `gorm:"zone" json:"zone"`
+	TSIGKeyName   string `gorm:"tsig_key_name" json:"tsig_key_name"`
+	TSIGAlgorithm string `gorm:"tsig_algorithm" json:"tsig_algorithm"`
+	TSIGSecret    string `gorm:"tsig_secret" json:"tsig_secret"`
+	// OwnedRecords - the records last pushed, only these are ever removed from the server
+	OwnedRecords datatypes.JSONSlice[string] `gorm:"owned_records" json:"owned_records"`
+	// SkippedNames - names of the network that could not be placed in the zone at the last push
+	SkippedNames datatypes.JSONSlice[string] `gorm:"skipped_names" json:"skipped_names"`
+	LastSync     time.Time                   `gorm:"last_sync" json:"last_sync"`
+	LastError    string                      `gorm:"last_error" json:"last_error"`
+	CreatedAt    time.Time                   `gorm:"created_at" json:"created_at"`
+	UpdatedAt    time.Time                   `gorm:"updated_at" json:"updated_at"`
+}
+
+func (c *DNSSyncConfig) Table() string {
+	return dnsSyncConfigTable
+}
+
+func (c *DNSSyncConfig) Get(ctx context.Context) error {
+	return db.FromContext(ctx).Table(c.Table()).Where("network = ?", c.Network).First(&c).Error
+}
+
+func (c *DNSSyncConfig) ListAll(ctx context.Context) ([]DNSSyncConfig, error) {
+	var configs []DNSSyncConfig
+	err := db.FromContext(ctx).Table(c.Table()).Find(&configs).Error
+	return configs, err
+}
+
+// Upsert - creates or replaces the config of the network
+func (c *DNSSyncConfig) Upsert(ctx context.Context) error {
+	return db.FromContext(ctx).Table(c.Table()).Save(&c).Error
+}
+
+func (c *DNSSyncConfig) Delete(ctx context.Context) error {
+	return db.FromContext(ctx).Table(c.Table()).Where("network = ?", c.Network).Delete(&DNSSyncConfig{}).Error
+}
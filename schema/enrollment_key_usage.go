@@ -0,0 +1,44 @@
+package schema
+
+import (
+	"context"
+	"time"
+
+	"github.com/gravitl/netmaker/db"
+	"github.com/gravitl/netmaker/models"
+	"gorm.io/datatypes"
+)
+
+// EnrollmentKeyUsage - a record of a host registering with an enrollment key
+type EnrollmentKeyUsage struct {
+	ID         string `gorm:"primaryKey" json:"id"`
+	KeyID      string `gorm:"key_id;index" json:"key_id"`
+	HostID     string `gorm:"host_id" json:"host_id"`
+	HostName   string `gorm:"host_name" json:"host_name"`
+	PublicIP   string `gorm:"public_ip" json:"public_ip"`
+	InstanceID string `gorm:"instance_id" json:"instance_id,omitempty"`
+	// AttestationDigest - hash of the signature of the instance identity document the host registered with
+	AttestationDigest string                            `gorm:"attestation_digest;index" json:"-"`
+	Networks          datatypes.JSONSlice[string]       `gorm:"networks" json:"networks"`
+	Tags              datatypes.JSONSlice[models.TagID] `gorm:"tags" json:"tags"`
+	TimeStamp         time.Time                         `gorm:"time_stamp" json:"time_stamp"`
+}
+
+func (u *EnrollmentKeyUsage) Create(ctx context.Context) error {
+	return db.FromContext(ctx).Model(&EnrollmentKeyUsage{}).Create(&u).Error
+}
+
+func (u *EnrollmentKeyUsage) ListByKey(ctx context.Context) (usages []EnrollmentKeyUsage, err error) {
+	err = db.FromContext(ctx).Model(&EnrollmentKeyUsage{}).Where("key_id = ?", u.KeyID).Order("time_stamp DESC").Find(&usages).Error
+	return
+}
+
+func (u *EnrollmentKeyUsage) ListByInstance(ctx context.Context) (usages []EnrollmentKeyUsage, err error) {
+	err = db.FromContext(ctx).Model(&EnrollmentKeyUsage{}).Where("instance_id = ?", u.InstanceID).Find(&usages).Error
+	return
+}
+
+func (u *EnrollmentKeyUsage) ListByAttestationDigest(ctx context.Context) (usages []EnrollmentKeyUsage, err error) {
+	err = db.FromContext(ctx).Model(&EnrollmentKeyUsage{}).Where("attestation_digest = ?", u.AttestationDigest).Find(&usages).Error
+	return
+}
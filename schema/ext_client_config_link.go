@@ -0,0 +1,54 @@
+package schema
+
+import (
+	"context"
+	"time"
+
+	"github.com/gravitl/netmaker/db"
+)
+
+const extClientConfigLinkTable = "ext_client_config_links"
+
+// ExtClientConfigLink - a signed link to download the config of an ext client without an account
+type ExtClientConfigLink struct {
+	ID       string `gorm:"primaryKey" json:"id"`
+	ClientID string `gorm:"client_id;index" json:"client_id"`
+	Network  string `gorm:"network;index" json:"network"`
+	Format   string `gorm:"format" json:"format"`
+	// MaxDownloads - downloads allowed before the link stops working, 0 allows any number until it expires
+	MaxDownloads     int       `gorm:"max_downloads" json:"max_downloads"`
+	Downloads        int       `gorm:"downloads" json:"downloads"`
+	Recipient        string    `gorm:"recipient" json:"recipient"`
+	CreatedBy        string    `gorm:"created_by" json:"created_by"`
+	CreatedAt        time.Time `gorm:"created_at" json:"created_at"`
+	ExpiresAt        time.Time `gorm:"expires_at" json:"expires_at"`
+	LastDownloadedAt time.Time `gorm:"last_downloaded_at" json:"last_downloaded_at"`
+	Revoked          bool      `gorm:"revoked" json:"revoked"`
+}
+
+func (l *ExtClientConfigLink) Table() string {
+	return extClientConfigLinkTable
+}
+
+func (l *ExtClientConfigLink) Get(ctx context.Context) error {
+	return db.FromContext(ctx).Table(l.Table()).Where("id = ?", l.ID).First(&l).Error
+}
+
+func (l *ExtClientConfigLink) Create(ctx context.Context) error {
+	return db.FromContext(ctx).Table(l.Table()).Create(&l).Error
+}
+
+func (l *ExtClientConfigLink) Update(ctx context.Context) error {
+	return db.FromContext(ctx).Table(l.Table()).Where("id = ?", l.ID).Save(&l).Error
+}
+
+func (l *ExtClientConfigLink) ListByClient(ctx context.Context) (links []ExtClientConfigLink, err error) {
+	err = db.FromContext(ctx).Table(l.Table()).Where("client_id = ? AND network = ?", l.ClientID, l.Network).
+		Order("created_at DESC").Find(&links).Error
+	return
+}
+
+func (l *ExtClientConfigLink) DeleteByClient(ctx context.Context) error {
+	return db.FromContext(ctx).Table(l.Table()).Where("client_id = ? AND network = ?", l.ClientID, l.Network).
+		Delete(&ExtClientConfigLink{}).Error
+}
@@ -0,0 +1,87 @@
+package schema
+
+import (
+	"context"
+	"time"
+
+	"github.com/gravitl/netmaker/db"
+	"gorm.io/datatypes"
+)
+
+const extClientSessionTable = "ext_client_sessions"
+
+// ExtClientSessionEndpoint - a public endpoint an ext client connected from during a session
+type ExtClientSessionEndpoint struct {
+	Endpoint string    `json:"endpoint"`
+	SeenAt   time.Time `json:"seen_at"`
+}
+
+// ExtClientSession - a period an ext client was connected to a gateway
+type ExtClientSession struct {
+	ID             string                                        `gorm:"primaryKey" json:"id"`
+	ClientID       string                                        `gorm:"client_id;index" json:"client_id"`
+	Network        string                                        `gorm:"network;index" json:"network"`
+	OwnerID        string                                        `gorm:"owner_id;index" json:"owner_id"`
+	GatewayID      string                                        `gorm:"gateway_id;index" json:"gateway_id"`
+	PublicEndpoint string                                        `gorm:"public_endpoint" json:"public_endpoint"`
+	Endpoints      datatypes.JSONSlice[ExtClientSessionEndpoint] `gorm:"endpoints" json:"endpoints"`
+	// StartedAt and LastSeenAt - the first and the last gateway report that found the client connected
+	StartedAt     time.Time `gorm:"started_at;index" json:"started_at"`
+	LastSeenAt    time.Time `gorm:"last_seen_at;index" json:"last_seen_at"`
+	BytesSent     int64     `gorm:"bytes_sent" json:"bytes_sent"`
+	BytesReceived int64     `gorm:"bytes_received" json:"bytes_received"`
+	// LastTotalSent and LastTotalReceived - the gateway counters at the last update, used to add up the session traffic
+	LastTotalSent     int64 `gorm:"last_total_sent" json:"-"`
+	LastTotalReceived int64 `gorm:"last_total_received" json:"-"`
+	Active            bool  `gorm:"active;index" json:"active"`
+}
+
+func (s *ExtClientSession) Table() string {
+	return extClientSessionTable
+}
+
+func (s *ExtClientSession) Create(ctx context.Context) error {
+	return db.FromContext(ctx).Table(s.Table()).Create(&s).Error
+}
+
+func (s *ExtClientSession) Update(ctx context.Context) error {
+	return db.FromContext(ctx).Table(s.Table()).Where("id = ?", s.ID).Save(&s).Error
+}
+
+// GetActive - gets the open session of the client on its network
+func (s *ExtClientSession) GetActive(ctx context.Context) error {
+	return db.FromContext(ctx).Table(s.Table()).Where("client_id = ? AND network = ? AND active = ?",
+		s.ClientID, s.Network, true).First(&s).Error
+}
+
+// List - lists the sessions matching the set client, network, owner and gateway,
+// limited to the sessions seen connected after from and started before to when given
+func (s *ExtClientSession) List(ctx context.Context, from, to time.Time) (sessions []ExtClientSession, err error) {
+	query := db.FromContext(ctx).Table(s.Table())
+	if s.ClientID != "" {
+		query = query.Where("client_id = ?", s.ClientID)
+	}
+	if s.Network != "" {
+		query = query.Where("network = ?", s.Network)
+	}
+	if s.OwnerID != "" {
+		query = query.Where("owner_id = ?", s.OwnerID)
+	}
+	if s.GatewayID != "" {
+		query = query.Where("gateway_id = ?", s.GatewayID)
+	}
+	if !from.IsZero() {
+		query = query.Where("last_seen_at >= ?", from)
+	}
+	if !to.IsZero() {
+		query = query.Where("started_at <= ?", to)
+	}
+	err = query.Order("started_at DESC").Find(&sessions).Error
+	return
+}
+
+// CloseStale - ends the open sessions last seen connected before the given time
+func (s *ExtClientSession) CloseStale(ctx context.Context, before time.Time) error {
+	return db.FromContext(ctx).Table(s.Table()).Where("active = ? AND last_seen_at < ?", true, before).
+		Update("active", false).Error
+}
@@ -0,0 +1,58 @@
+package schema
+
+import (
+	"context"
+	"time"
+
+	"github.com/gravitl/netmaker/db"
+)
+
+const extClientUsageTable = "ext_client_usages"
+
+// ExtClientUsage - the traffic of an ext client on one day (UTC)
+type ExtClientUsage struct {
+	ID            string    `gorm:"primaryKey" json:"id"`
+	ClientID      string    `gorm:"client_id;index" json:"client_id"`
+	Network       string    `gorm:"network;index" json:"network"`
+	OwnerID       string    `gorm:"owner_id;index" json:"owner_id"`
+	Day           time.Time `gorm:"day;index" json:"day"`
+	BytesSent     int64     `gorm:"bytes_sent" json:"bytes_sent"`
+	BytesReceived int64     `gorm:"bytes_received" json:"bytes_received"`
+}
+
+func (u *ExtClientUsage) Table() string {
+	return extClientUsageTable
+}
+
+// Get - gets the usage of the client on its network for the day
+func (u *ExtClientUsage) Get(ctx context.Context) error {
+	return db.FromContext(ctx).Table(u.Table()).Where("client_id = ? AND network = ? AND day = ?",
+		u.ClientID, u.Network, u.Day).First(&u).Error
+}
+
+func (u *ExtClientUsage) Create(ctx context.Context) error {
+	return db.FromContext(ctx).Table(u.Table()).Create(&u).Error
+}
+
+func (u *ExtClientUsage) Update(ctx context.Context) error {
+	return db.FromContext(ctx).Table(u.Table()).Where("id = ?", u.ID).Save(&u).Error
+}
+
+// Total - the bytes sent and received since the given day by the set client and network, or by the set owner
+func (u *ExtClientUsage) Total(ctx context.Context, since time.Time) (total int64, err error) {
+	query := db.FromContext(ctx).Table(u.Table()).Where("day >= ?", since)
+	if u.ClientID != "" {
+		query = query.Where("client_id = ? AND network = ?", u.ClientID, u.Network)
+	}
+	if u.OwnerID != "" {
+		query = query.Where("owner_id = ?", u.OwnerID)
+	}
+	err = query.Select("COALESCE(SUM(bytes_sent + bytes_received), 0)").Row().Scan(&total)
+	return
+}
+
+// DeleteByClient - deletes the usage records of the client on its network
+func (u *ExtClientUsage) DeleteByClient(ctx context.Context) error {
+	return db.FromContext(ctx).Table(u.Table()).Where("client_id = ? AND network = ?", u.ClientID, u.Network).
+		Delete(&ExtClientUsage{}).Error
+}
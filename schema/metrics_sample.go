@@ -0,0 +1,92 @@
+package schema
+
+import (
+	"context"
+	"time"
+
+	"github.com/gravitl/netmaker/db"
+)
+
+const metricsSampleTable = "metrics_samples"
+
+// resolutions of the metrics history, raw samples are downsampled to 5m and then to 1h buckets
+const (
+	MetricsResolutionRaw = "raw"
+	MetricsResolution5m  = "5m"
+	MetricsResolution1h  = "1h"
+)
+
+// MetricsSample - the metrics a node reported for a peer, either a raw sample or a downsampled bucket
+type MetricsSample struct {
+	ID         string    `gorm:"primaryKey" json:"id"`
+	Resolution string    `gorm:"resolution;index:idx_metrics_samples_lookup,priority:1" json:"resolution"`
+	Network    string    `gorm:"network;index" json:"network"`
+	NodeID     string    `gorm:"node_id;index:idx_metrics_samples_lookup,priority:2" json:"node_id"`
+	PeerID     string    `gorm:"peer_id;index" json:"peer_id"`
+	PeerName   string    `gorm:"peer_name" json:"peer_name"`
+	Timestamp  time.Time `gorm:"timestamp;index:idx_metrics_samples_lookup,priority:3" json:"timestamp"`
+	// Seconds - the time the sample covers, the bytes are transferred over it
+	Seconds       float64 `gorm:"seconds" json:"seconds"`
+	BytesReceived int64   `gorm:"bytes_received" json:"bytes_received"`
+	BytesSent     int64   `gorm:"bytes_sent" json:"bytes_sent"`
+	// Latency - the average latency in milliseconds of the connected samples
+	Latency float64 `gorm:"latency" json:"latency"`
+	// Samples and Connected - the raw samples in the bucket and how many of them found the peer connected
+	Samples   int `gorm:"samples" json:"samples"`
+	Connected int `gorm:"connected" json:"connected"`
+}
+
+func (s *MetricsSample) Table() string {
+	return metricsSampleTable
+}
+
+func (s *MetricsSample) Create(ctx context.Context) error {
+	return db.FromContext(ctx).Table(s.Table()).Create(&s).Error
+}
+
+// CreateBatch - creates the samples in batches
+func (s *MetricsSample) CreateBatch(ctx context.Context, samples []MetricsSample) error {
+	if len(samples) == 0 {
+		return nil
+	}
+	return db.FromContext(ctx).Table(s.Table()).CreateInBatches(samples, 500).Error
+}
+
+// List - lists the samples of the set resolution in the from-to window, narrowed down
+// to the set network, node and peer and to the given peers when any are given
+func (s *MetricsSample) List(ctx context.Context, from, to time.Time, peers ...string) (samples []MetricsSample, err error) {
+	query := db.FromContext(ctx).Table(s.Table()).Where("resolution = ? AND timestamp >= ? AND timestamp < ?",
+		s.Resolution, from, to)
+	if s.Network != "" {
+		query = query.Where("network = ?", s.Network)
+	}
+	if s.NodeID != "" {
+		query = query.Where("node_id = ?", s.NodeID)
+	}
+	if s.PeerID != "" {
+		query = query.Where("peer_id = ?", s.PeerID)
+	}
+	if len(peers) > 0 {
+		query = query.Where("peer_id IN ?", peers)
+	}
+	err = query.Order("timestamp").Find(&samples).Error
+	return
+}
+
+// Latest - gets the most recent sample of the set resolution
+func (s *MetricsSample) Latest(ctx context.Context) error {
+	return db.FromContext(ctx).Table(s.Table()).Where("resolution = ?", s.Resolution).
+		Order("timestamp DESC").First(&s).Error
+}
+
+// Earliest - gets the oldest sample of the set resolution
+func (s *MetricsSample) Earliest(ctx context.Context) error {
+	return db.FromContext(ctx).Table(s.Table()).Where("resolution = ?", s.Resolution).
+		Order("timestamp").First(&s).Error
+}
+
+// DeleteOlderThan - deletes the samples of the set resolution from before the given time
+func (s *MetricsSample) DeleteOlderThan(ctx context.Context, before time.Time) error {
+	return db.FromContext(ctx).Table(s.Table()).Where("resolution = ? AND timestamp < ?", s.Resolution, before).
+		Delete(&MetricsSample{}).Error
+}
@@ -7,5 +7,13 @@ func ListModels() []interface{} {
 		&Egress{},
 		&UserAccessToken{},
 		&Event{},
+		&EnrollmentKeyUsage{},
+		&PresharedKey{},
+		&ExtClientSession{},
+		&ExtClientConfigLink{},
+		&ExtClientUsage{},
+		&DNSSyncConfig{},
+		&DNSFilterPolicy{},
+		&MetricsSample{},
 	}
 }
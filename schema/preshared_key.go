@@ -0,0 +1,47 @@
+package schema
+
+import (
+	"context"
+	"time"
+
+	"github.com/gravitl/netmaker/db"
+)
+
+const presharedKeyTable = "preshared_keys"
+
+// PresharedKey - the wireguard pre-shared key used between a pair of peers
+type PresharedKey struct {
+	ID        string    `gorm:"primaryKey" json:"id"`
+	PeerA     string    `gorm:"peer_a;index" json:"peer_a"`
+	PeerB     string    `gorm:"peer_b;index" json:"peer_b"`
+	Key       string    `gorm:"key" json:"-"`
+	CreatedAt time.Time `gorm:"created_at" json:"created_at"`
+}
+
+func (k *PresharedKey) Table() string {
+	return presharedKeyTable
+}
+
+func (k *PresharedKey) Get(ctx context.Context) error {
+	return db.FromContext(ctx).Table(k.Table()).Where("id = ?", k.ID).First(&k).Error
+}
+
+func (k *PresharedKey) Create(ctx context.Context) error {
+	return db.FromContext(ctx).Table(k.Table()).Create(&k).Error
+}
+
+func (k *PresharedKey) Update(ctx context.Context) error {
+	return db.FromContext(ctx).Table(k.Table()).Where("id = ?", k.ID).Updates(map[string]any{
+		"key":        k.Key,
+		"created_at": k.CreatedAt,
+	}).Error
+}
+
+func (k *PresharedKey) ListAll(ctx context.Context) (keys []PresharedKey, err error) {
+	err = db.FromContext(ctx).Table(k.Table()).Find(&keys).Error
+	return
+}
+
+func (k *PresharedKey) DeleteByPeer(ctx context.Context, peer string) error {
+	return db.FromContext(ctx).Table(k.Table()).Where("peer_a = ? OR peer_b = ?", peer, peer).Delete(&PresharedKey{}).Error
+}
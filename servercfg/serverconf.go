@@ -464,6 +464,51 @@ func IsDNSMode() bool {
 	return isdns
 }
 
+// IsEmbeddedDNS - should the server answer DNS itself instead of writing the hosts file for CoreDNS
+func IsEmbeddedDNS() bool {
+	if os.Getenv("EMBEDDED_DNS") != "" {
+		return os.Getenv("EMBEDDED_DNS") == "true"
+	}
+	return config.Config.Server.EmbeddedDNS
+}
+
+// GetEmbeddedDNSAddr - gets the address the embedded DNS server listens on
+func GetEmbeddedDNSAddr() string {
+	addr := ":53"
+	if os.Getenv("EMBEDDED_DNS_ADDR") != "" {
+		addr = os.Getenv("EMBEDDED_DNS_ADDR")
+	} else if config.Config.Server.EmbeddedDNSAddr != "" {
+		addr = config.Config.Server.EmbeddedDNSAddr
+	}
+	return addr
+}
+
+// GetDNSUpstreams - gets the resolvers the server's DNS forwards other names to
+func GetDNSUpstreams() []string {
+	upstreams := "8.8.8.8,8.8.4.4"
+	if os.Getenv("DNS_UPSTREAMS") != "" {
+		upstreams = os.Getenv("DNS_UPSTREAMS")
+	} else if config.Config.Server.DNSUpstreams != "" {
+		upstreams = config.Config.Server.DNSUpstreams
+	}
+	var servers []string
+	for _, server := range strings.Split(upstreams, ",") {
+		if server = strings.TrimSpace(server); server != "" {
+			servers = append(servers, server)
+		}
+	}
+	return servers
+}
+
+// GetDNSForwardRules - gets the server wide conditional forwarding rules,
+// written as domain=nameserver;nameserver and separated by commas
+func GetDNSForwardRules() string {
+	if os.Getenv("DNS_FORWARD_RULES") != "" {
+		return os.Getenv("DNS_FORWARD_RULES")
+	}
+	return config.Config.Server.DNSForwardRules
+}
+
 // IsDisplayKeys - should server be able to display keys?
 func IsDisplayKeys() bool {
 	isdisplay := true
@@ -876,6 +921,81 @@ func GetNmBaseDomain() string {
 	return os.Getenv("NM_DOMAIN")
 }
 
+// GetAttestationCertsDir - gets the directory holding the certificates used to verify
+// cloud instance identity documents, one sub directory per provider
+func GetAttestationCertsDir() string {
+	if dir := os.Getenv("ATTESTATION_CERTS_DIR"); dir != "" {
+		return dir
+	}
+	return config.Config.Server.AttestationCertsDir
+}
+
+// GetEphemeralHostTimeout - gets how long an ephemeral host may stay offline before it is removed, default 5 minutes
+func GetEphemeralHostTimeout() time.Duration {
+	timeout := config.Config.Server.EphemeralHostTimeout
+	if os.Getenv("EPHEMERAL_HOST_TIMEOUT") != "" {
+		if t, err := strconv.Atoi(os.Getenv("EPHEMERAL_HOST_TIMEOUT")); err == nil {
+			timeout = t
+		}
+	}
+	if timeout <= 0 {
+		timeout = 5
+	}
+	return time.Duration(timeout) * time.Minute
+}
+
+// GetPresharedKeyRotationInterval - gets how often pre-shared keys between hosts are rotated, default 7 days
+func GetPresharedKeyRotationInterval() time.Duration {
+	hours := config.Config.Server.PresharedKeyRotation
+	if os.Getenv("PRESHARED_KEY_ROTATION") != "" {
+		if h, err := strconv.Atoi(os.Getenv("PRESHARED_KEY_ROTATION")); err == nil {
+			hours = h
+		}
+	}
+	if hours <= 0 {
+		hours = 168
+	}
+	return time.Duration(hours) * time.Hour
+}
+
+// GetGeoIPDBPath - gets the path of the MaxMind/DB-IP mmdb file used to locate endpoints
+func GetGeoIPDBPath() string {
+	if os.Getenv("GEOIP_DB_PATH") != "" {
+		return os.Getenv("GEOIP_DB_PATH")
+	}
+	return config.Config.Server.GeoIPDBPath
+}
+
+// GetGeoIPBlockedCountries - gets the ISO country codes VPN access is refused from
+func GetGeoIPBlockedCountries() []string {
+	countries := config.Config.Server.GeoIPBlockedCountries
+	if os.Getenv("GEOIP_BLOCKED_COUNTRIES") != "" {
+		countries = os.Getenv("GEOIP_BLOCKED_COUNTRIES")
+	}
+	blocked := []string{}
+	for _, country := range strings.Split(countries, ",") {
+		if country = strings.ToUpper(strings.TrimSpace(country)); country != "" {
+			blocked = append(blocked, country)
+		}
+	}
+	return blocked
+}
+
+// GetTrustedProxies - gets the ips and cidrs of the reverse proxies whose forwarding headers are trusted
+func GetTrustedProxies() []string {
+	proxies := config.Config.Server.TrustedProxies
+	if os.Getenv("TRUSTED_PROXIES") != "" {
+		proxies = os.Getenv("TRUSTED_PROXIES")
+	}
+	trusted := []string{}
+	for _, proxy := range strings.Split(proxies, ",") {
+		if proxy = strings.TrimSpace(proxy); proxy != "" {
+			trusted = append(trusted, proxy)
+		}
+	}
+	return trusted
+}
+
 func IsAutoCleanUpEnabled() bool {
 	return os.Getenv("AUTO_DELETE_OFFLINE_NODES") == "true"
 }